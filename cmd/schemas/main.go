@@ -0,0 +1,34 @@
+// Command schemas writes the JSON Schema of every broker message to a directory,
+// one file per message type and version.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"ride-hail/internal/contracts"
+)
+
+func main() {
+	out := flag.String("out", "docs/schemas", "directory to write the schemas to")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatalf("Failed to create %s: %v", *out, err)
+	}
+
+	for _, msg := range contracts.All() {
+		data, err := json.MarshalIndent(contracts.EnvelopeSchema(msg), "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal schema of %s: %v", msg.MessageType(), err)
+		}
+		path := filepath.Join(*out, contracts.SchemaName(msg))
+		if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			log.Fatalf("Failed to write %s: %v", path, err)
+		}
+		log.Printf("wrote %s", path)
+	}
+}
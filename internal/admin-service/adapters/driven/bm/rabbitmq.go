@@ -0,0 +1,240 @@
+package bm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"ride-hail/internal/admin-service/core/domain/dto"
+	"ride-hail/internal/admin-service/core/ports"
+	"ride-hail/internal/config"
+	"ride-hail/internal/confirm"
+	"ride-hail/internal/mylogger"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	reconnInterval = 10
+)
+
+type RabbitMQ struct {
+	ctx          context.Context
+	cfg          config.RabbitMqconfig
+	mylog        mylogger.Logger
+	conn         *amqp.Connection
+	ch           *amqp.Channel
+	pub          *confirm.Channel
+	reconnecting bool
+	mu           *sync.Mutex
+}
+
+var _ ports.IDeadLettersBroker = (*RabbitMQ)(nil)
+
+// create RabbitMQ adapter
+func New(ctx context.Context, rabbitmqCfg config.RabbitMqconfig, mylog mylogger.Logger) (*RabbitMQ, error) {
+	r := &RabbitMQ{
+		ctx:          ctx,
+		cfg:          rabbitmqCfg,
+		mylog:        mylog,
+		mu:           &sync.Mutex{},
+		reconnecting: false,
+	}
+	if err := r.connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to rabbitmq: %v", err)
+	}
+	return r, nil
+}
+
+// Consume reads the queue with manual acks, holding at most prefetch unacked
+// messages. The channel closes with the connection; call Consume again after
+// a reconnect.
+func (r *RabbitMQ) Consume(ctx context.Context, queue string, prefetch int) (<-chan amqp.Delivery, error) {
+	if !r.IsAlive() {
+		go r.reconnect(r.ctx)
+		return nil, confirm.ErrClosed
+	}
+	if err := r.ch.Qos(prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("qos: %w", err)
+	}
+	return r.ch.ConsumeWithContext(ctx, queue, "", false, false, false, false, nil)
+}
+
+// Replay publishes the letter straight to the queue it died in through the
+// default exchange, so other queues bound to its original exchange do not get
+// it twice. The broker's death headers are dropped; the message id is kept
+// for consumers that deduplicate.
+func (r *RabbitMQ) Replay(ctx context.Context, letter dto.DeadLetter) error {
+	mylog := r.mylog.Action("replay")
+
+	headers := amqp.Table{}
+	for k, v := range letter.Headers {
+		switch k {
+		case "x-death", "x-first-death-queue", "x-first-death-reason", "x-first-death-exchange",
+			"x-last-death-queue", "x-last-death-reason", "x-last-death-exchange":
+			continue
+		}
+		headers[k] = tableValue(v)
+	}
+	headers["x-replayed-from"] = letter.ID
+
+	msg := amqp.Publishing{
+		Headers:      headers,
+		ContentType:  letter.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    letter.MessageID,
+		Timestamp:    time.Now(),
+		Body:         letter.Body,
+	}
+	if msg.MessageId == "" {
+		msg.MessageId = confirm.NewMessageID()
+	}
+
+	backoff := time.Duration(r.cfg.RetryBackoffMillis) * time.Millisecond
+	err := confirm.Retry(ctx, r.cfg.MaxRetries, backoff, func() error {
+		return r.publish(ctx, "", letter.Queue, msg)
+	})
+	if err != nil {
+		mylog.Error("failed to replay dead letter", err, "dead_letter_id", letter.ID, "queue", letter.Queue)
+		return err
+	}
+	return nil
+}
+
+func (r *RabbitMQ) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	if !r.IsAlive() {
+		go r.reconnect(r.ctx)
+		return confirm.ErrClosed
+	}
+	err := r.pub.Publish(ctx, exchange, routingKey, msg)
+	if errors.Is(err, confirm.ErrClosed) {
+		go r.reconnect(r.ctx)
+	}
+	return err
+}
+
+// tableValue turns a header decoded from JSON back into an AMQP field value;
+// whole numbers become integers again
+func tableValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		t := amqp.Table{}
+		for k, item := range v {
+			t[k] = tableValue(item)
+		}
+		return t
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = tableValue(item)
+		}
+		return out
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func (r *RabbitMQ) IsAlive() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check if both connection and channel are initialized and not closed
+	if r.conn == nil || r.conn.IsClosed() {
+		return false
+	}
+	if r.ch == nil || r.ch.IsClosed() {
+		return false
+	}
+
+	return true
+}
+
+func (r *RabbitMQ) Close() error {
+	if r.ch != nil && !r.ch.IsClosed() {
+		if err := r.ch.Close(); err != nil {
+			return fmt.Errorf("close rabbitmq channel: %v", err)
+		}
+	}
+
+	if r.conn != nil && !r.conn.IsClosed() {
+		if err := r.conn.Close(); err != nil {
+			return fmt.Errorf("close rabbitmq connection: %v", err)
+		}
+	}
+	return nil
+}
+
+// connect to rabbitmq
+func (r *RabbitMQ) connect() error {
+	conn, err := amqp.Dial(fmt.Sprintf("amqp://%v:%v@%v:%v/%v",
+		r.cfg.User,
+		r.cfg.Password,
+		r.cfg.Host,
+		r.cfg.Port,
+		r.cfg.VHost,
+	))
+	if err != nil {
+		return err
+	}
+
+	// try channel
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	pub, err := confirm.NewChannel(ch, time.Duration(r.cfg.ConfirmTimeoutMillis)*time.Millisecond)
+	if err != nil {
+		_ = ch.Close()
+		_ = conn.Close()
+		return err
+	}
+
+	r.mu.Lock()
+	r.conn = conn
+	r.ch = ch
+	r.pub = pub
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *RabbitMQ) reconnect(ctx context.Context) {
+	r.mu.Lock()
+	if r.reconnecting {
+		r.mu.Unlock()
+		return
+	}
+	r.reconnecting = true
+	r.mu.Unlock()
+
+	t := time.NewTicker(time.Second * reconnInterval)
+	mylog := r.mylog.Action("mb_reconnecting")
+
+	for {
+		select {
+		case <-t.C:
+			if err := r.connect(); err == nil {
+				t.Stop()
+				mylog.Action("mb_reconnection_completed").Info("Successfully reconnected!")
+				r.mu.Lock()
+				r.reconnecting = false
+				r.mu.Unlock()
+				return
+			}
+			mylog.Info("rabbitmq failed to reconnect")
+
+		case <-ctx.Done():
+			t.Stop()
+			return
+		}
+	}
+}
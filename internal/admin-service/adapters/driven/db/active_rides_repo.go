@@ -24,7 +24,7 @@ func (ar *ActiveDrivesRepo) GetActiveRides(ctx context.Context, page, pageSize i
     `
 
 	totalCount := 0
-	err := ar.db.conn.QueryRow(ctx, countQuery).Scan(&totalCount)
+	err := ar.db.store.QueryRow(ctx, countQuery).Scan(&totalCount)
 	if err != nil {
 		// Check if the database is alive
 		if err2 := ar.db.IsAlive(); err2 != nil {
@@ -69,7 +69,7 @@ func (ar *ActiveDrivesRepo) GetActiveRides(ctx context.Context, page, pageSize i
     `
 
 	offset := (page - 1) * pageSize
-	rows, err := ar.db.conn.Query(ctx, query, pageSize, offset)
+	rows, err := ar.db.store.Query(ctx, query, pageSize, offset)
 	if err != nil {
 		return 0, nil, fmt.Errorf("failed to query active rides: %v", err)
 	}
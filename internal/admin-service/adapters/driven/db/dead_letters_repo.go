@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ride-hail/internal/admin-service/core/domain/dto"
+	"ride-hail/internal/admin-service/core/myerrors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DeadLettersRepo struct {
+	db *DB
+}
+
+func NewDeadLettersRepo(db *DB) *DeadLettersRepo {
+	return &DeadLettersRepo{db: db}
+}
+
+const deadLetterColumns = `
+	dead_letter_id,
+	COALESCE(message_id, ''),
+	queue,
+	exchange,
+	routing_key,
+	reason,
+	death_count,
+	headers,
+	COALESCE(content_type, ''),
+	body,
+	dead_at,
+	received_at,
+	replayed_at,
+	replay_count`
+
+// Save upserts by queue and message id, so a redelivered dead letter is not
+// stored twice and a replayed one that died again is pending once more
+func (dr *DeadLettersRepo) Save(ctx context.Context, letter dto.DeadLetter) error {
+	headers, err := json.Marshal(letter.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal headers: %w", err)
+	}
+
+	q := `
+	INSERT INTO dead_letters (
+		message_id, queue, exchange, routing_key, reason, death_count,
+		headers, content_type, body, dead_at
+	)
+	VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10)
+	ON CONFLICT (queue, message_id) DO UPDATE SET
+		exchange = EXCLUDED.exchange,
+		routing_key = EXCLUDED.routing_key,
+		reason = EXCLUDED.reason,
+		death_count = EXCLUDED.death_count,
+		headers = EXCLUDED.headers,
+		content_type = EXCLUDED.content_type,
+		body = EXCLUDED.body,
+		dead_at = EXCLUDED.dead_at,
+		received_at = NOW(),
+		replayed_at = NULL`
+
+	_, err = dr.db.store.Exec(ctx, q,
+		letter.MessageID,
+		letter.Queue,
+		letter.Exchange,
+		letter.RoutingKey,
+		letter.Reason,
+		letter.DeathCount,
+		headers,
+		letter.ContentType,
+		letter.Body,
+		letter.DeadAt,
+	)
+	if err != nil {
+		if err2 := dr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to save dead letter: %w", err)
+	}
+	return nil
+}
+
+func (dr *DeadLettersRepo) List(ctx context.Context, filter dto.DeadLetterFilter, page, pageSize int) (int, []dto.DeadLetter, error) {
+	var (
+		where = []string{"TRUE"}
+		args  = []interface{}{}
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.Queue != "" {
+		where = append(where, "queue = "+arg(filter.Queue))
+	}
+	if filter.Reason != "" {
+		where = append(where, "reason = "+arg(filter.Reason))
+	}
+	if filter.MessageID != "" {
+		where = append(where, "message_id = "+arg(filter.MessageID))
+	}
+	if filter.Replayed != nil {
+		if *filter.Replayed {
+			where = append(where, "replayed_at IS NOT NULL")
+		} else {
+			where = append(where, "replayed_at IS NULL")
+		}
+	}
+	if filter.From != nil {
+		where = append(where, "dead_at >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		where = append(where, "dead_at < "+arg(*filter.To))
+	}
+	cond := strings.Join(where, " AND ")
+
+	totalCount := 0
+	if err := dr.db.store.QueryRow(ctx, `SELECT COUNT(*) FROM dead_letters WHERE `+cond, args...).Scan(&totalCount); err != nil {
+		// Check if the database is alive
+		if err2 := dr.db.IsAlive(); err2 != nil {
+			return 0, nil, err2
+		}
+		return 0, nil, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+
+	q := `SELECT ` + deadLetterColumns + `
+	FROM dead_letters
+	WHERE ` + cond + `
+	ORDER BY dead_at DESC, dead_letter_id DESC
+	LIMIT ` + arg(pageSize) + ` OFFSET ` + arg((page-1)*pageSize)
+
+	rows, err := dr.db.store.Query(ctx, q, args...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to query dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	letters := []dto.DeadLetter{}
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		letters = append(letters, letter)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return totalCount, letters, nil
+}
+
+func (dr *DeadLettersRepo) Get(ctx context.Context, id string) (dto.DeadLetter, error) {
+	q := `SELECT ` + deadLetterColumns + ` FROM dead_letters WHERE dead_letter_id = $1`
+
+	letter, err := scanDeadLetter(dr.db.store.QueryRow(ctx, q, id))
+	if err != nil {
+		return dto.DeadLetter{}, dr.notFound(err)
+	}
+	return letter, nil
+}
+
+func (dr *DeadLettersRepo) MarkReplayed(ctx context.Context, id string) (dto.DeadLetter, error) {
+	q := `
+	UPDATE dead_letters
+	SET replayed_at = NOW(), replay_count = replay_count + 1
+	WHERE dead_letter_id = $1
+	RETURNING ` + deadLetterColumns
+
+	letter, err := scanDeadLetter(dr.db.store.QueryRow(ctx, q, id))
+	if err != nil {
+		return dto.DeadLetter{}, dr.notFound(err)
+	}
+	return letter, nil
+}
+
+func (dr *DeadLettersRepo) Delete(ctx context.Context, id string) error {
+	tag, err := dr.db.store.Exec(ctx, `DELETE FROM dead_letters WHERE dead_letter_id = $1`, id)
+	if err != nil {
+		return dr.notFound(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return myerrors.ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// notFound maps a missing row or a malformed id to ErrDeadLetterNotFound
+func (dr *DeadLettersRepo) notFound(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == "22P02") { // invalid_text_representation
+		return myerrors.ErrDeadLetterNotFound
+	}
+	if err2 := dr.db.IsAlive(); err2 != nil {
+		return err2
+	}
+	return fmt.Errorf("failed to query dead letter: %w", err)
+}
+
+func scanDeadLetter(row pgx.Row) (dto.DeadLetter, error) {
+	var (
+		letter  dto.DeadLetter
+		headers []byte
+	)
+	err := row.Scan(
+		&letter.ID,
+		&letter.MessageID,
+		&letter.Queue,
+		&letter.Exchange,
+		&letter.RoutingKey,
+		&letter.Reason,
+		&letter.DeathCount,
+		&headers,
+		&letter.ContentType,
+		&letter.Body,
+		&letter.DeadAt,
+		&letter.ReceivedAt,
+		&letter.ReplayedAt,
+		&letter.ReplayCount,
+	)
+	if err != nil {
+		return dto.DeadLetter{}, err
+	}
+	if err := json.Unmarshal(headers, &letter.Headers); err != nil {
+		return dto.DeadLetter{}, fmt.Errorf("failed to unmarshal headers: %w", err)
+	}
+	return letter, nil
+}
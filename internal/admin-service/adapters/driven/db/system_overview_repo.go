@@ -46,7 +46,7 @@ func (sr *SystemOverviewRepo) GetMetrics(ctx context.Context) (dto.MetricsParams
     `
 
 	// Execute queries
-	err := sr.db.conn.QueryRow(ctx, q1).Scan(
+	err := sr.db.store.QueryRow(ctx, q1).Scan(
 		&metrics.ActiveRides,
 		&metrics.TotalRidesToday,
 		&metrics.TotalRevenueToday,
@@ -63,7 +63,7 @@ func (sr *SystemOverviewRepo) GetMetrics(ctx context.Context) (dto.MetricsParams
 		return dto.MetricsParams{}, fmt.Errorf("failed to get ride metrics: %v", err)
 	}
 
-	err = sr.db.conn.QueryRow(ctx, q2).Scan(
+	err = sr.db.store.QueryRow(ctx, q2).Scan(
 		&metrics.AvailableDrivers,
 		&metrics.BusyDrivers,
 	)
@@ -89,7 +89,7 @@ func (sr *SystemOverviewRepo) GetDriverDistribution(ctx context.Context) (dto.Dr
     `
 
 	// Execute queries
-	err := sr.db.conn.QueryRow(ctx, q).Scan(
+	err := sr.db.store.QueryRow(ctx, q).Scan(
 		&driverDistribution.Economy,
 		&driverDistribution.Premium,
 		&driverDistribution.XL,
@@ -146,7 +146,7 @@ func (sr *SystemOverviewRepo) GetHotspots(ctx context.Context) ([]dto.HotspotsPa
 	LIMIT 10;
     `
 
-	rows, err := sr.db.conn.Query(ctx, q)
+	rows, err := sr.db.store.Query(ctx, q)
 	if err != nil {
 		// Check if the database is alive
 		if err2 := sr.db.IsAlive(); err2 != nil {
@@ -0,0 +1,153 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/internal/admin-service/core/domain/dto"
+	"ride-hail/internal/admin-service/core/service"
+	"ride-hail/internal/mylogger"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// every queue dead-letters to dlx with this routing key
+	deadLettersQueue = "dead_messages"
+	prefetch         = 20
+	// pause before consuming again after the broker or the database failed
+	retryInterval = 5 * time.Second
+	storeTimeout  = 10 * time.Second
+)
+
+type source interface {
+	Consume(ctx context.Context, queue string, prefetch int) (<-chan amqp.Delivery, error)
+}
+
+// DeadLettersConsumer stores every message of dead_messages. A message is
+// acked only once it is stored; dead_messages has no dead-letter exchange of
+// its own, so a message that cannot be stored is requeued, never dropped.
+type DeadLettersConsumer struct {
+	ctx                context.Context
+	mylog              mylogger.Logger
+	broker             source
+	deadLettersService *service.DeadLettersService
+}
+
+func NewDeadLettersConsumer(ctx context.Context, mylog mylogger.Logger, broker source, deadLettersService *service.DeadLettersService) *DeadLettersConsumer {
+	return &DeadLettersConsumer{
+		ctx:                ctx,
+		mylog:              mylog,
+		broker:             broker,
+		deadLettersService: deadLettersService,
+	}
+}
+
+// Run consumes until ctx is cancelled, subscribing again after a reconnect
+func (dc *DeadLettersConsumer) Run() {
+	mylog := dc.mylog.Action("dead_letters_consumer")
+	mylog.Info("dead letters consumer started", "queue", deadLettersQueue)
+
+	for {
+		deliveries, err := dc.broker.Consume(dc.ctx, deadLettersQueue, prefetch)
+		if err != nil {
+			mylog.Warn("cannot consume dead letters", "error", err.Error())
+		} else {
+			dc.consume(deliveries)
+		}
+
+		select {
+		case <-dc.ctx.Done():
+			mylog.Info("dead letters consumer stopped")
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (dc *DeadLettersConsumer) consume(deliveries <-chan amqp.Delivery) {
+	for {
+		select {
+		case <-dc.ctx.Done():
+			return
+		case msg, ok := <-deliveries:
+			if !ok {
+				return
+			}
+			dc.store(msg)
+		}
+	}
+}
+
+func (dc *DeadLettersConsumer) store(msg amqp.Delivery) {
+	mylog := dc.mylog.Action("store_dead_letter")
+
+	ctx, cancel := context.WithTimeout(dc.ctx, storeTimeout)
+	defer cancel()
+
+	if err := dc.deadLettersService.StoreDeadLetter(ctx, deadLetter(msg)); err != nil {
+		mylog.Error("cannot store dead letter, requeueing", err, "message_id", msg.MessageId)
+		// slows the redelivery loop down while the database is away
+		select {
+		case <-dc.ctx.Done():
+		case <-time.After(retryInterval):
+		}
+		msg.Nack(false, true)
+		return
+	}
+	msg.Ack(false)
+}
+
+// deadLetter reads where and why the message died from its latest x-death
+// entry; RabbitMQ keeps the most recent death first
+func deadLetter(msg amqp.Delivery) dto.DeadLetter {
+	letter := dto.DeadLetter{
+		MessageID:   msg.MessageId,
+		Queue:       msg.RoutingKey,
+		Exchange:    msg.Exchange,
+		RoutingKey:  msg.RoutingKey,
+		Reason:      "unknown",
+		DeathCount:  1,
+		Headers:     map[string]any(msg.Headers),
+		ContentType: msg.ContentType,
+		Body:        msg.Body,
+		DeadAt:      time.Now(),
+	}
+	if letter.Headers == nil {
+		letter.Headers = map[string]any{}
+	}
+	if letter.Body == nil {
+		letter.Body = []byte{}
+	}
+
+	deaths, _ := msg.Headers["x-death"].([]interface{})
+	if len(deaths) == 0 {
+		return letter
+	}
+	death, ok := deaths[0].(amqp.Table)
+	if !ok {
+		return letter
+	}
+
+	if v, ok := death["queue"].(string); ok {
+		letter.Queue = v
+	}
+	if v, ok := death["reason"].(string); ok {
+		letter.Reason = v
+	}
+	if v, ok := death["exchange"].(string); ok {
+		letter.Exchange = v
+	}
+	if keys, ok := death["routing-keys"].([]interface{}); ok && len(keys) > 0 {
+		if v, ok := keys[0].(string); ok {
+			letter.RoutingKey = v
+		}
+	}
+	if v, ok := death["count"].(int64); ok {
+		letter.DeathCount = v
+	}
+	if v, ok := death["time"].(time.Time); ok {
+		letter.DeadAt = v
+	}
+	return letter
+}
@@ -0,0 +1,147 @@
+package handle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ride-hail/internal/admin-service/core/domain/dto"
+	"ride-hail/internal/admin-service/core/myerrors"
+	"ride-hail/internal/admin-service/core/service"
+	"ride-hail/internal/mylogger"
+)
+
+type DeadLettersHandler struct {
+	deadLettersService *service.DeadLettersService
+	mylog              mylogger.Logger
+}
+
+func NewDeadLettersHandler(mylog mylogger.Logger, deadLettersService *service.DeadLettersService) *DeadLettersHandler {
+	return &DeadLettersHandler{
+		deadLettersService: deadLettersService,
+		mylog:              mylog,
+	}
+}
+
+// GetDeadLetters lists stored dead letters, newest first. Query parameters:
+// queue, reason, message_id, status (pending|replayed), from and to (RFC 3339),
+// page and page_size.
+func (dh *DeadLettersHandler) GetDeadLetters() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
+		defer cancel()
+
+		query := r.URL.Query()
+
+		page, err := intParam(query.Get("page"), 1)
+		if err != nil || page < 1 {
+			JsonError(w, http.StatusBadRequest, fmt.Errorf("Invalid page parameter"))
+			return
+		}
+		pageSize, err := intParam(query.Get("page_size"), 20)
+		if err != nil || pageSize < 1 || pageSize > 100 {
+			JsonError(w, http.StatusBadRequest, fmt.Errorf("Invalid page_size parameter"))
+			return
+		}
+
+		filter := dto.DeadLetterFilter{
+			Queue:     query.Get("queue"),
+			Reason:    query.Get("reason"),
+			MessageID: query.Get("message_id"),
+		}
+
+		switch query.Get("status") {
+		case "":
+		case "pending":
+			replayed := false
+			filter.Replayed = &replayed
+		case "replayed":
+			replayed := true
+			filter.Replayed = &replayed
+		default:
+			JsonError(w, http.StatusBadRequest, fmt.Errorf("Invalid status parameter, expected pending or replayed"))
+			return
+		}
+
+		if filter.From, err = timeParam(query.Get("from")); err != nil {
+			JsonError(w, http.StatusBadRequest, fmt.Errorf("Invalid from parameter, expected RFC 3339"))
+			return
+		}
+		if filter.To, err = timeParam(query.Get("to")); err != nil {
+			JsonError(w, http.StatusBadRequest, fmt.Errorf("Invalid to parameter, expected RFC 3339"))
+			return
+		}
+
+		deadLetters, err := dh.deadLettersService.GetDeadLetters(ctx, filter, page, pageSize)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, deadLetters)
+	}
+}
+
+// ReplayDeadLetter publishes the dead letter back to the queue it died in
+func (dh *DeadLettersHandler) ReplayDeadLetter() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
+		defer cancel()
+
+		replayed, err := dh.deadLettersService.ReplayDeadLetter(ctx, r.PathValue("id"))
+		if err != nil {
+			JsonError(w, deadLetterStatus(err), err)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, replayed)
+	}
+}
+
+func (dh *DeadLettersHandler) DeleteDeadLetter() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
+		defer cancel()
+
+		if err := dh.deadLettersService.DeleteDeadLetter(ctx, r.PathValue("id")); err != nil {
+			JsonError(w, deadLetterStatus(err), err)
+			return
+		}
+
+		jsonResponse(w, http.StatusNoContent, nil)
+	}
+}
+
+func deadLetterStatus(err error) int {
+	switch {
+	case errors.Is(err, myerrors.ErrDeadLetterNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, myerrors.ErrReplayUnroutable):
+		return http.StatusConflict
+	case errors.Is(err, myerrors.ErrBrokerUnavailable), errors.Is(err, myerrors.ErrDBConnClosedMsg):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func intParam(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
+
+func timeParam(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -4,19 +4,23 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"ride-hail/internal/admin-service/adapters/driver/myhttp/handle"
+	"ride-hail/internal/admin-service/core/ports"
 
 	"github.com/golang-jwt/jwt"
 )
 
 type AuthMiddleware struct {
-	accessSecret string
+	keyfunc     jwt.Keyfunc
+	revocations ports.IRevocationRepo
 }
 
-func NewAuthMiddleware(accessSecret string) *AuthMiddleware {
+func NewAuthMiddleware(keyfunc jwt.Keyfunc, revocations ports.IRevocationRepo) *AuthMiddleware {
 	return &AuthMiddleware{
-		accessSecret: accessSecret,
+		keyfunc:     keyfunc,
+		revocations: revocations,
 	}
 }
 
@@ -28,9 +32,7 @@ func (am *AuthMiddleware) Wrap(next http.Handler) http.Handler {
 			return
 		}
 		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-			return []byte(am.accessSecret), nil
-		})
+		token, err := jwt.Parse(tokenString, am.keyfunc)
 		if err != nil {
 			handle.JsonError(w, http.StatusBadRequest, fmt.Errorf("Failed to parse JWT-Token"))
 			return
@@ -59,6 +61,23 @@ func (am *AuthMiddleware) Wrap(next http.Handler) http.Handler {
 			return
 		}
 
+		jti, _ := claims["jti"].(string)
+		iat, _ := claims["iat"].(float64)
+		if jti == "" || iat == 0 {
+			handle.JsonError(w, http.StatusUnauthorized, fmt.Errorf("Token has no jti or iat"))
+			return
+		}
+
+		revoked, err := am.revocations.IsRevoked(r.Context(), jti, userId, time.UnixMilli(int64(iat*1000)))
+		if err != nil {
+			handle.JsonError(w, http.StatusInternalServerError, fmt.Errorf("Failed to check JWT-Token"))
+			return
+		}
+		if revoked {
+			handle.JsonError(w, http.StatusUnauthorized, fmt.Errorf("Token revoked"))
+			return
+		}
+
 		if role != "ADMIN" {
 			handle.JsonError(w, http.StatusBadRequest, fmt.Errorf("Only admins allowed to use this service"))
 			return
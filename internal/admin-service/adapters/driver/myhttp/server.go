@@ -9,12 +9,16 @@ import (
 	"sync"
 	"time"
 
+	"ride-hail/internal/admin-service/adapters/driven/bm"
 	"ride-hail/internal/admin-service/adapters/driven/db"
+	"ride-hail/internal/admin-service/adapters/driver/consumer"
 	"ride-hail/internal/admin-service/adapters/driver/myhttp/handle"
 	"ride-hail/internal/admin-service/adapters/driver/myhttp/middleware"
 	"ride-hail/internal/admin-service/core/service"
 	"ride-hail/internal/config"
+	"ride-hail/internal/jwks"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/tracing"
 )
 
 var ErrServerClosed = errors.New("Server closed")
@@ -27,6 +31,7 @@ type Server struct {
 	srv    *http.Server
 	mylog  mylogger.Logger
 	db     *db.DB
+	broker *bm.RabbitMQ
 	ctx    context.Context
 	appCtx context.Context
 	mu     sync.Mutex
@@ -53,8 +58,19 @@ func (s *Server) Run() error {
 	}
 	mylog.Action("db_connected").Info("Successful database connection")
 
+	// Initialize message broker connection
+	if err := s.initializeMessageBroker(); err != nil {
+		mylog.Action("rabbitmq_connection_failed").Error("Failed to connect to rabbitmq", err)
+		return err
+	}
+	mylog.Action("rabbitmq_connected").Info("Successful rabbitmq connection")
+
 	// Configure routes and handlers
-	s.Configure()
+	if err := s.Configure(); err != nil {
+		mylog.Action("configure_failed").Error("Failed to configure server", err)
+		return err
+	}
+
 	cert, err := tls.LoadX509KeyPair(s.cfg.App.CertPath, s.cfg.App.CertKeyPath)
 	if err != nil {
 		return fmt.Errorf("failed to load TLS cert/key: %w", err)
@@ -67,7 +83,7 @@ func (s *Server) Run() error {
 	s.mu.Lock()
 	s.srv = &http.Server{
 		Addr:      fmt.Sprintf(":%v", s.cfg.Srv.AdminServicePort),
-		Handler:   s.mux,
+		Handler:   tracing.Middleware(s.mux),
 		TLSConfig: tlsConfig,
 	}
 	s.mu.Unlock()
@@ -98,6 +114,14 @@ func (s *Server) Stop(ctx context.Context) error {
 		}
 	}
 
+	if s.broker != nil {
+		if err := s.broker.Close(); err != nil {
+			s.mylog.Action("rabbitmq_close_failed").Error("Failed to close rabbitmq", err)
+			return fmt.Errorf("rabbitmq close: %w", err)
+		}
+		s.mylog.Action("rabbitmq_closed").Info("RabbitMQ closed")
+	}
+
 	if s.db != nil {
 		if err := s.db.Close(); err != nil {
 			s.mylog.Action("db_close_failed").Error("Failed to close database", err)
@@ -131,22 +155,45 @@ func (s *Server) startHTTPServer() error {
 }
 
 // Configure sets up the HTTP handlers for various APIs including Market Data, Data Mode control, and Health checks.
-func (s *Server) Configure() {
+func (s *Server) Configure() error {
+	// Public keys of auth-service
+	keys, err := jwks.NewCache(s.cfg.Jwt, s.cfg.App.CertPath, s.mylog)
+	if err != nil {
+		return fmt.Errorf("failed to create key set cache: %w", err)
+	}
+
 	// Repositories and services
 	systemOverviewRepo := db.NewSystemOverviewRepo(s.db)
 	activeRidesRepo := db.NewActiveDrivesRepo(s.db)
+	revocationRepo := db.NewRevocationRepo(s.db)
+	deadLettersRepo := db.NewDeadLettersRepo(s.db)
 
 	systemOverviewService := service.NewSystemOverviewService(s.ctx, s.mylog, systemOverviewRepo)
 	activeRidesService := service.NewActiveDrivesService(s.ctx, s.mylog, activeRidesRepo)
+	deadLettersService := service.NewDeadLettersService(s.ctx, s.mylog, deadLettersRepo, s.broker)
 
 	systemOverviewHandler := handle.NewSystemOverviewHandler(s.mylog, systemOverviewService)
 	activeRidesHandler := handle.NewActiveDrivesHandler(s.mylog, activeRidesService)
+	deadLettersHandler := handle.NewDeadLettersHandler(s.mylog, deadLettersService)
+
+	// Dead letters are stored until the server stops
+	deadLettersConsumer := consumer.NewDeadLettersConsumer(s.ctx, s.mylog, s.broker, deadLettersService)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		deadLettersConsumer.Run()
+	}()
 
-	authMiddleware := middleware.NewAuthMiddleware(s.cfg.App.PublicJwtSecret)
+	authMiddleware := middleware.NewAuthMiddleware(keys.Keyfunc(), revocationRepo)
 
 	// Register routes
 	s.mux.Handle("GET /admin/overview", authMiddleware.Wrap(systemOverviewHandler.GetSystemOverview()))
 	s.mux.Handle("GET /admin/rides/active", authMiddleware.Wrap(activeRidesHandler.GetActiveRides()))
+	s.mux.Handle("GET /admin/dead-letters", authMiddleware.Wrap(deadLettersHandler.GetDeadLetters()))
+	s.mux.Handle("POST /admin/dead-letters/{id}/replay", authMiddleware.Wrap(deadLettersHandler.ReplayDeadLetter()))
+	s.mux.Handle("DELETE /admin/dead-letters/{id}", authMiddleware.Wrap(deadLettersHandler.DeleteDeadLetter()))
+
+	return nil
 }
 
 func (s *Server) initializeDatabase() error {
@@ -157,3 +204,12 @@ func (s *Server) initializeDatabase() error {
 	s.db = db
 	return nil
 }
+
+func (s *Server) initializeMessageBroker() error {
+	broker, err := bm.New(s.ctx, *s.cfg.RabbitMq, s.mylog)
+	if err != nil {
+		return fmt.Errorf("failed to connect to rabbitmq: %w", err)
+	}
+	s.broker = broker
+	return nil
+}
@@ -0,0 +1,68 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DeadLetter is a message RabbitMQ dead-lettered, as stored by admin-service
+type DeadLetter struct {
+	ID          string
+	MessageID   string
+	Queue       string
+	Exchange    string
+	RoutingKey  string
+	Reason      string
+	DeathCount  int64
+	Headers     map[string]any
+	ContentType string
+	Body        []byte
+	DeadAt      time.Time
+	ReceivedAt  time.Time
+	ReplayedAt  *time.Time
+	ReplayCount int
+}
+
+// DeadLetterFilter narrows GET /admin/dead-letters; zero fields match everything
+type DeadLetterFilter struct {
+	Queue     string
+	Reason    string
+	MessageID string
+	// Replayed selects replayed (true) or not yet replayed (false) letters
+	Replayed *bool
+	From     *time.Time
+	To       *time.Time
+}
+
+type DeadLetters struct {
+	DeadLetters []DeadLetterDto `json:"dead_letters"`
+	TotalCount  int             `json:"total_count"`
+	Page        int             `json:"page"`
+	PageSize    int             `json:"page_size"`
+}
+
+// DeadLetterDto carries a JSON body as is and any other body base64 encoded
+type DeadLetterDto struct {
+	ID          string          `json:"id"`
+	MessageID   string          `json:"message_id,omitempty"`
+	Queue       string          `json:"queue"`
+	Exchange    string          `json:"exchange"`
+	RoutingKey  string          `json:"routing_key"`
+	Reason      string          `json:"reason"`
+	DeathCount  int64           `json:"death_count"`
+	Headers     map[string]any  `json:"headers"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	BodyBase64  []byte          `json:"body_base64,omitempty"`
+	DeadAt      time.Time       `json:"dead_at"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ReplayedAt  *time.Time      `json:"replayed_at,omitempty"`
+	ReplayCount int             `json:"replay_count"`
+}
+
+type DeadLetterReplayed struct {
+	ID          string    `json:"id"`
+	Queue       string    `json:"queue"`
+	ReplayedAt  time.Time `json:"replayed_at"`
+	ReplayCount int       `json:"replay_count"`
+}
@@ -6,3 +6,11 @@ var (
 	ErrDBConnClosed    = errors.New("failed to connect to db")
 	ErrDBConnClosedMsg = errors.New("internal error, please try again later")
 )
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrBrokerUnavailable means a replay could not reach RabbitMQ; the dead letter is kept
+	ErrBrokerUnavailable = errors.New("message broker is unavailable, please try again later")
+	// ErrReplayUnroutable means the queue the letter died in no longer exists
+	ErrReplayUnroutable = errors.New("dead letter queue of origin no longer exists")
+)
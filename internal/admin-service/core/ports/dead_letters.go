@@ -0,0 +1,21 @@
+package ports
+
+import (
+	"context"
+
+	"ride-hail/internal/admin-service/core/domain/dto"
+)
+
+type IDeadLettersRepo interface {
+	// Save stores a dead letter; one that is already stored for the same queue is updated
+	Save(ctx context.Context, letter dto.DeadLetter) error
+	List(ctx context.Context, filter dto.DeadLetterFilter, page, pageSize int) (int, []dto.DeadLetter, error)
+	Get(ctx context.Context, id string) (dto.DeadLetter, error)
+	MarkReplayed(ctx context.Context, id string) (dto.DeadLetter, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type IDeadLettersBroker interface {
+	// Replay publishes the letter back to the queue it died in and waits for the broker's confirm
+	Replay(ctx context.Context, letter dto.DeadLetter) error
+}
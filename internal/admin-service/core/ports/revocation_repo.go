@@ -0,0 +1,10 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+type IRevocationRepo interface {
+	IsRevoked(ctx context.Context, jti, subjectId string, issuedAt time.Time) (bool, error)
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/admin-service/core/domain/dto"
+	"ride-hail/internal/admin-service/core/myerrors"
+	"ride-hail/internal/admin-service/core/ports"
+	"ride-hail/internal/confirm"
+	"ride-hail/internal/mylogger"
+)
+
+type DeadLettersService struct {
+	ctx             context.Context
+	mylog           mylogger.Logger
+	deadLettersRepo ports.IDeadLettersRepo
+	broker          ports.IDeadLettersBroker
+}
+
+func NewDeadLettersService(ctx context.Context, mylog mylogger.Logger, deadLettersRepo ports.IDeadLettersRepo, broker ports.IDeadLettersBroker) *DeadLettersService {
+	return &DeadLettersService{
+		ctx:             ctx,
+		mylog:           mylog,
+		deadLettersRepo: deadLettersRepo,
+		broker:          broker,
+	}
+}
+
+func (ds *DeadLettersService) StoreDeadLetter(ctx context.Context, letter dto.DeadLetter) error {
+	mylog := ds.mylog.Action("StoreDeadLetter")
+
+	if err := ds.deadLettersRepo.Save(ctx, letter); err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			mylog.Error("Failed to connect to connect to db", err)
+			return myerrors.ErrDBConnClosedMsg
+		}
+		return fmt.Errorf("Failed to store dead letter: %w", err)
+	}
+
+	mylog.Info("dead letter stored", "queue", letter.Queue, "reason", letter.Reason, "message_id", letter.MessageID)
+	return nil
+}
+
+func (ds *DeadLettersService) GetDeadLetters(ctx context.Context, filter dto.DeadLetterFilter, page, pageSize int) (dto.DeadLetters, error) {
+	mylog := ds.mylog.Action("GetDeadLetters")
+
+	totalCount, letters, err := ds.deadLettersRepo.List(ctx, filter, page, pageSize)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			mylog.Error("Failed to connect to connect to db", err)
+			return dto.DeadLetters{}, myerrors.ErrDBConnClosedMsg
+		}
+		return dto.DeadLetters{}, fmt.Errorf("Failed to get dead letters: %v", err)
+	}
+
+	res := dto.DeadLetters{
+		DeadLetters: make([]dto.DeadLetterDto, 0, len(letters)),
+		TotalCount:  totalCount,
+		Page:        page,
+		PageSize:    pageSize,
+	}
+	for _, letter := range letters {
+		res.DeadLetters = append(res.DeadLetters, toDeadLetterDto(letter))
+	}
+	return res, nil
+}
+
+// ReplayDeadLetter publishes the letter back to its queue and only then marks
+// it replayed; a letter whose replay failed stays pending
+func (ds *DeadLettersService) ReplayDeadLetter(ctx context.Context, id string) (dto.DeadLetterReplayed, error) {
+	mylog := ds.mylog.Action("ReplayDeadLetter")
+
+	letter, err := ds.deadLettersRepo.Get(ctx, id)
+	if err != nil {
+		return dto.DeadLetterReplayed{}, ds.repoError(mylog, err)
+	}
+
+	if err := ds.broker.Replay(ctx, letter); err != nil {
+		if errors.Is(err, confirm.ErrUnroutable) {
+			return dto.DeadLetterReplayed{}, myerrors.ErrReplayUnroutable
+		}
+		mylog.Error("Failed to replay dead letter", err, "dead_letter_id", id)
+		return dto.DeadLetterReplayed{}, myerrors.ErrBrokerUnavailable
+	}
+
+	letter, err = ds.deadLettersRepo.MarkReplayed(ctx, id)
+	if err != nil {
+		// the message is back in its queue; a second replay would publish it again
+		mylog.Error("Dead letter replayed but not marked", err, "dead_letter_id", id)
+		return dto.DeadLetterReplayed{}, ds.repoError(mylog, err)
+	}
+	mylog.Info("dead letter replayed", "dead_letter_id", id, "queue", letter.Queue, "replay_count", letter.ReplayCount)
+
+	res := dto.DeadLetterReplayed{
+		ID:          letter.ID,
+		Queue:       letter.Queue,
+		ReplayCount: letter.ReplayCount,
+	}
+	if letter.ReplayedAt != nil {
+		res.ReplayedAt = *letter.ReplayedAt
+	} else {
+		res.ReplayedAt = time.Now()
+	}
+	return res, nil
+}
+
+func (ds *DeadLettersService) DeleteDeadLetter(ctx context.Context, id string) error {
+	mylog := ds.mylog.Action("DeleteDeadLetter")
+
+	if err := ds.deadLettersRepo.Delete(ctx, id); err != nil {
+		return ds.repoError(mylog, err)
+	}
+	mylog.Info("dead letter deleted", "dead_letter_id", id)
+	return nil
+}
+
+func (ds *DeadLettersService) repoError(mylog mylogger.Logger, err error) error {
+	switch {
+	case errors.Is(err, myerrors.ErrDeadLetterNotFound):
+		return err
+	case errors.Is(err, myerrors.ErrDBConnClosed):
+		mylog.Error("Failed to connect to connect to db", err)
+		return myerrors.ErrDBConnClosedMsg
+	default:
+		return fmt.Errorf("Failed to access dead letter: %v", err)
+	}
+}
+
+func toDeadLetterDto(letter dto.DeadLetter) dto.DeadLetterDto {
+	res := dto.DeadLetterDto{
+		ID:          letter.ID,
+		MessageID:   letter.MessageID,
+		Queue:       letter.Queue,
+		Exchange:    letter.Exchange,
+		RoutingKey:  letter.RoutingKey,
+		Reason:      letter.Reason,
+		DeathCount:  letter.DeathCount,
+		Headers:     letter.Headers,
+		ContentType: letter.ContentType,
+		DeadAt:      letter.DeadAt,
+		ReceivedAt:  letter.ReceivedAt,
+		ReplayedAt:  letter.ReplayedAt,
+		ReplayCount: letter.ReplayCount,
+	}
+	if json.Valid(letter.Body) {
+		res.Body = letter.Body
+	} else {
+		res.BodyBase64 = letter.Body
+	}
+	return res
+}
@@ -10,12 +10,24 @@ import (
 	"ride-hail/internal/admin-service/adapters/driver/myhttp"
 	"ride-hail/internal/config"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/tracing"
 )
 
 func Execute(ctx context.Context, mylog mylogger.Logger, cfg *config.Config) error {
 	newCtx, close := signal.NotifyContext(ctx, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
 	defer close()
 
+	shutdownTracing, err := tracing.Init("admin-service", cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	// after the server, so the spans of the last requests are flushed
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			mylog.Action("tracing_flush_failed").Error("Failed to flush traces", err)
+		}
+	}()
+
 	server := myhttp.NewServer(newCtx, ctx, mylog, cfg)
 
 	// Run server in goroutine
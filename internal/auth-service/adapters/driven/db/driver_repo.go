@@ -26,7 +26,7 @@ func NewDriverRepo(ctx context.Context, db *DB) *DriverRepo {
 
 func (dr *DriverRepo) Create(ctx context.Context, driver models.Driver) (string, error) {
 	// Start a new transaction
-	tx, err := dr.db.conn.Begin(ctx)
+	tx, err := dr.db.store.Begin(ctx)
 	if err != nil {
 		// Check if the database is alive
 		if err2 := dr.db.IsAlive(); err2 != nil {
@@ -117,7 +117,7 @@ func (dr *DriverRepo) GetByEmail(ctx context.Context, email string) (models.Driv
 	`
 
 	var d models.Driver
-	err := dr.db.conn.QueryRow(ctx, q, email).Scan(
+	err := dr.db.store.QueryRow(ctx, q, email).Scan(
 		&d.DriverId,
 		&d.CreatedAt,
 		&d.UpdatedAt,
@@ -147,3 +147,17 @@ func (dr *DriverRepo) GetByEmail(ctx context.Context, email string) (models.Driv
 
 	return d, nil
 }
+
+func (dr *DriverRepo) UpdatePassword(ctx context.Context, id, passwordHash string) error {
+	q := `UPDATE drivers SET password = $2, updated_at = NOW() WHERE driver_id = $1`
+
+	if _, err := dr.db.store.Exec(ctx, q, id, passwordHash); err != nil {
+		// Check if the database is alive
+		if err2 := dr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
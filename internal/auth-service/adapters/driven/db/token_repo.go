@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/auth-service/core/domain/models"
+	"ride-hail/internal/auth-service/core/myerrors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type TokenRepo struct {
+	ctx context.Context
+	db  *DB
+}
+
+func NewTokenRepo(ctx context.Context, db *DB) *TokenRepo {
+	return &TokenRepo{
+		ctx: ctx,
+		db:  db,
+	}
+}
+
+func (tr *TokenRepo) CreateRefreshToken(ctx context.Context, token models.RefreshToken) (string, error) {
+	q := `INSERT INTO refresh_tokens (
+		family_id, subject_id, role, token_hash, expires_at, claims
+	) VALUES ($1, $2, $3, $4, $5, $6) RETURNING refresh_token_id;`
+
+	id := ""
+	err := tr.db.store.QueryRow(ctx, q,
+		token.FamilyId,
+		token.SubjectId,
+		token.Role,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.Claims,
+	).Scan(&id)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := tr.db.IsAlive(); err2 != nil {
+			return "", err2
+		}
+		return "", fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+
+	return id, nil
+}
+
+func (tr *TokenRepo) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	q := `
+		SELECT
+			refresh_token_id,
+			created_at,
+			family_id,
+			subject_id,
+			role,
+			token_hash,
+			expires_at,
+			revoked_at,
+			replaced_by,
+			claims
+		FROM
+			refresh_tokens
+		WHERE
+			token_hash = $1
+	`
+
+	var t models.RefreshToken
+	err := tr.db.store.QueryRow(ctx, q, tokenHash).Scan(
+		&t.RefreshTokenId,
+		&t.CreatedAt,
+		&t.FamilyId,
+		&t.SubjectId,
+		&t.Role,
+		&t.TokenHash,
+		&t.ExpiresAt,
+		&t.RevokedAt,
+		&t.ReplacedBy,
+		&t.Claims,
+	)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := tr.db.IsAlive(); err2 != nil {
+			return models.RefreshToken{}, err2
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RefreshToken{}, myerrors.ErrInvalidRefreshToken
+		}
+		return models.RefreshToken{}, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return t, nil
+}
+
+// RotateRefreshToken replaces the old token with the next one of the same family.
+// Concurrent rotations of the same token are detected by the revoked_at guard.
+func (tr *TokenRepo) RotateRefreshToken(ctx context.Context, oldId string, next models.RefreshToken) (string, error) {
+	id := ""
+	err := tr.db.store.WithTx(ctx, func(tx pgx.Tx) error {
+		q := `INSERT INTO refresh_tokens (
+			family_id, subject_id, role, token_hash, expires_at, claims
+		) VALUES ($1, $2, $3, $4, $5, $6) RETURNING refresh_token_id;`
+
+		err := tx.QueryRow(ctx, q,
+			next.FamilyId,
+			next.SubjectId,
+			next.Role,
+			next.TokenHash,
+			next.ExpiresAt,
+			next.Claims,
+		).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to insert refresh token: %w", err)
+		}
+
+		q = `UPDATE refresh_tokens
+			SET revoked_at = NOW(), replaced_by = $2
+			WHERE refresh_token_id = $1 AND revoked_at IS NULL;`
+
+		tag, err := tx.Exec(ctx, q, oldId, id)
+		if err != nil {
+			return fmt.Errorf("failed to revoke refresh token: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return myerrors.ErrRefreshTokenRevoked
+		}
+		return nil
+	})
+	if err != nil {
+		// Check if the database is alive
+		if err2 := tr.db.IsAlive(); err2 != nil {
+			return "", err2
+		}
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (tr *TokenRepo) RevokeFamily(ctx context.Context, familyId string) error {
+	q := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+
+	if _, err := tr.db.store.Exec(ctx, q, familyId); err != nil {
+		// Check if the database is alive
+		if err2 := tr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
+
+func (tr *TokenRepo) RevokeAccessToken(ctx context.Context, jti, subjectId string, expiresAt time.Time) error {
+	q := `INSERT INTO revoked_tokens (jti, subject_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING`
+
+	if _, err := tr.db.store.Exec(ctx, q, jti, subjectId, expiresAt); err != nil {
+		// Check if the database is alive
+		if err2 := tr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeSubject rejects every token issued to the subject up to now
+func (tr *TokenRepo) RevokeSubject(ctx context.Context, subjectId, reason string) error {
+	err := tr.db.store.WithTx(ctx, func(tx pgx.Tx) error {
+		q := `INSERT INTO revoked_subjects (subject_id, revoked_before, reason)
+			VALUES ($1, NOW(), $2)
+			ON CONFLICT (subject_id) DO UPDATE
+				SET revoked_before = EXCLUDED.revoked_before, reason = EXCLUDED.reason`
+
+		if _, err := tx.Exec(ctx, q, subjectId, reason); err != nil {
+			return fmt.Errorf("failed to revoke subject: %w", err)
+		}
+
+		q = `UPDATE refresh_tokens SET revoked_at = NOW() WHERE subject_id = $1 AND revoked_at IS NULL`
+
+		if _, err := tx.Exec(ctx, q, subjectId); err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		// Check if the database is alive
+		if err2 := tr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (tr *TokenRepo) IsRevoked(ctx context.Context, jti, subjectId string, issuedAt time.Time) (bool, error) {
+	q := `
+		SELECT
+			EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+			OR EXISTS (SELECT 1 FROM revoked_subjects WHERE subject_id = $2 AND revoked_before > $3)
+	`
+
+	revoked := false
+	if err := tr.db.store.QueryRow(ctx, q, jti, subjectId, issuedAt).Scan(&revoked); err != nil {
+		// Check if the database is alive
+		if err2 := tr.db.IsAlive(); err2 != nil {
+			return false, err2
+		}
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	return revoked, nil
+}
@@ -26,7 +26,7 @@ func NewUserRepo(ctx context.Context, db *DB) *UserRepo {
 
 func (ur *UserRepo) Create(ctx context.Context, user models.User) (string, error) {
 	// Start a new transaction
-	tx, err := ur.db.conn.Begin(ctx)
+	tx, err := ur.db.store.Begin(ctx)
 	if err != nil {
 		// Check if the database is alive
 		if err2 := ur.db.IsAlive(); err2 != nil {
@@ -84,7 +84,7 @@ func (ur *UserRepo) GetByEmail(ctx context.Context, name string) (models.User, e
 	`
 
 	var u models.User
-	err := ur.db.conn.QueryRow(ctx, q, name).Scan(
+	err := ur.db.store.QueryRow(ctx, q, name).Scan(
 		&u.UserId,
 		&u.CreatedAt,
 		&u.UpdatedAt,
@@ -108,3 +108,17 @@ func (ur *UserRepo) GetByEmail(ctx context.Context, name string) (models.User, e
 
 	return u, nil
 }
+
+func (ur *UserRepo) UpdatePassword(ctx context.Context, id, passwordHash string) error {
+	q := `UPDATE users SET password = $2, updated_at = NOW() WHERE user_id = $1`
+
+	if _, err := ur.db.store.Exec(ctx, q, id, passwordHash); err != nil {
+		// Check if the database is alive
+		if err2 := ur.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
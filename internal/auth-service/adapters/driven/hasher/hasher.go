@@ -0,0 +1,172 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"ride-hail/internal/auth-service/core/myerrors"
+	"ride-hail/internal/auth-service/core/ports/driven"
+	"ride-hail/internal/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+
+	argon2Prefix = "$argon2id$"
+)
+
+type Hasher struct {
+	algorithm      string
+	bcryptCost     int
+	argon2Time     uint32
+	argon2MemoryKB uint32
+	argon2Threads  uint8
+}
+
+var _ driven.IPasswordHasher = (*Hasher)(nil)
+
+// New creates a password hasher from config, falling back to safe defaults for invalid values
+func New(cfg *config.Passwordconfig) (*Hasher, error) {
+	h := &Hasher{
+		algorithm:      strings.ToLower(cfg.Algorithm),
+		bcryptCost:     cfg.BcryptCost,
+		argon2Time:     uint32(cfg.Argon2Time),
+		argon2MemoryKB: uint32(cfg.Argon2MemoryKB),
+		argon2Threads:  uint8(cfg.Argon2Threads),
+	}
+
+	if h.algorithm != AlgorithmBcrypt && h.algorithm != AlgorithmArgon2id {
+		return nil, fmt.Errorf("%w: %s", myerrors.ErrUnknownHashAlgorithm, cfg.Algorithm)
+	}
+	if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+		h.bcryptCost = bcrypt.DefaultCost
+	}
+	if h.argon2Time == 0 {
+		h.argon2Time = 1
+	}
+	if h.argon2MemoryKB == 0 {
+		h.argon2MemoryKB = 64 * 1024
+	}
+	if h.argon2Threads == 0 {
+		h.argon2Threads = 4
+	}
+
+	return h, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	switch h.algorithm {
+	case AlgorithmArgon2id:
+		return h.hashArgon2(password)
+	default:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("bcrypt hash: %w", err)
+		}
+		return string(hash), nil
+	}
+}
+
+func (h *Hasher) Compare(hash, password string) (bool, bool, error) {
+	switch {
+	case isBcrypt(hash):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if err == bcrypt.ErrMismatchedHashAndPassword {
+				return false, false, nil
+			}
+			return false, false, fmt.Errorf("%w: %v", myerrors.ErrMalformedHash, err)
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return true, true, nil
+		}
+		return true, h.algorithm != AlgorithmBcrypt || cost < h.bcryptCost, nil
+
+	case strings.HasPrefix(hash, argon2Prefix):
+		p, err := decodeArgon2(hash)
+		if err != nil {
+			return false, false, err
+		}
+		key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+		if subtle.ConstantTimeCompare(key, p.key) != 1 {
+			return false, false, nil
+		}
+		weaker := p.time < h.argon2Time || p.memory < h.argon2MemoryKB || p.threads < h.argon2Threads
+		return true, h.algorithm != AlgorithmArgon2id || weaker, nil
+
+	default:
+		// Legacy rows were stored in plaintext, they always need to be upgraded
+		ok := subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1
+		return ok, ok, nil
+	}
+}
+
+func (h *Hasher) hashArgon2(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.argon2Time, h.argon2MemoryKB, h.argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix,
+		argon2.Version,
+		h.argon2MemoryKB,
+		h.argon2Time,
+		h.argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// decodeArgon2 parses PHC string format: $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+func decodeArgon2(hash string) (argon2Params, error) {
+	var p argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, myerrors.ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, myerrors.ErrMalformedHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return p, myerrors.ErrMalformedHash
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, myerrors.ErrMalformedHash
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(p.key) == 0 {
+		return p, myerrors.ErrMalformedHash
+	}
+
+	return p, nil
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
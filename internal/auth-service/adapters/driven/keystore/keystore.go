@@ -0,0 +1,235 @@
+package keystore
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ride-hail/internal/auth-service/core/myerrors"
+	"ride-hail/internal/auth-service/core/ports/driven"
+	"ride-hail/internal/config"
+	"ride-hail/internal/jwks"
+	"ride-hail/internal/mylogger"
+
+	"github.com/golang-jwt/jwt"
+)
+
+type signingKey struct {
+	kid     string
+	private crypto.Signer
+	method  jwt.SigningMethod
+	modTime time.Time
+}
+
+// KeyStore loads <kid>.pem private keys from a directory.
+// Rotation: drop a new key in the directory, it becomes active on the next
+// reload (unless JWT_ACTIVE_KID pins another one), and remove the old file
+// once tokens signed by it have expired.
+type KeyStore struct {
+	cfg   *config.JWTconfig
+	mylog mylogger.Logger
+
+	mu     sync.RWMutex
+	keys   map[string]signingKey
+	active string
+}
+
+var _ driven.ITokenSigner = (*KeyStore)(nil)
+
+func New(cfg *config.JWTconfig, mylog mylogger.Logger) (*KeyStore, error) {
+	ks := &KeyStore{
+		cfg:   cfg,
+		mylog: mylog,
+	}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Watch reloads the key directory periodically until ctx is done
+func (ks *KeyStore) Watch(ctx context.Context) {
+	if ks.cfg.ReloadSeconds <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(ks.cfg.ReloadSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Reload(); err != nil {
+				ks.mylog.Action("keys_reload_failed").Error("Failed to reload signing keys, keeping the previous set", err)
+			}
+		}
+	}
+}
+
+// Reload reads every key from disk and picks the active one
+func (ks *KeyStore) Reload() error {
+	paths, err := filepath.Glob(filepath.Join(ks.cfg.KeysDir, "*.pem"))
+	if err != nil {
+		return fmt.Errorf("list keys: %w", err)
+	}
+
+	keys := make(map[string]signingKey, len(paths))
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			return err
+		}
+		keys[key.kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("%w in %s", myerrors.ErrNoSigningKeys, ks.cfg.KeysDir)
+	}
+
+	active := ks.cfg.ActiveKid
+	if active == "" {
+		active = newestKid(keys)
+	}
+	if _, ok := keys[active]; !ok {
+		return fmt.Errorf("%w: %s", myerrors.ErrUnknownKeyKid, active)
+	}
+
+	ks.mu.Lock()
+	changed := ks.active != active || len(ks.keys) != len(keys)
+	ks.keys = keys
+	ks.active = active
+	ks.mu.Unlock()
+
+	if changed {
+		ks.mylog.Action("keys_loaded").Info("Signing keys loaded", "active_kid", active, "keys", len(keys))
+	}
+	return nil
+}
+
+func (ks *KeyStore) Sign(claims map[string]interface{}) (string, error) {
+	ks.mu.RLock()
+	key := ks.keys[ks.active]
+	ks.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.method, jwt.MapClaims(claims))
+	token.Header["kid"] = key.kid
+
+	return token.SignedString(key.private)
+}
+
+func (ks *KeyStore) Verify(tokenString string) (map[string]interface{}, error) {
+	token, err := jwt.Parse(tokenString, jwks.Keyfunc(ks.publicKey))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, myerrors.ErrInvalidAccessToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, myerrors.ErrInvalidAccessToken
+	}
+	return claims, nil
+}
+
+func (ks *KeyStore) KeySet() jwks.Set {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := jwks.Set{Keys: make([]jwks.Key, 0, len(ks.keys))}
+	for _, kid := range sortedKids(ks.keys) {
+		key, err := jwks.FromPublicKey(kid, ks.keys[kid].private.Public())
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, key)
+	}
+	return set
+}
+
+func (ks *KeyStore) publicKey(kid string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", jwks.ErrUnknownKid, kid)
+	}
+	return key.private.Public(), nil
+}
+
+// loadKey parses a PKCS#8 (RSA or Ed25519) or PKCS#1 (RSA) private key
+func loadKey(path string) (signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("read key %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("stat key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return signingKey{}, fmt.Errorf("%w: %s is not PEM", myerrors.ErrInvalidKeyFile, path)
+	}
+
+	var private crypto.Signer
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch k := parsed.(type) {
+		case *rsa.PrivateKey:
+			private = k
+		case ed25519.PrivateKey:
+			private = k
+		default:
+			return signingKey{}, fmt.Errorf("%w: %s has unsupported key type %T", myerrors.ErrInvalidKeyFile, path, parsed)
+		}
+	} else if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		private = k
+	} else {
+		return signingKey{}, fmt.Errorf("%w: %s: %v", myerrors.ErrInvalidKeyFile, path, err)
+	}
+
+	method, err := jwks.SigningMethod(private)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("%w: %s: %v", myerrors.ErrInvalidKeyFile, path, err)
+	}
+
+	return signingKey{
+		kid:     strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		private: private,
+		method:  method,
+		modTime: info.ModTime(),
+	}, nil
+}
+
+func newestKid(keys map[string]signingKey) string {
+	newest := ""
+	for _, kid := range sortedKids(keys) {
+		if newest == "" || !keys[kid].modTime.Before(keys[newest].modTime) {
+			newest = kid
+		}
+	}
+	return newest
+}
+
+func sortedKids(keys map[string]signingKey) []string {
+	kids := make([]string, 0, len(keys))
+	for kid := range keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	return kids
+}
@@ -42,7 +42,7 @@ func (ah *DriverHandler) Register() http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
 		defer cancel()
 
-		userId, accessToken, err := ah.driverService.Register(ctx, regReq)
+		userId, tokens, err := ah.driverService.Register(ctx, regReq)
 		if err != nil {
 			if errors.Is(err, myerrors.ErrEmailRegistered) || errors.Is(err, myerrors.ErrDriverLicenseNumberRegistered) {
 				JsonError(w, http.StatusConflict, err)
@@ -52,10 +52,12 @@ func (ah *DriverHandler) Register() http.HandlerFunc {
 			return
 		}
 
-		jsonResponse(w, http.StatusOK, map[string]string{
-			"msg":        fmt.Sprintf("%s registered successfully!", regReq.Username),
-			"jwt_access": accessToken,
-			"driverId":   userId,
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"msg":         fmt.Sprintf("%s registered successfully!", regReq.Username),
+			"jwt_access":  tokens.AccessToken,
+			"jwt_refresh": tokens.RefreshToken,
+			"expires_in":  tokens.ExpiresIn,
+			"driverId":    userId,
 		})
 		mylog.Info("Successfully registered!")
 	}
@@ -77,15 +79,21 @@ func (ah *DriverHandler) Login() http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
 		defer cancel()
 
-		accessToken, err := ah.driverService.Login(ctx, driverReq)
+		tokens, err := ah.driverService.Login(ctx, driverReq)
 		if err != nil {
+			if errors.Is(err, myerrors.ErrUserBanned) {
+				JsonError(w, http.StatusForbidden, err)
+				return
+			}
 			JsonError(w, http.StatusInternalServerError, err)
 			return
 		}
 
-		jsonResponse(w, http.StatusOK, map[string]string{
-			"msg":        "Successfully logged in",
-			"jwt_access": accessToken,
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"msg":         "Successfully logged in",
+			"jwt_access":  tokens.AccessToken,
+			"jwt_refresh": tokens.RefreshToken,
+			"expires_in":  tokens.ExpiresIn,
 		})
 		ah.mylog.Info("Successfully login!")
 	}
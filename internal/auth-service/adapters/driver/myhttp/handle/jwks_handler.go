@@ -0,0 +1,31 @@
+package handle
+
+import (
+	"fmt"
+	"net/http"
+
+	"ride-hail/internal/auth-service/core/ports/driven"
+	"ride-hail/internal/mylogger"
+)
+
+type JwksHandler struct {
+	signer driven.ITokenSigner
+	maxAge int
+	mylog  mylogger.Logger
+}
+
+func NewJwksHandler(signer driven.ITokenSigner, maxAge int, mylog mylogger.Logger) *JwksHandler {
+	return &JwksHandler{
+		signer: signer,
+		maxAge: maxAge,
+		mylog:  mylog,
+	}
+}
+
+// KeySet publishes the public keys, verifiers cache them for maxAge seconds
+func (jh *JwksHandler) KeySet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", jh.maxAge))
+		jsonResponse(w, http.StatusOK, jh.signer.KeySet())
+	}
+}
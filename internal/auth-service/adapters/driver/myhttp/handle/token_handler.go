@@ -0,0 +1,104 @@
+package handle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"ride-hail/internal/auth-service/core/domain/dto"
+	"ride-hail/internal/auth-service/core/myerrors"
+	"ride-hail/internal/auth-service/core/ports/driver"
+	"ride-hail/internal/mylogger"
+)
+
+type TokenHandler struct {
+	tokenService driver.ITokenService
+	mylog        mylogger.Logger
+}
+
+func NewTokenHandler(tokenService driver.ITokenService, mylog mylogger.Logger) *TokenHandler {
+	return &TokenHandler{
+		tokenService: tokenService,
+		mylog:        mylog,
+	}
+}
+
+func (th *TokenHandler) Refresh() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req dto.RefreshRequest
+
+		mylog := th.mylog.Action("Refresh")
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			mylog.Error("Failed to parse refresh request", err)
+			JsonError(w, http.StatusBadRequest, errors.New("failed to parse JSON"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
+		defer cancel()
+
+		tokens, err := th.tokenService.Refresh(ctx, req.RefreshToken)
+		if err != nil {
+			if isTokenError(err) {
+				JsonError(w, http.StatusUnauthorized, err)
+				return
+			}
+			JsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, tokens)
+		mylog.Info("Successfully refreshed!")
+	}
+}
+
+func (th *TokenHandler) Logout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req dto.LogoutRequest
+
+		mylog := th.mylog.Action("Logout")
+
+		accessToken := r.Header.Get("Authorization")
+		if accessToken == "" {
+			JsonError(w, http.StatusUnauthorized, errors.New("Empty JWT-Token"))
+			return
+		}
+
+		// Body is optional, a bare logout only revokes the access token
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				mylog.Error("Failed to parse logout request", err)
+				JsonError(w, http.StatusBadRequest, errors.New("failed to parse JSON"))
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
+		defer cancel()
+
+		if err := th.tokenService.Logout(ctx, accessToken, req); err != nil {
+			if isTokenError(err) {
+				JsonError(w, http.StatusUnauthorized, err)
+				return
+			}
+			JsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]string{
+			"msg": "Successfully logged out",
+		})
+		mylog.Info("Successfully logged out!")
+	}
+}
+
+func isTokenError(err error) bool {
+	return errors.Is(err, myerrors.ErrInvalidRefreshToken) ||
+		errors.Is(err, myerrors.ErrRefreshTokenExpired) ||
+		errors.Is(err, myerrors.ErrRefreshTokenRevoked) ||
+		errors.Is(err, myerrors.ErrInvalidAccessToken) ||
+		errors.Is(err, myerrors.ErrTokenRevoked)
+}
@@ -42,7 +42,7 @@ func (ah *UserHandler) Register() http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
 		defer cancel()
 
-		userId, accessToken, err := ah.authService.Register(ctx, regReq)
+		userId, tokens, err := ah.authService.Register(ctx, regReq)
 		if err != nil {
 			if errors.Is(err, myerrors.ErrEmailRegistered) {
 				JsonError(w, http.StatusConflict, err)
@@ -52,10 +52,12 @@ func (ah *UserHandler) Register() http.HandlerFunc {
 			return
 		}
 
-		jsonResponse(w, http.StatusOK, map[string]string{
-			"msg":        fmt.Sprintf("%s registered successfully!", regReq.Username),
-			"jwt_access": accessToken,
-			"userId":     userId,
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"msg":         fmt.Sprintf("%s registered successfully!", regReq.Username),
+			"jwt_access":  tokens.AccessToken,
+			"jwt_refresh": tokens.RefreshToken,
+			"expires_in":  tokens.ExpiresIn,
+			"userId":      userId,
 		})
 		mylog.Info("Successfully registered!")
 	}
@@ -77,15 +79,21 @@ func (ah *UserHandler) Login() http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
 		defer cancel()
 
-		accessToken, err := ah.authService.Login(ctx, authReq)
+		tokens, err := ah.authService.Login(ctx, authReq)
 		if err != nil {
+			if errors.Is(err, myerrors.ErrUserBanned) {
+				JsonError(w, http.StatusForbidden, err)
+				return
+			}
 			JsonError(w, http.StatusInternalServerError, err)
 			return
 		}
 
-		jsonResponse(w, http.StatusOK, map[string]string{
-			"msg":        "Successfully login!",
-			"jwt_access": accessToken,
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"msg":         "Successfully login!",
+			"jwt_access":  tokens.AccessToken,
+			"jwt_refresh": tokens.RefreshToken,
+			"expires_in":  tokens.ExpiresIn,
 		})
 		ah.mylog.Info("Successfully login!")
 	}
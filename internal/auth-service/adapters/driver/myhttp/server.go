@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"ride-hail/internal/auth-service/adapters/driven/db"
+	"ride-hail/internal/auth-service/adapters/driven/hasher"
+	"ride-hail/internal/auth-service/adapters/driven/keystore"
 	"ride-hail/internal/auth-service/adapters/driver/myhttp/handle"
 	"ride-hail/internal/auth-service/core/service"
 	"ride-hail/internal/config"
@@ -53,7 +55,10 @@ func (s *Server) Run() error {
 	mylog.Action("db_connected").Info("Successful database connection")
 
 	// Configure routes and handlers
-	s.Configure()
+	if err := s.Configure(); err != nil {
+		mylog.Action("configure_failed").Error("Failed to configure server", err)
+		return err
+	}
 
 	cert, err := tls.LoadX509KeyPair(s.cfg.App.CertPath, s.cfg.App.CertKeyPath)
 	if err != nil {
@@ -131,21 +136,44 @@ func (s *Server) startHTTPServer() error {
 }
 
 // Configure sets up the HTTP handlers for various APIs including Market Data, Data Mode control, and Health checks.
-func (s *Server) Configure() {
+func (s *Server) Configure() error {
+	passwordHasher, err := hasher.New(s.cfg.Password)
+	if err != nil {
+		return fmt.Errorf("failed to create password hasher: %w", err)
+	}
+
+	signer, err := keystore.New(s.cfg.Jwt, s.mylog)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	go signer.Watch(s.ctx)
+
+	jwksHandler := handle.NewJwksHandler(signer, s.cfg.Jwt.JwksCacheSeconds, s.mylog)
+	s.mux.Handle("GET /.well-known/jwks.json", jwksHandler.KeySet())
+
 	// Repositories and services
+	tokenRepo := db.NewTokenRepo(s.ctx, s.db)
+	tokenService := service.NewTokenService(s.ctx, s.cfg, tokenRepo, signer, s.mylog)
+	tokenHandler := handle.NewTokenHandler(tokenService, s.mylog)
+
+	s.mux.Handle("POST /auth/refresh", tokenHandler.Refresh())
+	s.mux.Handle("POST /auth/logout", tokenHandler.Logout())
+
 	authRepo := db.NewUserRepo(s.ctx, s.db)
-	authService := service.NewUserService(s.ctx, s.cfg, authRepo, s.mylog)
+	authService := service.NewUserService(s.ctx, s.cfg, authRepo, passwordHasher, tokenService, s.mylog)
 	authHandler := handle.NewUserHandler(authService, s.mylog)
 
 	s.mux.Handle("POST /user/register", authHandler.Register())
 	s.mux.Handle("POST /user/login", authHandler.Login())
 
 	driverRepo := db.NewDriverRepo(s.ctx, s.db)
-	driverService := service.NewDriverService(s.ctx, s.cfg, driverRepo, s.mylog)
+	driverService := service.NewDriverService(s.ctx, s.cfg, driverRepo, passwordHasher, tokenService, s.mylog)
 	driverHandler := handle.NewDriverHandler(driverService, s.mylog)
 
 	s.mux.Handle("POST /driver/register", driverHandler.Register())
 	s.mux.Handle("POST /driver/login", driverHandler.Login())
+
+	return nil
 }
 
 func (s *Server) initializeDatabase() error {
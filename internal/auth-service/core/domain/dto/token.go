@@ -0,0 +1,17 @@
+package dto
+
+type TokenPair struct {
+	AccessToken  string `json:"jwt_access"`
+	RefreshToken string `json:"jwt_refresh"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	// All revokes every session of the subject, not only the current one
+	All bool `json:"all"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	RefreshTokenId string     `json:"refresh_token_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	FamilyId       string     `json:"family_id"`
+	SubjectId      string     `json:"subject_id"`
+	Role           string     `json:"role"`
+	TokenHash      string     `json:"token_hash"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy     *string    `json:"replaced_by,omitempty"`
+	// Claims are the extra access token claims of the login, kept across rotations
+	Claims map[string]interface{} `json:"claims,omitempty"`
+}
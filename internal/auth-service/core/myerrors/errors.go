@@ -11,6 +11,20 @@ var (
 	ErrEmailRegistered               = errors.New("email already registered")
 	ErrDriverLicenseNumberRegistered = errors.New("driver licence number is already registered")
 
+	ErrUnknownHashAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash        = errors.New("malformed password hash")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenRevoked = errors.New("refresh token revoked")
+	ErrInvalidAccessToken  = errors.New("invalid access token")
+	ErrTokenRevoked        = errors.New("token revoked")
+	ErrUserBanned          = errors.New("user is banned")
+
+	ErrNoSigningKeys  = errors.New("no signing keys loaded")
+	ErrUnknownKeyKid  = errors.New("active signing key not found")
+	ErrInvalidKeyFile = errors.New("invalid signing key file")
+
 	ErrDBConnClosed    = errors.New("failed to connect to db")
 	ErrDBConnClosedMsg = errors.New("internal error, please try again later")
 )
@@ -9,4 +9,5 @@ import (
 type IDriverRepo interface {
 	Create(ctx context.Context, driver models.Driver) (string, error)
 	GetByEmail(ctx context.Context, email string) (models.Driver, error)
+	UpdatePassword(ctx context.Context, id, passwordHash string) error
 }
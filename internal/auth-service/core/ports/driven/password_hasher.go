@@ -0,0 +1,11 @@
+package driven
+
+// IPasswordHasher hides the concrete password hashing algorithm from the services.
+type IPasswordHasher interface {
+	// Hash returns an encoded hash of the password that is safe to store in db.
+	Hash(password string) (string, error)
+	// Compare checks the password against a stored hash in constant time.
+	// needsRehash is true when the stored value is plaintext or was produced
+	// with weaker parameters than the current configuration.
+	Compare(hash, password string) (ok bool, needsRehash bool, err error)
+}
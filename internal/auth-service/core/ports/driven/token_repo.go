@@ -0,0 +1,18 @@
+package driven
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/internal/auth-service/core/domain/models"
+)
+
+type ITokenRepo interface {
+	CreateRefreshToken(ctx context.Context, token models.RefreshToken) (string, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldId string, next models.RefreshToken) (string, error)
+	RevokeFamily(ctx context.Context, familyId string) error
+	RevokeAccessToken(ctx context.Context, jti, subjectId string, expiresAt time.Time) error
+	RevokeSubject(ctx context.Context, subjectId, reason string) error
+	IsRevoked(ctx context.Context, jti, subjectId string, issuedAt time.Time) (bool, error)
+}
@@ -0,0 +1,11 @@
+package driven
+
+import "ride-hail/internal/jwks"
+
+// ITokenSigner signs access tokens with the active private key and exposes
+// the public halves of all loaded keys so that other services can verify them.
+type ITokenSigner interface {
+	Sign(claims map[string]interface{}) (string, error)
+	Verify(tokenString string) (map[string]interface{}, error)
+	KeySet() jwks.Set
+}
@@ -9,4 +9,5 @@ import (
 type IUserRepo interface {
 	Create(ctx context.Context, user models.User) (string, error)
 	GetByEmail(ctx context.Context, name string) (models.User, error)
+	UpdatePassword(ctx context.Context, id, passwordHash string) error
 }
@@ -7,6 +7,6 @@ import (
 )
 
 type IDriverService interface {
-	Register(ctx context.Context, regReq dto.DriverRegistrationRequest) (string, string, error)
-	Login(ctx context.Context, authReq dto.DriverAuthRequest) (string, error)
+	Register(ctx context.Context, regReq dto.DriverRegistrationRequest) (string, dto.TokenPair, error)
+	Login(ctx context.Context, authReq dto.DriverAuthRequest) (dto.TokenPair, error)
 }
@@ -0,0 +1,13 @@
+package driver
+
+import (
+	"context"
+
+	"ride-hail/internal/auth-service/core/domain/dto"
+)
+
+type ITokenService interface {
+	Issue(ctx context.Context, subjectId, role string, extra map[string]interface{}) (dto.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (dto.TokenPair, error)
+	Logout(ctx context.Context, accessToken string, req dto.LogoutRequest) error
+}
@@ -7,6 +7,6 @@ import (
 )
 
 type IUserService interface {
-	Register(ctx context.Context, regReq dto.UserRegistrationRequest) (string, string, error)
-	Login(ctx context.Context, authReq dto.UserAuthRequest) (string, error)
+	Register(ctx context.Context, regReq dto.UserRegistrationRequest) (string, dto.TokenPair, error)
+	Login(ctx context.Context, authReq dto.UserAuthRequest) (dto.TokenPair, error)
 }
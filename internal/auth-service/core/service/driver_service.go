@@ -4,23 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"ride-hail/internal/auth-service/core/domain/dto"
 	"ride-hail/internal/auth-service/core/domain/models"
 	"ride-hail/internal/auth-service/core/myerrors"
 	"ride-hail/internal/auth-service/core/ports/driven"
+	"ride-hail/internal/auth-service/core/ports/driver"
 
 	"ride-hail/internal/config"
 	"ride-hail/internal/mylogger"
-
-	"github.com/golang-jwt/jwt"
 )
 
 type DriverService struct {
 	ctx        context.Context
 	cfg        *config.Config
 	driverRepo driven.IDriverRepo
+	hasher     driven.IPasswordHasher
+	tokens     driver.ITokenService
 	mylog      mylogger.Logger
 }
 
@@ -28,18 +28,22 @@ func NewDriverService(
 	ctx context.Context,
 	cfg *config.Config,
 	driverRepo driven.IDriverRepo,
+	hasher driven.IPasswordHasher,
+	tokens driver.ITokenService,
 	mylogger mylogger.Logger,
 ) *DriverService {
 	return &DriverService{
 		ctx:        ctx,
 		cfg:        cfg,
 		driverRepo: driverRepo,
+		hasher:     hasher,
+		tokens:     tokens,
 		mylog:      mylogger,
 	}
 }
 
 // ======================= Register =======================
-func (ds *DriverService) Register(ctx context.Context, regReq dto.DriverRegistrationRequest) (string, string, error) {
+func (ds *DriverService) Register(ctx context.Context, regReq dto.DriverRegistrationRequest) (string, dto.TokenPair, error) {
 	mylog := ds.mylog.Action("Register")
 
 	r := dto.UserRegistrationRequest{
@@ -51,17 +55,23 @@ func (ds *DriverService) Register(ctx context.Context, regReq dto.DriverRegistra
 	}
 
 	if err := validateUserRegistration(ctx, r); err != nil {
-		return "", "", err
+		return "", dto.TokenPair{}, err
 	}
 
 	if err := validateDriverRegistration(ctx, regReq.LicenseNumber, regReq.VehicleType, regReq.VehicleAttrs); err != nil {
-		return "", "", err
+		return "", dto.TokenPair{}, err
+	}
+
+	passwordHash, err := ds.hasher.Hash(regReq.Password)
+	if err != nil {
+		mylog.Error("Failed to hash password", err)
+		return "", dto.TokenPair{}, fmt.Errorf("cannot hash password: %w", err)
 	}
 
 	user := models.Driver{
 		Username:      regReq.Username,
 		Email:         regReq.Email,
-		Password:      regReq.Password,
+		Password:      passwordHash,
 		LicenseNumber: regReq.LicenseNumber,
 		VehicleType:   regReq.VehicleType,
 		VehicleAttrs:  regReq.VehicleAttrs,
@@ -72,75 +82,89 @@ func (ds *DriverService) Register(ctx context.Context, regReq dto.DriverRegistra
 	if err != nil {
 		if errors.Is(err, myerrors.ErrDBConnClosed) {
 			mylog.Error("Failed to connect to connect to db", err)
-			return "", "", myerrors.ErrDBConnClosedMsg
+			return "", dto.TokenPair{}, myerrors.ErrDBConnClosedMsg
 		}
 		if errors.Is(err, myerrors.ErrEmailRegistered) {
 			mylog.Error("Failed to register, email already registered", err)
-			return "", "", err
+			return "", dto.TokenPair{}, err
 		}
 
 		mylog.Error("Failed to save user in db", err)
-		return "", "", fmt.Errorf("cannot save user in db: %w", err)
+		return "", dto.TokenPair{}, fmt.Errorf("cannot save user in db: %w", err)
 	}
 
-	AccessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  id,
-		"username": regReq.Username,
-		"role":     "DRIVER",
-		"exp":      time.Now().Add(time.Hour * 27 * 7).Unix(),
-	})
-
-	accessTokenString, err := AccessToken.SignedString([]byte(ds.cfg.App.PublicJwtSecret))
+	tokens, err := ds.tokens.Issue(ctx, id, "DRIVER", map[string]interface{}{"username": regReq.Username})
 	if err != nil {
-		mylog.Error("error to create jwt token", err)
-		return "", "", err
+		mylog.Error("error to create tokens", err)
+		return "", dto.TokenPair{}, err
 	}
 
 	mylog.Info("User registered successfully")
-	return id, accessTokenString, nil
+	return id, tokens, nil
 }
 
-func (ds *DriverService) Login(ctx context.Context, authReq dto.DriverAuthRequest) (string, error) {
+func (ds *DriverService) Login(ctx context.Context, authReq dto.DriverAuthRequest) (dto.TokenPair, error) {
 	mylog := ds.mylog.Action("Login")
 
 	if err := validateLogin(ctx, authReq.Email, authReq.Password); err != nil {
-		return "", err
+		return dto.TokenPair{}, err
 	}
 
 	user, err := ds.driverRepo.GetByEmail(ctx, authReq.Email)
 	if err != nil {
 		if errors.Is(err, myerrors.ErrDBConnClosed) {
 			mylog.Error("Failed to connect to connect to db", err)
-			return "", myerrors.ErrDBConnClosedMsg
+			return dto.TokenPair{}, myerrors.ErrDBConnClosedMsg
 		}
 		if errors.Is(err, myerrors.ErrUnknownEmail) {
 			mylog.Error("Failed to login, unknown email", err)
-			return "", err
+			return dto.TokenPair{}, err
 		}
 
 		mylog.Error("Failed to get driver by id", err)
-		return "", fmt.Errorf("cannot get user from db: %w", err)
+		return dto.TokenPair{}, fmt.Errorf("cannot get user from db: %w", err)
 	}
 
 	// Compare password hashes
-	if user.Password != authReq.Password {
+	ok, needsRehash, err := ds.hasher.Compare(user.Password, authReq.Password)
+	if err != nil {
+		mylog.Error("Failed to compare password hashes", err)
+		return dto.TokenPair{}, fmt.Errorf("cannot verify password: %w", err)
+	}
+	if !ok {
 		mylog.Debug("Failed to login, unknown password")
-		return "", myerrors.ErrPasswordUnknown
+		return dto.TokenPair{}, myerrors.ErrPasswordUnknown
 	}
 
-	AccessTokenString := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.DriverId,
-		"username": user.Username,
-		"role":     "DRIVER",
-		"exp":      time.Now().Add(time.Hour * 27 * 7).Unix(),
-	})
+	// Transparently upgrade plaintext or weaker hashes
+	if needsRehash {
+		ds.rehash(ctx, user.DriverId, authReq.Password)
+	}
 
-	accessTokenString, err := AccessTokenString.SignedString([]byte(ds.cfg.App.PublicJwtSecret))
+	tokens, err := ds.tokens.Issue(ctx, user.DriverId, "DRIVER", map[string]interface{}{"username": user.Username})
 	if err != nil {
-		mylog.Error("error to create jwt token", err)
-		return "", err
+		mylog.Error("error to create tokens", err)
+		return dto.TokenPair{}, err
 	}
 
 	mylog.Info("User login successfully")
-	return accessTokenString, nil
+	return tokens, nil
+}
+
+// rehash stores a fresh hash of the password, login should not fail if the upgrade does
+func (ds *DriverService) rehash(ctx context.Context, id, password string) {
+	mylog := ds.mylog.Action("Rehash")
+
+	passwordHash, err := ds.hasher.Hash(password)
+	if err != nil {
+		mylog.Error("Failed to hash password", err)
+		return
+	}
+
+	if err := ds.driverRepo.UpdatePassword(ctx, id, passwordHash); err != nil {
+		mylog.Error("Failed to update password hash", err)
+		return
+	}
+
+	mylog.Info("Password hash upgraded", "id", id)
 }
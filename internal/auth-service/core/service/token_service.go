@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ride-hail/internal/auth-service/core/domain/dto"
+	"ride-hail/internal/auth-service/core/domain/models"
+	"ride-hail/internal/auth-service/core/myerrors"
+	"ride-hail/internal/auth-service/core/ports/driven"
+	"ride-hail/internal/config"
+	"ride-hail/internal/mylogger"
+)
+
+type TokenService struct {
+	ctx       context.Context
+	cfg       *config.Config
+	tokenRepo driven.ITokenRepo
+	signer    driven.ITokenSigner
+	mylog     mylogger.Logger
+}
+
+func NewTokenService(
+	ctx context.Context,
+	cfg *config.Config,
+	tokenRepo driven.ITokenRepo,
+	signer driven.ITokenSigner,
+	mylogger mylogger.Logger,
+) *TokenService {
+	return &TokenService{
+		ctx:       ctx,
+		cfg:       cfg,
+		tokenRepo: tokenRepo,
+		signer:    signer,
+		mylog:     mylogger,
+	}
+}
+
+// ======================= Issue =======================
+// Issue starts a new refresh token family and returns a fresh token pair
+func (ts *TokenService) Issue(ctx context.Context, subjectId, role string, extra map[string]interface{}) (dto.TokenPair, error) {
+	mylog := ts.mylog.Action("IssueTokens")
+
+	familyId, err := newTokenID()
+	if err != nil {
+		mylog.Error("Failed to generate token family", err)
+		return dto.TokenPair{}, err
+	}
+
+	refreshToken, tokenHash, err := newRefreshToken()
+	if err != nil {
+		mylog.Error("Failed to generate refresh token", err)
+		return dto.TokenPair{}, err
+	}
+
+	_, err = ts.tokenRepo.CreateRefreshToken(ctx, models.RefreshToken{
+		FamilyId:  familyId,
+		SubjectId: subjectId,
+		Role:      role,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ts.refreshTTL()),
+		Claims:    extra,
+	})
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			mylog.Error("Failed to connect to connect to db", err)
+			return dto.TokenPair{}, myerrors.ErrDBConnClosedMsg
+		}
+		mylog.Error("Failed to save refresh token", err)
+		return dto.TokenPair{}, fmt.Errorf("cannot save refresh token: %w", err)
+	}
+
+	accessToken, err := ts.newAccessToken(subjectId, role, extra)
+	if err != nil {
+		mylog.Error("error to create jwt token", err)
+		return dto.TokenPair{}, err
+	}
+
+	return dto.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(ts.accessTTL().Seconds()),
+	}, nil
+}
+
+// ======================= Refresh =======================
+// Refresh rotates the refresh token, reuse of an already rotated token revokes the whole family
+func (ts *TokenService) Refresh(ctx context.Context, refreshToken string) (dto.TokenPair, error) {
+	mylog := ts.mylog.Action("Refresh")
+
+	if refreshToken == "" {
+		return dto.TokenPair{}, myerrors.ErrInvalidRefreshToken
+	}
+
+	stored, err := ts.tokenRepo.GetRefreshToken(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			mylog.Error("Failed to connect to connect to db", err)
+			return dto.TokenPair{}, myerrors.ErrDBConnClosedMsg
+		}
+		if errors.Is(err, myerrors.ErrInvalidRefreshToken) {
+			mylog.Debug("Unknown refresh token")
+			return dto.TokenPair{}, err
+		}
+		mylog.Error("Failed to get refresh token", err)
+		return dto.TokenPair{}, fmt.Errorf("cannot get refresh token: %w", err)
+	}
+
+	if stored.RevokedAt != nil {
+		mylog.Warn("Revoked refresh token reused, revoking family", "subject_id", stored.SubjectId, "family_id", stored.FamilyId)
+		ts.revokeFamily(ctx, stored.FamilyId)
+		return dto.TokenPair{}, myerrors.ErrRefreshTokenRevoked
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		mylog.Debug("Refresh token expired", "subject_id", stored.SubjectId)
+		return dto.TokenPair{}, myerrors.ErrRefreshTokenExpired
+	}
+
+	nextToken, tokenHash, err := newRefreshToken()
+	if err != nil {
+		mylog.Error("Failed to generate refresh token", err)
+		return dto.TokenPair{}, err
+	}
+
+	_, err = ts.tokenRepo.RotateRefreshToken(ctx, stored.RefreshTokenId, models.RefreshToken{
+		FamilyId:  stored.FamilyId,
+		SubjectId: stored.SubjectId,
+		Role:      stored.Role,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ts.refreshTTL()),
+		Claims:    stored.Claims,
+	})
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			mylog.Error("Failed to connect to connect to db", err)
+			return dto.TokenPair{}, myerrors.ErrDBConnClosedMsg
+		}
+		if errors.Is(err, myerrors.ErrRefreshTokenRevoked) {
+			// Lost the race against another rotation of the same token
+			mylog.Warn("Refresh token rotated concurrently, revoking family", "subject_id", stored.SubjectId, "family_id", stored.FamilyId)
+			ts.revokeFamily(ctx, stored.FamilyId)
+			return dto.TokenPair{}, err
+		}
+		mylog.Error("Failed to rotate refresh token", err)
+		return dto.TokenPair{}, fmt.Errorf("cannot rotate refresh token: %w", err)
+	}
+
+	accessToken, err := ts.newAccessToken(stored.SubjectId, stored.Role, stored.Claims)
+	if err != nil {
+		mylog.Error("error to create jwt token", err)
+		return dto.TokenPair{}, err
+	}
+
+	mylog.Info("Tokens refreshed successfully")
+	return dto.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: nextToken,
+		ExpiresIn:    int64(ts.accessTTL().Seconds()),
+	}, nil
+}
+
+// ======================= Logout =======================
+func (ts *TokenService) Logout(ctx context.Context, accessToken string, req dto.LogoutRequest) error {
+	mylog := ts.mylog.Action("Logout")
+
+	claims, err := ts.parseAccessToken(ctx, accessToken)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			mylog.Error("Failed to connect to connect to db", err)
+			return myerrors.ErrDBConnClosedMsg
+		}
+		mylog.Debug("Failed to logout, invalid access token", "error", err.Error())
+		return err
+	}
+
+	if err := ts.tokenRepo.RevokeAccessToken(ctx, claims.jti, claims.subjectId, claims.expiresAt); err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			mylog.Error("Failed to connect to connect to db", err)
+			return myerrors.ErrDBConnClosedMsg
+		}
+		mylog.Error("Failed to revoke access token", err)
+		return fmt.Errorf("cannot revoke access token: %w", err)
+	}
+
+	if req.All {
+		if err := ts.tokenRepo.RevokeSubject(ctx, claims.subjectId, "logout"); err != nil {
+			if errors.Is(err, myerrors.ErrDBConnClosed) {
+				mylog.Error("Failed to connect to connect to db", err)
+				return myerrors.ErrDBConnClosedMsg
+			}
+			mylog.Error("Failed to revoke subject", err)
+			return fmt.Errorf("cannot revoke sessions: %w", err)
+		}
+		mylog.Info("All sessions logged out", "subject_id", claims.subjectId)
+		return nil
+	}
+
+	if req.RefreshToken != "" {
+		stored, err := ts.tokenRepo.GetRefreshToken(ctx, hashRefreshToken(req.RefreshToken))
+		if err != nil {
+			if errors.Is(err, myerrors.ErrDBConnClosed) {
+				mylog.Error("Failed to connect to connect to db", err)
+				return myerrors.ErrDBConnClosedMsg
+			}
+			if errors.Is(err, myerrors.ErrInvalidRefreshToken) {
+				return err
+			}
+			mylog.Error("Failed to get refresh token", err)
+			return fmt.Errorf("cannot get refresh token: %w", err)
+		}
+
+		// Nobody should be able to log out someone else's session
+		if stored.SubjectId != claims.subjectId {
+			mylog.Warn("Refresh token belongs to another subject", "subject_id", claims.subjectId)
+			return myerrors.ErrInvalidRefreshToken
+		}
+
+		if err := ts.tokenRepo.RevokeFamily(ctx, stored.FamilyId); err != nil {
+			if errors.Is(err, myerrors.ErrDBConnClosed) {
+				mylog.Error("Failed to connect to connect to db", err)
+				return myerrors.ErrDBConnClosedMsg
+			}
+			mylog.Error("Failed to revoke refresh token", err)
+			return fmt.Errorf("cannot revoke refresh token: %w", err)
+		}
+	}
+
+	mylog.Info("Logged out successfully", "subject_id", claims.subjectId)
+	return nil
+}
+
+type accessClaims struct {
+	jti       string
+	subjectId string
+	expiresAt time.Time
+}
+
+// parseAccessToken validates the signature, expiry and revocation status of the access token
+func (ts *TokenService) parseAccessToken(ctx context.Context, tokenString string) (accessClaims, error) {
+	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+	if tokenString == "" {
+		return accessClaims{}, myerrors.ErrInvalidAccessToken
+	}
+
+	claims, err := ts.signer.Verify(tokenString)
+	if err != nil {
+		return accessClaims{}, myerrors.ErrInvalidAccessToken
+	}
+
+	jti, _ := claims["jti"].(string)
+	subjectId, _ := claims["user_id"].(string)
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+	if jti == "" || subjectId == "" || iat == 0 || exp == 0 {
+		return accessClaims{}, myerrors.ErrInvalidAccessToken
+	}
+
+	revoked, err := ts.tokenRepo.IsRevoked(ctx, jti, subjectId, time.UnixMilli(int64(iat*1000)))
+	if err != nil {
+		return accessClaims{}, err
+	}
+	if revoked {
+		return accessClaims{}, myerrors.ErrTokenRevoked
+	}
+
+	return accessClaims{
+		jti:       jti,
+		subjectId: subjectId,
+		expiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
+
+func (ts *TokenService) newAccessToken(subjectId, role string, extra map[string]interface{}) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	claims["user_id"] = subjectId
+	claims["role"] = role
+	claims["jti"] = jti
+	// milliseconds, so a token issued right after "logout everywhere" is
+	// told apart from the ones it revoked
+	claims["iat"] = float64(now.UnixMilli()) / 1000
+	claims["exp"] = now.Add(ts.accessTTL()).Unix()
+
+	return ts.signer.Sign(claims)
+}
+
+// revokeFamily is best effort, the caller already rejects the request
+func (ts *TokenService) revokeFamily(ctx context.Context, familyId string) {
+	if err := ts.tokenRepo.RevokeFamily(ctx, familyId); err != nil {
+		ts.mylog.Action("RevokeFamily").Error("Failed to revoke refresh token family", err)
+	}
+}
+
+func (ts *TokenService) accessTTL() time.Duration {
+	return time.Duration(ts.cfg.Token.AccessTTLMinutes) * time.Minute
+}
+
+func (ts *TokenService) refreshTTL() time.Duration {
+	return time.Duration(ts.cfg.Token.RefreshTTLHours) * time.Hour
+}
+
+// newRefreshToken returns the opaque token for the client and the hash to store in db
+func newRefreshToken() (string, string, error) {
+	b := make([]byte, TokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTokenID returns a random UUIDv4 string
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token id: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -4,22 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"ride-hail/internal/auth-service/core/domain/dto"
 	"ride-hail/internal/auth-service/core/domain/models"
 	"ride-hail/internal/auth-service/core/myerrors"
 	"ride-hail/internal/auth-service/core/ports/driven"
+	"ride-hail/internal/auth-service/core/ports/driver"
 	"ride-hail/internal/config"
 	"ride-hail/internal/mylogger"
-
-	"github.com/golang-jwt/jwt"
 )
 
 type UserService struct {
 	ctx      context.Context
 	cfg      *config.Config
 	authRepo driven.IUserRepo
+	hasher   driven.IPasswordHasher
+	tokens   driver.ITokenService
 	mylog    mylogger.Logger
 }
 
@@ -27,28 +27,38 @@ func NewUserService(
 	ctx context.Context,
 	cfg *config.Config,
 	authRepo driven.IUserRepo,
+	hasher driven.IPasswordHasher,
+	tokens driver.ITokenService,
 	mylogger mylogger.Logger,
 ) *UserService {
 	return &UserService{
 		ctx:      ctx,
 		cfg:      cfg,
 		authRepo: authRepo,
+		hasher:   hasher,
+		tokens:   tokens,
 		mylog:    mylogger,
 	}
 }
 
 // ======================= Register =======================
-func (us *UserService) Register(ctx context.Context, regReq dto.UserRegistrationRequest) (string, string, error) {
+func (us *UserService) Register(ctx context.Context, regReq dto.UserRegistrationRequest) (string, dto.TokenPair, error) {
 	mylog := us.mylog.Action("Register")
 
 	if err := validateUserRegistration(ctx, regReq); err != nil {
-		return "", "", err
+		return "", dto.TokenPair{}, err
+	}
+
+	passwordHash, err := us.hasher.Hash(regReq.Password)
+	if err != nil {
+		mylog.Error("Failed to hash password", err)
+		return "", dto.TokenPair{}, fmt.Errorf("cannot hash password: %w", err)
 	}
 
 	user := models.User{
 		Username:  regReq.Username,
 		Email:     regReq.Email,
-		Password:  regReq.Password,
+		Password:  passwordHash,
 		Role:      regReq.Role,
 		UserAttrs: regReq.UserAttrs,
 	}
@@ -57,74 +67,92 @@ func (us *UserService) Register(ctx context.Context, regReq dto.UserRegistration
 	if err != nil {
 		if errors.Is(err, myerrors.ErrDBConnClosed) {
 			mylog.Error("Failed to connect to connect to db", err)
-			return "", "", myerrors.ErrDBConnClosedMsg
+			return "", dto.TokenPair{}, myerrors.ErrDBConnClosedMsg
 		}
 		if errors.Is(err, myerrors.ErrEmailRegistered) {
 			mylog.Error("Failed to register, email already registered", err)
-			return "", "", err
+			return "", dto.TokenPair{}, err
 		}
 		mylog.Error("Failed to save user in db", err)
-		return "", "", fmt.Errorf("cannot save user in db: %w", err)
+		return "", dto.TokenPair{}, fmt.Errorf("cannot save user in db: %w", err)
 	}
 
-	AccessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": id,
-		"email":   regReq.Email,
-		"role":    regReq.Role,
-		"exp":     time.Now().Add(time.Hour * 27 * 7).Unix(),
-	})
-	mylog.Info(us.cfg.App.PublicJwtSecret)
-	accessTokenString, err := AccessToken.SignedString([]byte(us.cfg.App.PublicJwtSecret))
+	tokens, err := us.tokens.Issue(ctx, id, regReq.Role, map[string]interface{}{"email": regReq.Email})
 	if err != nil {
-		mylog.Error("error to create jwt token", err)
-		return "", "", err
+		mylog.Error("error to create tokens", err)
+		return "", dto.TokenPair{}, err
 	}
 
 	mylog.Info("User registered successfully")
-	return id, accessTokenString, nil
+	return id, tokens, nil
 }
 
-func (us *UserService) Login(ctx context.Context, authReq dto.UserAuthRequest) (string, error) {
+func (us *UserService) Login(ctx context.Context, authReq dto.UserAuthRequest) (dto.TokenPair, error) {
 	mylog := us.mylog.Action("Login")
 
 	if err := validateLogin(ctx, authReq.Email, authReq.Password); err != nil {
-		return "", err
+		return dto.TokenPair{}, err
 	}
 
 	user, err := us.authRepo.GetByEmail(ctx, authReq.Email)
 	if err != nil {
 		if errors.Is(err, myerrors.ErrDBConnClosed) {
 			mylog.Error("Failed to connect to connect to db", err)
-			return "", myerrors.ErrDBConnClosedMsg
+			return dto.TokenPair{}, myerrors.ErrDBConnClosedMsg
 		}
 		if errors.Is(err, myerrors.ErrUnknownEmail) {
 			mylog.Error("Failed to login, unknown email", err)
-			return "", err
+			return dto.TokenPair{}, err
 		}
 
 		mylog.Error("Failed to save user in db", err)
-		return "", fmt.Errorf("cannot save user in db: %w", err)
+		return dto.TokenPair{}, fmt.Errorf("cannot save user in db: %w", err)
 	}
 
 	// Compare password hashes
-	if user.Password != authReq.Password {
+	ok, needsRehash, err := us.hasher.Compare(user.Password, authReq.Password)
+	if err != nil {
+		mylog.Error("Failed to compare password hashes", err)
+		return dto.TokenPair{}, fmt.Errorf("cannot verify password: %w", err)
+	}
+	if !ok {
 		mylog.Debug("Failed to login, unknown password")
-		return "", myerrors.ErrPasswordUnknown
+		return dto.TokenPair{}, myerrors.ErrPasswordUnknown
+	}
+	if user.Status != nil && *user.Status == "BANNED" {
+		mylog.Debug("Failed to login, user is banned", "user_id", user.UserId)
+		return dto.TokenPair{}, myerrors.ErrUserBanned
 	}
 
-	AccessTokenString := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.UserId,
-		"email":   authReq.Email,
-		"role":    user.Role,
-		"exp":     time.Now().Add(time.Hour * 27 * 7).Unix(),
-	})
+	// Transparently upgrade plaintext or weaker hashes
+	if needsRehash {
+		us.rehash(ctx, user.UserId, authReq.Password)
+	}
 
-	accessTokenString, err := AccessTokenString.SignedString([]byte(us.cfg.App.PublicJwtSecret))
+	tokens, err := us.tokens.Issue(ctx, user.UserId, user.Role, map[string]interface{}{"email": authReq.Email})
 	if err != nil {
-		mylog.Error("error to create jwt token", err)
-		return "", err
+		mylog.Error("error to create tokens", err)
+		return dto.TokenPair{}, err
 	}
 
 	mylog.Info("User login successfully")
-	return accessTokenString, nil
+	return tokens, nil
+}
+
+// rehash stores a fresh hash of the password, login should not fail if the upgrade does
+func (us *UserService) rehash(ctx context.Context, id, password string) {
+	mylog := us.mylog.Action("Rehash")
+
+	passwordHash, err := us.hasher.Hash(password)
+	if err != nil {
+		mylog.Error("Failed to hash password", err)
+		return
+	}
+
+	if err := us.authRepo.UpdatePassword(ctx, id, passwordHash); err != nil {
+		mylog.Error("Failed to update password hash", err)
+		return
+	}
+
+	mylog.Info("Password hash upgraded", "id", id)
 }
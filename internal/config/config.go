@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"strconv"
@@ -14,6 +16,19 @@ type Config struct {
 	Srv      *Serviceconfig
 	Log      *Loggerconfig
 	App      *App
+	Password *Passwordconfig
+	Token    *Tokenconfig
+	Jwt      *JWTconfig
+	Matching *Matchingconfig
+	Ranking  *Rankingconfig
+	Pricing  *Pricingconfig
+	Surge    *Surgeconfig
+	Quote    *Quoteconfig
+	Schedule *Scheduleconfig
+	Routing  *Routingconfig
+	Outbox   *Outboxconfig
+	Inbox    *Inboxconfig
+	Tracing  *Tracingconfig
 }
 
 type DBconfig struct {
@@ -23,6 +38,19 @@ type DBconfig struct {
 	Password   string `yaml:"password"`
 	Database   string `yaml:"database"`
 	MaxRetries int    `yaml:"max_retries"`
+	// MaxConns and MinConns bound the connection pool of each service
+	MaxConns int `yaml:"max_conns"`
+	MinConns int `yaml:"min_conns"`
+	// MaxConnLifetimeMinutes recycles connections, MaxConnIdleMinutes closes the idle ones
+	MaxConnLifetimeMinutes int `yaml:"max_conn_lifetime_minutes"`
+	MaxConnIdleMinutes     int `yaml:"max_conn_idle_minutes"`
+	// QueryTimeoutMillis bounds a query outside a transaction, TxTimeoutMillis a whole transaction
+	QueryTimeoutMillis int `yaml:"query_timeout_millis"`
+	TxTimeoutMillis    int `yaml:"tx_timeout_millis"`
+	// SlowQueryMillis is the duration above which a query is logged as slow
+	SlowQueryMillis int `yaml:"slow_query_millis"`
+	// StatsIntervalSeconds is how often the pool statistics are logged, 0 disables them
+	StatsIntervalSeconds int `yaml:"stats_interval_seconds"`
 }
 
 type RabbitMqconfig struct {
@@ -32,11 +60,21 @@ type RabbitMqconfig struct {
 	Password   string `yaml:"password"`
 	VHost      string `yaml:"vhost"`
 	MaxRetries int    `yaml:"max_retries"`
+	// ConfirmTimeoutMillis is how long a publish waits for the broker's confirm
+	ConfirmTimeoutMillis int `yaml:"confirm_timeout_millis"`
+	// RetryBackoffMillis is the first pause between publish attempts; it doubles after every failure
+	RetryBackoffMillis int `yaml:"retry_backoff_millis"`
 }
 
 type WebSocketconfig struct {
 	Port       int `yaml:"port"`
 	MaxRetries int `yaml:"max_retries"`
+	// InstanceID names this replica in the connection registry and its broker queue,
+	// by default the host name with a random suffix
+	InstanceID string `yaml:"instance_id"`
+	// HeartbeatSeconds is how often an instance refreshes its registry rows;
+	// rows not refreshed for three heartbeats belong to a dead instance
+	HeartbeatSeconds int `yaml:"heartbeat_seconds"`
 }
 
 type Serviceconfig struct {
@@ -51,9 +89,130 @@ type Loggerconfig struct {
 }
 
 type App struct {
-	PublicJwtSecret string `yaml:"public_jwt"`
-	CertPath        string `yaml:"cert_path"`
-	CertKeyPath     string `yaml:"cert_key_path"`
+	CertPath    string `yaml:"cert_path"`
+	CertKeyPath string `yaml:"cert_key_path"`
+}
+
+type Passwordconfig struct {
+	Algorithm      string `yaml:"algorithm"`
+	BcryptCost     int    `yaml:"bcrypt_cost"`
+	Argon2Time     int    `yaml:"argon2_time"`
+	Argon2MemoryKB int    `yaml:"argon2_memory_kb"`
+	Argon2Threads  int    `yaml:"argon2_threads"`
+}
+
+type Tokenconfig struct {
+	AccessTTLMinutes int `yaml:"access_ttl_minutes"`
+	RefreshTTLHours  int `yaml:"refresh_ttl_hours"`
+}
+
+type JWTconfig struct {
+	KeysDir          string `yaml:"keys_dir"`
+	ActiveKid        string `yaml:"active_kid"`
+	ReloadSeconds    int    `yaml:"reload_seconds"`
+	JwksURL          string `yaml:"jwks_url"`
+	JwksServerName   string `yaml:"jwks_server_name"`
+	JwksCacheSeconds int    `yaml:"jwks_cache_seconds"`
+}
+
+type Matchingconfig struct {
+	MatchSeconds int `yaml:"match_seconds"`
+	OfferSeconds int `yaml:"offer_seconds"`
+	WaveSize     int `yaml:"wave_size"`
+	// MaxAttempts is how many times a ride request is matched before it is parked
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+type Rankingconfig struct {
+	WeightDistance   float64 `yaml:"weight_distance"`
+	WeightETA        float64 `yaml:"weight_eta"`
+	WeightRating     float64 `yaml:"weight_rating"`
+	WeightAcceptance float64 `yaml:"weight_acceptance"`
+	WeightIdle       float64 `yaml:"weight_idle"`
+	RadiusMeters     int     `yaml:"radius_meters"`
+	MaxRadiusMeters  int     `yaml:"max_radius_meters"`
+	CandidateLimit   int     `yaml:"candidate_limit"`
+	AvgSpeedKmh      float64 `yaml:"avg_speed_kmh"`
+	MaxEtaMinutes    float64 `yaml:"max_eta_minutes"`
+	IdleCapMinutes   float64 `yaml:"idle_cap_minutes"`
+}
+
+type Pricingconfig struct {
+	Currency string `yaml:"currency"`
+	// AvgSpeedKmh turns an estimated distance into an estimated duration
+	AvgSpeedKmh float64                  `yaml:"avg_speed_kmh"`
+	Tariffs     map[string]*Tariffconfig `yaml:"tariffs"`
+}
+
+// Tariffconfig is the price list of one vehicle type
+type Tariffconfig struct {
+	BaseFare    float64 `yaml:"base_fare"`
+	PerKm       float64 `yaml:"per_km"`
+	PerMinute   float64 `yaml:"per_minute"`
+	MinimumFare float64 `yaml:"minimum_fare"`
+	BookingFee  float64 `yaml:"booking_fee"`
+}
+
+type Surgeconfig struct {
+	// GeohashPrecision sets the cell size; 6 is roughly 1.2km x 0.6km
+	GeohashPrecision int     `yaml:"geohash_precision"`
+	Sensitivity      float64 `yaml:"sensitivity"`
+	MaxMultiplier    float64 `yaml:"max_multiplier"`
+	MinDemand        int     `yaml:"min_demand"`
+	SmoothingSeconds int     `yaml:"smoothing_seconds"`
+}
+
+type Quoteconfig struct {
+	// SigningSecret signs quote ids. Required, and the same on every
+	// ride-service replica so any of them accepts a quote another issued
+	SigningSecret string `yaml:"signing_secret"`
+	TTLSeconds    int    `yaml:"ttl_seconds"`
+}
+
+type Scheduleconfig struct {
+	// LeadMinutes before pickup a booked ride is sent to matching
+	LeadMinutes  int `yaml:"lead_minutes"`
+	PollSeconds  int `yaml:"poll_seconds"`
+	MaxDaysAhead int `yaml:"max_days_ahead"`
+	// GraceMinutes past pickup a booking missed during downtime is still
+	// dispatched; later ones fail instead of becoming live rides
+	GraceMinutes int `yaml:"grace_minutes"`
+}
+
+type Routingconfig struct {
+	// GraphPath is an OSM-derived road graph; empty means straight lines and the speed profile only
+	GraphPath string `yaml:"graph_path"`
+	// DetourFactor stretches straight-line distance to road distance
+	DetourFactor  float64 `yaml:"detour_factor"`
+	AvgSpeedKmh   float64 `yaml:"avg_speed_kmh"`
+	PeakSpeedKmh  float64 `yaml:"peak_speed_kmh"`
+	NightSpeedKmh float64 `yaml:"night_speed_kmh"`
+	// MaxSnapMeters is how far a point may be from the nearest road vertex
+	MaxSnapMeters int `yaml:"max_snap_meters"`
+}
+
+type Outboxconfig struct {
+	PollMillis int `yaml:"poll_millis"`
+	BatchSize  int `yaml:"batch_size"`
+	// LeaseSeconds a claimed message stays hidden from other relays
+	LeaseSeconds int `yaml:"lease_seconds"`
+	// MaxBackoffSeconds caps the delay between retries of a failing message
+	MaxBackoffSeconds     int `yaml:"max_backoff_seconds"`
+	PublishTimeoutSeconds int `yaml:"publish_timeout_seconds"`
+}
+
+type Inboxconfig struct {
+	// RetentionHours a processed message id is kept; longer than any message
+	// can wait in the retry and parking queues or the dead letters
+	RetentionHours       int `yaml:"retention_hours"`
+	PurgeIntervalMinutes int `yaml:"purge_interval_minutes"`
+}
+
+type Tracingconfig struct {
+	// Dir receives one {service}.traces.jsonl file of finished spans per service
+	Dir string `yaml:"dir"`
+	// SampleRatio of new traces that are recorded; ids are propagated either way
+	SampleRatio float64 `yaml:"sample_ratio"`
 }
 
 func New() (*Config, error) {
@@ -80,6 +239,32 @@ func New() (*Config, error) {
 		return val
 	}
 
+	getEnvFloat := func(key string, def float64) float64 {
+		valStr := os.Getenv(key)
+		if valStr == "" {
+			fmt.Printf("using default key: %v: %v\n", key, def)
+			return def
+		}
+		val, err := strconv.ParseFloat(valStr, 64)
+		if err != nil {
+			fmt.Printf("using default key: %v: %v", key, def)
+			return def
+		}
+		return val
+	}
+
+	// PRICING_<VEHICLE_TYPE>_<FIELD>, e.g. PRICING_ECONOMY_PER_KM
+	getTariff := func(vehicleType string, def Tariffconfig) *Tariffconfig {
+		prefix := "PRICING_" + vehicleType + "_"
+		return &Tariffconfig{
+			BaseFare:    getEnvFloat(prefix+"BASE_FARE", def.BaseFare),
+			PerKm:       getEnvFloat(prefix+"PER_KM", def.PerKm),
+			PerMinute:   getEnvFloat(prefix+"PER_MINUTE", def.PerMinute),
+			MinimumFare: getEnvFloat(prefix+"MINIMUM_FARE", def.MinimumFare),
+			BookingFee:  getEnvFloat(prefix+"BOOKING_FEE", def.BookingFee),
+		}
+	}
+
 	cnf := &Config{
 		DB: &DBconfig{
 			Host:       getEnv("DB_HOST", "localhost"),
@@ -88,6 +273,15 @@ func New() (*Config, error) {
 			Password:   getEnv("DB_PASSWORD", "ridehail_pass"),
 			Database:   getEnv("DB_NAME", "ridehail_db"),
 			MaxRetries: getEnvInt("DB_MAX_RETRIES", 5),
+
+			MaxConns:               getEnvInt("DB_MAX_CONNS", 10),
+			MinConns:               getEnvInt("DB_MIN_CONNS", 2),
+			MaxConnLifetimeMinutes: getEnvInt("DB_MAX_CONN_LIFETIME_MINUTES", 60),
+			MaxConnIdleMinutes:     getEnvInt("DB_MAX_CONN_IDLE_MINUTES", 10),
+			QueryTimeoutMillis:     getEnvInt("DB_QUERY_TIMEOUT_MILLIS", 5000),
+			TxTimeoutMillis:        getEnvInt("DB_TX_TIMEOUT_MILLIS", 10000),
+			SlowQueryMillis:        getEnvInt("DB_SLOW_QUERY_MILLIS", 200),
+			StatsIntervalSeconds:   getEnvInt("DB_STATS_INTERVAL_SECONDS", 60),
 		},
 		RabbitMq: &RabbitMqconfig{
 			Host:       getEnv("RABBITMQ_HOST", "localhost"),
@@ -96,10 +290,16 @@ func New() (*Config, error) {
 			Password:   getEnv("RABBITMQ_PASSWORD", "admin"),
 			VHost:      getEnv("RABBITMQ_VHOST", "fake-taxi"),
 			MaxRetries: getEnvInt("RABBITMQ_MAX_RETRIES", 5),
+
+			ConfirmTimeoutMillis: getEnvInt("RABBITMQ_CONFIRM_TIMEOUT_MILLIS", 3000),
+			RetryBackoffMillis:   getEnvInt("RABBITMQ_RETRY_BACKOFF_MILLIS", 200),
 		},
 		WS: &WebSocketconfig{
 			Port:       getEnvInt("WS_PORT", 8080),
 			MaxRetries: getEnvInt("WS_MAX_RETRIES", 5),
+
+			InstanceID:       getEnv("WS_INSTANCE_ID", defaultInstanceID()),
+			HeartbeatSeconds: getEnvInt("WS_HEARTBEAT_SECONDS", 10),
 		},
 		Srv: &Serviceconfig{
 			RideServicePort:           getEnv("RIDE_SERVICE_PORT", "3000"),
@@ -111,15 +311,113 @@ func New() (*Config, error) {
 			Level: getEnv("LOG_LEVEL", "INFO"),
 		},
 		App: &App{
-			PublicJwtSecret: getEnv("PUBLIC_JWT", "default-public-secret"),
-			CertPath:        getEnv("CERT_PATH", "gay"),
-			CertKeyPath:     getEnv("CERT_KEY_PATH", "gay"),
+			CertPath:    getEnv("CERT_PATH", "server.crt"),
+			CertKeyPath: getEnv("CERT_KEY_PATH", "server.key"),
+		},
+		Password: &Passwordconfig{
+			Algorithm:      getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt"),
+			BcryptCost:     getEnvInt("PASSWORD_BCRYPT_COST", 10),
+			Argon2Time:     getEnvInt("PASSWORD_ARGON2_TIME", 1),
+			Argon2MemoryKB: getEnvInt("PASSWORD_ARGON2_MEMORY_KB", 64*1024),
+			Argon2Threads:  getEnvInt("PASSWORD_ARGON2_THREADS", 4),
+		},
+		Token: &Tokenconfig{
+			AccessTTLMinutes: getEnvInt("ACCESS_TOKEN_TTL_MINUTES", 15),
+			RefreshTTLHours:  getEnvInt("REFRESH_TOKEN_TTL_HOURS", 24*30),
+		},
+		Jwt: &JWTconfig{
+			KeysDir:          getEnv("JWT_KEYS_DIR", "keys"),
+			ActiveKid:        getEnv("JWT_ACTIVE_KID", ""),
+			ReloadSeconds:    getEnvInt("JWT_KEYS_RELOAD_SECONDS", 60),
+			JwksURL:          getEnv("JWKS_URL", "https://localhost:3010/.well-known/jwks.json"),
+			JwksServerName:   getEnv("JWKS_TLS_SERVER_NAME", "localhost"),
+			JwksCacheSeconds: getEnvInt("JWKS_CACHE_SECONDS", 300),
+		},
+		Matching: &Matchingconfig{
+			MatchSeconds: getEnvInt("MATCH_TIMEOUT_SECONDS", 120),
+			OfferSeconds: getEnvInt("MATCH_OFFER_SECONDS", 15),
+			WaveSize:     getEnvInt("MATCH_WAVE_SIZE", 3),
+			MaxAttempts:  getEnvInt("MATCH_MAX_ATTEMPTS", 5),
+		},
+		Ranking: &Rankingconfig{
+			WeightDistance:   getEnvFloat("RANK_WEIGHT_DISTANCE", 0.35),
+			WeightETA:        getEnvFloat("RANK_WEIGHT_ETA", 0.25),
+			WeightRating:     getEnvFloat("RANK_WEIGHT_RATING", 0.15),
+			WeightAcceptance: getEnvFloat("RANK_WEIGHT_ACCEPTANCE", 0.15),
+			WeightIdle:       getEnvFloat("RANK_WEIGHT_IDLE", 0.10),
+			RadiusMeters:     getEnvInt("RANK_RADIUS_METERS", 3000),
+			MaxRadiusMeters:  getEnvInt("RANK_MAX_RADIUS_METERS", 12000),
+			CandidateLimit:   getEnvInt("RANK_CANDIDATE_LIMIT", 10),
+			AvgSpeedKmh:      getEnvFloat("RANK_AVG_SPEED_KMH", 30),
+			MaxEtaMinutes:    getEnvFloat("RANK_MAX_ETA_MINUTES", 20),
+			IdleCapMinutes:   getEnvFloat("RANK_IDLE_CAP_MINUTES", 30),
+		},
+		Pricing: &Pricingconfig{
+			Currency:    getEnv("PRICING_CURRENCY", "KZT"),
+			AvgSpeedKmh: getEnvFloat("PRICING_AVG_SPEED_KMH", 30),
+			Tariffs: map[string]*Tariffconfig{
+				"ECONOMY": getTariff("ECONOMY", Tariffconfig{BaseFare: 500, PerKm: 100, PerMinute: 50, MinimumFare: 800, BookingFee: 0}),
+				"PREMIUM": getTariff("PREMIUM", Tariffconfig{BaseFare: 800, PerKm: 120, PerMinute: 60, MinimumFare: 1200, BookingFee: 0}),
+				"XL":      getTariff("XL", Tariffconfig{BaseFare: 1000, PerKm: 150, PerMinute: 75, MinimumFare: 1500, BookingFee: 0}),
+			},
+		},
+		Surge: &Surgeconfig{
+			GeohashPrecision: getEnvInt("SURGE_GEOHASH_PRECISION", 6),
+			Sensitivity:      getEnvFloat("SURGE_SENSITIVITY", 0.5),
+			MaxMultiplier:    getEnvFloat("SURGE_MAX_MULTIPLIER", 3.0),
+			MinDemand:        getEnvInt("SURGE_MIN_DEMAND", 3),
+			SmoothingSeconds: getEnvInt("SURGE_SMOOTHING_SECONDS", 300),
+		},
+		Quote: &Quoteconfig{
+			SigningSecret: getEnv("QUOTE_SIGNING_SECRET", ""),
+			TTLSeconds:    getEnvInt("QUOTE_TTL_SECONDS", 120),
+		},
+		Schedule: &Scheduleconfig{
+			LeadMinutes:  getEnvInt("SCHEDULE_LEAD_MINUTES", 15),
+			PollSeconds:  getEnvInt("SCHEDULE_POLL_SECONDS", 15),
+			MaxDaysAhead: getEnvInt("SCHEDULE_MAX_DAYS_AHEAD", 7),
+			GraceMinutes: getEnvInt("SCHEDULE_GRACE_MINUTES", 10),
+		},
+		Routing: &Routingconfig{
+			GraphPath:     getEnv("ROUTING_GRAPH_PATH", ""),
+			DetourFactor:  getEnvFloat("ROUTING_DETOUR_FACTOR", 1.3),
+			AvgSpeedKmh:   getEnvFloat("ROUTING_AVG_SPEED_KMH", 30),
+			PeakSpeedKmh:  getEnvFloat("ROUTING_PEAK_SPEED_KMH", 20),
+			NightSpeedKmh: getEnvFloat("ROUTING_NIGHT_SPEED_KMH", 45),
+			MaxSnapMeters: getEnvInt("ROUTING_MAX_SNAP_METERS", 500),
+		},
+		Outbox: &Outboxconfig{
+			PollMillis:            getEnvInt("OUTBOX_POLL_MILLIS", 500),
+			BatchSize:             getEnvInt("OUTBOX_BATCH_SIZE", 100),
+			LeaseSeconds:          getEnvInt("OUTBOX_LEASE_SECONDS", 30),
+			MaxBackoffSeconds:     getEnvInt("OUTBOX_MAX_BACKOFF_SECONDS", 300),
+			PublishTimeoutSeconds: getEnvInt("OUTBOX_PUBLISH_TIMEOUT_SECONDS", 5),
+		},
+		Inbox: &Inboxconfig{
+			RetentionHours:       getEnvInt("INBOX_RETENTION_HOURS", 14*24),
+			PurgeIntervalMinutes: getEnvInt("INBOX_PURGE_INTERVAL_MINUTES", 60),
+		},
+		Tracing: &Tracingconfig{
+			Dir:         getEnv("TRACING_DIR", "logs"),
+			SampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1.0),
 		},
 	}
 
 	return cnf, nil
 }
 
+// defaultInstanceID is the host name with a random suffix, so that every run
+// of a replica gets its own broker queue
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "ride-hail"
+	}
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
+
 // func NewFromYAML(path string) (*Config, error) {
 // 	data, err := os.ReadFile(path)
 // 	if err != nil {
@@ -0,0 +1,165 @@
+// Package confirm publishes to RabbitMQ and reports whether the broker took
+// the message. Channels run in confirm mode and every publish is mandatory,
+// so a message the broker drops comes back as ErrNacked and a message no
+// queue is bound for comes back as ErrUnroutable instead of being lost.
+package confirm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	// ErrClosed means the channel or connection is gone; reconnect and retry
+	ErrClosed = errors.New("rabbitmq channel is closed")
+	// ErrNacked means the broker refused the message
+	ErrNacked = errors.New("rabbitmq nacked the message")
+	// ErrConfirmTimeout means no confirm arrived in time; the message may or may not be stored
+	ErrConfirmTimeout = errors.New("rabbitmq confirm timed out")
+	// ErrUnroutable means no queue is bound for the routing key
+	ErrUnroutable = errors.New("rabbitmq returned the message as unroutable")
+)
+
+// ReturnedError is the basic.return of an unroutable message
+type ReturnedError struct {
+	Exchange   string
+	RoutingKey string
+	ReplyCode  uint16
+	ReplyText  string
+}
+
+func (e *ReturnedError) Error() string {
+	return fmt.Sprintf("message to %s/%s returned: %d %s", e.Exchange, e.RoutingKey, e.ReplyCode, e.ReplyText)
+}
+
+func (e *ReturnedError) Unwrap() error { return ErrUnroutable }
+
+// returnsBuffer holds the returns of publishes that gave up waiting
+const returnsBuffer = 64
+
+// Channel publishes one message at a time and waits for its confirm. The
+// broker sends basic.return before the ack of the same message, so once the
+// ack is in, the return, if any, is already buffered.
+type Channel struct {
+	mu      sync.Mutex
+	ch      *amqp.Channel
+	returns chan amqp.Return
+	timeout time.Duration
+}
+
+// NewChannel puts ch into confirm mode; timeout bounds the wait for a confirm
+func NewChannel(ch *amqp.Channel, timeout time.Duration) (*Channel, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("enable publisher confirms: %w", err)
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &Channel{
+		ch:      ch,
+		returns: ch.NotifyReturn(make(chan amqp.Return, returnsBuffer)),
+		timeout: timeout,
+	}, nil
+}
+
+// Publish sends msg as mandatory and returns once the broker confirmed it.
+// A message without a MessageId gets a random one.
+func (c *Channel) Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ch.IsClosed() {
+		return ErrClosed
+	}
+	if msg.MessageId == "" {
+		msg.MessageId = NewMessageID()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	confirm, err := c.ch.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, true, false, msg)
+	if err != nil {
+		if errors.Is(err, amqp.ErrClosed) {
+			return ErrClosed
+		}
+		return fmt.Errorf("publish to %s/%s: %w", exchange, routingKey, err)
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		if c.ch.IsClosed() {
+			return ErrClosed
+		}
+		return fmt.Errorf("%w: %s/%s", ErrConfirmTimeout, exchange, routingKey)
+	}
+	if !acked {
+		return fmt.Errorf("%w: %s/%s", ErrNacked, exchange, routingKey)
+	}
+
+	return c.returned(msg.MessageId)
+}
+
+// returned drains the buffered returns, dropping those of earlier publishes
+func (c *Channel) returned(messageID string) error {
+	for {
+		select {
+		case r, ok := <-c.returns:
+			if !ok {
+				return nil
+			}
+			if r.MessageId == messageID {
+				return &ReturnedError{Exchange: r.Exchange, RoutingKey: r.RoutingKey, ReplyCode: r.ReplyCode, ReplyText: r.ReplyText}
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// Retryable reports whether publishing the same message again may succeed.
+// An unroutable message stays unroutable until someone binds a queue.
+func Retryable(err error) bool {
+	return err != nil && !errors.Is(err, ErrUnroutable) && !errors.Is(err, context.Canceled)
+}
+
+// Retry calls publish until it succeeds, fails for good or attempts run out,
+// doubling the pause from base after every failure
+func Retry(ctx context.Context, attempts int, base time.Duration, publish func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = publish(); !Retryable(err) {
+			return err
+		}
+		if i == attempts-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(base << i):
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+}
+
+// NewMessageID is a random id for messages that are not stored anywhere first;
+// set it once and reuse it when retrying so consumers can drop duplicates
+func NewMessageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
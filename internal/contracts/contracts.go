@@ -0,0 +1,110 @@
+// Package contracts holds the broker messages exchanged between the services.
+// Every message travels in an Envelope naming its type and version; Decode
+// rejects a message of another type or version and checks the payload
+// against the JSON Schema generated from its Go type, so producer and
+// consumer can no longer drift apart field by field.
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// producers
+const (
+	ProducerRideService           = "ride-service"
+	ProducerDriverLocationService = "driver-location-service"
+)
+
+var (
+	ErrInvalidEnvelope    = errors.New("invalid message envelope")
+	ErrUnexpectedType     = errors.New("unexpected message type")
+	ErrUnsupportedVersion = errors.New("unsupported message version")
+	ErrInvalidMessage     = errors.New("message does not match its schema")
+)
+
+// Message is a payload with a stable type name and a version; a change that
+// breaks consumers gets a new version
+type Message interface {
+	MessageType() string
+	MessageVersion() int
+}
+
+type Envelope struct {
+	Type          string          `json:"type"`
+	Version       int             `json:"version"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Producer      string          `json:"producer"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// Wrap validates msg and puts it in an envelope stamped with the current time
+func Wrap(producer, correlationID string, msg Message) (Envelope, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("marshal %s: %w", msg.MessageType(), err)
+	}
+	if err := validateData(msg, data); err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{
+		Type:          msg.MessageType(),
+		Version:       msg.MessageVersion(),
+		CorrelationID: correlationID,
+		Producer:      producer,
+		Timestamp:     time.Now().UTC(),
+		Data:          data,
+	}, nil
+}
+
+// Encode is Wrap followed by json.Marshal
+func Encode(producer, correlationID string, msg Message) ([]byte, error) {
+	env, err := Wrap(producer, correlationID, msg)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+// Decode reads an envelope of msg's type and version into msg. Errors wrap
+// ErrInvalidEnvelope, ErrUnexpectedType, ErrUnsupportedVersion or
+// ErrInvalidMessage; none of them goes away on redelivery.
+func Decode(body []byte, msg Message) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	if env.Type != msg.MessageType() {
+		return env, fmt.Errorf("%w: got %q, want %q", ErrUnexpectedType, env.Type, msg.MessageType())
+	}
+	if env.Version != msg.MessageVersion() {
+		return env, fmt.Errorf("%w: %s v%d, want v%d", ErrUnsupportedVersion, env.Type, env.Version, msg.MessageVersion())
+	}
+
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return env, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	if err := EnvelopeSchema(msg).validate(doc, ""); err != nil {
+		return env, fmt.Errorf("%w: %s v%d: %v", ErrInvalidMessage, env.Type, env.Version, err)
+	}
+
+	if err := json.Unmarshal(env.Data, msg); err != nil {
+		return env, fmt.Errorf("%w: %s v%d: %v", ErrInvalidMessage, env.Type, env.Version, err)
+	}
+	return env, nil
+}
+
+func validateData(msg Message, data []byte) error {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if err := DataSchema(msg).validate(doc, "data"); err != nil {
+		return fmt.Errorf("%w: %s v%d: %v", ErrInvalidMessage, msg.MessageType(), msg.MessageVersion(), err)
+	}
+	return nil
+}
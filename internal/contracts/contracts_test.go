@@ -0,0 +1,155 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func validStatus() DriverStatusChanged {
+	return DriverStatusChanged{DriverID: "driver-1", RideID: "ride-1", Status: "ARRIVED"}
+}
+
+// encoded returns a valid driver.status message with edit applied to its
+// decoded JSON, so each case breaks exactly one thing
+func encoded(t *testing.T, edit func(env, data map[string]any)) []byte {
+	t.Helper()
+	body, err := Encode(ProducerDriverLocationService, "corr-1", validStatus())
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	var env map[string]any
+	if err := json.Unmarshal(body, &env); err != nil {
+		t.Fatal(err)
+	}
+	edit(env, env["data"].(map[string]any))
+
+	body, err = json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestDecode(t *testing.T) {
+	body := encoded(t, func(env, data map[string]any) {})
+
+	var got DriverStatusChanged
+	env, err := Decode(body, &got)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got != validStatus() {
+		t.Errorf("Decode() message = %+v, want %+v", got, validStatus())
+	}
+	if env.Type != "driver.status" || env.Version != 1 || env.Producer != ProducerDriverLocationService || env.CorrelationID != "corr-1" {
+		t.Errorf("Decode() envelope = %+v", env)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(env, data map[string]any)
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name:    "wrong type",
+			edit:    func(env, data map[string]any) { env["type"] = "ride.status" },
+			wantErr: ErrUnexpectedType,
+			wantMsg: `got "ride.status", want "driver.status"`,
+		},
+		{
+			name:    "newer version",
+			edit:    func(env, data map[string]any) { env["version"] = 2 },
+			wantErr: ErrUnsupportedVersion,
+			wantMsg: "driver.status v2, want v1",
+		},
+		{
+			name:    "missing required field",
+			edit:    func(env, data map[string]any) { delete(data, "driver_id") },
+			wantErr: ErrInvalidMessage,
+			wantMsg: "data.driver_id: is required",
+		},
+		{
+			name:    "null required field",
+			edit:    func(env, data map[string]any) { data["status"] = nil },
+			wantErr: ErrInvalidMessage,
+			wantMsg: "data.status: is required",
+		},
+		{
+			name:    "value outside the enum",
+			edit:    func(env, data map[string]any) { data["status"] = "SLEEPING" },
+			wantErr: ErrInvalidMessage,
+			wantMsg: `data.status: "SLEEPING" is not one of`,
+		},
+		{
+			name:    "field of the wrong type",
+			edit:    func(env, data map[string]any) { data["driver_id"] = 42 },
+			wantErr: ErrInvalidMessage,
+			wantMsg: "data.driver_id: must be a string",
+		},
+		{
+			name:    "missing producer",
+			edit:    func(env, data map[string]any) { delete(env, "producer") },
+			wantErr: ErrInvalidMessage,
+			wantMsg: "producer: is required",
+		},
+		{
+			name:    "malformed timestamp",
+			edit:    func(env, data map[string]any) { env["timestamp"] = "yesterday" },
+			wantErr: ErrInvalidEnvelope,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg DriverStatusChanged
+			_, err := Decode(encoded(t, tt.edit), &msg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Decode() error = %q, want it to mention %q", err, tt.wantMsg)
+			}
+		})
+	}
+
+	var msg DriverStatusChanged
+	if _, err := Decode([]byte("not json"), &msg); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("Decode() of garbage error = %v, want ErrInvalidEnvelope", err)
+	}
+}
+
+func TestWrapValidates(t *testing.T) {
+	msg := validStatus()
+	msg.Status = "SLEEPING"
+	if _, err := Wrap(ProducerDriverLocationService, "", msg); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Wrap() error = %v, want ErrInvalidMessage", err)
+	}
+
+	nested := RideRequested{
+		RideID:              "ride-1",
+		RideNumber:          "RIDE_1",
+		PickupLocation:      Location{Lat: 43.2, Lng: 76.9},
+		DestinationLocation: Location{Lat: 43.3, Lng: 76.8},
+		Stops:               []Location{{Lat: 43.25, Lng: 76.85}},
+		RideType:            "ECONOMY",
+		EstimatedFare:       1500,
+		MaxDistanceKm:       5,
+		TimeoutSeconds:      30,
+	}
+	body, err := Encode(ProducerRideService, "", nested)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var got RideRequested
+	if _, err := Decode(body, &got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.RideID != nested.RideID || len(got.Stops) != 1 || got.Stops[0] != nested.Stops[0] {
+		t.Errorf("Decode() = %+v, want %+v", got, nested)
+	}
+}
@@ -0,0 +1,139 @@
+package contracts
+
+// A field without omitempty is required by the schema of its message.
+
+type Location struct {
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	Address string  `json:"address,omitempty"`
+}
+
+type Vehicle struct {
+	Make  string `json:"make"`
+	Model string `json:"model"`
+	Color string `json:"color"`
+	Plate string `json:"plate"`
+}
+
+type DriverInfo struct {
+	Name    string  `json:"name"`
+	Rating  float64 `json:"rating"`
+	Vehicle Vehicle `json:"vehicle"`
+}
+
+// RideRequested asks for a driver: ride_topic → ride.request.{ride_type}.
+// A request no driver took is parked with the same body.
+type RideRequested struct {
+	RideID              string     `json:"ride_id"`
+	RideNumber          string     `json:"ride_number"`
+	PickupLocation      Location   `json:"pickup_location"`
+	DestinationLocation Location   `json:"destination_location"`
+	Stops               []Location `json:"stops,omitempty"`
+	RideType            string     `json:"ride_type" enum:"ECONOMY,PREMIUM,XL"`
+	EstimatedFare       float64    `json:"estimated_fare"`
+	MaxDistanceKm       float64    `json:"max_distance_km"`
+	TimeoutSeconds      int        `json:"timeout_seconds"`
+	Priority            int        `json:"priority,omitempty"`
+}
+
+func (RideRequested) MessageType() string { return "ride.request" }
+func (RideRequested) MessageVersion() int { return 1 }
+
+// RideStatusChanged: ride_topic → ride.status.{status}. FinalFare is what the
+// driver is paid for a completed or cancelled ride.
+type RideStatusChanged struct {
+	RideID    string  `json:"ride_id"`
+	Status    string  `json:"status" enum:"REQUESTED,MATCHED,EN_ROUTE,ARRIVED,IN_PROGRESS,COMPLETED,CANCELLED"`
+	DriverID  string  `json:"driver_id,omitempty"`
+	FinalFare float64 `json:"final_fare,omitempty"`
+}
+
+func (RideStatusChanged) MessageType() string { return "ride.status" }
+func (RideStatusChanged) MessageVersion() int { return 1 }
+
+// DestinationChangeRequested: ride_topic → ride.destination.{ride_id}
+type DestinationChangeRequested struct {
+	ChangeID       string   `json:"change_id"`
+	RideID         string   `json:"ride_id"`
+	DriverID       string   `json:"driver_id"`
+	NewDestination Location `json:"new_destination"`
+	DistanceKm     float64  `json:"distance_km"`
+	OldFare        float64  `json:"old_fare"`
+	NewFare        float64  `json:"new_fare"`
+	ExpiresAt      string   `json:"expires_at" format:"date-time"`
+}
+
+func (DestinationChangeRequested) MessageType() string { return "ride.destination" }
+func (DestinationChangeRequested) MessageVersion() int { return 1 }
+
+// DestinationChangeResolved is the outcome of the driver's answer, sent back
+// to the driver: ride_topic → ride.destination.{ride_id}
+type DestinationChangeResolved struct {
+	ChangeID string `json:"change_id"`
+	RideID   string `json:"ride_id"`
+	DriverID string `json:"driver_id"`
+	Status   string `json:"status" enum:"ACCEPTED,REJECTED,EXPIRED"`
+}
+
+func (DestinationChangeResolved) MessageType() string { return "ride.destination.result" }
+func (DestinationChangeResolved) MessageVersion() int { return 1 }
+
+// DriverResponse is the driver who accepted a ride: driver_topic → driver.response.{driver_id}
+type DriverResponse struct {
+	RideID                  string     `json:"ride_id"`
+	DriverID                string     `json:"driver_id"`
+	Accepted                bool       `json:"accepted"`
+	EstimatedArrivalMinutes int        `json:"estimated_arrival_minutes"`
+	DriverLocation          Location   `json:"driver_location"`
+	DriverInfo              DriverInfo `json:"driver_info"`
+}
+
+func (DriverResponse) MessageType() string { return "driver.response" }
+func (DriverResponse) MessageVersion() int { return 1 }
+
+// DriverStatusChanged: driver_topic → driver.status.{driver_id}
+type DriverStatusChanged struct {
+	DriverID string `json:"driver_id"`
+	RideID   string `json:"ride_id,omitempty"`
+	Status   string `json:"status" enum:"OFFLINE,AVAILABLE,BUSY,EN_ROUTE,ARRIVED,COMPLETED"`
+}
+
+func (DriverStatusChanged) MessageType() string { return "driver.status" }
+func (DriverStatusChanged) MessageVersion() int { return 1 }
+
+// DestinationChangeAnswered: driver_topic → driver.destination.{driver_id}
+type DestinationChangeAnswered struct {
+	ChangeID string `json:"change_id"`
+	RideID   string `json:"ride_id"`
+	DriverID string `json:"driver_id"`
+	Accepted bool   `json:"accepted"`
+}
+
+func (DestinationChangeAnswered) MessageType() string { return "driver.destination" }
+func (DestinationChangeAnswered) MessageVersion() int { return 1 }
+
+// LocationUpdated: location_fanout; RideID is empty while the driver has no ride
+type LocationUpdated struct {
+	DriverID       string   `json:"driver_id"`
+	RideID         string   `json:"ride_id,omitempty"`
+	Location       Location `json:"location"`
+	SpeedKmh       float64  `json:"speed_kmh,omitempty"`
+	HeadingDegrees float64  `json:"heading_degrees,omitempty"`
+}
+
+func (LocationUpdated) MessageType() string { return "location.update" }
+func (LocationUpdated) MessageVersion() int { return 1 }
+
+// All is every message in its current version
+func All() []Message {
+	return []Message{
+		RideRequested{},
+		RideStatusChanged{},
+		DestinationChangeRequested{},
+		DestinationChangeResolved{},
+		DriverResponse{},
+		DriverStatusChanged{},
+		DestinationChangeAnswered{},
+		LocationUpdated{},
+	}
+}
@@ -0,0 +1,209 @@
+package contracts
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema the contracts need. Objects accept
+// properties they do not know, so a field can be added without a new version.
+type Schema struct {
+	Schema     string             `json:"$schema,omitempty"`
+	ID         string             `json:"$id,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Const      any                `json:"const,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+}
+
+var (
+	schemasMu sync.Mutex
+	schemas   = map[reflect.Type]*Schema{}
+)
+
+// SchemaName is the file name of the message's envelope schema, e.g. ride.status.v1.json
+func SchemaName(msg Message) string {
+	return fmt.Sprintf("%s.v%d.json", msg.MessageType(), msg.MessageVersion())
+}
+
+// DataSchema describes the payload of msg
+func DataSchema(msg Message) *Schema {
+	t := reflect.TypeOf(msg)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	if s, ok := schemas[t]; ok {
+		return s
+	}
+	s := schemaOf(t)
+	schemas[t] = s
+	return s
+}
+
+// EnvelopeSchema describes a whole message of msg's type and version
+func EnvelopeSchema(msg Message) *Schema {
+	return &Schema{
+		Schema: schemaDraft,
+		ID:     SchemaName(msg),
+		Title:  msg.MessageType(),
+		Type:   "object",
+		Properties: map[string]*Schema{
+			"type":           {Type: "string", Const: msg.MessageType()},
+			"version":        {Type: "integer", Const: msg.MessageVersion()},
+			"correlation_id": {Type: "string"},
+			"producer":       {Type: "string"},
+			"timestamp":      {Type: "string", Format: "date-time"},
+			"data":           DataSchema(msg),
+		},
+		Required: []string{"data", "producer", "timestamp", "type", "version"},
+	}
+}
+
+func schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+
+			prop := schemaOf(f.Type)
+			if enum := f.Tag.Get("enum"); enum != "" {
+				prop.Enum = strings.Split(enum, ",")
+			}
+			if format := f.Tag.Get("format"); format != "" {
+				prop.Format = format
+			}
+			s.Properties[name] = prop
+			if !slices.Contains(strings.Split(opts, ","), "omitempty") {
+				s.Required = append(s.Required, name)
+			}
+		}
+		slices.Sort(s.Required)
+		return s
+	default:
+		return &Schema{}
+	}
+}
+
+// validate checks a value decoded by encoding/json into any
+func (s *Schema) validate(v any, path string) error {
+	if s.Const != nil && fmt.Sprint(v) != fmt.Sprint(s.Const) {
+		return fmt.Errorf("%s: must be %v", fieldPath(path), s.Const)
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an object", fieldPath(path))
+		}
+		for _, name := range s.Required {
+			if val, ok := obj[name]; !ok || val == nil {
+				return fmt.Errorf("%s: is required", fieldPath(join(path, name)))
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(s.Properties)) {
+			if val, ok := obj[name]; ok && val != nil {
+				if err := s.Properties[name].validate(val, join(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an array", fieldPath(path))
+		}
+		for i, item := range arr {
+			if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string", fieldPath(path))
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %s", fieldPath(path), str, strings.Join(s.Enum, ", "))
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fmt.Errorf("%s: must be an RFC 3339 date-time", fieldPath(path))
+			}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: must be an integer", fieldPath(path))
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: must be a number", fieldPath(path))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: must be a boolean", fieldPath(path))
+		}
+	}
+	return nil
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func fieldPath(path string) string {
+	if path == "" {
+		return "message"
+	}
+	return path
+}
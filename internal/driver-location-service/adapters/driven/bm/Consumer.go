@@ -14,6 +14,7 @@ import (
 const (
 	bindRideRequest = "ride.request.*"
 	bindRideStatus  = "ride.status.*"
+	bindRideDest    = "ride.destination.*"
 )
 
 type Consumer struct {
@@ -33,7 +34,7 @@ func NewConsumer(ctx context.Context, broker driven.IDriverBroker, log mylogger.
 	}
 }
 
-func (c *Consumer) ListenAll() (<-chan amqp.Delivery, <-chan amqp.Delivery, error) {
+func (c *Consumer) ListenAll() (<-chan amqp.Delivery, <-chan amqp.Delivery, <-chan amqp.Delivery, error) {
 	reqMsgs, err := c.broker.Consume(
 		c.ctx,
 		"ride_requests",
@@ -41,7 +42,7 @@ func (c *Consumer) ListenAll() (<-chan amqp.Delivery, <-chan amqp.Delivery, erro
 		driven.ConsumeOptions{Prefetch: 20, AutoAck: false, QueueDurable: true},
 	)
 	if err != nil {
-		return nil, nil, fmt.Errorf("consume ride.request: %w", err)
+		return nil, nil, nil, fmt.Errorf("consume ride.request: %w", err)
 	}
 
 	statusMsgs, err := c.broker.Consume(
@@ -51,8 +52,18 @@ func (c *Consumer) ListenAll() (<-chan amqp.Delivery, <-chan amqp.Delivery, erro
 		driven.ConsumeOptions{Prefetch: 20, AutoAck: false, QueueDurable: true},
 	)
 	if err != nil {
-		return nil, nil, fmt.Errorf("consume ride.status: %w", err)
+		return nil, nil, nil, fmt.Errorf("consume ride.status: %w", err)
 	}
-	c.log.Info("Consumers started for ride.request.* and ride.status.*")
-	return reqMsgs, statusMsgs, nil
+
+	destMsgs, err := c.broker.Consume(
+		c.ctx,
+		"ride_destination",
+		bindRideDest,
+		driven.ConsumeOptions{Prefetch: 20, AutoAck: false, QueueDurable: true},
+	)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("consume ride.destination: %w", err)
+	}
+	c.log.Info("Consumers started for ride.request.*, ride.status.* and ride.destination.*")
+	return reqMsgs, statusMsgs, destMsgs, nil
 }
@@ -9,8 +9,11 @@ import (
 	"time"
 
 	"ride-hail/internal/config"
+	"ride-hail/internal/confirm"
 	ports "ride-hail/internal/driver-location-service/core/ports/driven"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/tracing"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -26,6 +29,7 @@ type RabbitMQ struct {
 	log          mylogger.Logger
 	conn         *amqp.Connection
 	ch           *amqp.Channel
+	pub          *confirm.Channel
 	reconnecting bool
 	mu           *sync.Mutex
 	Messages     chan amqp.Delivery
@@ -47,30 +51,90 @@ func New(ctx context.Context, rabbitmqCfg config.RabbitMqconfig, log mylogger.Lo
 	return r, nil
 }
 
-func (r *RabbitMQ) PublishJSON(ctx context.Context, exchange, routingKey string, msg any) error {
-	if !r.IsAlive() {
-		r.log.Action("publish").Error("amqp not alive", errors.New("amqp closed"))
-		go r.reconnect(r.ctx)
-		return errors.New("amqp closed")
-	}
-	r.log.Action("publish").Info("publishing message to exchange %s with routing key %s", exchange, routingKey)
-	// if err := r.ensureExchange(exchange); err != nil {
-	// 	return fmt.Errorf("declare exchange: %w", err)
-	// }
+// PublishJSON публикует объект как JSON и ждёт подтверждения брокера.
+// Временные ошибки повторяются с экспоненциальной паузой, недоставляемое
+// сообщение (basic.return) не повторяется и возвращается как confirm.ErrUnroutable.
+// Трассировка ctx передаётся в заголовках.
+func (r *RabbitMQ) PublishJSON(ctx context.Context, exchange, routingKey string, msg any) (err error) {
+	l := r.log.Action("publish").With("exchange", exchange, "routing_key", routingKey)
 	body, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("marshal: %w", err)
 	}
-	pubctx, cancel := context.WithTimeout(ctx, 3*time.Second)
-	defer cancel()
-	r.log.Action("publish").Info("message marshaled, publishing now")
-	return r.ch.PublishWithContext(pubctx, exchange, routingKey, false, false, amqp.Publishing{
-		ContentType:  "application/json",
-		DeliveryMode: amqp.Persistent,
-		Body:         body,
+	ctx, span := tracing.StartProducer(ctx, exchange, routingKey)
+	defer func() { tracing.End(span, err) }()
+
+	// один id на все попытки, чтобы потребитель отбросил дубликат
+	pub := amqp.Publishing{
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     confirm.NewMessageID(),
+		CorrelationId: tracing.CorrelationID(ctx),
+		Headers:       tracing.Inject(ctx, nil),
+		Body:          body,
+	}
+	backoff := time.Duration(r.cfg.RetryBackoffMillis) * time.Millisecond
+	err = confirm.Retry(ctx, r.cfg.MaxRetries, backoff, func() error {
+		return r.publish(ctx, exchange, routingKey, pub)
+	})
+	if err != nil {
+		l.Error("publish failed", err)
+		return err
+	}
+	return nil
+}
+
+// PublishConfirmed ждёт подтверждения брокера, чтобы relay отметил сообщение
+// отправленным только после того, как RabbitMQ его принял. Повторы делает relay.
+func (r *RabbitMQ) PublishConfirmed(ctx context.Context, m outbox.Message) error {
+	return r.publish(ctx, m.Exchange, m.RoutingKey, amqp.Publishing{
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		Priority:      m.Priority,
+		MessageId:     m.ID,
+		CorrelationId: tracing.CorrelationID(ctx),
+		Headers:       tracing.Inject(ctx, nil),
+		Timestamp:     m.CreatedAt,
+		Body:          m.Payload,
 	})
 }
 
+// Republish отправляет сообщение как есть, с его MessageId и заголовками,
+// повторяя временные ошибки как PublishJSON. Трассировка в заголовках
+// продолжается от ctx.
+func (r *RabbitMQ) Republish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) (err error) {
+	if msg.MessageId == "" {
+		msg.MessageId = confirm.NewMessageID()
+	}
+	ctx, span := tracing.StartProducer(ctx, exchange, routingKey)
+	defer func() { tracing.End(span, err) }()
+	msg.Headers = tracing.Inject(ctx, msg.Headers)
+
+	backoff := time.Duration(r.cfg.RetryBackoffMillis) * time.Millisecond
+	err = confirm.Retry(ctx, r.cfg.MaxRetries, backoff, func() error {
+		return r.publish(ctx, exchange, routingKey, msg)
+	})
+	if err != nil {
+		r.log.Action("republish").Error("republish failed", err, "exchange", exchange, "routing_key", routingKey)
+		return err
+	}
+	return nil
+}
+
+// publish — одна попытка; закрытый канал запускает переподключение
+func (r *RabbitMQ) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	if !r.IsAlive() {
+		r.log.Action("publish").Error("amqp not alive", confirm.ErrClosed)
+		go r.reconnect(r.ctx)
+		return confirm.ErrClosed
+	}
+	err := r.pub.Publish(ctx, exchange, routingKey, msg)
+	if errors.Is(err, confirm.ErrClosed) {
+		go r.reconnect(r.ctx)
+	}
+	return err
+}
+
 func (r *RabbitMQ) Consume(ctx context.Context, queueName, bindingKey string, opts ports.ConsumeOptions) (<-chan amqp.Delivery, error) {
 	if !r.IsAlive() {
 		return nil, errors.New("amqp closed")
@@ -137,6 +201,59 @@ func (r *RabbitMQ) Consume(ctx context.Context, queueName, bindingKey string, op
 	return out, nil
 }
 
+func (r *RabbitMQ) ConsumeInstance(ctx context.Context, exchange, instanceID string) (<-chan amqp.Delivery, error) {
+	if !r.IsAlive() {
+		return nil, errors.New("amqp closed")
+	}
+	if err := r.ch.ExchangeDeclare(exchange, "direct", true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("exchange declare: %w", err)
+	}
+	// очередь живёт, пока живо соединение экземпляра: после его падения
+	// сообщения для его водителей становятся недоставляемыми
+	q, err := r.ch.QueueDeclare(
+		exchange+"."+instanceID,
+		false, // durable
+		true,  // auto-delete
+		true,  // exclusive
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("queue declare: %w", err)
+	}
+	if err := r.ch.QueueBind(q.Name, instanceID, exchange, false, nil); err != nil {
+		return nil, fmt.Errorf("queue bind: %w", err)
+	}
+	deliveries, err := r.ch.Consume(
+		q.Name,
+		"",    // consumer tag
+		true,  // auto-ack
+		true,  // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("consume: %w", err)
+	}
+	out := make(chan amqp.Delivery)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-deliveries:
+				if !ok {
+					return
+				}
+				out <- m
+			}
+		}
+	}()
+	return out, nil
+}
+
 func (r *RabbitMQ) IsAlive() bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -176,8 +293,9 @@ func (r *RabbitMQ) connect() error {
 		_ = conn.Close()
 		return err
 	}
-	// включаем publisher confirms (ожидание можно добавить позже)
-	if err := ch.Confirm(false); err != nil {
+	// publisher confirms: каждая публикация ждёт ack/nack и basic.return
+	pub, err := confirm.NewChannel(ch, time.Duration(r.cfg.ConfirmTimeoutMillis)*time.Millisecond)
+	if err != nil {
 		_ = ch.Close()
 		_ = conn.Close()
 		return err
@@ -190,6 +308,7 @@ func (r *RabbitMQ) connect() error {
 	}
 	r.conn = conn
 	r.ch = ch
+	r.pub = pub
 	return nil
 }
 
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ConnectionRegistry хранит экземпляр, держащий WebSocket водителя.
+// Запись без heartbeat дольше staleAfter считается записью упавшего экземпляра.
+type ConnectionRegistry struct {
+	db         *DataBase
+	staleAfter time.Duration
+}
+
+func NewConnectionRegistry(db *DataBase, staleAfter time.Duration) *ConnectionRegistry {
+	return &ConnectionRegistry{db: db, staleAfter: staleAfter}
+}
+
+func (cr *ConnectionRegistry) Register(ctx context.Context, driverID, instanceID string) error {
+	q := `
+		INSERT INTO driver_connections (driver_id, instance_id)
+		VALUES ($1, $2)
+		ON CONFLICT (driver_id) DO UPDATE
+			SET instance_id = EXCLUDED.instance_id, connected_at = NOW(), last_seen_at = NOW()
+	`
+	if _, err := cr.db.store.Exec(ctx, q, driverID, instanceID); err != nil {
+		if err2 := cr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to register driver connection: %w", err)
+	}
+	return nil
+}
+
+// Unregister не трогает запись, если водитель уже переподключился к другому экземпляру
+func (cr *ConnectionRegistry) Unregister(ctx context.Context, driverID, instanceID string) error {
+	q := `DELETE FROM driver_connections WHERE driver_id = $1 AND instance_id = $2`
+	if _, err := cr.db.store.Exec(ctx, q, driverID, instanceID); err != nil {
+		if err2 := cr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to unregister driver connection: %w", err)
+	}
+	return nil
+}
+
+func (cr *ConnectionRegistry) Heartbeat(ctx context.Context, instanceID string) error {
+	q := `UPDATE driver_connections SET last_seen_at = NOW() WHERE instance_id = $1`
+	if _, err := cr.db.store.Exec(ctx, q, instanceID); err != nil {
+		if err2 := cr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to refresh driver connections: %w", err)
+	}
+	return nil
+}
+
+func (cr *ConnectionRegistry) UnregisterInstance(ctx context.Context, instanceID string) error {
+	q := `DELETE FROM driver_connections WHERE instance_id = $1`
+	if _, err := cr.db.store.Exec(ctx, q, instanceID); err != nil {
+		if err2 := cr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to unregister instance connections: %w", err)
+	}
+	return nil
+}
+
+func (cr *ConnectionRegistry) Locate(ctx context.Context, driverID string) (string, bool, error) {
+	q := `
+		SELECT instance_id
+		FROM driver_connections
+		WHERE driver_id = $1 AND last_seen_at > NOW() - make_interval(secs => $2)
+	`
+	instanceID := ""
+	err := cr.db.store.QueryRow(ctx, q, driverID, cr.staleAfter.Seconds()).Scan(&instanceID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		if err2 := cr.db.IsAlive(); err2 != nil {
+			return "", false, err2
+		}
+		return "", false, fmt.Errorf("failed to locate driver connection: %w", err)
+	}
+	return instanceID, true, nil
+}
+
+func (cr *ConnectionRegistry) CountConnected(ctx context.Context) (int, error) {
+	q := `SELECT COUNT(*) FROM driver_connections WHERE last_seen_at > NOW() - make_interval(secs => $1)`
+	count := 0
+	if err := cr.db.store.QueryRow(ctx, q, cr.staleAfter.Seconds()).Scan(&count); err != nil {
+		if err2 := cr.db.IsAlive(); err2 != nil {
+			return 0, err2
+		}
+		return 0, fmt.Errorf("failed to count driver connections: %w", err)
+	}
+	return count, nil
+}
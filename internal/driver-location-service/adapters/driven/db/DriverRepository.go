@@ -2,10 +2,15 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"ride-hail/internal/driver-location-service/core/domain/model"
+	"ride-hail/internal/inbox"
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/ridestate"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -19,7 +24,7 @@ func NewDriverRepository(db *DataBase) *DriverRepository {
 }
 
 func (dr *DriverRepository) GoOnline(ctx context.Context, coord model.DriverCoordinates) (string, error) {
-	conn := dr.db.conn
+	conn := dr.db.store
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		// Check if the database is alive
@@ -60,7 +65,7 @@ func (dr *DriverRepository) GoOnline(ctx context.Context, coord model.DriverCoor
 }
 
 func (dr *DriverRepository) GoOffline(ctx context.Context, driver_id string) (model.DriverOfflineResponse, error) {
-	conn := dr.db.conn
+	conn := dr.db.store
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		// Check if the database is alive
@@ -112,8 +117,7 @@ func (dr *DriverRepository) GoOffline(ctx context.Context, driver_id string) (mo
 }
 
 func (dr *DriverRepository) UpdateLocation(ctx context.Context, driver_id string, newLocation model.NewLocation) (model.NewLocationResponse, error) {
-	// Get a fresh connection from the pool (if you're using pooling)
-	conn := dr.db.conn
+	conn := dr.db.store
 
 	// Start a new transaction
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
@@ -177,7 +181,7 @@ func (dr *DriverRepository) UpdateLocation(ctx context.Context, driver_id string
 }
 
 func (dr *DriverRepository) StartRide(ctx context.Context, requestData model.StartRide) (model.StartRideResponse, error) {
-	conn := dr.db.conn
+	conn := dr.db.store
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		// Check if the database is alive
@@ -214,8 +218,8 @@ func (dr *DriverRepository) StartRide(ctx context.Context, requestData model.Sta
 	return response, tx.Commit(ctx)
 }
 
-func (dr *DriverRepository) StartRideTx(ctx context.Context, driverID, rideID string) (model.StartRideResponse, error) {
-	conn := dr.db.conn
+func (dr *DriverRepository) StartRideTx(ctx context.Context, driverID, rideID string, msgs ...outbox.Message) (model.StartRideResponse, error) {
+	conn := dr.db.store
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		// Check if the database is alive
@@ -237,6 +241,33 @@ func (dr *DriverRepository) StartRideTx(ctx context.Context, driverID, rideID st
 		return model.StartRideResponse{}, err
 	}
 
+	// Only the assigned driver may start the ride
+	const qOwner = `SELECT status FROM rides WHERE ride_id = $1 AND driver_id = $2 FOR UPDATE;`
+	var status string
+	if err = tx.QueryRow(ctx, qOwner, rideID, driverID).Scan(&status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.StartRideResponse{}, model.ErrRideNotFound
+		}
+		return model.StartRideResponse{}, err
+	}
+
+	// ARRIVED reaches the ride asynchronously from location updates, so it may
+	// not be there yet, or its message was lost; starting the ride implies it
+	if st := ridestate.Status(status); st == ridestate.Matched || st == ridestate.EnRoute {
+		payload := map[string]interface{}{"implied_by": "start_ride"}
+		if _, err = ridestate.Apply(ctx, tx, rideID, ridestate.Arrived, ridestate.ActorDriver, driverID, payload); err != nil {
+			return model.StartRideResponse{}, err
+		}
+	}
+
+	if _, err = ridestate.Apply(ctx, tx, rideID, ridestate.InProgress, ridestate.ActorDriver, driverID, nil); err != nil {
+		return model.StartRideResponse{}, err
+	}
+
+	if err = outbox.Insert(ctx, tx, outboxService, msgs...); err != nil {
+		return model.StartRideResponse{}, err
+	}
+
 	resp := model.StartRideResponse{
 		Ride_id:    rideID,
 		Status:     "BUSY",
@@ -257,7 +288,7 @@ func (dr *DriverRepository) GetDestinationAndDriverCoords(ctx context.Context, r
 		LIMIT 1;
 	`
 	var d float64
-	err := dr.db.GetConn().QueryRow(ctx, q, rideID, driverID).Scan(
+	err := dr.db.store.QueryRow(ctx, q, rideID, driverID).Scan(
 		&d,
 	)
 	if err != nil {
@@ -270,70 +301,99 @@ func (dr *DriverRepository) GetDestinationAndDriverCoords(ctx context.Context, r
 	return d, nil
 }
 
-func (dr *DriverRepository) FindDrivers(ctx context.Context, longtitude, latitude float64, vehicleType string) ([]model.DriverInfo, error) {
+// FindDrivers returns available drivers within radiusMeters of the point,
+// nearest first, with the stats the ranker needs
+func (dr *DriverRepository) FindDrivers(ctx context.Context, longtitude, latitude float64, vehicleType string, radiusMeters float64, limit int) ([]model.DriverInfo, error) {
 	Query := `
-	SELECT d.driver_id, d.email, d.username, d.vehicle_attrs, d.rating, c.latitude, c.longitude,
+	SELECT d.driver_id, d.email, d.username, d.vehicle_attrs, COALESCE(d.rating, 5.0), c.latitude, c.longitude,
        ST_Distance(
          ST_MakePoint(c.longitude, c.latitude)::geography,
          ST_MakePoint($1, $2)::geography
-       ) / 1000 as distance_km
+       ) / 1000 as distance_km,
+       COALESCE(lh.speed_kmh, 0),
+       d.offers_received,
+       d.offers_accepted,
+       EXTRACT(EPOCH FROM NOW() - GREATEST(
+         (SELECT MAX(r.completed_at) FROM rides r WHERE r.driver_id = d.driver_id),
+         (SELECT MAX(s.started_at) FROM driver_sessions s WHERE s.driver_id = d.driver_id AND s.ended_at IS NULL),
+         d.updated_at
+       ))::float8 as idle_seconds
 	FROM drivers d
 	JOIN coordinates c ON c.entity_id = d.driver_id
   		AND c.entity_type = 'DRIVER'
   		AND c.is_current = true
+	LEFT JOIN LATERAL (
+		SELECT speed_kmh
+		FROM location_history
+		WHERE driver_id = d.driver_id
+		ORDER BY recorded_at DESC
+		LIMIT 1
+	) lh ON true
 	WHERE d.status = 'AVAILABLE'
  		AND d.vehicle_type = $3
   		AND ST_DWithin(
         	ST_MakePoint(c.longitude, c.latitude)::geography,
         	ST_MakePoint($1, $2)::geography,
-        	5000  -- 5km radius
+        	$4
       	)
 	ORDER BY distance_km, d.rating DESC
-	LIMIT 10;
+	LIMIT $5;
 	`
-	rows, err := dr.db.GetConn().Query(ctx, Query, longtitude, latitude, vehicleType)
+	rows, err := dr.db.store.Query(ctx, Query, longtitude, latitude, vehicleType, radiusMeters, limit)
 	if err != nil {
 		// Check if the database is alive
 		if err2 := dr.db.IsAlive(); err2 != nil {
 			return nil, err2
 		}
-
-		fmt.Println("Repository Error Arrived ", err)
 		return []model.DriverInfo{}, err
 	}
+	defer rows.Close()
+
 	var result []model.DriverInfo
 	for rows.Next() {
 		var dInfo model.DriverInfo
-		err := rows.Scan(&dInfo.DriverId, &dInfo.Email, &dInfo.Name, &dInfo.Vehicle, &dInfo.Rating, &dInfo.Latitude, &dInfo.Longitude, &dInfo.Distance)
+		err := rows.Scan(
+			&dInfo.DriverId,
+			&dInfo.Email,
+			&dInfo.Name,
+			&dInfo.Vehicle,
+			&dInfo.Rating,
+			&dInfo.Latitude,
+			&dInfo.Longitude,
+			&dInfo.Distance,
+			&dInfo.SpeedKmh,
+			&dInfo.OffersReceived,
+			&dInfo.OffersAccepted,
+			&dInfo.IdleSeconds,
+		)
 		if err != nil {
-			fmt.Println("Repository Error Arrived ", err)
 			return []model.DriverInfo{}, err
 		}
-		fmt.Println("Reading rows", dInfo)
 		result = append(result, dInfo)
 	}
-	return result, nil
+	return result, rows.Err()
 }
 
-func (dr *DriverRepository) CalculateRideDetails(ctx context.Context, driverLocation model.Location, passagerLocation model.Location) (float64, error) {
-	q := `SELECT ST_Distance(ST_MakePoint($1, $2)::geography, ST_MakePoint($3, $4)::geography) / 1000 as distance_km`
-
-	db := dr.db.conn
-	row := db.QueryRow(ctx, q, driverLocation.Longitude, driverLocation.Latitude, passagerLocation.Longitude, passagerLocation.Latitude)
-	distance := 0.0
-	err := row.Scan(&distance)
-	if err != nil {
+// RecordOfferOutcome feeds the acceptance rate used for ranking
+func (dr *DriverRepository) RecordOfferOutcome(ctx context.Context, driverID string, accepted bool) error {
+	Query := `
+		UPDATE drivers
+		SET offers_received = offers_received + 1,
+		    offers_accepted = offers_accepted + CASE WHEN $2 THEN 1 ELSE 0 END
+		WHERE driver_id = $1;
+	`
+	if _, err := dr.db.store.Exec(ctx, Query, driverID, accepted); err != nil {
 		// Check if the database is alive
 		if err2 := dr.db.IsAlive(); err2 != nil {
-			return 0, err2
+			return err2
 		}
-		return 0.0, err
+		return err
 	}
-	return distance, nil
+	return nil
 }
 
 func (dr *DriverRepository) UpdateDriverStatus(ctx context.Context, driver_id string, status string) error {
-	conn := dr.db.conn
+	conn := dr.db.store
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		// Check if the database is alive
@@ -362,7 +422,7 @@ func (dr *DriverRepository) CheckDriverById(ctx context.Context, driver_id strin
 		SELECT EXISTS(SELECT 1 FROM drivers WHERE driver_id = $1);
 	`
 	var exists bool
-	err := dr.db.conn.QueryRow(ctx, Query, driver_id).Scan(&exists)
+	err := dr.db.store.QueryRow(ctx, Query, driver_id).Scan(&exists)
 	if err != nil {
 		// Check if the database is alive
 		if err2 := dr.db.IsAlive(); err2 != nil {
@@ -378,7 +438,7 @@ func (dr *DriverRepository) GetDriverIdByRideId(ctx context.Context, ride_id str
         SELECT driver_id FROM rides WHERE ride_id = $1;
     `
 	var driver_id *string // Use a pointer to string
-	err := dr.db.conn.QueryRow(ctx, Query, ride_id).Scan(&driver_id)
+	err := dr.db.store.QueryRow(ctx, Query, ride_id).Scan(&driver_id)
 	// Check for errors
 	if err != nil {
 		// Check if the database is alive
@@ -405,7 +465,7 @@ func (dr *DriverRepository) GetRideIdByDriverId(ctx context.Context, driver_id s
 		SELECT ride_id FROM rides WHERE driver_id = $1 AND status NOT IN ('CANCELLED', 'COMPLETED');
 	`
 	var ride_id string
-	err := dr.db.conn.QueryRow(ctx, Query, driver_id).Scan(&ride_id)
+	err := dr.db.store.QueryRow(ctx, Query, driver_id).Scan(&ride_id)
 	if err != nil {
 		// Check if the database is alive
 		if err2 := dr.db.IsAlive(); err2 != nil {
@@ -426,7 +486,7 @@ func (dr *DriverRepository) GetRideDetailsByRideId(ctx context.Context, ride_id
 		WHERE r.ride_id = $1;
 		`
 	var details model.RideDetails
-	err := dr.db.conn.QueryRow(ctx, Query, ride_id).Scan(
+	err := dr.db.store.QueryRow(ctx, Query, ride_id).Scan(
 		&details.Ride_id,
 		&details.PassengerName,
 		&details.PassengerAttrs,
@@ -441,15 +501,172 @@ func (dr *DriverRepository) GetRideDetailsByRideId(ctx context.Context, ride_id
 		}
 		return model.RideDetails{}, err
 	}
+
+	const qStops = `
+		SELECT stop_index, latitude, longitude, address, arrived_at
+		FROM ride_stops
+		WHERE ride_id = $1
+		ORDER BY stop_index;
+	`
+	rows, err := dr.db.store.Query(ctx, qStops, ride_id)
+	if err != nil {
+		return model.RideDetails{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stop model.RideStop
+		if err := rows.Scan(&stop.StopIndex, &stop.Location.Latitude, &stop.Location.Longitude, &stop.Location.Address, &stop.ArrivedAt); err != nil {
+			return model.RideDetails{}, err
+		}
+		details.Stops = append(details.Stops, stop)
+	}
+	if err := rows.Err(); err != nil {
+		return model.RideDetails{}, err
+	}
 	return details, nil
 }
 
+// ArriveAtStopTx отмечает промежуточную остановку как достигнутую.
+// Остановки проходятся по порядку; повторная отметка ничего не меняет.
+func (dr *DriverRepository) ArriveAtStopTx(ctx context.Context, driverID, rideID string, stopIndex int) (model.RideStop, int, error) {
+	conn := dr.db.store
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		// Check if the database is alive
+		if err2 := dr.db.IsAlive(); err2 != nil {
+			return model.RideStop{}, 0, err2
+		}
+		return model.RideStop{}, 0, err
+	}
+	defer tx.Rollback(ctx) // Safe rollback if not committed
+
+	// Only the assigned driver may report stops, and only during the ride
+	const qRide = `SELECT status FROM rides WHERE ride_id = $1 AND driver_id = $2 FOR UPDATE;`
+	var status string
+	if err = tx.QueryRow(ctx, qRide, rideID, driverID).Scan(&status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.RideStop{}, 0, model.ErrRideNotFound
+		}
+		return model.RideStop{}, 0, err
+	}
+	if status != string(ridestate.InProgress) {
+		return model.RideStop{}, 0, model.ErrNoActiveRide
+	}
+
+	const qStop = `
+		SELECT stop_index, latitude, longitude, address, arrived_at,
+		       (SELECT COUNT(*) FROM ride_stops p
+		        WHERE p.ride_id = s.ride_id AND p.stop_index < s.stop_index AND p.arrived_at IS NULL)
+		FROM ride_stops s
+		WHERE s.ride_id = $1 AND s.stop_index = $2;
+	`
+	var (
+		stop    model.RideStop
+		earlier int
+	)
+	err = tx.QueryRow(ctx, qStop, rideID, stopIndex).Scan(
+		&stop.StopIndex,
+		&stop.Location.Latitude,
+		&stop.Location.Longitude,
+		&stop.Location.Address,
+		&stop.ArrivedAt,
+		&earlier,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.RideStop{}, 0, model.ErrStopNotFound
+		}
+		return model.RideStop{}, 0, err
+	}
+
+	if stop.ArrivedAt == nil {
+		if earlier > 0 {
+			return model.RideStop{}, 0, model.ErrStopOutOfOrder
+		}
+
+		const qArrive = `
+			UPDATE ride_stops SET arrived_at = NOW()
+			WHERE ride_id = $1 AND stop_index = $2
+			RETURNING arrived_at;
+		`
+		if err = tx.QueryRow(ctx, qArrive, rideID, stopIndex).Scan(&stop.ArrivedAt); err != nil {
+			return model.RideStop{}, 0, err
+		}
+
+		event := ridestate.EventData{
+			Actor:      ridestate.ActorDriver,
+			ActorId:    driverID,
+			FromStatus: ridestate.InProgress,
+			ToStatus:   ridestate.InProgress,
+			Payload: map[string]interface{}{
+				"reason":     "stop_arrived",
+				"stop_index": stop.StopIndex,
+				"latitude":   stop.Location.Latitude,
+				"longitude":  stop.Location.Longitude,
+				"address":    stop.Location.Address,
+			},
+		}
+		if err = ridestate.InsertEvent(ctx, tx, rideID, ridestate.EventLocationUpdated, event); err != nil {
+			return model.RideStop{}, 0, err
+		}
+	}
+
+	const qRemaining = `SELECT COUNT(*) FROM ride_stops WHERE ride_id = $1 AND arrived_at IS NULL;`
+	var remaining int
+	if err = tx.QueryRow(ctx, qRemaining, rideID).Scan(&remaining); err != nil {
+		return model.RideStop{}, 0, err
+	}
+
+	return stop, remaining, tx.Commit(ctx)
+}
+
+// GetRidePricing returns what the final fare depends on besides the trip:
+// the vehicle type, the surge multiplier locked in when the ride was requested
+// and, for a ride priced from a quote, the agreed fare
+func (dr *DriverRepository) GetRidePricing(ctx context.Context, ride_id string) (model.RidePricing, error) {
+	Query := `
+		SELECT vehicle_type,
+		       COALESCE((fare_breakdown->>'surge_multiplier')::float8, 1),
+		       price_locked,
+		       COALESCE(estimated_fare, 0),
+		       fare_breakdown
+		FROM rides
+		WHERE ride_id = $1;
+	`
+	var (
+		p             model.RidePricing
+		estimatedFare float64
+		breakdown     []byte
+	)
+	if err := dr.db.store.QueryRow(ctx, Query, ride_id).Scan(&p.VehicleType, &p.SurgeMultiplier, &p.PriceLocked, &estimatedFare, &breakdown); err != nil {
+		// Check if the database is alive
+		if err2 := dr.db.IsAlive(); err2 != nil {
+			return model.RidePricing{}, err2
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.RidePricing{}, model.ErrRideNotFound
+		}
+		return model.RidePricing{}, err
+	}
+
+	if p.PriceLocked {
+		if len(breakdown) > 0 {
+			if err := json.Unmarshal(breakdown, &p.LockedFare); err != nil {
+				return model.RidePricing{}, fmt.Errorf("failed to unmarshal fare breakdown: %w", err)
+			}
+		}
+		// estimated_fare — то, что согласовал пассажир, в том числе при смене назначения
+		p.LockedFare.Total = estimatedFare
+	}
+	return p, nil
+}
+
 func (dr *DriverRepository) CheckDriverStatus(ctx context.Context, driver_id string) (string, error) {
 	Query := `
 		SELECT status FROM drivers WHERE driver_id = $1;
 	`
 	var status string
-	err := dr.db.conn.QueryRow(ctx, Query, driver_id).Scan(&status)
+	err := dr.db.store.QueryRow(ctx, Query, driver_id).Scan(&status)
 	if err != nil {
 		// Check if the database is alive
 		if err2 := dr.db.IsAlive(); err2 != nil {
@@ -466,10 +683,11 @@ func (dr *DriverRepository) HasActiveRide(ctx context.Context, driverID string)
             SELECT 1
             FROM rides
             WHERE driver_id = $1
-            AND status IN ('ARRIVED','IN_PROGRESS')
+            AND status = $2
         )`
 	var ok bool
-	if err := dr.db.GetConn().QueryRow(ctx, q, driverID).Scan(&ok); err != nil {
+	// ARRIVED is the state a ride is started from, so it does not count as active here
+	if err := dr.db.store.QueryRow(ctx, q, driverID, ridestate.InProgress).Scan(&ok); err != nil {
 		// Check if the database is alive
 		if err2 := dr.db.IsAlive(); err2 != nil {
 			return false, err2
@@ -491,7 +709,7 @@ func (dr *DriverRepository) GetPickupAndDriverCoords(ctx context.Context, rideID
 		WHERE r.ride_id = $1 AND d.driver_id = $2
 		LIMIT 1;
 	`
-	err = dr.db.GetConn().QueryRow(ctx, q, rideID, driverID).Scan(
+	err = dr.db.store.QueryRow(ctx, q, rideID, driverID).Scan(
 		&pickupLat, &pickupLng, &driverLat, &driverLng,
 	)
 	if err != nil {
@@ -509,8 +727,8 @@ func (dr *DriverRepository) CompleteRide(ctx context.Context, requestData model.
 	return dr.CompleteRideTx(ctx, requestData)
 }
 
-func (dr *DriverRepository) CompleteRideTx(ctx context.Context, requestData model.RideCompleteForm) (model.RideCompleteResponse, error) {
-	conn := dr.db.conn
+func (dr *DriverRepository) CompleteRideTx(ctx context.Context, requestData model.RideCompleteForm, msgs ...outbox.Message) (model.RideCompleteResponse, error) {
+	conn := dr.db.store
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		// Check if the database is alive
@@ -521,63 +739,74 @@ func (dr *DriverRepository) CompleteRideTx(ctx context.Context, requestData mode
 	}
 	defer tx.Rollback(ctx) // Safe rollback if not committed
 
-	// 1) убеждаемся, что ride принадлежит этому водителю и сейчас IN_PROGRESS
+	// 1) убеждаемся, что ride принадлежит этому водителю; статус проверяет ridestate
 	const qCheck = `
-		SELECT status, driver_id
+		SELECT COALESCE(driver_id::text, '')
 		FROM rides
 		WHERE ride_id = $1
 		FOR UPDATE;
 	`
-	var status string
 	var rideDriverID string
-	if err = tx.QueryRow(ctx, qCheck, requestData.Ride_id).Scan(&status, &rideDriverID); err != nil {
+	if err = tx.QueryRow(ctx, qCheck, requestData.Ride_id).Scan(&rideDriverID); err != nil {
 		if err == pgx.ErrNoRows {
-			return model.RideCompleteResponse{}, fmt.Errorf("ride not found")
+			return model.RideCompleteResponse{}, model.ErrRideNotFound
 		}
 		return model.RideCompleteResponse{}, err
 	}
 	if rideDriverID == "" || rideDriverID != requestData.FinalLocation.Driver_id {
 		return model.RideCompleteResponse{}, fmt.Errorf("ride driver mismatch")
 	}
-	if status != "IN_PROGRESS" {
-		return model.RideCompleteResponse{}, fmt.Errorf("invalid ride status: %s", status)
-	}
 
-	// 2) обновляем координаты destination фактическими (дистанция/длительность/координаты)
+	// 2) записываем фактические дистанцию и длительность. Координаты destination
+	// не трогаем: это точка, подтверждённая пассажиром (в том числе через смену
+	// назначения); где водитель завершил поездку, хранит событие RIDE_COMPLETED
 	const qUpdateDest = `
 		UPDATE coordinates
 		SET distance_km = $1,
 		    duration_minutes = $2,
-		    latitude = $3,
-		    longitude = $4,
 		    updated_at = NOW()
 		WHERE coord_id = (
-			SELECT destination_coord_id FROM rides WHERE ride_id = $5
+			SELECT destination_coord_id FROM rides WHERE ride_id = $3
 		);
 	`
 	if _, err = tx.Exec(ctx, qUpdateDest,
 		requestData.ActualDistancekm,
 		requestData.ActualDurationm,
-		requestData.FinalLocation.Latitude,
-		requestData.FinalLocation.Longitude,
 		requestData.Ride_id,
 	); err != nil {
 		return model.RideCompleteResponse{}, err
 	}
 
-	// 3) завершить поездку
-	const qRideDone = `
+	// 3) записываем финальную сумму, посчитанную PricingEngine
+	const qFare = `
 		UPDATE rides
-		SET status = 'COMPLETED',
-		    completed_at = NOW(),
-		    updated_at = NOW()
-		WHERE ride_id = $1;
+		SET final_fare = $1,
+		    fare_breakdown = $2
+		WHERE ride_id = $3;
 	`
-	if _, err = tx.Exec(ctx, qRideDone, requestData.Ride_id); err != nil {
+	earning := requestData.FinalFare.Total
+	breakdown, err := json.Marshal(requestData.FinalFare)
+	if err != nil {
+		return model.RideCompleteResponse{}, err
+	}
+	if _, err = tx.Exec(ctx, qFare, earning, breakdown, requestData.Ride_id); err != nil {
+		return model.RideCompleteResponse{}, err
+	}
+
+	// 4) завершить поездку (статус, completed_at и ride_events)
+	payload := map[string]interface{}{
+		"actual_distance_km":      requestData.ActualDistancekm,
+		"actual_duration_minutes": requestData.ActualDurationm,
+		"final_fare":              earning,
+		"fare_breakdown":          requestData.FinalFare,
+		"final_latitude":          requestData.FinalLocation.Latitude,
+		"final_longitude":         requestData.FinalLocation.Longitude,
+	}
+	if _, err = ridestate.Apply(ctx, tx, requestData.Ride_id, ridestate.Completed, ridestate.ActorDriver, rideDriverID, payload); err != nil {
 		return model.RideCompleteResponse{}, err
 	}
 
-	// 4) освободить водителя
+	// 5) освободить водителя
 	const qDriverAvail = `
 		UPDATE drivers
 		SET status = 'AVAILABLE',
@@ -588,10 +817,8 @@ func (dr *DriverRepository) CompleteRideTx(ctx context.Context, requestData mode
 		return model.RideCompleteResponse{}, err
 	}
 
-	// 5) забираем финальную сумму (final_fare) для ответа
-	const qFare = `SELECT COALESCE(final_fare, estimated_fare) FROM rides WHERE ride_id = $1`
-	var earning float64
-	if err = tx.QueryRow(ctx, qFare, requestData.Ride_id).Scan(&earning); err != nil {
+	// 6) сообщения о смене статуса уходят вместе с коммитом
+	if err = outbox.Insert(ctx, tx, outboxService, msgs...); err != nil {
 		return model.RideCompleteResponse{}, err
 	}
 
@@ -604,8 +831,14 @@ func (dr *DriverRepository) CompleteRideTx(ctx context.Context, requestData mode
 	}, tx.Commit(ctx)
 }
 
-func (dr *DriverRepository) PayDriverMoney(ctx context.Context, driver_id string, amount float64) error {
-	conn := dr.db.conn
+// consumerRideStatus — потребитель ride.status в processed_messages
+const consumerRideStatus = "driver-location-service.ride_status"
+
+// ApplyRideStatus применяет сообщение ride.status одной транзакцией: выплата,
+// смена статуса и сообщения outbox. Id сообщения записывается первым, поэтому
+// повторная доставка ничего не меняет и возвращает inbox.ErrAlreadyProcessed.
+func (dr *DriverRepository) ApplyRideStatus(ctx context.Context, messageID string, update model.DriverUpdate, msgs ...outbox.Message) error {
+	conn := dr.db.store
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		// Check if the database is alive
@@ -616,26 +849,46 @@ func (dr *DriverRepository) PayDriverMoney(ctx context.Context, driver_id string
 	}
 	defer tx.Rollback(ctx) // Safe rollback if not committed
 
-	Query := `
-		UPDATE drivers
-		SET total_earnings = total_earnings + $1
-		WHERE driver_id = $2;IsDriverNear(ctx context.Context, driver_id string) (bool, error)
-	`
-	_, err = tx.Exec(ctx, Query, amount, driver_id)
-	if err != nil {
+	if err := inbox.Claim(ctx, tx, consumerRideStatus, messageID); err != nil {
 		return err
 	}
 
-	SessionQuery := `aled, publishin
-	UPDATE driver_sessions
-	SET total_rides = total_rides + 1,
-	total_earnings = total_earnings + $1
-	WHERE driver_id = $2 AND ended_at = NULL;
-	`
-	_, err = tx.Exec(ctx, SessionQuery, amount, driver_id)
-	if err != nil {
+	if update.Payout > 0 {
+		const qPay = `
+			UPDATE drivers
+			SET total_earnings = total_earnings + $1
+			WHERE driver_id = $2;
+		`
+		if _, err = tx.Exec(ctx, qPay, update.Payout, update.DriverID); err != nil {
+			return err
+		}
+
+		const qSession = `
+			UPDATE driver_sessions
+			SET total_rides = total_rides + 1,
+			    total_earnings = total_earnings + $1
+			WHERE driver_id = $2 AND ended_at IS NULL;
+		`
+		if _, err = tx.Exec(ctx, qSession, update.Payout, update.DriverID); err != nil {
+			return err
+		}
+	}
+
+	if update.Status != "" {
+		const qStatus = `
+			UPDATE drivers
+			SET status = $1
+			WHERE driver_id = $2;
+		`
+		if _, err = tx.Exec(ctx, qStatus, update.Status, update.DriverID); err != nil {
+			return err
+		}
+	}
+
+	if err = outbox.Insert(ctx, tx, outboxService, msgs...); err != nil {
 		return err
 	}
+
 	return tx.Commit(ctx)
 }
 
@@ -644,7 +897,7 @@ func (dr *DriverRepository) SetAllOffline() error {
 		UPDATE drivers
 		SET status = 'OFFLINE';
 	`
-	_, err := dr.db.conn.Exec(context.Background(), Query)
+	_, err := dr.db.store.Exec(context.Background(), Query)
 	return err
 }
 
@@ -653,7 +906,7 @@ func (dr *DriverRepository) EndAllSessions() error {
 		UPDATE driver_sessions
 		SET ended_at = NOW();
 	`
-	_, err := dr.db.conn.Exec(context.Background(), Query)
+	_, err := dr.db.store.Exec(context.Background(), Query)
 	return err
 }
 
@@ -667,7 +920,7 @@ func (dr *DriverRepository) IsDriverNear(ctx context.Context, driver_id string)
 				WHERE d.driver_id = $1 AND (r.status = 'EN_ROUTE' OR r.status = 'ARRIVED');
 	`
 	var res float64
-	err := dr.db.GetConn().QueryRow(ctx, Query, driver_id).Scan(&res)
+	err := dr.db.store.QueryRow(ctx, Query, driver_id).Scan(&res)
 	if err != nil {
 		return 1000000, err
 	}
@@ -681,7 +934,7 @@ func (dr *DriverRepository) IsOffline(ctx context.Context, driver_id string) (bo
 		WHERE driver_id = $1;
 	`
 	var isOffline bool
-	err := dr.db.GetConn().QueryRow(ctx, Query, driver_id).Scan(&isOffline)
+	err := dr.db.store.QueryRow(ctx, Query, driver_id).Scan(&isOffline)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/internal/inbox"
+)
+
+type InboxRepository struct {
+	db *DataBase
+}
+
+func NewInboxRepository(db *DataBase) *InboxRepository {
+	return &InboxRepository{db: db}
+}
+
+func (ir *InboxRepository) Purge(ctx context.Context, olderThan time.Duration, limit int) (int64, error) {
+	n, err := inbox.Purge(ctx, ir.db.store, olderThan, limit)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := ir.db.IsAlive(); err2 != nil {
+			return 0, err2
+		}
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/internal/outbox"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// outboxService marks the outbox rows written by the driver location service
+const outboxService = "driver-location-service"
+
+type OutboxRepository struct {
+	db *DataBase
+}
+
+func NewOutboxRepository(db *DataBase) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+func (or *OutboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]outbox.Message, error) {
+	var msgs []outbox.Message
+	err := or.db.store.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		msgs, err = outbox.Claim(ctx, tx, outboxService, limit, lease)
+		return err
+	})
+	if err != nil {
+		// Check if the database is alive
+		if err2 := or.db.IsAlive(); err2 != nil {
+			return nil, err2
+		}
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (or *OutboxRepository) MarkSent(ctx context.Context, id string) error {
+	if err := outbox.MarkSent(ctx, or.db.store, id); err != nil {
+		if err2 := or.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return err
+	}
+	return nil
+}
+
+func (or *OutboxRepository) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	if err := outbox.MarkFailed(ctx, or.db.store, id, cause, retryAt); err != nil {
+		if err2 := or.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return err
+	}
+	return nil
+}
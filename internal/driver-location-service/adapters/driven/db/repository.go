@@ -1,11 +1,17 @@
 package db
 
 type Repository struct {
-	DriverRepository *DriverRepository
+	DriverRepository     *DriverRepository
+	RevocationRepository *RevocationRepository
+	OutboxRepository     *OutboxRepository
+	InboxRepository      *InboxRepository
 }
 
 func New(db *DataBase) *Repository {
 	return &Repository{
-		DriverRepository: NewDriverRepository(db),
+		DriverRepository:     NewDriverRepository(db),
+		RevocationRepository: NewRevocationRepository(db),
+		OutboxRepository:     NewOutboxRepository(db),
+		InboxRepository:      NewInboxRepository(db),
 	}
 }
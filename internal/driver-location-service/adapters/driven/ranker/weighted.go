@@ -0,0 +1,96 @@
+package ranker
+
+import (
+	"math"
+	"sort"
+
+	"ride-hail/internal/config"
+	"ride-hail/internal/driver-location-service/core/domain/model"
+	"ride-hail/internal/driver-location-service/core/ports/driven"
+)
+
+const (
+	// New drivers start from this acceptance rate until they have some history
+	priorAcceptance = 0.8
+	priorOffers     = 5.0
+	// Reported speeds below this are treated as standing still
+	minMovingSpeedKmh = 5.0
+)
+
+// WeightedRanker scores each candidate as the weighted mean of normalized
+// distance, ETA, rating, acceptance rate and idle time.
+type WeightedRanker struct {
+	cfg *config.Rankingconfig
+}
+
+var _ driven.DriverRanker = (*WeightedRanker)(nil)
+
+func NewWeighted(cfg *config.Rankingconfig) *WeightedRanker {
+	return &WeightedRanker{cfg: cfg}
+}
+
+func (r *WeightedRanker) Rank(radiusMeters float64, candidates []model.DriverInfo) []model.ScoredDriver {
+	scored := make([]model.ScoredDriver, 0, len(candidates))
+	for _, d := range candidates {
+		scored = append(scored, r.score(radiusMeters, d))
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].Score != scored[j].Score {
+			return scored[i].Score > scored[j].Score
+		}
+		return scored[i].Driver.Distance < scored[j].Driver.Distance
+	})
+	return scored
+}
+
+func (r *WeightedRanker) score(radiusMeters float64, d model.DriverInfo) model.ScoredDriver {
+	speed := d.SpeedKmh
+	if speed < minMovingSpeedKmh {
+		speed = r.cfg.AvgSpeedKmh
+	}
+	eta := 0.0
+	if speed > 0 {
+		eta = d.Distance / speed * 60
+	}
+
+	acceptance := (float64(d.OffersAccepted) + priorAcceptance*priorOffers) / (float64(d.OffersReceived) + priorOffers)
+	idleMinutes := d.IdleSeconds / 60
+
+	components := []model.ScoreComponent{
+		{Name: "distance_km", Raw: d.Distance, Normalized: 1 - ratio(d.Distance*1000, radiusMeters), Weight: r.cfg.WeightDistance},
+		{Name: "eta_min", Raw: eta, Normalized: 1 - ratio(eta, r.cfg.MaxEtaMinutes), Weight: r.cfg.WeightETA},
+		{Name: "rating", Raw: d.Rating, Normalized: ratio(d.Rating-1, 4), Weight: r.cfg.WeightRating},
+		{Name: "acceptance", Raw: acceptance, Normalized: clamp(acceptance), Weight: r.cfg.WeightAcceptance},
+		{Name: "idle_min", Raw: idleMinutes, Normalized: ratio(idleMinutes, r.cfg.IdleCapMinutes), Weight: r.cfg.WeightIdle},
+	}
+
+	var total, weights float64
+	for _, c := range components {
+		total += c.Normalized * c.Weight
+		weights += c.Weight
+	}
+	score := 0.0
+	if weights > 0 {
+		score = total / weights
+	}
+
+	return model.ScoredDriver{
+		Driver:     d,
+		EtaMinutes: eta,
+		Score:      score,
+		Components: components,
+	}
+}
+
+// ratio is v/max clamped to 0..1
+func ratio(v, max float64) float64 {
+	if max <= 0 {
+		return 0
+	}
+	return clamp(v / max)
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
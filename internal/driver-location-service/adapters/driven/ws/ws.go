@@ -3,20 +3,55 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"ride-hail/internal/confirm"
 	"ride-hail/internal/driver-location-service/core/domain/dto"
 	websocketdto "ride-hail/internal/driver-location-service/core/domain/websocket_dto"
+	"ride-hail/internal/driver-location-service/core/ports/driven"
+	"ride-hail/internal/mylogger"
+	"ride-hail/internal/tracing"
 
 	"github.com/gorilla/websocket"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+const (
+	// driverWSExchange — direct exchange между экземплярами, ключ — id экземпляра
+	driverWSExchange = "driver_ws"
+	// remoteInboxSize — ответы на офферы, ждущие чтения Matcher'ом
+	remoteInboxSize = 8
+	// offerGrace — сколько после истечения оффера ещё ждём опоздавший ответ
+	offerGrace = time.Minute
+)
+
+// WebSocketManager держит сокеты водителей этого экземпляра. Сообщение
+// водителю другого экземпляра уходит через брокер в очередь экземпляра,
+// записанного в реестре подключений; ответ водителя на такой оффер
+// возвращается экземпляру, который его отправил.
 type WebSocketManager struct {
 	connections map[string]*DriverConnection
 	FanIn       chan dto.DriverMessage
 	mu          sync.RWMutex
+
+	instanceID string
+	registry   driven.IConnectionRegistry
+	broker     driven.IDriverBroker
+	heartbeat  time.Duration
+	log        mylogger.Logger
+
+	// offers: offer_id -> экземпляр, ждущий ответа водителя этого экземпляра
+	offers map[string]routedOffer
+	// remote: driver_id -> ответы водителя другого экземпляра на наши офферы
+	remote map[string]chan []byte
+}
+
+type routedOffer struct {
+	origin    string
+	expiresAt time.Time
 }
 
 type DriverConnection struct {
@@ -30,89 +65,296 @@ type DriverConnection struct {
 	mu         sync.Mutex
 }
 
-func NewWebSocketManager() *WebSocketManager {
+func NewWebSocketManager(instanceID string, registry driven.IConnectionRegistry, broker driven.IDriverBroker, heartbeat time.Duration, log mylogger.Logger) *WebSocketManager {
 	return &WebSocketManager{
 		connections: make(map[string]*DriverConnection),
 		FanIn:       make(chan dto.DriverMessage, 1000),
+		instanceID:  instanceID,
+		registry:    registry,
+		broker:      broker,
+		heartbeat:   heartbeat,
+		log:         log,
+		offers:      make(map[string]routedOffer),
+		remote:      make(map[string]chan []byte),
+	}
+}
+
+// Run принимает сообщения других экземпляров и продлевает записи реестра,
+// пока ctx не отменён; при остановке записи экземпляра удаляются
+func (m *WebSocketManager) Run(ctx context.Context) {
+	log := m.log.Action("ws_instance").With("instance_id", m.instanceID)
+
+	// записи прошлого запуска с тем же id не относятся к открытым сокетам
+	m.unregisterInstance(ctx)
+	defer m.unregisterInstance(ctx)
+
+	deliveries, err := m.broker.ConsumeInstance(ctx, driverWSExchange, m.instanceID)
+	if err != nil {
+		log.Error("Failed to consume the instance queue, retrying on heartbeat", err)
+	}
+	ticker := time.NewTicker(m.heartbeat)
+	defer ticker.Stop()
+
+	log.Info("Instance is routing driver messages")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.registry.Heartbeat(ctx, m.instanceID); err != nil {
+				log.Error("Failed to refresh driver connections", err)
+			}
+			// очередь пропадает вместе с соединением брокера
+			if deliveries == nil {
+				if deliveries, err = m.broker.ConsumeInstance(ctx, driverWSExchange, m.instanceID); err != nil {
+					log.Error("Failed to consume the instance queue", err)
+				}
+			}
+		case d, ok := <-deliveries:
+			if !ok {
+				log.Warn("Instance queue closed, resubscribing on heartbeat")
+				deliveries = nil
+				continue
+			}
+			m.handleRouted(ctx, d)
+		}
+	}
+}
+
+func (m *WebSocketManager) unregisterInstance(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+	if err := m.registry.UnregisterInstance(ctx, m.instanceID); err != nil {
+		m.log.Action("ws_instance").Error("Failed to unregister instance connections", err, "instance_id", m.instanceID)
+	}
+}
+
+func (m *WebSocketManager) handleRouted(ctx context.Context, d amqp.Delivery) {
+	ctx, span := tracing.StartConsumer(ctx, driverWSExchange+"."+m.instanceID, d)
+	defer span.End()
+	log := m.log.Action("handleRouted").WithContext(ctx)
+
+	var msg dto.RoutedMessage
+	if err := json.Unmarshal(d.Body, &msg); err != nil {
+		log.Error("Failed to decode routed message", err)
+		return
+	}
+
+	switch msg.Kind {
+	case dto.RoutedToDriver:
+		if !m.deliverLocal(msg.DriverID, msg.Payload, msg.Origin) {
+			log.Debug("Driver is no longer connected here", "driver_id", msg.DriverID, "origin", msg.Origin)
+		}
+	case dto.RoutedFromDriver:
+		m.mu.RLock()
+		inbox, ok := m.remote[msg.DriverID]
+		m.mu.RUnlock()
+		if !ok {
+			return
+		}
+		select {
+		case inbox <- msg.Payload:
+		default:
+			log.Warn("Dropped driver response, nobody reads it", "driver_id", msg.DriverID)
+		}
+	default:
+		log.Warn("Unknown routed message", "kind", msg.Kind)
 	}
 }
 
-func (m *WebSocketManager) RegisterDriver(ctx context.Context, driverID string, incoming <-chan []byte, outgoing chan<- []byte) error {
+// RegisterDriver возвращает id сессии: им handler потом снимает именно своё
+// подключение, не задевая более новое того же водителя
+func (m *WebSocketManager) RegisterDriver(ctx context.Context, driverID string, incoming <-chan []byte, outgoing chan<- []byte) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if existing, exists := m.connections[driverID]; exists {
+	if existing, exists := m.connections[driverID]; exists && existing.Conn != nil {
 		existing.Conn.Close()
 	}
 
+	sessionID := fmt.Sprintf("session_%s_%d", driverID, time.Now().UnixNano())
 	m.connections[driverID] = &DriverConnection{
 		DriverID:   driverID,
 		fromDriver: incoming,
 		toDriver:   outgoing,
 		Auth:       false,
 		LastPing:   time.Now(),
-		SessionID:  fmt.Sprintf("session_%s_%d", driverID, time.Now().Unix()),
+		SessionID:  sessionID,
 	}
 
-	return nil
+	return sessionID, nil
 }
 
-func (m *WebSocketManager) UnregisterDriver(ctx context.Context, driverID string) {
+// UnregisterDriver снимает подключение, только если оно всё ещё принадлежит
+// сессии sessionID: после переподключения к этому же экземпляру старый
+// handler не должен удалять новое подключение и запись реестра
+func (m *WebSocketManager) UnregisterDriver(ctx context.Context, driverID, sessionID string) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if conn, exists := m.connections[driverID]; exists {
-		conn.Conn.Close()
+	conn, exists := m.connections[driverID]
+	current := exists && conn.SessionID == sessionID
+	if current {
+		if conn.Conn != nil {
+			conn.Conn.Close()
+		}
 		delete(m.connections, driverID)
 	}
+	m.mu.Unlock()
+
+	if !current {
+		return
+	}
+	// запрос уже завершается, а запись нужно убрать
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+	if err := m.registry.Unregister(ctx, driverID, m.instanceID); err != nil {
+		m.log.Action("UnregisterDriver").Error("Failed to unregister driver connection", err, "driver_id", driverID)
+	}
 }
 
-func (m *WebSocketManager) IsDriverConnected(driverID string) bool {
+// IsDriverConnected — подключён ли водитель к этому или к другому живому экземпляру
+func (m *WebSocketManager) IsDriverConnected(ctx context.Context, driverID string) bool {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	conn, exists := m.connections[driverID]
-	return exists && conn.Auth && time.Since(conn.LastPing) < 60*time.Second
+	m.mu.RUnlock()
+	if exists && conn.Auth && time.Since(conn.LastPing) < 60*time.Second {
+		return true
+	}
+
+	instanceID, ok, err := m.registry.Locate(ctx, driverID)
+	if err != nil {
+		m.log.Action("IsDriverConnected").Error("Failed to locate driver", err, "driver_id", driverID)
+		return false
+	}
+	return ok && instanceID != m.instanceID
 }
 
+// SendToDriver доставляет сообщение в сокет водителя, где бы он ни был открыт
 func (m *WebSocketManager) SendToDriver(ctx context.Context, driverID string, message any) error {
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	if m.deliverLocal(driverID, messageBytes, m.instanceID) {
+		return nil
+	}
+
+	instanceID, ok, err := m.registry.Locate(ctx, driverID)
+	if err != nil {
+		return fmt.Errorf("failed to locate driver %s: %w", driverID, err)
+	}
+	if !ok || instanceID == m.instanceID {
+		return fmt.Errorf("driver not connected or not authenticated: %s", driverID)
+	}
+	return m.route(ctx, instanceID, dto.RoutedMessage{
+		Kind:     dto.RoutedToDriver,
+		DriverID: driverID,
+		Origin:   m.instanceID,
+		Payload:  messageBytes,
+	})
+}
+
+// ForwardResponse возвращает ответ водителя на оффер экземпляру, который этот
+// оффер отправил. false — оффер отправлен отсюда, ответ читается локально.
+func (m *WebSocketManager) ForwardResponse(ctx context.Context, driverID string, message []byte) bool {
+	var response websocketdto.RideResponseMessage
+	if err := json.Unmarshal(message, &response); err != nil {
+		return false
+	}
+
+	m.mu.Lock()
+	offer, ok := m.offers[response.OfferID]
+	delete(m.offers, response.OfferID)
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	err := m.route(ctx, offer.origin, dto.RoutedMessage{
+		Kind:     dto.RoutedFromDriver,
+		DriverID: driverID,
+		Origin:   m.instanceID,
+		Payload:  message,
+	})
+	if err != nil {
+		// экземпляр-отправитель закроет волну по таймауту
+		m.log.Action("ForwardResponse").Error("Failed to forward driver response", err, "driver_id", driverID, "offer_id", response.OfferID)
+	}
+	return true
+}
+
+// deliverLocal пишет в сокет этого экземпляра; false, если водителя здесь нет
+func (m *WebSocketManager) deliverLocal(driverID string, message []byte, origin string) bool {
 	m.mu.RLock()
 	conn, exists := m.connections[driverID]
 	m.mu.RUnlock()
 
 	if !exists || !conn.Auth {
-		return fmt.Errorf("driver not connected or not authenticated: %s", driverID)
+		return false
 	}
 
-	messageBytes, err := json.Marshal(message)
-	fmt.Println("Sending to driver:", driverID, "message:", string(messageBytes))
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
-	}
+	m.trackOffer(message, origin)
 
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
-	conn.toDriver <- messageBytes
-	// return conn.Conn.WriteMessage(websocket.TextMessage, messageBytes)
-	return nil
+	conn.toDriver <- message
+	return true
 }
 
-func (m *WebSocketManager) SetConnection(driverID string, conn *websocket.Conn) {
+// trackOffer запоминает, какому экземпляру вернуть ответ на оффер
+func (m *WebSocketManager) trackOffer(message []byte, origin string) {
+	if origin == m.instanceID {
+		return
+	}
+	var offer websocketdto.RideOfferMessage
+	if err := json.Unmarshal(message, &offer); err != nil || offer.Type != websocketdto.MessageTypeRideOffer {
+		return
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if driverConn, exists := m.connections[driverID]; exists {
-		driverConn.Conn = conn
+	now := time.Now()
+	for id, o := range m.offers {
+		if now.After(o.expiresAt.Add(offerGrace)) {
+			delete(m.offers, id)
+		}
+	}
+	m.offers[offer.OfferID] = routedOffer{origin: origin, expiresAt: offer.ExpiresAt}
+}
+
+func (m *WebSocketManager) route(ctx context.Context, instanceID string, msg dto.RoutedMessage) error {
+	err := m.broker.PublishJSON(ctx, driverWSExchange, instanceID, msg)
+	if errors.Is(err, confirm.ErrUnroutable) {
+		return fmt.Errorf("instance %s holding driver %s is gone: %w", instanceID, msg.DriverID, err)
 	}
+	return err
 }
 
-func (m *WebSocketManager) SetAuthenticated(driverID string, authenticated bool) {
+func (m *WebSocketManager) SetConnection(driverID, sessionID string, conn *websocket.Conn) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if conn, exists := m.connections[driverID]; exists {
+	if driverConn, exists := m.connections[driverID]; exists && driverConn.SessionID == sessionID {
+		driverConn.Conn = conn
+	}
+}
+
+// SetAuthenticated также записывает водителя в реестр, чтобы его нашли другие экземпляры
+func (m *WebSocketManager) SetAuthenticated(ctx context.Context, driverID string, authenticated bool) {
+	m.mu.Lock()
+	conn, exists := m.connections[driverID]
+	if exists {
 		conn.Auth = authenticated
 	}
+	m.mu.Unlock()
+
+	if !exists || !authenticated {
+		return
+	}
+	if err := m.registry.Register(ctx, driverID, m.instanceID); err != nil {
+		m.log.Action("SetAuthenticated").Error("Failed to register driver connection", err, "driver_id", driverID)
+	}
 }
 
 func (m *WebSocketManager) UpdatePing(driverID string) {
@@ -144,6 +386,7 @@ func (m *WebSocketManager) GetConnectionStatus(driverID string) *websocketdto.Co
 	}
 }
 
+// GetConnectedDrivers — водители этого экземпляра
 func (m *WebSocketManager) GetConnectedDrivers() []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -157,23 +400,32 @@ func (m *WebSocketManager) GetConnectedDrivers() []string {
 	return drivers
 }
 
+// GetDriverMessages возвращает ответы водителя: из его сокета, если он
+// подключён здесь, иначе — пересланные его экземпляром
 func (m *WebSocketManager) GetDriverMessages(driverID string) (<-chan []byte, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	conn, exists := m.connections[driverID]
-	if !exists {
-		return nil, fmt.Errorf("driver not connected: %s", driverID)
+	if conn, exists := m.connections[driverID]; exists {
+		return conn.fromDriver, nil
 	}
 
-	return conn.fromDriver, nil
+	inbox, exists := m.remote[driverID]
+	if !exists {
+		inbox = make(chan []byte, remoteInboxSize)
+		m.remote[driverID] = inbox
+	}
+	return inbox, nil
 }
 
+// GetDriversCount — подключённые водители всех экземпляров
 func (m *WebSocketManager) GetDriversCount(ctx context.Context) int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	return len(m.connections)
+	count, err := m.registry.CountConnected(ctx)
+	if err != nil {
+		m.log.Action("GetDriversCount").Error("Failed to count driver connections, counting this instance only", err)
+		return len(m.GetConnectedDrivers())
+	}
+	return count
 }
 
 func (m *WebSocketManager) GetFanIn() <-chan dto.DriverMessage {
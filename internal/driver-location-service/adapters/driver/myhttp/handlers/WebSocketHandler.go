@@ -12,8 +12,10 @@ import (
 	websocketdto "ride-hail/internal/driver-location-service/core/domain/websocket_dto"
 	"ride-hail/internal/driver-location-service/core/ports/driver"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/tracing"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type WebSocketHandler struct {
@@ -65,12 +67,13 @@ func (h *WebSocketHandler) HandleDriverWebSocket(w http.ResponseWriter, r *http.
 	}
 	fromDriver := make(chan []byte, 100)
 	toDriver := make(chan []byte, 100)
-	if err := h.wsManager.RegisterDriver(r.Context(), driverID, fromDriver, toDriver); err != nil {
+	sessionID, err := h.wsManager.RegisterDriver(r.Context(), driverID, fromDriver, toDriver)
+	if err != nil {
 		log.Error("Failed to register driver:", err, driverID)
 		JsonError(w, http.StatusInternalServerError, fmt.Errorf("Failed to register driver"))
 		return
 	}
-	defer h.wsManager.UnregisterDriver(r.Context(), driverID)
+	defer h.wsManager.UnregisterDriver(r.Context(), driverID, sessionID)
 	log.Info("Driver registered:", driverID)
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -78,7 +81,7 @@ func (h *WebSocketHandler) HandleDriverWebSocket(w http.ResponseWriter, r *http.
 	}
 	defer conn.Close()
 
-	h.wsManager.SetConnection(driverID, conn)
+	h.wsManager.SetConnection(driverID, sessionID, conn)
 
 	conn.SetPongHandler(func(string) error {
 		h.wsManager.UpdatePing(driverID)
@@ -151,7 +154,7 @@ func (h *WebSocketHandler) handleIncomingMessages(ctx context.Context, driverID
 					}
 					conn.WriteMessage(websocket.TextMessage, msg)
 				} else if authenticated = h.handleAuthentication(driverID, message); authenticated {
-					h.wsManager.SetAuthenticated(driverID, true)
+					h.wsManager.SetAuthenticated(ctx, driverID, true)
 					h.sendAuthSuccess(conn)
 					log.Info("Driver authenticated successfully:", driverID)
 				} else {
@@ -166,13 +169,22 @@ func (h *WebSocketHandler) handleIncomingMessages(ctx context.Context, driverID
 			switch userMessageType {
 			case websocketdto.MessageTypeRideResponse:
 				log.Info("Received ride response from driver:", driverID)
-				incoming <- message
+				// an answer to an offer of another instance goes back to it
+				if !h.wsManager.ForwardResponse(ctx, driverID, message) {
+					incoming <- message
+				}
 			case websocketdto.MessageTypeLocationUpdate:
 				log.Info("Received location update:", driverID)
 				var driverMessage dto.DriverMessage
 				driverMessage.DriverID = driverID
 				driverMessage.Message = message
 				h.wsManager.FanIn <- driverMessage
+			case websocketdto.MessageTypeStopArrived:
+				log.Info("Received stop arrival:", driverID)
+				h.handleStopArrived(ctx, driverID, message)
+			case websocketdto.MessageTypeDestinationChangeResponse:
+				log.Info("Received destination change answer:", driverID)
+				h.handleDestinationChangeResponse(ctx, driverID, message)
 			default:
 				log.Warn("Unhandled message type from driver:", driverID, messageType)
 			}
@@ -270,6 +282,18 @@ func (h *WebSocketHandler) validateMessage(message []byte) (string, error) {
 			return "", err
 		}
 		return baseMsg.Type, h.validateLocationUpdate(locUpdate)
+	case websocketdto.MessageTypeStopArrived:
+		var stopArrived websocketdto.StopArrivedMessage
+		if err := json.Unmarshal(message, &stopArrived); err != nil {
+			return "", err
+		}
+		return baseMsg.Type, h.validateStopArrived(stopArrived)
+	case websocketdto.MessageTypeDestinationChangeResponse:
+		var destResp websocketdto.DestinationChangeResponseMessage
+		if err := json.Unmarshal(message, &destResp); err != nil {
+			return "", err
+		}
+		return baseMsg.Type, h.validateDestinationChangeResponse(destResp)
 	case websocketdto.MessageTypeAuth:
 		return baseMsg.Type, nil
 	default:
@@ -297,6 +321,72 @@ func (h *WebSocketHandler) validateLocationUpdate(update websocketdto.LocationUp
 	return nil
 }
 
+func (h *WebSocketHandler) validateStopArrived(msg websocketdto.StopArrivedMessage) error {
+	if msg.RideID == "" {
+		return fmt.Errorf("ride_id is required")
+	}
+	if msg.StopIndex < 1 {
+		return fmt.Errorf("invalid stop_index: %d", msg.StopIndex)
+	}
+	return nil
+}
+
+func (h *WebSocketHandler) validateDestinationChangeResponse(msg websocketdto.DestinationChangeResponseMessage) error {
+	if msg.ChangeID == "" {
+		return fmt.Errorf("change_id is required")
+	}
+	if msg.RideID == "" {
+		return fmt.Errorf("ride_id is required")
+	}
+	return nil
+}
+
+// handleDestinationChangeResponse relays the driver's answer; ride-service
+// decides the outcome and both the passenger and the driver (as
+// destination_change_result) get it from there
+func (h *WebSocketHandler) handleDestinationChangeResponse(ctx context.Context, driverID string, message []byte) {
+	var msg websocketdto.DestinationChangeResponseMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		h.sendDriverError(ctx, driverID, "invalid_message", err.Error())
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "ws "+websocketdto.MessageTypeDestinationChangeResponse, trace.WithSpanKind(trace.SpanKindServer))
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err := h.driverService.RespondDestinationChange(ctx, driverID, msg)
+	if err != nil {
+		h.sendDriverError(ctx, driverID, "destination_change_failed", err.Error())
+	}
+	tracing.End(span, err)
+}
+
+// handleStopArrived records the stop and confirms it with stop_update
+func (h *WebSocketHandler) handleStopArrived(ctx context.Context, driverID string, message []byte) {
+	log := h.log.Action("handleStopArrived")
+
+	var msg websocketdto.StopArrivedMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		h.sendDriverError(ctx, driverID, "invalid_message", err.Error())
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "ws "+websocketdto.MessageTypeStopArrived, trace.WithSpanKind(trace.SpanKindServer))
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	update, err := h.driverService.ArriveAtStop(ctx, driverID, msg.RideID, msg.StopIndex)
+	defer func() { tracing.End(span, err) }()
+	if err != nil {
+		h.sendDriverError(ctx, driverID, "stop_rejected", err.Error())
+		return
+	}
+	if err := h.wsManager.SendToDriver(ctx, driverID, update); err != nil {
+		log.Error("Failed to confirm stop arrival", err, driverID)
+	}
+}
+
 func (h *WebSocketHandler) sendAuthSuccess(conn *websocket.Conn) {
 	successMsg := websocketdto.WebSocketMessage{
 		Type: "auth_success",
@@ -317,6 +407,21 @@ func (h *WebSocketHandler) sendAuthError(conn *websocket.Conn, message string) {
 	conn.WriteMessage(websocket.TextMessage, messageBytes)
 }
 
+// sendDriverError queues the error behind the driver's other outgoing
+// messages; only handleOutgoingMessages may write to the socket
+func (h *WebSocketHandler) sendDriverError(ctx context.Context, driverID, code, message string) {
+	errorMsg := websocketdto.ErrorMessage{
+		WebSocketMessage: websocketdto.WebSocketMessage{
+			Type: websocketdto.MessageTypeError,
+		},
+		ErrorCode:    code,
+		ErrorMessage: message,
+	}
+	if err := h.wsManager.SendToDriver(ctx, driverID, errorMsg); err != nil {
+		h.log.Action("sendDriverError").Error("Failed to send error to driver", err, driverID, "code", code)
+	}
+}
+
 func (h *WebSocketHandler) sendError(conn *websocket.Conn, code, message string) {
 	errorMsg := websocketdto.ErrorMessage{
 		WebSocketMessage: websocketdto.WebSocketMessage{
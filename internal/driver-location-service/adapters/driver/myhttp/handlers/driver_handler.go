@@ -11,6 +11,8 @@ import (
 	"ride-hail/internal/driver-location-service/core/domain/model"
 	"ride-hail/internal/driver-location-service/core/ports/driver"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/pricing"
+	"ride-hail/internal/ridestate"
 
 	"github.com/gorilla/websocket"
 )
@@ -39,7 +41,7 @@ func NewDriverHandler(driverService driver.IDriverService, log mylogger.Logger)
 }
 
 func (dh *DriverHandler) GoOnline(w http.ResponseWriter, r *http.Request) {
-	log := dh.log.Action("GoOnline")
+	log := dh.log.Action("GoOnline").WithContext(r.Context())
 	ctx := r.Context()
 
 	driverID := r.PathValue("driver_id")
@@ -90,7 +92,7 @@ func (dh *DriverHandler) GoOnline(w http.ResponseWriter, r *http.Request) {
 }
 
 func (dh *DriverHandler) GoOffline(w http.ResponseWriter, r *http.Request) {
-	log := dh.log.Action("GoOffline")
+	log := dh.log.Action("GoOffline").WithContext(r.Context())
 	ctx := r.Context()
 
 	driverID := r.PathValue("driver_id")
@@ -131,7 +133,7 @@ func (dh *DriverHandler) GoOffline(w http.ResponseWriter, r *http.Request) {
 }
 
 func (dh *DriverHandler) UpdateLocation(w http.ResponseWriter, r *http.Request) {
-	log := dh.log.Action("Update Location")
+	log := dh.log.Action("Update Location").WithContext(r.Context())
 	ctx := r.Context()
 
 	driverID := r.PathValue("driver_id")
@@ -170,8 +172,9 @@ func (dh *DriverHandler) UpdateLocation(w http.ResponseWriter, r *http.Request)
 }
 
 func (dh *DriverHandler) StartRide(w http.ResponseWriter, r *http.Request) {
-	log := dh.log.Action("driver.start_ride")
-	ctx := context.Background()
+	log := dh.log.Action("driver.start_ride").WithContext(r.Context())
+	// не отменяется вместе с запросом, но остаётся в его трассировке
+	ctx := context.WithoutCancel(r.Context())
 
 	driverID := r.PathValue("driver_id")
 	log.Info("request received", "driver_id", driverID)
@@ -201,7 +204,7 @@ func (dh *DriverHandler) StartRide(w http.ResponseWriter, r *http.Request) {
 	req.Driver_location.Driver_id = driverID
 	res, err := dh.driverService.StartRide(ctx, req)
 	if err != nil {
-		JsonError(w, http.StatusInternalServerError, err)
+		JsonError(w, rideErrorCode(err), err)
 		log.Error("start ride failed", err, "ride_id", req.Ride_id, "driver_id", driverID)
 		return
 	}
@@ -211,8 +214,8 @@ func (dh *DriverHandler) StartRide(w http.ResponseWriter, r *http.Request) {
 }
 
 func (dh *DriverHandler) CompleteRide(w http.ResponseWriter, r *http.Request) {
-	log := dh.log.Action("Go Online")
-	ctx := context.Background()
+	log := dh.log.Action("Go Online").WithContext(r.Context())
+	ctx := context.WithoutCancel(r.Context())
 
 	// Checking Driver For Existance
 	driverID := r.PathValue("driver_id")
@@ -234,9 +237,25 @@ func (dh *DriverHandler) CompleteRide(w http.ResponseWriter, r *http.Request) {
 	}
 	res, err := dh.driverService.CompleteRide(ctx, req)
 	if err != nil {
-		JsonError(w, http.StatusInternalServerError, err)
+		JsonError(w, rideErrorCode(err), err)
 		return
 	}
 
 	jsonResponse(w, http.StatusAccepted, res)
 }
+
+// rideErrorCode maps ride lifecycle errors to HTTP status codes
+func rideErrorCode(err error) int {
+	switch {
+	case errors.Is(err, model.ErrRideNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ridestate.ErrIllegalTransition), errors.Is(err, ridestate.ErrSameStatus):
+		return http.StatusConflict
+	case errors.Is(err, ridestate.ErrActorNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, pricing.ErrInvalidTrip):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
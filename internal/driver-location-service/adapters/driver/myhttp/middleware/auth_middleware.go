@@ -4,19 +4,23 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"ride-hail/internal/driver-location-service/adapters/driver/myhttp/handlers"
+	"ride-hail/internal/driver-location-service/core/ports/driven"
 
 	jwt "github.com/golang-jwt/jwt"
 )
 
 type AuthMiddleware struct {
-	accessSecret string
+	keyfunc     jwt.Keyfunc
+	revocations driven.IRevocationRepository
 }
 
-func NewAuthMiddleware(accessSecret string) *AuthMiddleware {
+func NewAuthMiddleware(keyfunc jwt.Keyfunc, revocations driven.IRevocationRepository) *AuthMiddleware {
 	return &AuthMiddleware{
-		accessSecret: accessSecret,
+		keyfunc:     keyfunc,
+		revocations: revocations,
 	}
 }
 
@@ -28,9 +32,7 @@ func (am *AuthMiddleware) SessionHandler(next http.Handler) http.Handler {
 			return
 		}
 		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-			return []byte(am.accessSecret), nil
-		})
+		token, err := jwt.Parse(tokenString, am.keyfunc)
 		if err != nil {
 			handlers.JsonError(w, http.StatusBadRequest, fmt.Errorf("Failed to parse JWT-Token"))
 			return
@@ -59,6 +61,23 @@ func (am *AuthMiddleware) SessionHandler(next http.Handler) http.Handler {
 			return
 		}
 
+		jti, _ := claims["jti"].(string)
+		iat, _ := claims["iat"].(float64)
+		if jti == "" || iat == 0 {
+			handlers.JsonError(w, http.StatusUnauthorized, fmt.Errorf("Token has no jti or iat"))
+			return
+		}
+
+		revoked, err := am.revocations.IsRevoked(r.Context(), jti, userId, time.UnixMilli(int64(iat*1000)))
+		if err != nil {
+			handlers.JsonError(w, http.StatusInternalServerError, fmt.Errorf("Failed to check JWT-Token"))
+			return
+		}
+		if revoked {
+			handlers.JsonError(w, http.StatusUnauthorized, fmt.Errorf("Token revoked"))
+			return
+		}
+
 		if role != "DRIVER" {
 			handlers.JsonError(w, http.StatusBadRequest, fmt.Errorf("Only drivers allowed to use this service"))
 			return
@@ -3,14 +3,16 @@ package myhttp
 import (
 	"net/http"
 
-	"ride-hail/internal/config"
 	"ride-hail/internal/driver-location-service/adapters/driver/myhttp/handlers"
 	"ride-hail/internal/driver-location-service/adapters/driver/myhttp/middleware"
+	"ride-hail/internal/driver-location-service/core/ports/driven"
+
+	"github.com/golang-jwt/jwt"
 )
 
-func Router(handlers *handlers.Handlers, cfg *config.Config) http.Handler {
+func Router(handlers *handlers.Handlers, keyfunc jwt.Keyfunc, revocations driven.IRevocationRepository) http.Handler {
 	mux := http.NewServeMux()
-	mdl := middleware.NewAuthMiddleware(cfg.App.PublicJwtSecret)
+	mdl := middleware.NewAuthMiddleware(keyfunc, revocations)
 	mux.HandleFunc("/ws/drivers/{driver_id}", handlers.WebSocketHandler.HandleDriverWebSocket)
 	mux.Handle("/drivers/{driver_id}/online", mdl.SessionHandler(func() http.HandlerFunc { return handlers.DriverHandler.GoOnline }()))
 	mux.Handle("/drivers/{driver_id}/offline", mdl.SessionHandler(func() http.HandlerFunc { return handlers.DriverHandler.GoOffline }()))
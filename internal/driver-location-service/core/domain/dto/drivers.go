@@ -1,6 +1,9 @@
 package dto
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // ONLINE MODE
 type DriverCoordinatesDTO struct {
@@ -81,15 +84,16 @@ type RideCompleteResponse struct {
 
 // Ride Details
 type RideDetails struct {
-	Ride_id              string         `json:"ride_id"`
-	Ride_number          string         `json:"ride_number"`
-	Pickup_location      LocationDetail `json:"pickup_location"`
-	Destination_location LocationDetail `json:"destination_location"`
-	Ride_type            string         `json:"ride_type"`
-	Estimated_fare       float64        `json:"estimated_fare"`
-	Max_distance_km      float64        `json:"max_distance_km"`
-	Timeout_seconds      int            `json:"timeout_seconds"`
-	Correlation_id       string         `json:"correlation_id"`
+	Ride_id              string           `json:"ride_id"`
+	Ride_number          string           `json:"ride_number"`
+	Pickup_location      LocationDetail   `json:"pickup_location"`
+	Destination_location LocationDetail   `json:"destination_location"`
+	Stops                []LocationDetail `json:"stops,omitempty"`
+	Ride_type            string           `json:"ride_type"`
+	Estimated_fare       float64          `json:"estimated_fare"`
+	Max_distance_km      float64          `json:"max_distance_km"`
+	Timeout_seconds      int              `json:"timeout_seconds"`
+	Correlation_id       string           `json:"correlation_id"`
 }
 type LocationDetail struct {
 	Lat     float64 `json:"lat"`
@@ -141,14 +145,16 @@ type RideStatusUpdate struct {
 
 // Driver Info
 type DriverInfo struct {
-	DriverId  string
-	Name      string `json:"name"`
-	Email     string
-	Vehicle   VehicleDetail `json:"vehicle"`
-	Rating    float64       `json:"rating"`
-	Latitude  float64
-	Longitude float64
-	Distance  float64
+	DriverId   string
+	Name       string `json:"name"`
+	Email      string
+	Vehicle    VehicleDetail `json:"vehicle"`
+	Rating     float64       `json:"rating"`
+	Latitude   float64
+	Longitude  float64
+	Distance   float64
+	Score      float64
+	EtaMinutes float64
 }
 type VehicleDetail struct {
 	Make  string `json:"make"`
@@ -157,25 +163,21 @@ type VehicleDetail struct {
 	Plate string `json:"plate"`
 }
 
-// Driver Match Response
-
-type DriverMatchResponse struct {
-	Ride_id                   string                `json:"ride_id"`
-	Driver_id                 string                `json:"driver_id"`
-	Accepted                  bool                  `json:"accepted"`
-	Estimated_arrival_minutes int                   `json:"estimated_arrival_minutes"`
-	Driver_location           Location              `json:"driver_location"`
-	Driver_info               DriverInfoForResponse `json:"driver_info"`
-}
-
-type DriverInfoForResponse struct {
-	Name    string        `json:"name"`
-	Vehicle VehicleDetail `json:"vehicle"`
-	Rating  float64       `json:"rating"`
-}
-
 // Driver Message
 type DriverMessage struct {
 	DriverID string
 	Message  []byte
 }
+
+// Routed Message — сообщение WebSocket водителя, переданное между экземплярами
+const (
+	RoutedToDriver   = "to_driver"   // доставить водителю, подключённому к экземпляру
+	RoutedFromDriver = "from_driver" // ответ водителя на оффер экземпляра-отправителя
+)
+
+type RoutedMessage struct {
+	Kind     string          `json:"kind"`
+	DriverID string          `json:"driver_id"`
+	Origin   string          `json:"origin"` // экземпляр, отправивший сообщение
+	Payload  json.RawMessage `json:"payload"`
+}
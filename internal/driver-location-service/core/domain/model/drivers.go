@@ -1,5 +1,13 @@
 package model
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ride-hail/internal/pricing"
+)
+
 // Online Mode
 type DriverCoordinates struct {
 	Driver_id string
@@ -20,6 +28,14 @@ type Summary struct {
 	Earnings        float64
 }
 
+// DriverUpdate — изменения водителя по сообщению ride.status
+type DriverUpdate struct {
+	DriverID string
+	Payout   float64
+	// Status пустой — статус не меняется
+	Status string
+}
+
 // START RIDE
 
 type StartRide struct {
@@ -54,6 +70,16 @@ type RideCompleteForm struct {
 	FinalLocation    Location
 	ActualDistancekm float64
 	ActualDurationm  float64
+	FinalFare        pricing.Breakdown
+}
+
+// RidePricing — от чего зависит финальная стоимость помимо самой поездки
+type RidePricing struct {
+	VehicleType     string
+	SurgeMultiplier float64
+	// PriceLocked — цена зафиксирована котировкой, LockedFare — согласованная стоимость
+	PriceLocked bool
+	LockedFare  pricing.Breakdown
 }
 
 type RideCompleteResponse struct {
@@ -66,14 +92,43 @@ type RideCompleteResponse struct {
 
 // DriverInfo
 type DriverInfo struct {
-	DriverId  string
-	Name      string
-	Email     string
-	Vehicle   []byte
-	Rating    float64
-	Latitude  float64
-	Longitude float64
-	Distance  float64
+	DriverId       string
+	Name           string
+	Email          string
+	Vehicle        []byte
+	Rating         float64
+	Latitude       float64
+	Longitude      float64
+	Distance       float64 // km to pickup
+	SpeedKmh       float64 // last reported speed
+	OffersReceived int
+	OffersAccepted int
+	IdleSeconds    float64 // since the last completed ride or going online
+}
+
+// ScoreComponent is one weighted factor of a driver's ranking score
+type ScoreComponent struct {
+	Name       string
+	Raw        float64
+	Normalized float64 // 0..1, higher is better
+	Weight     float64
+}
+
+type ScoredDriver struct {
+	Driver     DriverInfo
+	EtaMinutes float64
+	Score      float64
+	Components []ScoreComponent
+}
+
+// Explain renders the score breakdown for logs
+func (s ScoredDriver) Explain() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "score=%.3f", s.Score)
+	for _, c := range s.Components {
+		fmt.Fprintf(&b, " %s=%.2f(norm %.2f x w %.2f)", c.Name, c.Raw, c.Normalized, c.Weight)
+	}
+	return b.String()
 }
 
 // RideDetails for WebSocket
@@ -82,6 +137,14 @@ type RideDetails struct {
 	PassengerName  string
 	PassengerAttrs []byte
 	PickupLocation Location
+	Stops          []RideStop
+}
+
+// RideStop is an intermediate stop of a ride
+type RideStop struct {
+	StopIndex int
+	Location  Location
+	ArrivedAt *time.Time
 }
 
 type DriverLocation struct {
@@ -1,5 +1,14 @@
 package model
 
-import "errors"
+import (
+	"errors"
+
+	"ride-hail/internal/ridestate"
+)
 
 var ErrNoActiveRide = errors.New("no active ride")
+
+// ErrRideNotFound is the error of ridestate.Apply, so status changes report it too
+var ErrRideNotFound = ridestate.ErrRideNotFound
+var ErrStopNotFound = errors.New("stop not found")
+var ErrStopOutOfOrder = errors.New("previous stops are not reached yet")
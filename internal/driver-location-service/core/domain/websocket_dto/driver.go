@@ -9,9 +9,16 @@ const (
 	MessageTypeRideResponse   = "ride_response"
 	MessageTypeLocationUpdate = "location_update"
 	MessageTypeRideDetails    = "ride_details"
-	MessageTypePing           = "ping"
-	MessageTypePong           = "pong"
-	MessageTypeError          = "error"
+	MessageTypeOfferExpired   = "offer_expired"
+	MessageTypeStopArrived    = "stop_arrived"
+	MessageTypeStopUpdate     = "stop_update"
+
+	MessageTypeDestinationChange         = "destination_change"
+	MessageTypeDestinationChangeResponse = "destination_change_response"
+	MessageTypeDestinationChangeResult   = "destination_change_result"
+	MessageTypePing                      = "ping"
+	MessageTypePong                      = "pong"
+	MessageTypeError                     = "error"
 )
 
 // Base message structure
@@ -33,6 +40,7 @@ type RideOfferMessage struct {
 	RideNumber                   string    `json:"ride_number"`
 	PickupLocation               Location  `json:"pickup_location"`
 	DestinationLocation          Location  `json:"destination_location"`
+	Stops                        []Stop    `json:"stops,omitempty"`
 	EstimatedFare                float64   `json:"estimated_fare"`
 	DriverEarnings               float64   `json:"driver_earnings"`
 	DistanceToPickupKm           float64   `json:"distance_to_pickup_km"`
@@ -40,6 +48,14 @@ type RideOfferMessage struct {
 	ExpiresAt                    time.Time `json:"expires_at"`
 }
 
+// Offer withdrawn: taken by another driver or not answered in time
+type OfferExpiredMessage struct {
+	WebSocketMessage
+	OfferID string `json:"offer_id"`
+	RideID  string `json:"ride_id"`
+	Reason  string `json:"reason"`
+}
+
 // Driver response to ride offer
 type RideResponseMessage struct {
 	WebSocketMessage
@@ -66,6 +82,57 @@ type RideDetailsMessage struct {
 	PassengerName  string   `json:"passenger_name"`
 	PassengerPhone string   `json:"passenger_phone"`
 	PickupLocation Location `json:"pickup_location"`
+	Stops          []Stop   `json:"stops,omitempty"`
+}
+
+// Driver reached an intermediate stop
+type StopArrivedMessage struct {
+	WebSocketMessage
+	RideID    string `json:"ride_id"`
+	StopIndex int    `json:"stop_index"`
+}
+
+// Stop confirmed after stop_arrived
+type StopUpdateMessage struct {
+	WebSocketMessage
+	RideID         string `json:"ride_id"`
+	Stop           Stop   `json:"stop"`
+	RemainingStops int    `json:"remaining_stops"`
+}
+
+// Passenger asked for a new destination, the driver has until expires_at to answer
+type DestinationChangeMessage struct {
+	WebSocketMessage
+	ChangeID       string   `json:"change_id"`
+	RideID         string   `json:"ride_id"`
+	NewDestination Location `json:"new_destination"`
+	DistanceKm     float64  `json:"distance_km"`
+	OldFare        float64  `json:"old_fare"`
+	NewFare        float64  `json:"new_fare"`
+	ExpiresAt      string   `json:"expires_at"`
+}
+
+// Driver answer to destination_change
+type DestinationChangeResponseMessage struct {
+	WebSocketMessage
+	ChangeID string `json:"change_id"`
+	RideID   string `json:"ride_id"`
+	Accepted bool   `json:"accepted"`
+}
+
+// Outcome of the driver's answer: ACCEPTED, REJECTED or EXPIRED
+type DestinationChangeResultMessage struct {
+	WebSocketMessage
+	ChangeID string `json:"change_id"`
+	RideID   string `json:"ride_id"`
+	Status   string `json:"status"`
+}
+
+// Intermediate stop between pickup and destination, stop_index starts at 1
+type Stop struct {
+	StopIndex int `json:"stop_index"`
+	Location
+	ArrivedAt *time.Time `json:"arrived_at,omitempty"`
 }
 
 // Location structure
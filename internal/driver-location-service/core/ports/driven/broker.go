@@ -3,6 +3,8 @@ package driven
 import (
 	"context"
 
+	"ride-hail/internal/outbox"
+
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -17,10 +19,19 @@ type ConsumeOptions struct {
 type IDriverBroker interface {
 	// PublishJSON публикует объект как JSON в указанный exchange/routing key.
 	PublishJSON(ctx context.Context, exchange, routingKey string, msg any) error
+	// PublishConfirmed публикует сообщение outbox и ждёт подтверждения брокера.
+	PublishConfirmed(ctx context.Context, m outbox.Message) error
+	// Republish публикует готовое сообщение (например, полученное из очереди)
+	// с повторами и ждёт подтверждения брокера.
+	Republish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error
 
 	// Consume подписывается на очередь с указанным биндингом.
 	// Возвращает канал Deliveries (amqp.Delivery), из которого читает consumer.
 	Consume(ctx context.Context, queueName, bindingKey string, opts ConsumeOptions) (<-chan amqp.Delivery, error)
+	// ConsumeInstance объявляет direct exchange и собственную очередь экземпляра
+	// "<exchange>.<instanceID>", которая удаляется вместе с соединением.
+	// Сообщения подтверждаются автоматически.
+	ConsumeInstance(ctx context.Context, exchange, instanceID string) (<-chan amqp.Delivery, error)
 	// IsAlive проверяет состояние соединения.
 	IsAlive() bool
 
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"ride-hail/internal/driver-location-service/core/domain/model"
+	"ride-hail/internal/outbox"
 )
 
 type IDriverRepository interface {
@@ -12,20 +13,23 @@ type IDriverRepository interface {
 	UpdateLocation(ctx context.Context, driver_id string, newLocation model.NewLocation) (model.NewLocationResponse, error)
 	StartRide(ctx context.Context, requestData model.StartRide) (model.StartRideResponse, error)
 	CompleteRide(ctx context.Context, requestData model.RideCompleteForm) (model.RideCompleteResponse, error)
-	CompleteRideTx(ctx context.Context, requestData model.RideCompleteForm) (model.RideCompleteResponse, error)
-	FindDrivers(ctx context.Context, longtitude, latitude float64, vehicleType string) ([]model.DriverInfo, error)
-	CalculateRideDetails(ctx context.Context, driverLocation model.Location, passagerLocation model.Location) (float64, error)
+	CompleteRideTx(ctx context.Context, requestData model.RideCompleteForm, msgs ...outbox.Message) (model.RideCompleteResponse, error)
+	FindDrivers(ctx context.Context, longtitude, latitude float64, vehicleType string, radiusMeters float64, limit int) ([]model.DriverInfo, error)
+	RecordOfferOutcome(ctx context.Context, driverID string, accepted bool) error
 	UpdateDriverStatus(ctx context.Context, driver_id string, status string) error
 	CheckDriverById(ctx context.Context, driver_id string) (bool, error)
 	CheckDriverStatus(ctx context.Context, driver_id string) (string, error)
 	HasActiveRide(ctx context.Context, driverID string) (bool, error)
-	StartRideTx(ctx context.Context, driverID, rideID string) (model.StartRideResponse, error)
+	StartRideTx(ctx context.Context, driverID, rideID string, msgs ...outbox.Message) (model.StartRideResponse, error)
 	GetPickupAndDriverCoords(ctx context.Context, rideID, driverID string) (pickupLat, pickupLng, driverLat, driverLng float64, err error)
 	GetDestinationAndDriverCoords(ctx context.Context, rideID, driverID string) (float64, error)
 	GetDriverIdByRideId(ctx context.Context, ride_id string) (string, error)
 	GetRideIdByDriverId(ctx context.Context, driver_id string) (string, error)
 	GetRideDetailsByRideId(ctx context.Context, ride_id string) (model.RideDetails, error)
-	PayDriverMoney(ctx context.Context, driver_id string, amount float64) error
+	ArriveAtStopTx(ctx context.Context, driverID, rideID string, stopIndex int) (stop model.RideStop, remaining int, err error)
+	GetRidePricing(ctx context.Context, ride_id string) (model.RidePricing, error)
+	// ApplyRideStatus выплачивает, меняет статус и пишет outbox в одной транзакции с дедупликацией сообщения
+	ApplyRideStatus(ctx context.Context, messageID string, update model.DriverUpdate, msgs ...outbox.Message) error
 	SetAllOffline() error
 	EndAllSessions() error
 	IsDriverNear(ctx context.Context, driver_id string) (float64, error)
@@ -0,0 +1,8 @@
+package driven
+
+import "ride-hail/internal/pricing"
+
+type IPricingEngine interface {
+	// Quote returns the itemized fare of a trip in the given vehicle type
+	Quote(vehicleType string, trip pricing.Trip) (pricing.Breakdown, error)
+}
@@ -0,0 +1,9 @@
+package driven
+
+import "ride-hail/internal/driver-location-service/core/domain/model"
+
+// DriverRanker orders matching candidates, best first.
+// radiusMeters is the search radius the candidates were found in.
+type DriverRanker interface {
+	Rank(radiusMeters float64, candidates []model.DriverInfo) []model.ScoredDriver
+}
@@ -0,0 +1,19 @@
+package driven
+
+import "context"
+
+// IConnectionRegistry — где открыт WebSocket каждого водителя, общий для всех экземпляров
+type IConnectionRegistry interface {
+	// Register отмечает водителя подключённым к экземпляру, заменяя прежнюю запись
+	Register(ctx context.Context, driverID, instanceID string) error
+	// Unregister удаляет запись, только если она ещё принадлежит экземпляру
+	Unregister(ctx context.Context, driverID, instanceID string) error
+	// Heartbeat продлевает записи экземпляра
+	Heartbeat(ctx context.Context, instanceID string) error
+	// UnregisterInstance удаляет все записи экземпляра при остановке
+	UnregisterInstance(ctx context.Context, instanceID string) error
+	// Locate возвращает экземпляр водителя; ok == false, если живой записи нет
+	Locate(ctx context.Context, driverID string) (instanceID string, ok bool, err error)
+	// CountConnected — число водителей с живой записью на любом экземпляре
+	CountConnected(ctx context.Context) (int, error)
+}
@@ -0,0 +1,10 @@
+package driven
+
+import (
+	"context"
+	"time"
+)
+
+type IRevocationRepository interface {
+	IsRevoked(ctx context.Context, jti, subjectId string, issuedAt time.Time) (bool, error)
+}
@@ -6,10 +6,11 @@ import (
 	"ride-hail/internal/driver-location-service/core/domain/dto"
 )
 
+// WSConnectionMeneger доставляет сообщения водителям, подключённым к любому экземпляру
 type WSConnectionMeneger interface {
-	RegisterDriver(ctx context.Context, driverID string, incoming <-chan []byte, outgoing chan<- []byte) error
-	UnregisterDriver(ctx context.Context, driverID string)
-	IsDriverConnected(driverID string) bool
+	RegisterDriver(ctx context.Context, driverID string, incoming <-chan []byte, outgoing chan<- []byte) (string, error)
+	UnregisterDriver(ctx context.Context, driverID, sessionID string)
+	IsDriverConnected(ctx context.Context, driverID string) bool
 	SendToDriver(ctx context.Context, driverID string, message any) error
 	GetDriversCount(ctx context.Context) int
 	GetDriverMessages(driverID string) (<-chan []byte, error)
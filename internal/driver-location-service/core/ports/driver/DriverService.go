@@ -14,14 +14,22 @@ type IDriverService interface {
 	StartRide(ctx context.Context, requestMessage dto.StartRide) (dto.StartRideResponse, error)
 	CompleteRide(ctx context.Context, request dto.RideCompleteForm) (dto.RideCompleteResponse, error)
 	FindAppropriateDrivers(ctx context.Context, longtitude, latitude float64, vehicleType string) ([]dto.DriverInfo, error)
+	RecordOfferOutcome(ctx context.Context, driverID string, accepted bool) error
+	// distance in km and minutes for the driver to reach the passenger
 	CalculateRideDetails(ctx context.Context, driverLocation dto.Location, passagerLocation dto.Location) (float64, int, error)
+	// distance in km and minutes from pickup through the stops to destination
+	EstimateTrip(ctx context.Context, ride dto.RideDetails) (float64, int, error)
 	UpdateDriverStatus(ctx context.Context, driver_id string, status string) error
+	// ChangeDriverStatus и SettleDriver применяют сообщение ride.status ровно один раз
+	ChangeDriverStatus(ctx context.Context, messageID, driverID, rideID, status string) error
 	CheckDriverById(ctx context.Context, driver_id string) (bool, error)
 	CheckDriverStatus(ctx context.Context, driver_id string) (string, error)
 	RequireActiveRide(ctx context.Context, driverID string) error
 	GetDriverIdByRideId(ctx context.Context, ride_id string) (string, error)
 	GetRideIdByDriverId(ctx context.Context, driver_id string) (string, error)
 	GetRideDetailsByRideId(ctx context.Context, ride_id string) (websocketdto.RideDetailsMessage, error)
+	ArriveAtStop(ctx context.Context, driverID, rideID string, stopIndex int) (websocketdto.StopUpdateMessage, error)
+	RespondDestinationChange(ctx context.Context, driverID string, msg websocketdto.DestinationChangeResponseMessage) error
 	IsOffline(ctx context.Context, driver_id string) (bool, error)
-	PayDriverMoney(ctx context.Context, driver_id string, amount float64) error
+	SettleDriver(ctx context.Context, messageID, driverID string, amount float64, status string) error
 }
@@ -1,20 +1,27 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
+	ports "ride-hail/internal/driver-location-service/core/ports/driven"
+
 	"github.com/golang-jwt/jwt"
 )
 
+const revocationCheckTimeout = 5 * time.Second
+
 type AuthService struct {
-	secretKey string
+	keyfunc     jwt.Keyfunc
+	revocations ports.IRevocationRepository
 }
 
-func NewAuthService(secretKey string) *AuthService {
+func NewAuthService(keyfunc jwt.Keyfunc, revocations ports.IRevocationRepository) *AuthService {
 	return &AuthService{
-		secretKey: secretKey,
+		keyfunc:     keyfunc,
+		revocations: revocations,
 	}
 }
 
@@ -28,9 +35,7 @@ func (a *AuthService) ValidateDriverToken(tokenString string) (string, error) {
 		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 	}
 
-	tokenJWT, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		return []byte(a.secretKey), nil
-	})
+	tokenJWT, err := jwt.Parse(tokenString, a.keyfunc)
 	if err != nil {
 		return "", err
 	}
@@ -57,5 +62,22 @@ func (a *AuthService) ValidateDriverToken(tokenString string) (string, error) {
 		return "", fmt.Errorf("Error: token expired")
 	}
 
+	jti, _ := claims["jti"].(string)
+	iat, _ := claims["iat"].(float64)
+	if jti == "" || iat == 0 {
+		return "", fmt.Errorf("Error: no jti or iat")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), revocationCheckTimeout)
+	defer cancel()
+
+	revoked, err := a.revocations.IsRevoked(ctx, jti, userId, time.UnixMilli(int64(iat*1000)))
+	if err != nil {
+		return "", fmt.Errorf("Error: failed to check revocation: %w", err)
+	}
+	if revoked {
+		return "", fmt.Errorf("Error: token revoked")
+	}
+
 	return userId, nil
 }
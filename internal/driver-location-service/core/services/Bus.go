@@ -3,28 +3,43 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
-	"time"
 
+	"ride-hail/internal/config"
+	"ride-hail/internal/contracts"
 	"ride-hail/internal/driver-location-service/core/ports/driver"
+	"ride-hail/internal/inbox"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/tracing"
 
 	dto "ride-hail/internal/driver-location-service/core/domain/dto"
-	messagebrokerdto "ride-hail/internal/driver-location-service/core/domain/message_broker_dto"
 	websocketdto "ride-hail/internal/driver-location-service/core/domain/websocket_dto"
 	driven "ride-hail/internal/driver-location-service/core/ports/driven"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queues the distributor consumes
+const (
+	rideRequestsQueue    = "ride_requests"
+	rideStatusQueue      = "ride_status"
+	rideDestinationQueue = "ride_destination"
 )
 
 type Distributor struct {
 	// Rabbit MQ
 	rideOffers   <-chan amqp.Delivery
 	rideStatuses <-chan amqp.Delivery
+	rideDests    <-chan amqp.Delivery
 	// Websocket Handler
 	wsManager     driven.WSConnectionMeneger
 	driverService driver.IDriverService
+	matcher       *Matcher
+	// attempts before an unmatched ride request is parked
+	maxAttempts int
 	// Driver Messages
 	driverMessages chan DriverMessage
 	// Tools
@@ -39,29 +54,26 @@ type DriverMessage struct {
 	Message  []byte
 }
 
-type PendingOffer struct {
-	RideID       string
-	DriverID     string
-	OfferID      string
-	ExpiresAt    time.Time
-	ResponseChan chan<- websocketdto.RideResponseMessage
-}
-
 func NewDistributor(
 	ctx context.Context,
 	rideOffers <-chan amqp.Delivery,
 	rideStatuses <-chan amqp.Delivery,
+	rideDests <-chan amqp.Delivery,
 	wsManager driven.WSConnectionMeneger,
 	broker driven.IDriverBroker,
 	driverService driver.IDriverService,
+	matchCfg *config.Matchingconfig,
 	log mylogger.Logger,
 ) *Distributor {
 	distributor := &Distributor{
 		rideOffers:     rideOffers,
 		rideStatuses:   rideStatuses,
+		rideDests:      rideDests,
 		wsManager:      wsManager,
 		broker:         broker,
 		driverService:  driverService,
+		matcher:        NewMatcher(matchCfg, wsManager, driverService, log),
+		maxAttempts:    max(matchCfg.MaxAttempts, 1),
 		driverMessages: make(chan DriverMessage, 1000),
 		ctx:            ctx,
 		log:            log,
@@ -83,6 +95,10 @@ func (d *Distributor) MessageDistributor() error {
 			d.wg.Add(1)
 			go d.handleRideStatus(statusDelivery)
 
+		case destDelivery := <-d.rideDests:
+			d.wg.Add(1)
+			go d.handleDestinationChange(destDelivery)
+
 		case driverMsg := <-d.wsManager.GetFanIn():
 			d.wg.Add(1)
 			go d.handleDriverMessage(driverMsg)
@@ -96,37 +112,39 @@ func (d *Distributor) MessageDistributor() error {
 }
 
 func (d *Distributor) handleDriverMessage(msg dto.DriverMessage) {
-	log := d.log.Action("handleDriverMessage")
+	// every location sent by a driver starts a trace
+	ctx, span := tracing.Start(d.ctx, "ws location_update", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	log := d.log.Action("handleDriverMessage").WithContext(ctx)
 	var LocationUpdate websocketdto.LocationUpdateMessage
 	if err := json.Unmarshal(msg.Message, &LocationUpdate); err != nil {
 		log.Error("Failed to unmarshal message:", err)
 		return
 	}
 	log.Info("Handling driver message")
-	d.driverService.UpdateLocation(context.Background(), dto.NewLocation{
+	d.driverService.UpdateLocation(ctx, dto.NewLocation{
 		Latitude:        LocationUpdate.Latitude,
 		Longitude:       LocationUpdate.Longitude,
 		Accuracy_meters: LocationUpdate.AccuracyMeters,
 		Speed_kmh:       LocationUpdate.SpeedKmh,
 		Heading_Degrees: LocationUpdate.HeadingDegrees,
 	}, msg.DriverID)
-	ride_id, err := d.driverService.GetRideIdByDriverId(context.Background(), msg.DriverID)
+	ride_id, err := d.driverService.GetRideIdByDriverId(ctx, msg.DriverID)
 	if err != nil {
 		log.Error("Failed to get ride id from db:", err)
 		return
 	}
-	rmMessage := messagebrokerdto.LocationUpdate{
+	rmMessage := contracts.LocationUpdated{
 		DriverID: msg.DriverID,
 		RideID:   ride_id,
-		Location: messagebrokerdto.Location{
+		Location: contracts.Location{
 			Lng: LocationUpdate.Longitude,
 			Lat: LocationUpdate.Latitude,
 		},
 		SpeedKmh:       LocationUpdate.SpeedKmh,
 		HeadingDegrees: LocationUpdate.HeadingDegrees,
-		Timestamp:      time.Now().String(),
 	}
-	d.driverService.UpdateLocation(d.ctx, dto.NewLocation{
+	d.driverService.UpdateLocation(ctx, dto.NewLocation{
 		Latitude:        LocationUpdate.Latitude,
 		Longitude:       LocationUpdate.Longitude,
 		Accuracy_meters: LocationUpdate.AccuracyMeters,
@@ -134,199 +152,284 @@ func (d *Distributor) handleDriverMessage(msg dto.DriverMessage) {
 		Heading_Degrees: LocationUpdate.HeadingDegrees,
 	}, msg.DriverID)
 
-	if err := d.broker.PublishJSON(context.Background(), "location_fanout", "location", rmMessage); err != nil {
+	if err := publishMessage(ctx, d.broker, "location_fanout", "location", rmMessage); err != nil {
 		log.Error("Failed to Publish location_fanout", err)
 	}
 }
 
 func (d *Distributor) handleRideRequest(requestDelivery amqp.Delivery) {
-	log := d.log.Action("handleRideRequest")
-	var req dto.RideDetails
-
-	if err := json.Unmarshal(requestDelivery.Body, &req); err != nil {
-		log.Error("Error Unmarshalling request:", err)
+	ctx, span := tracing.StartConsumer(d.ctx, rideRequestsQueue, requestDelivery)
+	defer span.End()
+	log := d.log.Action("handleRideRequest").WithContext(ctx)
+	var request contracts.RideRequested
+	env, err := contracts.Decode(requestDelivery.Body, &request)
+	if err != nil {
+		log.Error("Error decoding request:", err)
 		requestDelivery.Nack(false, false)
 		return
 	}
-	if len(d.wsManager.GetConnectedDrivers()) == 0 {
-		log.Info("No drivers online to handle ride request (sleeping):", "ride-id", req.Ride_id)
-		time.Sleep(7 * time.Second)
-		requestDelivery.Nack(false, true)
+	req := rideDetails(request, env.CorrelationID)
+	if d.wsManager.GetDriversCount(ctx) == 0 {
+		log.Info("No drivers online to handle ride request:", "ride-id", req.Ride_id)
+		d.retryRideRequest(ctx, requestDelivery, req.Ride_id, "no drivers online")
 		return
 	}
 	log.Info("Processing ride request:", req.Ride_id)
-	ctx := context.Background()
 	allDrivers, err := d.driverService.FindAppropriateDrivers(ctx,
 		req.Pickup_location.Lng,
-		req.Destination_location.Lat,
+		req.Pickup_location.Lat,
 		req.Ride_type,
 	)
 	if err != nil {
 		log.Error("Failed to get appropriate drivers from db:", err, "ride-id", req.Ride_id)
-		requestDelivery.Nack(false, false)
+		d.retryRideRequest(ctx, requestDelivery, req.Ride_id, "driver search failed")
 		return
 	}
 
 	var connectedDrivers []dto.DriverInfo
 	for _, driver := range allDrivers {
-		if d.wsManager.IsDriverConnected(driver.DriverId) {
+		if d.wsManager.IsDriverConnected(ctx, driver.DriverId) {
 			connectedDrivers = append(connectedDrivers, driver)
 		}
 	}
 	log.Info(fmt.Sprintf("Found %d connected drivers for ride %s", len(connectedDrivers), req.Ride_id))
-	d.sendRideOffers(connectedDrivers, req, requestDelivery)
+	d.sendRideOffers(ctx, connectedDrivers, req, requestDelivery)
 }
 
-func (d *Distributor) sendRideOffers(drivers []dto.DriverInfo, rideDetails dto.RideDetails, requestDelivery amqp.Delivery) {
-	log := d.log.Action("sendRideOffers")
-	_ = log
+func (d *Distributor) sendRideOffers(ctx context.Context, drivers []dto.DriverInfo, rideDetails dto.RideDetails, requestDelivery amqp.Delivery) {
+	log := d.log.Action("sendRideOffers").WithContext(ctx)
 
-	for _, driver := range drivers {
-		offer := websocketdto.RideOfferMessage{
-			WebSocketMessage: websocketdto.WebSocketMessage{
-				Type: websocketdto.MessageTypeRideOffer,
-			},
-			OfferID:    fmt.Sprintf("offer_%s_%s", rideDetails.Ride_id, driver.DriverId),
-			RideID:     rideDetails.Ride_id,
-			RideNumber: rideDetails.Ride_number,
-			PickupLocation: websocketdto.Location{
-				Latitude:  rideDetails.Pickup_location.Lat,
-				Longitude: rideDetails.Pickup_location.Lng,
-				Address:   rideDetails.Pickup_location.Address,
-			},
-			DestinationLocation: websocketdto.Location{
-				Latitude:  rideDetails.Destination_location.Lat,
-				Longitude: rideDetails.Destination_location.Lng,
-				Address:   rideDetails.Destination_location.Address,
-			},
-			EstimatedFare:                rideDetails.Estimated_fare,
-			DriverEarnings:               rideDetails.Estimated_fare * 0.8,
-			DistanceToPickupKm:           driver.Distance,
-			EstimatedRideDurationMinutes: int(driver.Distance / 0.75),
-			ExpiresAt:                    time.Now().Add(30 * time.Second),
-		}
-		log.Info("Sending message to driver:", offer)
-		d.wsManager.SendToDriver(context.Background(), driver.DriverId, offer)
-		driverResponse, err := d.wsManager.GetDriverMessages(driver.DriverId)
-		if err != nil {
-			log.Error("Failed to get messages for driver", err, driver.DriverId)
-			continue
-		}
-		select {
-		case data := <-driverResponse:
-			var response websocketdto.RideResponseMessage
-			if err := json.Unmarshal(data, &response); err != nil {
-				log.Error("Failed to unmarshal driver response:", err, driver.DriverId)
-				requestDelivery.Nack(false, false)
-				return
-			}
-			if response.Accepted {
-				d.handleDriverAcceptance(response, rideDetails, requestDelivery, driver)
-				return
-			} else {
-				continue
-			}
-		case <-time.After(30 * time.Second):
-			log.Info("No driver accepted the ride within timeout")
-			continue
-		}
+	result, ok := d.matcher.Match(ctx, rideDetails, drivers)
+	if ok {
+		d.handleDriverAcceptance(ctx, result.Response, rideDetails, requestDelivery, result.Driver)
+		return
 	}
+
 	log.Info("No drivers accepted this ride:", "RideID", rideDetails.Ride_id)
-	time.Sleep(7 * time.Second)
-	requestDelivery.Nack(false, true)
+	d.retryRideRequest(ctx, requestDelivery, rideDetails.Ride_id, "no driver accepted")
 }
 
-func (d *Distributor) handleDriverAcceptance(response websocketdto.RideResponseMessage, rideDetails dto.RideDetails, requestDelivery amqp.Delivery, driver dto.DriverInfo) {
-	log := d.log.Action("handleDriverAcceptance")
-	_ = log
-	driverMatch := dto.DriverMatchResponse{
-		Ride_id:                   rideDetails.Ride_id,
-		Driver_id:                 driver.DriverId,
-		Accepted:                  true,
-		Estimated_arrival_minutes: 5,
-		Driver_location: dto.Location{
-			Latitude:  response.CurrentLocation.Latitude,
-			Longitude: response.CurrentLocation.Longitude,
+func (d *Distributor) handleDriverAcceptance(ctx context.Context, response websocketdto.RideResponseMessage, rideDetails dto.RideDetails, requestDelivery amqp.Delivery, driver dto.DriverInfo) {
+	log := d.log.Action("handleDriverAcceptance").WithContext(ctx)
+
+	_, etaMinutes, err := d.driverService.CalculateRideDetails(ctx,
+		dto.Location{Latitude: response.CurrentLocation.Latitude, Longitude: response.CurrentLocation.Longitude},
+		dto.Location{Latitude: rideDetails.Pickup_location.Lat, Longitude: rideDetails.Pickup_location.Lng},
+	)
+	if err != nil {
+		log.Warn("Failed to estimate arrival", "ride_id", rideDetails.Ride_id, "error", err.Error())
+	}
+
+	driverMatch := contracts.DriverResponse{
+		RideID:                  rideDetails.Ride_id,
+		DriverID:                driver.DriverId,
+		Accepted:                true,
+		EstimatedArrivalMinutes: etaMinutes,
+		DriverLocation: contracts.Location{
+			Lat: response.CurrentLocation.Latitude,
+			Lng: response.CurrentLocation.Longitude,
 		},
-		Driver_info: dto.DriverInfoForResponse{
+		DriverInfo: contracts.DriverInfo{
 			Name:    driver.Name,
-			Vehicle: driver.Vehicle,
+			Vehicle: contracts.Vehicle(driver.Vehicle),
 			Rating:  driver.Rating,
 		},
 	}
 	requestDelivery.Ack(false)
-	d.broker.PublishJSON(d.ctx, "driver_topic", fmt.Sprintf("driver.response.%s", driver.DriverId), driverMatch)
+	if err := publishMessage(ctx, d.broker, driverExchangeName, fmt.Sprintf("driver.response.%s", driver.DriverId), driverMatch); err != nil {
+		log.Error("Failed to publish driver response", err, "ride_id", rideDetails.Ride_id)
+	}
 
-	log.Info("Ride accepted by driver", rideDetails.Ride_id, driverMatch.Driver_id)
+	log.Info("Ride accepted by driver", rideDetails.Ride_id, driverMatch.DriverID)
 }
 
 func (d *Distributor) handleRideStatus(statusDelivery amqp.Delivery) {
-	log := d.log.Action("handleRideStatus")
-	var status messagebrokerdto.RideStatus
-	if err := json.Unmarshal(statusDelivery.Body, &status); err != nil {
-		log.Error("Failed to unmarshal the ride response message: ", err, "Message", statusDelivery.Body)
+	ctx, span := tracing.StartConsumer(d.ctx, rideStatusQueue, statusDelivery)
+	defer span.End()
+	log := d.log.Action("handleRideStatus").WithContext(ctx)
+	var status contracts.RideStatusChanged
+	if _, err := contracts.Decode(statusDelivery.Body, &status); err != nil {
+		log.Error("Failed to decode the ride status message: ", err, "Message", statusDelivery.Body)
 		statusDelivery.Nack(false, false)
 		return
 	}
-	log.Info("Received ride status update:", status.RideId, status)
-	driverID, err := d.driverService.GetDriverIdByRideId(context.Background(), status.RideId)
+	log.Info("Received ride status update:", status.RideID, status)
+	driverID, err := d.driverService.GetDriverIdByRideId(ctx, status.RideID)
 	if err != nil {
-		log.Error("Failed to get driver ID by ride ID:", err, status.RideId)
+		log.Error("Failed to get driver ID by ride ID:", err, status.RideID)
 		statusDelivery.Nack(false, true)
 		return
 	}
+	// каждое сообщение применяется один раз: id пишется в транзакции выплаты/смены статуса
 	switch status.Status {
 	case "CANCELLED":
+		err := d.driverService.SettleDriver(ctx, statusDelivery.MessageId, driverID, status.FinalFare, "AVAILABLE")
+		if errors.Is(err, inbox.ErrAlreadyProcessed) {
+			log.Info("Ride cancelation already processed", "message_id", statusDelivery.MessageId, "ride_id", status.RideID)
+			statusDelivery.Ack(false)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to settle cancelled ride:", err, status.RideID)
+			statusDelivery.Nack(false, true)
+			return
+		}
+		log.Info("Driver status changed:", driverID)
+
 		cancelMessage := websocketdto.CanceledOrderMessage{
 			WebSocketMessage: websocketdto.WebSocketMessage{
 				Type: "Info",
 			},
-			RideID:  status.RideId,
+			RideID:  status.RideID,
 			Status:  "canceled",
 			Message: "Order was canceled",
 		}
-		d.wsManager.SendToDriver(d.ctx, driverID, cancelMessage)
-		log.Info("Processing ride cancelation:", status.RideId)
-		d.driverService.PayDriverMoney(d.ctx, driverID, status.Final_fare)
-		d.driverService.UpdateDriverStatus(d.ctx, driverID, "AVAILABLE")
-		log.Info("Driver status changed:", driverID)
+		d.wsManager.SendToDriver(ctx, driverID, cancelMessage)
+		log.Info("Processing ride cancelation:", status.RideID)
 		statusDelivery.Ack(false)
 
 	case "MATCHED":
-		rideDetails, err := d.driverService.GetRideDetailsByRideId(context.Background(), status.RideId)
+		rideDetails, err := d.driverService.GetRideDetailsByRideId(ctx, status.RideID)
 		if err != nil {
-			log.Error("Failed to get ride details by ride ID:", err, status.RideId)
+			log.Error("Failed to get ride details by ride ID:", err, status.RideID)
 			statusDelivery.Nack(false, true)
 			return
 		}
 		rideDetails.WebSocketMessage = websocketdto.WebSocketMessage{
 			Type: websocketdto.MessageTypeRideDetails,
 		}
-		d.driverService.UpdateDriverStatus(context.Background(), driverID, "EN_ROUTE")
-		log.Info("Driver status changed:", driverID)
-
-		driverStatus := messagebrokerdto.DriverStatus{
-			DriverID:  driverID,
-			RideID:    status.RideId,
-			Status:    "EN_ROUTE",
-			Timestamp: time.Now().String(),
+		err = d.driverService.ChangeDriverStatus(ctx, statusDelivery.MessageId, driverID, status.RideID, "EN_ROUTE")
+		if errors.Is(err, inbox.ErrAlreadyProcessed) {
+			log.Info("Ride match already processed", "message_id", statusDelivery.MessageId, "ride_id", status.RideID)
+			statusDelivery.Ack(false)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to change driver status:", err, driverID)
+			statusDelivery.Nack(false, true)
+			return
 		}
-		d.broker.PublishJSON(d.ctx, "driver_topic", fmt.Sprintf("driver.status.%s", driverID), driverStatus)
-		log.Info("Driver status send to rabbitmq", driverID)
+		log.Info("Driver status changed:", driverID)
 
-		d.wsManager.SendToDriver(context.Background(), driverID, rideDetails)
-		log.Info("Processing ride status update:", status.RideId)
+		d.wsManager.SendToDriver(ctx, driverID, rideDetails)
+		log.Info("Processing ride status update:", status.RideID)
 
 		statusDelivery.Ack(false)
 	case "COMPLETED":
-		log.Info("ride completed", "final_fare", status.Final_fare)
-		err := d.driverService.PayDriverMoney(d.ctx, driverID, status.Final_fare)
+		log.Info("ride completed", "final_fare", status.FinalFare)
+		err := d.driverService.SettleDriver(ctx, statusDelivery.MessageId, driverID, status.FinalFare, "")
+		if errors.Is(err, inbox.ErrAlreadyProcessed) {
+			log.Info("Ride payout already processed", "message_id", statusDelivery.MessageId, "ride_id", status.RideID)
+			statusDelivery.Ack(false)
+			return
+		}
 		if err != nil {
 			log.Error("Failed to pay money to driver:", err)
 			statusDelivery.Nack(false, false)
+			return
 		}
+		statusDelivery.Ack(false)
 	default:
 		log.Warn("Ride status message undefined (sending to trash queue)", "status", status.Status)
 		statusDelivery.Nack(false, false)
 	}
 }
+
+// handleDestinationChange forwards the passenger's new destination, or the
+// outcome of the driver's answer to it, to the driver. A driver that is not
+// connected cannot answer, the change expires on the ride-service side.
+func (d *Distributor) handleDestinationChange(destDelivery amqp.Delivery) {
+	defer d.wg.Done()
+	ctx, span := tracing.StartConsumer(d.ctx, rideDestinationQueue, destDelivery)
+	defer span.End()
+	log := d.log.Action("handleDestinationChange").WithContext(ctx)
+
+	var env contracts.Envelope
+	if err := json.Unmarshal(destDelivery.Body, &env); err == nil && env.Type == (contracts.DestinationChangeResolved{}).MessageType() {
+		d.handleDestinationChangeResult(ctx, destDelivery)
+		return
+	}
+
+	var change contracts.DestinationChangeRequested
+	if _, err := contracts.Decode(destDelivery.Body, &change); err != nil {
+		log.Error("Failed to decode destination change: ", err)
+		destDelivery.Nack(false, false)
+		return
+	}
+
+	if !d.wsManager.IsDriverConnected(ctx, change.DriverID) {
+		log.Warn("Driver is not connected, destination change will expire", "ride_id", change.RideID, "driver_id", change.DriverID)
+		destDelivery.Ack(false)
+		return
+	}
+
+	msg := websocketdto.DestinationChangeMessage{
+		WebSocketMessage: websocketdto.WebSocketMessage{
+			Type: websocketdto.MessageTypeDestinationChange,
+		},
+		ChangeID: change.ChangeID,
+		RideID:   change.RideID,
+		NewDestination: websocketdto.Location{
+			Latitude:  change.NewDestination.Lat,
+			Longitude: change.NewDestination.Lng,
+			Address:   change.NewDestination.Address,
+		},
+		DistanceKm: change.DistanceKm,
+		OldFare:    change.OldFare,
+		NewFare:    change.NewFare,
+		ExpiresAt:  change.ExpiresAt,
+	}
+	if err := d.wsManager.SendToDriver(ctx, change.DriverID, msg); err != nil {
+		log.Error("Failed to send destination change to driver", err, "driver_id", change.DriverID)
+	}
+	log.Info("Destination change sent to driver", "ride_id", change.RideID, "change_id", change.ChangeID)
+	destDelivery.Ack(false)
+}
+
+func (d *Distributor) handleDestinationChangeResult(ctx context.Context, destDelivery amqp.Delivery) {
+	log := d.log.Action("handleDestinationChangeResult").WithContext(ctx)
+
+	var result contracts.DestinationChangeResolved
+	if _, err := contracts.Decode(destDelivery.Body, &result); err != nil {
+		log.Error("Failed to decode destination change result: ", err)
+		destDelivery.Nack(false, false)
+		return
+	}
+
+	msg := websocketdto.DestinationChangeResultMessage{
+		WebSocketMessage: websocketdto.WebSocketMessage{
+			Type: websocketdto.MessageTypeDestinationChangeResult,
+		},
+		ChangeID: result.ChangeID,
+		RideID:   result.RideID,
+		Status:   result.Status,
+	}
+	if err := d.wsManager.SendToDriver(ctx, result.DriverID, msg); err != nil {
+		log.Warn("Failed to send destination change result to driver", "driver_id", result.DriverID, "error", err.Error())
+	}
+	log.Info("Destination change result sent to driver", "ride_id", result.RideID, "change_id", result.ChangeID, "status", result.Status)
+	destDelivery.Ack(false)
+}
+
+// rideDetails переводит запрос поездки из контракта в модель матчинга
+func rideDetails(req contracts.RideRequested, correlationID string) dto.RideDetails {
+	details := dto.RideDetails{
+		Ride_id:              req.RideID,
+		Ride_number:          req.RideNumber,
+		Pickup_location:      locationDetail(req.PickupLocation),
+		Destination_location: locationDetail(req.DestinationLocation),
+		Ride_type:            req.RideType,
+		Estimated_fare:       req.EstimatedFare,
+		Max_distance_km:      req.MaxDistanceKm,
+		Timeout_seconds:      req.TimeoutSeconds,
+		Correlation_id:       correlationID,
+	}
+	for _, stop := range req.Stops {
+		details.Stops = append(details.Stops, locationDetail(stop))
+	}
+	return details
+}
+
+func locationDetail(l contracts.Location) dto.LocationDetail {
+	return dto.LocationDetail{Lat: l.Lat, Lng: l.Lng, Address: l.Address}
+}
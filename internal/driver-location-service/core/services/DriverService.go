@@ -5,14 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"time"
+	"math"
 
+	"ride-hail/internal/config"
+	"ride-hail/internal/contracts"
 	"ride-hail/internal/driver-location-service/core/domain/dto"
 	"ride-hail/internal/driver-location-service/core/domain/model"
 	"ride-hail/internal/driver-location-service/core/myerrors"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/pricing"
+	"ride-hail/internal/routing"
 
-	messagebrokerdto "ride-hail/internal/driver-location-service/core/domain/message_broker_dto"
 	websocketdto "ride-hail/internal/driver-location-service/core/domain/websocket_dto"
 	driven "ride-hail/internal/driver-location-service/core/ports/driven"
 	ports "ride-hail/internal/driver-location-service/core/ports/driven"
@@ -26,10 +29,14 @@ type DriverService struct {
 	repositories driven.IDriverRepository
 	log          mylogger.Logger
 	broker       ports.IDriverBroker
+	ranker       driven.DriverRanker
+	rankCfg      *config.Rankingconfig
+	pricing      driven.IPricingEngine
+	router       driven.IRouter
 }
 
-func NewDriverService(repositories driven.IDriverRepository, log mylogger.Logger, broker ports.IDriverBroker) *DriverService {
-	return &DriverService{repositories: repositories, log: log, broker: broker}
+func NewDriverService(repositories driven.IDriverRepository, log mylogger.Logger, broker ports.IDriverBroker, ranker driven.DriverRanker, rankCfg *config.Rankingconfig, pricing driven.IPricingEngine, router driven.IRouter) *DriverService {
+	return &DriverService{repositories: repositories, log: log, broker: broker, ranker: ranker, rankCfg: rankCfg, pricing: pricing, router: router}
 }
 
 func (ds *DriverService) GoOnline(ctx context.Context, coordDTO dto.DriverCoordinatesDTO) (dto.DriverOnlineResponse, error) {
@@ -88,13 +95,12 @@ func (ds *DriverService) UpdateLocation(ctx context.Context, request dto.NewLoca
 		if err != nil {
 			l.Error("Failed to get ride id by driver id: ", err, "DriverID", driver_id)
 		}
-		driverStatus := messagebrokerdto.DriverStatus{
-			DriverID:  driver_id,
-			RideID:    rideID,
-			Status:    "ARRIVED",
-			Timestamp: time.Now().String(),
+		driverStatus := contracts.DriverStatusChanged{
+			DriverID: driver_id,
+			RideID:   rideID,
+			Status:   "ARRIVED",
 		}
-		ds.broker.PublishJSON(context.Background(), "driver_topic", fmt.Sprintf("driver.status.%s", driver_id), driverStatus)
+		publishMessage(context.WithoutCancel(ctx), ds.broker, driverExchangeName, fmt.Sprintf("driver.status.%s", driver_id), driverStatus)
 		l.Info("Driver status send to rabbitmq", driver_id, "STATUS", driverStatus)
 	}
 	var responseDTO dto.NewLocationResponse
@@ -147,21 +153,16 @@ func (ds *DriverService) StartRide(ctx context.Context, msg dto.StartRide) (dto.
 		return dto.StartRideResponse{}, fmt.Errorf("driver too far from pickup (%.1fm > %.0fm)", dist, maxPickupDistanceMeters)
 	}
 
-	// 5️⃣ Запускаем транзакционный апдейт
-	res, err := ds.repositories.StartRideTx(ctx, driverID, msg.Ride_id)
+	// 5️⃣ Запускаем транзакционный апдейт; driver.status.{driver_id} уходит через outbox
+	statusMsg, err := driverStatusMessage(ctx, driverID, msg.Ride_id, "BUSY")
 	if err != nil {
-		l.Error("repository.StartRideTx failed", err)
 		return dto.StartRideResponse{}, err
 	}
-	// Driver.statis.{driver_id}
-	driverStatus := messagebrokerdto.DriverStatus{
-		DriverID:  msg.Driver_location.Driver_id,
-		RideID:    msg.Ride_id,
-		Status:    "BUSY",
-		Timestamp: time.Now().String(),
+	res, err := ds.repositories.StartRideTx(ctx, driverID, msg.Ride_id, statusMsg)
+	if err != nil {
+		l.Error("repository.StartRideTx failed", err)
+		return dto.StartRideResponse{}, err
 	}
-	ds.broker.PublishJSON(context.Background(), "driver_topic", fmt.Sprintf("driver.status.%s", msg.Driver_location.Driver_id), driverStatus)
-	l.Info("Driver status send to rabbitmq", msg.Driver_location.Driver_id)
 
 	l.Info("success", "ride_id", res.Ride_id, "status", res.Status, "started_at", res.Started_at)
 	return dto.StartRideResponse{
@@ -218,11 +219,36 @@ func (ds *DriverService) CompleteRide(ctx context.Context, request dto.RideCompl
 		return dto.RideCompleteResponse{}, fmt.Errorf("driver too far from destination (%.1fm > %.0fm)", distance, maxCompleteDistanceMeters)
 	}
 
-	// 4️⃣ Транзакционно завершаем
+	// 4️⃣ Считаем финальную стоимость по фактическим дистанции и времени;
+	// поездка с зафиксированной котировкой стоит столько, сколько согласовано
+	ridePricing, err := ds.repositories.GetRidePricing(ctx, request.Ride_id)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			l.Error("Failed to connect to connect to db", err)
+			return dto.RideCompleteResponse{}, myerrors.ErrDBConnClosedMsg
+		}
+		l.Error("get ride pricing failed", err)
+		return dto.RideCompleteResponse{}, err
+	}
+	fare := ridePricing.LockedFare
+	if !ridePricing.PriceLocked {
+		fare, err = ds.pricing.Quote(ridePricing.VehicleType, pricing.Trip{
+			DistanceKm:      request.ActualDistancekm,
+			DurationMinutes: request.ActualDurationm,
+			SurgeMultiplier: ridePricing.SurgeMultiplier,
+		})
+		if err != nil {
+			l.Error("pricing failed", err, "vehicle_type", ridePricing.VehicleType)
+			return dto.RideCompleteResponse{}, err
+		}
+	}
+
+	// 5️⃣ Транзакционно завершаем
 	reqDAO := model.RideCompleteForm{
 		Ride_id:          request.Ride_id,
 		ActualDistancekm: request.ActualDistancekm,
 		ActualDurationm:  request.ActualDurationm,
+		FinalFare:        fare,
 		FinalLocation: model.Location{
 			Latitude:  request.FinalLocation.Latitude,
 			Longitude: request.FinalLocation.Longitude,
@@ -230,7 +256,12 @@ func (ds *DriverService) CompleteRide(ctx context.Context, request dto.RideCompl
 		},
 	}
 
-	resDAO, err := ds.repositories.CompleteRideTx(ctx, reqDAO)
+	// driver.status.{driver_id} уходит через outbox вместе с коммитом
+	statusMsg, err := driverStatusMessage(ctx, driverID, request.Ride_id, "COMPLETED")
+	if err != nil {
+		return dto.RideCompleteResponse{}, err
+	}
+	resDAO, err := ds.repositories.CompleteRideTx(ctx, reqDAO, statusMsg)
 	if err != nil {
 		if errors.Is(err, myerrors.ErrDBConnClosed) {
 			l.Error("Failed to connect to connect to db", err)
@@ -239,18 +270,9 @@ func (ds *DriverService) CompleteRide(ctx context.Context, request dto.RideCompl
 		l.Error("repository.CompleteRideTx failed", err)
 		return dto.RideCompleteResponse{}, err
 	}
-	// Driver.statis.{driver_id}
-	driverStatus := messagebrokerdto.DriverStatus{
-		DriverID:  driverID,
-		RideID:    request.Ride_id,
-		Status:    "AVAILABLE",
-		Timestamp: time.Now().String(),
-	}
-	ds.broker.PublishJSON(context.Background(), "driver_topic", fmt.Sprintf("driver.status.%s", driverID), driverStatus)
-	l.Info("Driver status send to rabbitmq", driverID)
 
 	// <<<<<<< HEAD
-	// 5️⃣ Формируем DTO
+	// 6️⃣ Формируем DTO
 	resp := dto.RideCompleteResponse{
 		Message:       resDAO.Message,
 		Ride_id:       resDAO.Ride_id,
@@ -262,17 +284,47 @@ func (ds *DriverService) CompleteRide(ctx context.Context, request dto.RideCompl
 	return resp, nil
 }
 
+// FindAppropriateDrivers searches around the pickup, widening the radius
+// while nobody is found, and returns the candidates best first
 func (ds *DriverService) FindAppropriateDrivers(ctx context.Context, longtitude, latitude float64, vehicleType string) ([]dto.DriverInfo, error) {
-	drivers, err := ds.repositories.FindDrivers(ctx, longtitude, latitude, vehicleType)
-	if err != nil {
-		if errors.Is(err, myerrors.ErrDBConnClosed) {
-			return []dto.DriverInfo{}, myerrors.ErrDBConnClosedMsg
+	l := ds.log.Action("FindAppropriateDrivers")
+
+	limit := ds.rankCfg.CandidateLimit
+	if limit <= 0 {
+		limit = 10
+	}
+	radius := float64(ds.rankCfg.RadiusMeters)
+	maxRadius := math.Max(radius, float64(ds.rankCfg.MaxRadiusMeters))
+
+	var drivers []model.DriverInfo
+	for {
+		// fetch a wider pool than we return so the ranker has a choice
+		found, err := ds.repositories.FindDrivers(ctx, longtitude, latitude, vehicleType, radius, limit*3)
+		if err != nil {
+			if errors.Is(err, myerrors.ErrDBConnClosed) {
+				return []dto.DriverInfo{}, myerrors.ErrDBConnClosedMsg
+			}
+			l.Error("Failed to find drivers", err, "radius_m", radius)
+			return []dto.DriverInfo{}, err
+		}
+		drivers = found
+		if len(drivers) > 0 || radius >= maxRadius {
+			break
 		}
-		fmt.Println("Service Error Arrived ", err)
-		return []dto.DriverInfo{}, err
+		radius = math.Min(radius*2, maxRadius)
+		l.Info("No drivers found, expanding search radius", "radius_m", radius)
 	}
+
+	ranked := ds.ranker.Rank(radius, drivers)
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
 	var results []dto.DriverInfo
-	for _, driver := range drivers {
+	for i, scored := range ranked {
+		driver := scored.Driver
+		l.Debug("Candidate score", "rank", i+1, "driver_id", driver.DriverId, "explain", scored.Explain())
+
 		var result dto.DriverInfo
 		result.DriverId = driver.DriverId
 		result.Email = driver.Email
@@ -281,8 +333,10 @@ func (ds *DriverService) FindAppropriateDrivers(ctx context.Context, longtitude,
 		result.Rating = driver.Rating
 		result.Name = driver.Name
 		result.Distance = driver.Distance
+		result.Score = scored.Score
+		result.EtaMinutes = scored.EtaMinutes
 		if err := json.Unmarshal(driver.Vehicle, &result.Vehicle); err != nil {
-			fmt.Println("Service Error Arrived ", err)
+			l.Error("Failed to unmarshal vehicle", err, "driver_id", driver.DriverId)
 			return []dto.DriverInfo{}, err
 		}
 		results = append(results, result)
@@ -290,28 +344,55 @@ func (ds *DriverService) FindAppropriateDrivers(ctx context.Context, longtitude,
 	return results, nil
 }
 
+func (ds *DriverService) RecordOfferOutcome(ctx context.Context, driverID string, accepted bool) error {
+	err := ds.repositories.RecordOfferOutcome(ctx, driverID, accepted)
+	if errors.Is(err, myerrors.ErrDBConnClosed) {
+		return myerrors.ErrDBConnClosedMsg
+	}
+	return err
+}
+
+// CalculateRideDetails оценивает дорогу водителя до пассажира: километры и минуты
 func (ds *DriverService) CalculateRideDetails(ctx context.Context, driverLocation dto.Location, passagerLocation dto.Location) (float64, int, error) {
-	distance, err := ds.repositories.CalculateRideDetails(ctx,
-		model.Location{
-			Latitude:  driverLocation.Latitude,
-			Longitude: driverLocation.Longitude,
-		},
-		model.Location{
-			Latitude:  passagerLocation.Latitude,
-			Longitude: passagerLocation.Longitude,
-		},
-	)
+	route, err := ds.router.Route(ctx, []routing.Point{
+		{Lat: driverLocation.Latitude, Lng: driverLocation.Longitude},
+		{Lat: passagerLocation.Latitude, Lng: passagerLocation.Longitude},
+	})
 	if err != nil {
 		return 0, 0, err
 	}
-	minutes := int(distance / 45)
-	return distance, minutes, nil
+	return route.DistanceKm, int(math.Ceil(route.DurationMinutes)), nil
+}
+
+// EstimateTrip оценивает саму поездку: от посадки через все остановки до места назначения
+func (ds *DriverService) EstimateTrip(ctx context.Context, ride dto.RideDetails) (float64, int, error) {
+	points := []routing.Point{{Lat: ride.Pickup_location.Lat, Lng: ride.Pickup_location.Lng}}
+	for _, stop := range ride.Stops {
+		points = append(points, routing.Point{Lat: stop.Lat, Lng: stop.Lng})
+	}
+	points = append(points, routing.Point{Lat: ride.Destination_location.Lat, Lng: ride.Destination_location.Lng})
+
+	route, err := ds.router.Route(ctx, points)
+	if err != nil {
+		return 0, 0, err
+	}
+	return route.DistanceKm, int(math.Ceil(route.DurationMinutes)), nil
 }
 
 func (d *DriverService) UpdateDriverStatus(ctx context.Context, driver_id string, status string) error {
 	return d.repositories.UpdateDriverStatus(ctx, driver_id, status)
 }
 
+// ChangeDriverStatus меняет статус водителя по сообщению messageID и публикует
+// driver.status.{driver_id} через outbox; повтор сообщения — inbox.ErrAlreadyProcessed
+func (d *DriverService) ChangeDriverStatus(ctx context.Context, messageID, driverID, rideID, status string) error {
+	msg, err := driverStatusMessage(ctx, driverID, rideID, status)
+	if err != nil {
+		return err
+	}
+	return d.repositories.ApplyRideStatus(ctx, messageID, model.DriverUpdate{DriverID: driverID, Status: status}, msg)
+}
+
 func (d *DriverService) CheckDriverById(ctx context.Context, driver_id string) (bool, error) {
 	return d.repositories.CheckDriverById(ctx, driver_id)
 }
@@ -347,6 +428,9 @@ func (d *DriverService) GetRideDetailsByRideId(ctx context.Context, ride_id stri
 		Longitude: rideDetailsModel.PickupLocation.Longitude,
 		Address:   rideDetailsModel.PickupLocation.Address,
 	}
+	for _, stop := range rideDetailsModel.Stops {
+		rideDetails.Stops = append(rideDetails.Stops, toStopMessage(stop))
+	}
 	tempStruct := struct {
 		PhoneNumer string `json:"phone"`
 	}{}
@@ -357,6 +441,61 @@ func (d *DriverService) GetRideDetailsByRideId(ctx context.Context, ride_id stri
 	return rideDetails, nil
 }
 
+// ArriveAtStop отмечает остановку, до которой доехал водитель
+func (ds *DriverService) ArriveAtStop(ctx context.Context, driverID, rideID string, stopIndex int) (websocketdto.StopUpdateMessage, error) {
+	log := ds.log.Action("ArriveAtStop").With("driver_id", driverID, "ride_id", rideID)
+
+	stop, remaining, err := ds.repositories.ArriveAtStopTx(ctx, driverID, rideID, stopIndex)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			return websocketdto.StopUpdateMessage{}, myerrors.ErrDBConnClosedMsg
+		}
+		log.Warn("Stop arrival rejected", "stop_index", stopIndex, "error", err.Error())
+		return websocketdto.StopUpdateMessage{}, err
+	}
+
+	log.Info("Driver arrived at stop", "stop_index", stop.StopIndex, "remaining_stops", remaining)
+	return websocketdto.StopUpdateMessage{
+		WebSocketMessage: websocketdto.WebSocketMessage{
+			Type: websocketdto.MessageTypeStopUpdate,
+		},
+		RideID:         rideID,
+		Stop:           toStopMessage(stop),
+		RemainingStops: remaining,
+	}, nil
+}
+
+// RespondDestinationChange передает ответ водителя в ride-service, который и меняет маршрут
+func (ds *DriverService) RespondDestinationChange(ctx context.Context, driverID string, msg websocketdto.DestinationChangeResponseMessage) error {
+	log := ds.log.Action("RespondDestinationChange").With("driver_id", driverID, "ride_id", msg.RideID)
+
+	answer := contracts.DestinationChangeAnswered{
+		ChangeID: msg.ChangeID,
+		RideID:   msg.RideID,
+		DriverID: driverID,
+		Accepted: msg.Accepted,
+	}
+	if err := publishMessage(ctx, ds.broker, driverExchangeName, fmt.Sprintf("driver.destination.%s", driverID), answer); err != nil {
+		log.Error("Failed to publish destination change answer", err)
+		return err
+	}
+
+	log.Info("Destination change answered", "change_id", msg.ChangeID, "accepted", msg.Accepted)
+	return nil
+}
+
+func toStopMessage(stop model.RideStop) websocketdto.Stop {
+	return websocketdto.Stop{
+		StopIndex: stop.StopIndex,
+		Location: websocketdto.Location{
+			Latitude:  stop.Location.Latitude,
+			Longitude: stop.Location.Longitude,
+			Address:   stop.Location.Address,
+		},
+		ArrivedAt: stop.ArrivedAt,
+	}
+}
+
 func (d *DriverService) CheckDriverStatus(ctx context.Context, driver_id string) (string, error) {
 	return d.repositories.CheckDriverStatus(ctx, driver_id)
 }
@@ -375,8 +514,10 @@ func (ds *DriverService) RequireActiveRide(ctx context.Context, driverID string)
 	return nil
 }
 
-func (ds *DriverService) PayDriverMoney(ctx context.Context, driver_id string, amount float64) error {
-	return ds.repositories.PayDriverMoney(ctx, driver_id, amount)
+// SettleDriver выплачивает водителю amount по сообщению messageID и, если status
+// не пустой, меняет его статус; повтор сообщения — inbox.ErrAlreadyProcessed
+func (ds *DriverService) SettleDriver(ctx context.Context, messageID, driverID string, amount float64, status string) error {
+	return ds.repositories.ApplyRideStatus(ctx, messageID, model.DriverUpdate{DriverID: driverID, Payout: amount, Status: status})
 }
 
 func (ds *DriverService) GracefullShutdown(ctx context.Context) error {
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"ride-hail/internal/config"
+	"ride-hail/internal/mylogger"
+
+	dto "ride-hail/internal/driver-location-service/core/domain/dto"
+	websocketdto "ride-hail/internal/driver-location-service/core/domain/websocket_dto"
+	driven "ride-hail/internal/driver-location-service/core/ports/driven"
+	ports "ride-hail/internal/driver-location-service/core/ports/driver"
+)
+
+const (
+	OfferExpiredTaken   = "accepted_by_another_driver"
+	OfferExpiredTimeout = "timeout"
+	OfferExpiredStale   = "offer_no_longer_valid"
+)
+
+// Matcher offers a ride to drivers in waves. Every driver in a wave gets the
+// offer at the same time; the first acceptance wins and everyone else still
+// holding the offer receives offer_expired.
+type Matcher struct {
+	wsManager     driven.WSConnectionMeneger
+	driverService ports.IDriverService
+	log           mylogger.Logger
+
+	matchTimeout time.Duration
+	offerTimeout time.Duration
+	waveSize     int
+
+	// a driver holds at most one offer, so only one goroutine ever reads
+	// their response channel
+	mu      sync.Mutex
+	offered map[string]string // driver_id -> offer_id
+}
+
+type MatchResult struct {
+	Driver   dto.DriverInfo
+	Response websocketdto.RideResponseMessage
+}
+
+type offerReply struct {
+	driver   dto.DriverInfo
+	offerID  string
+	response websocketdto.RideResponseMessage
+	ok       bool // false if the driver disconnected
+}
+
+func NewMatcher(cfg *config.Matchingconfig, wsManager driven.WSConnectionMeneger, driverService ports.IDriverService, log mylogger.Logger) *Matcher {
+	m := &Matcher{
+		wsManager:     wsManager,
+		driverService: driverService,
+		log:           log,
+		matchTimeout:  time.Duration(cfg.MatchSeconds) * time.Second,
+		offerTimeout:  time.Duration(cfg.OfferSeconds) * time.Second,
+		waveSize:      cfg.WaveSize,
+		offered:       make(map[string]string),
+	}
+	if m.waveSize <= 0 {
+		m.waveSize = 1
+	}
+	if m.offerTimeout <= 0 || m.offerTimeout > m.matchTimeout {
+		m.offerTimeout = m.matchTimeout
+	}
+	return m
+}
+
+// Match runs waves over the candidates, best first, until a driver accepts,
+// candidates run out or the match timeout passes. ok is false if nobody accepted.
+func (m *Matcher) Match(ctx context.Context, ride dto.RideDetails, candidates []dto.DriverInfo) (MatchResult, bool) {
+	log := m.log.Action("Match").With("ride_id", ride.Ride_id)
+
+	ctx, cancel := context.WithTimeout(ctx, m.matchTimeout)
+	defer cancel()
+
+	// the trip itself is the same for every driver
+	_, rideMinutes, err := m.driverService.EstimateTrip(ctx, ride)
+	if err != nil {
+		log.Warn("Failed to estimate the trip", "error", err.Error())
+	}
+
+	for wave := 1; len(candidates) > 0; wave++ {
+		batch := make([]dto.DriverInfo, 0, m.waveSize)
+		for len(candidates) > 0 && len(batch) < m.waveSize {
+			batch = append(batch, candidates[0])
+			candidates = candidates[1:]
+		}
+
+		log.Info("Sending offer wave", "wave", wave, "drivers", len(batch))
+		if res, ok := m.runWave(ctx, ride, rideMinutes, batch); ok {
+			return res, true
+		}
+		if ctx.Err() != nil {
+			log.Info("Match timeout reached", "wave", wave)
+			return MatchResult{}, false
+		}
+	}
+
+	return MatchResult{}, false
+}
+
+func (m *Matcher) runWave(ctx context.Context, ride dto.RideDetails, rideMinutes int, batch []dto.DriverInfo) (MatchResult, bool) {
+	log := m.log.Action("runWave").With("ride_id", ride.Ride_id)
+
+	waveCtx, cancel := context.WithTimeout(ctx, m.offerTimeout)
+	defer cancel()
+
+	expiresAt := time.Now().Add(m.offerTimeout)
+	if deadline, ok := waveCtx.Deadline(); ok {
+		expiresAt = deadline
+	}
+
+	replies := make(chan offerReply, len(batch))
+	pending := make(map[string]offerReply, len(batch)) // driver_id -> offer
+
+	for _, driver := range batch {
+		offerID := fmt.Sprintf("offer_%s_%s", ride.Ride_id, driver.DriverId)
+		if !m.reserve(driver.DriverId, offerID) {
+			log.Debug("Driver already holds an offer, skipping", "driver_id", driver.DriverId)
+			continue
+		}
+
+		responses, err := m.wsManager.GetDriverMessages(driver.DriverId)
+		if err != nil {
+			m.release(driver.DriverId, offerID)
+			log.Warn("Driver disconnected before the offer", "driver_id", driver.DriverId)
+			continue
+		}
+
+		if err := m.wsManager.SendToDriver(ctx, driver.DriverId, m.buildOffer(ctx, ride, driver, rideMinutes, offerID, expiresAt)); err != nil {
+			m.release(driver.DriverId, offerID)
+			log.Warn("Failed to send offer", "driver_id", driver.DriverId, "error", err.Error())
+			continue
+		}
+
+		pending[driver.DriverId] = offerReply{driver: driver, offerID: offerID}
+		go m.awaitReply(waveCtx, driver, offerID, responses, replies)
+	}
+
+	defer func() {
+		// stop the readers before the drivers can be offered another ride
+		cancel()
+		for driverID, offer := range pending {
+			m.release(driverID, offer.offerID)
+		}
+	}()
+
+	for len(pending) > 0 {
+		select {
+		case reply := <-replies:
+			delete(pending, reply.driver.DriverId)
+			m.release(reply.driver.DriverId, reply.offerID)
+
+			if !reply.ok {
+				log.Info("Driver disconnected during the offer", "driver_id", reply.driver.DriverId)
+				continue
+			}
+			if !reply.response.Accepted {
+				log.Info("Driver declined the offer", "driver_id", reply.driver.DriverId)
+				m.recordOutcome(reply.driver.DriverId, false)
+				continue
+			}
+
+			// Replies are handled one at a time here, so the first accept wins
+			log.Info("Driver accepted the offer", "driver_id", reply.driver.DriverId)
+			m.recordOutcome(reply.driver.DriverId, true)
+			m.withdraw(ride.Ride_id, pending, OfferExpiredTaken)
+			return MatchResult{Driver: reply.driver, Response: reply.response}, true
+
+		case <-waveCtx.Done():
+			m.withdraw(ride.Ride_id, pending, OfferExpiredTimeout)
+			// an offer left to time out counts against the acceptance rate
+			for driverID := range pending {
+				m.recordOutcome(driverID, false)
+			}
+			return MatchResult{}, false
+		}
+	}
+
+	return MatchResult{}, false
+}
+
+// awaitReply reads the driver's responses until the one for offerID arrives.
+// Responses to older offers are answered with offer_expired.
+func (m *Matcher) awaitReply(ctx context.Context, driver dto.DriverInfo, offerID string, responses <-chan []byte, replies chan<- offerReply) {
+	log := m.log.Action("awaitReply").With("driver_id", driver.DriverId)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data, ok := <-responses:
+			if !ok {
+				replies <- offerReply{driver: driver, offerID: offerID}
+				return
+			}
+
+			var response websocketdto.RideResponseMessage
+			if err := json.Unmarshal(data, &response); err != nil {
+				log.Error("Failed to unmarshal driver response", err)
+				continue
+			}
+
+			if response.OfferID != offerID {
+				if response.Accepted {
+					m.expire(driver.DriverId, response.OfferID, response.RideID, OfferExpiredStale)
+				}
+				continue
+			}
+
+			replies <- offerReply{driver: driver, offerID: offerID, response: response, ok: true}
+			return
+		}
+	}
+}
+
+func (m *Matcher) withdraw(rideID string, pending map[string]offerReply, reason string) {
+	for driverID, offer := range pending {
+		m.expire(driverID, offer.offerID, rideID, reason)
+	}
+}
+
+func (m *Matcher) expire(driverID, offerID, rideID, reason string) {
+	msg := websocketdto.OfferExpiredMessage{
+		WebSocketMessage: websocketdto.WebSocketMessage{
+			Type: websocketdto.MessageTypeOfferExpired,
+		},
+		OfferID: offerID,
+		RideID:  rideID,
+		Reason:  reason,
+	}
+	if err := m.wsManager.SendToDriver(context.Background(), driverID, msg); err != nil {
+		m.log.Action("expire").Debug("Could not deliver offer_expired", "driver_id", driverID, "error", err.Error())
+	}
+}
+
+// recordOutcome updates the driver's acceptance stats used by the ranker
+func (m *Matcher) recordOutcome(driverID string, accepted bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := m.driverService.RecordOfferOutcome(ctx, driverID, accepted); err != nil {
+		m.log.Action("recordOutcome").Warn("Failed to record offer outcome", "driver_id", driverID, "error", err.Error())
+	}
+}
+
+func (m *Matcher) reserve(driverID, offerID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, busy := m.offered[driverID]; busy {
+		return false
+	}
+	m.offered[driverID] = offerID
+	return true
+}
+
+func (m *Matcher) release(driverID, offerID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.offered[driverID] == offerID {
+		delete(m.offered, driverID)
+	}
+}
+
+func (m *Matcher) buildOffer(ctx context.Context, ride dto.RideDetails, driver dto.DriverInfo, rideMinutes int, offerID string, expiresAt time.Time) websocketdto.RideOfferMessage {
+	toPickupKm, _, err := m.driverService.CalculateRideDetails(ctx,
+		dto.Location{Latitude: driver.Latitude, Longitude: driver.Longitude},
+		dto.Location{Latitude: ride.Pickup_location.Lat, Longitude: ride.Pickup_location.Lng},
+	)
+	if err != nil {
+		m.log.Action("buildOffer").Warn("Failed to route to pickup", "driver_id", driver.DriverId, "error", err.Error())
+		toPickupKm = driver.Distance
+	}
+
+	var stops []websocketdto.Stop
+	for i, stop := range ride.Stops {
+		stops = append(stops, websocketdto.Stop{
+			StopIndex: i + 1,
+			Location: websocketdto.Location{
+				Latitude:  stop.Lat,
+				Longitude: stop.Lng,
+				Address:   stop.Address,
+			},
+		})
+	}
+
+	return websocketdto.RideOfferMessage{
+		WebSocketMessage: websocketdto.WebSocketMessage{
+			Type: websocketdto.MessageTypeRideOffer,
+		},
+		OfferID:    offerID,
+		RideID:     ride.Ride_id,
+		RideNumber: ride.Ride_number,
+		PickupLocation: websocketdto.Location{
+			Latitude:  ride.Pickup_location.Lat,
+			Longitude: ride.Pickup_location.Lng,
+			Address:   ride.Pickup_location.Address,
+		},
+		DestinationLocation: websocketdto.Location{
+			Latitude:  ride.Destination_location.Lat,
+			Longitude: ride.Destination_location.Lng,
+			Address:   ride.Destination_location.Address,
+		},
+		Stops:                        stops,
+		EstimatedFare:                ride.Estimated_fare,
+		DriverEarnings:               ride.Estimated_fare * 0.8,
+		DistanceToPickupKm:           toPickupKm,
+		EstimatedRideDurationMinutes: rideMinutes,
+		ExpiresAt:                    expiresAt,
+	}
+}
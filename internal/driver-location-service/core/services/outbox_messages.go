@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"ride-hail/internal/contracts"
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/tracing"
+
+	driven "ride-hail/internal/driver-location-service/core/ports/driven"
+)
+
+// driverExchangeName — topic exchange для сообщений о водителях
+const driverExchangeName = "driver_topic"
+
+// driverStatusMessage строит driver.status.{driver_id} для записи в outbox;
+// correlation id — трассировка ctx
+func driverStatusMessage(ctx context.Context, driverID, rideID, status string) (outbox.Message, error) {
+	env, err := contracts.Wrap(contracts.ProducerDriverLocationService, tracing.CorrelationID(ctx), contracts.DriverStatusChanged{
+		DriverID: driverID,
+		RideID:   rideID,
+		Status:   status,
+	})
+	if err != nil {
+		return outbox.Message{}, err
+	}
+	return outbox.NewMessage(driverExchangeName, fmt.Sprintf("driver.status.%s", driverID), env)
+}
+
+// publishMessage публикует сообщение в конверте contracts сразу, минуя outbox
+func publishMessage(ctx context.Context, broker driven.IDriverBroker, exchange, routingKey string, msg contracts.Message) error {
+	env, err := contracts.Wrap(contracts.ProducerDriverLocationService, tracing.CorrelationID(ctx), msg)
+	if err != nil {
+		return err
+	}
+	return broker.PublishJSON(ctx, exchange, routingKey, env)
+}
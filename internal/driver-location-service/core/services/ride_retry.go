@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// A ride request nobody took is not requeued in place. It waits in a delay
+// queue whose TTL dead-letters it back into ride_requests, each attempt
+// waiting longer than the one before. After the last attempt it is parked:
+// ride-service cancels the ride and a copy is kept for inspection.
+const (
+	retryAttemptHeader = "x-retry-attempt"
+	parkReasonHeader   = "x-park-reason"
+
+	rideParkingExchange   = "ride_parking" // fanout
+	rideParkingRoutingKey = "ride.request.parked"
+
+	parkReasonNoDrivers = "no drivers found"
+)
+
+// rideRetryQueues must match the delay queues in rabbitmq_definitions.json;
+// attempts beyond the last one keep using it
+var rideRetryQueues = []string{
+	"ride_requests.retry.10s",
+	"ride_requests.retry.30s",
+	"ride_requests.retry.60s",
+	"ride_requests.retry.120s",
+}
+
+// retryQueue is the delay queue for the given failed attempt, counted from 1
+func retryQueue(attempt int) string {
+	i := min(max(attempt-1, 0), len(rideRetryQueues)-1)
+	return rideRetryQueues[i]
+}
+
+// retryAttempt reads how many times the request has already failed
+func retryAttempt(headers amqp.Table) int {
+	switch v := headers[retryAttemptHeader].(type) {
+	case int:
+		return v
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// retryPublishing copies the delivery for its next attempt. x-death is
+// dropped: the broker appends to it on every pass through a delay queue.
+func retryPublishing(delivery amqp.Delivery, attempt int) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range delivery.Headers {
+		if k != "x-death" {
+			headers[k] = v
+		}
+	}
+	headers[retryAttemptHeader] = int32(attempt)
+
+	return amqp.Publishing{
+		Headers:       headers,
+		ContentType:   delivery.ContentType,
+		DeliveryMode:  amqp.Persistent,
+		Priority:      delivery.Priority,
+		CorrelationId: delivery.CorrelationId,
+		MessageId:     delivery.MessageId,
+		Timestamp:     delivery.Timestamp,
+		Body:          delivery.Body,
+	}
+}
+
+// retryRideRequest moves a request that found no driver to its delay queue,
+// or parks it once maxAttempts is used up. The delivery is acked only after
+// the broker confirmed the copy, so a failed move leaves it in ride_requests.
+func (d *Distributor) retryRideRequest(ctx context.Context, delivery amqp.Delivery, rideID, reason string) {
+	log := d.log.Action("retryRideRequest").WithContext(ctx)
+
+	attempt := retryAttempt(delivery.Headers) + 1
+	pub := retryPublishing(delivery, attempt)
+
+	exchange, routingKey := "", retryQueue(attempt)
+	if attempt >= d.maxAttempts {
+		pub.Headers[parkReasonHeader] = parkReasonNoDrivers
+		exchange, routingKey = rideParkingExchange, rideParkingRoutingKey
+	}
+
+	if err := d.broker.Republish(ctx, exchange, routingKey, pub); err != nil {
+		log.Error("Failed to move ride request, requeueing", err, "ride_id", rideID, "attempt", attempt)
+		delivery.Nack(false, true)
+		return
+	}
+	delivery.Ack(false)
+
+	if exchange == rideParkingExchange {
+		log.Warn("Ride request parked", "ride_id", rideID, "attempts", attempt, "reason", reason)
+		return
+	}
+	log.Info(fmt.Sprintf("Ride request delayed in %s", routingKey), "ride_id", rideID, "attempt", attempt, "reason", reason)
+}
@@ -1,9 +1,12 @@
 package services
 
 import (
+	"ride-hail/internal/config"
 	"ride-hail/internal/driver-location-service/adapters/driven/db"
 	ports "ride-hail/internal/driver-location-service/core/ports/driven"
 	"ride-hail/internal/mylogger"
+
+	"github.com/golang-jwt/jwt"
 )
 
 type Service struct {
@@ -12,9 +15,9 @@ type Service struct {
 }
 
 // Must properly implement Auth Service
-func New(repositories *db.Repository, log mylogger.Logger, broker ports.IDriverBroker, secretKey string) *Service {
+func New(repositories *db.Repository, log mylogger.Logger, broker ports.IDriverBroker, keyfunc jwt.Keyfunc, ranker ports.DriverRanker, rankCfg *config.Rankingconfig, pricing ports.IPricingEngine, router ports.IRouter) *Service {
 	return &Service{
-		DriverService: NewDriverService(repositories.DriverRepository, log, broker),
-		AuthService:   NewAuthService(secretKey),
+		DriverService: NewDriverService(repositories.DriverRepository, log, broker, ranker, rankCfg, pricing, router),
+		AuthService:   NewAuthService(keyfunc, repositories.RevocationRepository),
 	}
 }
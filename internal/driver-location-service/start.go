@@ -9,15 +9,23 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"ride-hail/internal/config"
 	"ride-hail/internal/driver-location-service/adapters/driven/bm"
 	"ride-hail/internal/driver-location-service/adapters/driven/db"
+	"ride-hail/internal/driver-location-service/adapters/driven/ranker"
 	"ride-hail/internal/driver-location-service/adapters/driven/ws"
 	"ride-hail/internal/driver-location-service/adapters/driver/myhttp"
 	"ride-hail/internal/driver-location-service/adapters/driver/myhttp/handlers"
 	"ride-hail/internal/driver-location-service/core/services"
+	"ride-hail/internal/inbox"
+	"ride-hail/internal/jwks"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/pricing"
+	"ride-hail/internal/routing"
+	"ride-hail/internal/tracing"
 )
 
 func Execute(ctx context.Context, mylog mylogger.Logger, cfg *config.Config) error {
@@ -26,6 +34,17 @@ func Execute(ctx context.Context, mylog mylogger.Logger, cfg *config.Config) err
 	// Context Declaration
 	signalCtx, close := signal.NotifyContext(ctx, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
 	defer close()
+	// Tracing, flushed last so the spans of the final requests are kept
+	shutdownTracing, err := tracing.Init("driver-location-service", cfg.Tracing)
+	if err != nil {
+		log.Error("Tracing initialization failed: ", err)
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error("Failed to flush traces", err)
+		}
+	}()
 	// Connecting to Database
 	database, err := db.ConnectDB(signalCtx, cfg.DB, mylog)
 	if err != nil {
@@ -46,23 +65,45 @@ func Execute(ctx context.Context, mylog mylogger.Logger, cfg *config.Config) err
 
 	// Declaring Consumer
 	consumer := bm.NewConsumer(signalCtx, broker, mylog)
-	req, statusMsgs, err := consumer.ListenAll()
+	req, statusMsgs, destMsgs, err := consumer.ListenAll()
 	if err != nil {
 		log.Error("Failed to subscribe for messages", err)
 		return err
 	}
 	log.Info("Consumer is listenning for the messages")
 
+	// Public keys of auth-service
+	keys, err := jwks.NewCache(cfg.Jwt, cfg.App.CertPath, mylog)
+	if err != nil {
+		log.Error("Failed to create key set cache", err)
+		return err
+	}
+
 	// Declaring service components
 	repository := db.New(database)
-	wbManager := ws.NewWebSocketManager()
-	service := services.New(repository, mylog, broker, cfg.App.PublicJwtSecret)
+	// Drivers connected to other replicas are reached through their instance queues
+	instanceID := cfg.WS.InstanceID
+	heartbeat := time.Duration(max(cfg.WS.HeartbeatSeconds, 1)) * time.Second
+	registry := db.NewConnectionRegistry(database, 3*heartbeat)
+	wbManager := ws.NewWebSocketManager(instanceID, registry, broker, heartbeat, mylog)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		wbManager.Run(signalCtx)
+	}()
+	log.Info("Driver connections are routed as instance " + instanceID)
+	router, err := routing.New(cfg.Routing)
+	if err != nil {
+		log.Error("Failed to create router", err)
+		return err
+	}
+	service := services.New(repository, mylog, broker, keys.Keyfunc(), ranker.NewWeighted(cfg.Ranking), cfg.Ranking, pricing.NewTariffEngine(cfg.Pricing), router)
 	handler := handlers.New(service, mylog, wbManager)
 	log.Info("All driver-location components are declared")
 
 	// Creating the distributor
 	wg.Add(1)
-	distributor := services.NewDistributor(signalCtx, req, statusMsgs, wbManager, broker, service.DriverService, mylog)
+	distributor := services.NewDistributor(signalCtx, req, statusMsgs, destMsgs, wbManager, broker, service.DriverService, cfg.Matching, mylog)
 	go func() {
 		defer wg.Done()
 		if err := distributor.MessageDistributor(); err != nil {
@@ -71,6 +112,26 @@ func Execute(ctx context.Context, mylog mylogger.Logger, cfg *config.Config) err
 	}()
 	log.Info("Distribur successfully setted up and ready to work")
 
+	// Relaying status messages committed with driver updates. It stops after the
+	// HTTP server and the distributor, so nothing they commit is left behind.
+	relayCtx, relayCancel := context.WithCancel(context.WithoutCancel(signalCtx))
+	defer relayCancel()
+	var relayWg sync.WaitGroup
+	relay := outbox.NewRelay(repository.OutboxRepository, broker, cfg.Outbox, mylog)
+	relayWg.Add(1)
+	go func() {
+		defer relayWg.Done()
+		relay.Run(relayCtx)
+	}()
+
+	// Forgetting processed message ids once redeliveries can no longer come
+	purger := inbox.NewPurger(repository.InboxRepository, cfg.Inbox, mylog)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		purger.Run(signalCtx)
+	}()
+
 	cert, err := tls.LoadX509KeyPair(cfg.App.CertPath, cfg.App.CertKeyPath)
 	if err != nil {
 		return fmt.Errorf("failed to load TLS cert/key: %w", err)
@@ -82,10 +143,10 @@ func Execute(ctx context.Context, mylog mylogger.Logger, cfg *config.Config) err
 	}
 
 	// Defining the rounter
-	mux := myhttp.Router(handler, cfg)
+	mux := myhttp.Router(handler, keys.Keyfunc(), repository.RevocationRepository)
 	httpServer := &http.Server{
 		Addr:      fmt.Sprintf(":%v", cfg.Srv.DriverLocationServicePort),
-		Handler:   mux,
+		Handler:   tracing.Middleware(mux),
 		TLSConfig: tlsConfig,
 	}
 
@@ -112,6 +173,8 @@ func Execute(ctx context.Context, mylog mylogger.Logger, cfg *config.Config) err
 		// Waiting
 		log.Info("waiting for workers......")
 		wg.Wait()
+		relayCancel()
+		relayWg.Wait()
 		log.Info("All workers are done")
 		// Drivers
 		err := service.DriverService.GracefullShutdown(context.Background())
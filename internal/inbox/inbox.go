@@ -0,0 +1,39 @@
+// Package inbox makes message consumers idempotent. A consumer records the
+// message id in processed_messages inside the transaction of its side
+// effect; a redelivered message finds the row and the side effect is skipped.
+package inbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ride-hail/internal/outbox"
+)
+
+// ErrAlreadyProcessed means the consumer has acted on the message before;
+// ack it and move on
+var ErrAlreadyProcessed = errors.New("message already processed")
+
+// Claim records that consumer processes the message. It returns
+// ErrAlreadyProcessed for a message recorded before. Messages without an id
+// cannot be deduplicated and are always let through.
+func Claim(ctx context.Context, tx outbox.DBTX, consumer, messageID string) error {
+	if messageID == "" {
+		return nil
+	}
+
+	q := `
+	INSERT INTO processed_messages (consumer, message_id)
+	VALUES ($1, $2)
+	ON CONFLICT (consumer, message_id) DO NOTHING`
+
+	tag, err := tx.Exec(ctx, q, consumer, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to record processed message: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlreadyProcessed
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/config"
+	"ride-hail/internal/mylogger"
+	"ride-hail/internal/outbox"
+)
+
+// purgeBatch bounds the rows one DELETE removes, so a large backlog does not
+// hold locks for long
+const purgeBatch = 5000
+
+// Store is the processed_messages table as seen by one service
+type Store interface {
+	Purge(ctx context.Context, olderThan time.Duration, limit int) (int64, error)
+}
+
+// Purger deletes the records of messages too old to be delivered again. The
+// retention has to outlast every way a message comes back: broker
+// redelivery, the retry and parking queues, and dead letter replays.
+type Purger struct {
+	store     Store
+	log       mylogger.Logger
+	every     time.Duration
+	retention time.Duration
+}
+
+func NewPurger(store Store, cfg *config.Inboxconfig, log mylogger.Logger) *Purger {
+	return &Purger{
+		store:     store,
+		log:       log,
+		every:     time.Duration(positive(cfg.PurgeIntervalMinutes, 60)) * time.Minute,
+		retention: time.Duration(positive(cfg.RetentionHours, 14*24)) * time.Hour,
+	}
+}
+
+// Run purges every interval until ctx is cancelled
+func (p *Purger) Run(ctx context.Context) {
+	log := p.log.Action("inbox_purge")
+	log.Info("inbox purger started", "every", p.every.String(), "retention", p.retention.String())
+
+	ticker := time.NewTicker(p.every)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Info("inbox purger stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	log := p.log.Action("inbox_purge")
+
+	var total int64
+	for ctx.Err() == nil {
+		n, err := p.store.Purge(ctx, p.retention, purgeBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error("cannot purge processed messages", err)
+			}
+			return
+		}
+		total += n
+		if n < purgeBatch {
+			break
+		}
+	}
+	if total > 0 {
+		log.Info("purged processed messages", "count", total)
+	}
+}
+
+// Purge deletes up to limit records processed more than olderThan ago
+func Purge(ctx context.Context, db outbox.DBTX, olderThan time.Duration, limit int) (int64, error) {
+	q := `
+	DELETE FROM processed_messages
+	WHERE (consumer, message_id) IN (
+		SELECT consumer, message_id
+		FROM processed_messages
+		WHERE processed_at < NOW() - $1::interval
+		LIMIT $2
+	)`
+
+	tag, err := db.Exec(ctx, q, olderThan, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge processed messages: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func positive(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
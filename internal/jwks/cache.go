@@ -0,0 +1,157 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"ride-hail/internal/config"
+	"ride-hail/internal/mylogger"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	fetchTimeout = 5 * time.Second
+	// minRefetch limits how often an unknown kid can force a refetch
+	minRefetch = 10 * time.Second
+)
+
+// Cache keeps the auth-service key set in memory and refetches it when it
+// gets stale or when a token arrives with a kid it has not seen yet.
+type Cache struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+	mylog  mylogger.Logger
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func NewCache(cfg *config.JWTconfig, certPath string, mylog mylogger.Logger) (*Cache, error) {
+	tlsConfig := &tls.Config{
+		ServerName: cfg.JwksServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	// Services use a self-signed certificate, trust it on top of the system pool
+	if certPath != "" {
+		pem, err := os.ReadFile(certPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cert: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pool.AppendCertsFromPEM(pem)
+		tlsConfig.RootCAs = pool
+	}
+
+	return &Cache{
+		url: cfg.JwksURL,
+		ttl: time.Duration(cfg.JwksCacheSeconds) * time.Second,
+		client: &http.Client{
+			Timeout:   fetchTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		mylog: mylog,
+		keys:  make(map[string]crypto.PublicKey),
+	}, nil
+}
+
+// Keyfunc verifies tokens against the cached key set
+func (c *Cache) Keyfunc() jwt.Keyfunc {
+	return Keyfunc(c.Get)
+}
+
+// Get returns the public key for kid, refreshing the set when needed.
+// A stale set is still used if auth-service cannot be reached.
+func (c *Cache) Get(kid string) (crypto.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	fresh := time.Since(c.fetchedAt) < c.ttl
+	recent := time.Since(c.attemptedAt) < minRefetch
+	c.mu.RUnlock()
+
+	if ok && fresh {
+		return key, nil
+	}
+	if !ok && recent {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKid, kid)
+	}
+
+	if err := c.refresh(); err != nil {
+		c.mylog.Action("jwks_refresh_failed").Error("Failed to fetch key set", err, "url", c.url)
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if key, ok = c.keys[kid]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKid, kid)
+	}
+	return key, nil
+}
+
+func (c *Cache) refresh() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another goroutine may have refreshed while we waited for the lock
+	if time.Since(c.attemptedAt) < minRefetch {
+		return nil
+	}
+	c.attemptedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("build jwks request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set Set
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.PublicKey()
+		if err != nil {
+			c.mylog.Warn("Skipping unsupported jwk", "kid", k.Kid, "error", err.Error())
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mylog.Action("jwks_refreshed").Debug("Key set refreshed", "keys", len(keys))
+	return nil
+}
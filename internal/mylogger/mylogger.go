@@ -1,11 +1,14 @@
 package mylogger
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -23,6 +26,8 @@ type Logger interface {
 	Action(action string) Logger
 	With(args ...any) Logger
 	WithGroup(groupName string) Logger
+	// WithContext adds the trace and span ids of ctx to every line
+	WithContext(ctx context.Context) Logger
 }
 
 func New(logLevel string) (Logger, error) {
@@ -132,3 +137,12 @@ func (l logger) WithGroup(groupName string) Logger {
 	l.log = l.log.WithGroup(groupName)
 	return &l
 }
+
+func (l logger) WithContext(ctx context.Context) Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return &l
+	}
+	l.log = l.log.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+	return &l
+}
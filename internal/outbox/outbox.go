@@ -0,0 +1,155 @@
+// Package outbox makes a database change and the broker message announcing
+// it a single write. The message is stored in the outbox table inside the
+// transaction of the change, and a Relay publishes it after the commit, so a
+// committed change is always announced and a rolled back one never is.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"ride-hail/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Message is one broker publish waiting in the outbox
+type Message struct {
+	ID         string
+	CreatedAt  time.Time
+	Exchange   string
+	RoutingKey string
+	Priority   uint8
+	Payload    []byte
+	// TraceContext is the trace of the transaction that wrote the message
+	TraceContext map[string]string
+	// Attempts counts claims, including the current one
+	Attempts int
+
+	seq int64
+}
+
+// DBTX is what the outbox needs from a connection or a transaction
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func NewMessage(exchange, routingKey string, payload any) (Message, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, fmt.Errorf("marshal outbox message: %w", err)
+	}
+	return Message{Exchange: exchange, RoutingKey: routingKey, Payload: body}, nil
+}
+
+// Insert stores the messages of the given service; call it with the
+// transaction of the change the messages announce. The trace of ctx is
+// stored with them.
+func Insert(ctx context.Context, db DBTX, service string, msgs ...Message) error {
+	q := `
+	INSERT INTO outbox (service, exchange, routing_key, priority, payload, trace_context)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	trace := tracing.InjectMap(ctx)
+	for _, m := range msgs {
+		if m.TraceContext == nil {
+			m.TraceContext = trace
+		}
+		if _, err := db.Exec(ctx, q, service, m.Exchange, m.RoutingKey, int16(m.Priority), m.Payload, m.TraceContext); err != nil {
+			return fmt.Errorf("failed to insert outbox message: %w", err)
+		}
+	}
+	return nil
+}
+
+// Claim leases up to limit unsent messages of the service, in write order.
+// A message is not claimed while an older one of the service is leased or
+// backing off, so messages are published in the order they were written.
+// Call it in a transaction: claims of one service are serialized on an
+// advisory lock, which concurrent relays would otherwise race past.
+// A message that is not marked before the lease runs out is claimed again.
+func Claim(ctx context.Context, db DBTX, service string, limit int, lease time.Duration) ([]Message, error) {
+	if _, err := db.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('outbox:' || $1))`, service); err != nil {
+		return nil, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+
+	q := `
+	UPDATE outbox
+	SET available_at = NOW() + $3::interval, attempts = attempts + 1
+	WHERE message_id IN (
+		SELECT o.message_id
+		FROM outbox o
+		WHERE o.service = $1 AND o.sent_at IS NULL AND o.available_at <= NOW()
+			AND NOT EXISTS (
+				SELECT 1
+				FROM outbox b
+				WHERE b.service = o.service AND b.sent_at IS NULL
+					AND b.available_at > NOW() AND b.seq < o.seq
+			)
+		ORDER BY o.seq
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING message_id, created_at, exchange, routing_key, priority, payload, trace_context, attempts, seq`
+
+	rows, err := db.Query(ctx, q, service, limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []Message
+	for rows.Next() {
+		var (
+			m        Message
+			priority int16
+			seq      int64
+		)
+		if err := rows.Scan(&m.ID, &m.CreatedAt, &m.Exchange, &m.RoutingKey, &priority, &m.Payload, &m.TraceContext, &m.Attempts, &seq); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		m.Priority = uint8(priority)
+		m.seq = seq
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].seq < msgs[j].seq })
+	return msgs, nil
+}
+
+func MarkSent(ctx context.Context, db DBTX, id string) error {
+	q := `UPDATE outbox SET sent_at = NOW(), last_error = NULL WHERE message_id = $1`
+	if _, err := db.Exec(ctx, q, id); err != nil {
+		return fmt.Errorf("failed to mark outbox message sent: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records the publish error and hides the message until retryAt,
+// together with the unsent messages of the service written after it
+func MarkFailed(ctx context.Context, db DBTX, id string, cause error, retryAt time.Time) error {
+	q := `
+	WITH failed AS (
+		UPDATE outbox SET last_error = $2, available_at = $3
+		WHERE message_id = $1
+		RETURNING service, seq
+	)
+	UPDATE outbox o
+	SET available_at = $3
+	FROM failed
+	WHERE o.service = failed.service AND o.sent_at IS NULL
+		AND o.seq > failed.seq AND o.available_at < $3`
+	if _, err := db.Exec(ctx, q, id, cause.Error(), retryAt); err != nil {
+		return fmt.Errorf("failed to mark outbox message failed: %w", err)
+	}
+	return nil
+}
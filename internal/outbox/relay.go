@@ -0,0 +1,138 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ride-hail/internal/config"
+	"ride-hail/internal/confirm"
+	"ride-hail/internal/mylogger"
+	"ride-hail/internal/tracing"
+)
+
+// Store is the outbox table of one service
+type Store interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]Message, error)
+	MarkSent(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error
+}
+
+// Publisher returns only after the broker has confirmed the message; it puts
+// the trace context of ctx in the message headers
+type Publisher interface {
+	PublishConfirmed(ctx context.Context, m Message) error
+}
+
+// Relay publishes committed outbox messages in the order they were written.
+// When one fails it backs off and every later message of the service waits
+// behind it, so a broker outage delays messages but does not reorder them.
+type Relay struct {
+	store      Store
+	publisher  Publisher
+	log        mylogger.Logger
+	poll       time.Duration
+	batchSize  int
+	lease      time.Duration
+	maxBackoff time.Duration
+	timeout    time.Duration
+}
+
+func NewRelay(store Store, publisher Publisher, cfg *config.Outboxconfig, log mylogger.Logger) *Relay {
+	return &Relay{
+		store:      store,
+		publisher:  publisher,
+		log:        log,
+		poll:       time.Duration(positive(cfg.PollMillis, 500)) * time.Millisecond,
+		batchSize:  positive(cfg.BatchSize, 100),
+		lease:      time.Duration(positive(cfg.LeaseSeconds, 30)) * time.Second,
+		maxBackoff: time.Duration(positive(cfg.MaxBackoffSeconds, 300)) * time.Second,
+		timeout:    time.Duration(positive(cfg.PublishTimeoutSeconds, 5)) * time.Second,
+	}
+}
+
+// Run relays until ctx is cancelled, then flushes once more
+func (r *Relay) Run(ctx context.Context) {
+	log := r.log.Action("outbox_relay")
+	log.Info("outbox relay started", "poll", r.poll.String(), "batch", r.batchSize)
+
+	ticker := time.NewTicker(r.poll)
+	defer ticker.Stop()
+
+	for {
+		// a full batch means more is waiting
+		for r.relayBatch(ctx) == r.batchSize && ctx.Err() == nil {
+		}
+
+		select {
+		case <-ctx.Done():
+			// publish what was committed while shutting down, e.g. cancelled rides
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.timeout)
+			for r.relayBatch(flushCtx) == r.batchSize {
+			}
+			cancel()
+			log.Info("outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch returns how many messages were published
+func (r *Relay) relayBatch(ctx context.Context) int {
+	log := r.log.Action("outbox_relay")
+
+	msgs, err := r.store.Claim(ctx, r.batchSize, r.lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("cannot claim outbox messages", err)
+		}
+		return 0
+	}
+
+	for i, m := range msgs {
+		// the publish joins the trace of the transaction that wrote the message
+		pubCtx, span := tracing.StartProducer(tracing.ExtractMap(ctx, m.TraceContext), m.Exchange, m.RoutingKey)
+		pubCtx, cancel := context.WithTimeout(pubCtx, r.timeout)
+		err := r.publisher.PublishConfirmed(pubCtx, m)
+		cancel()
+		tracing.End(span, err)
+
+		if err != nil {
+			retryAt := time.Now().Add(r.backoff(m.Attempts))
+			if errors.Is(err, confirm.ErrUnroutable) {
+				// kept and retried: the consumer may not have bound its queue yet
+				log.Error("outbox message is unroutable", err, "message_id", m.ID, "exchange", m.Exchange, "routing_key", m.RoutingKey, "attempts", m.Attempts)
+			} else {
+				log.Warn("outbox publish failed", "message_id", m.ID, "routing_key", m.RoutingKey, "attempts", m.Attempts, "error", err.Error())
+			}
+			if err := r.store.MarkFailed(ctx, m.ID, err, retryAt); err != nil {
+				log.Error("cannot mark outbox message failed", err, "message_id", m.ID)
+			}
+			return i
+		}
+
+		// a message published but not marked is sent again after the lease; consumers must tolerate duplicates
+		if err := r.store.MarkSent(ctx, m.ID); err != nil {
+			log.Error("cannot mark outbox message sent", err, "message_id", m.ID)
+			return i
+		}
+	}
+	return len(msgs)
+}
+
+// backoff doubles from one second up to maxBackoff
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := time.Second
+	for i := 1; i < attempts && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.maxBackoff)
+}
+
+func positive(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
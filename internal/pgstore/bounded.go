@@ -0,0 +1,72 @@
+package pgstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// rows releases the query timeout once the rows are read or closed
+type rows struct {
+	pgx.Rows
+	cancel context.CancelFunc
+}
+
+func (r *rows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.cancel()
+	return false
+}
+
+func (r *rows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+// row releases the query timeout after Scan
+type row struct {
+	pgx.Row
+	cancel context.CancelFunc
+}
+
+func (r *row) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
+
+// tx bounds its statements by the deadline of the transaction
+type tx struct {
+	pgx.Tx
+	deadline time.Time
+}
+
+func (t *tx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	ctx, cancel := context.WithDeadline(ctx, t.deadline)
+	defer cancel()
+	return t.Tx.Exec(ctx, sql, args...)
+}
+
+func (t *tx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ctx, cancel := context.WithDeadline(ctx, t.deadline)
+	r, err := t.Tx.Query(ctx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &rows{Rows: r, cancel: cancel}, nil
+}
+
+func (t *tx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	ctx, cancel := context.WithDeadline(ctx, t.deadline)
+	return &row{Row: t.Tx.QueryRow(ctx, sql, args...), cancel: cancel}
+}
+
+func (t *tx) Commit(ctx context.Context) error {
+	ctx, cancel := context.WithDeadline(ctx, t.deadline)
+	defer cancel()
+	return t.Tx.Commit(ctx)
+}
@@ -0,0 +1,166 @@
+// Package pgstore is the PostgreSQL layer shared by every service: a pgxpool
+// pool with bounded queries and transactions, slow query logging and pool
+// statistics. Repositories use a Store like a single connection.
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/config"
+	"ride-hail/internal/mylogger"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Store struct {
+	pool         *pgxpool.Pool
+	log          mylogger.Logger
+	queryTimeout time.Duration
+	txTimeout    time.Duration
+	stopStats    context.CancelFunc
+}
+
+// Open creates the pool and waits for the database, retrying with a growing pause
+func Open(ctx context.Context, cfg *config.DBconfig, log mylogger.Logger) (*Store, error) {
+	poolCfg, err := pgxpool.ParseConfig(fmt.Sprintf(
+		"postgres://%v:%v@%v:%v/%v?sslmode=disable",
+		cfg.User,
+		cfg.Password,
+		cfg.Host,
+		cfg.Port,
+		cfg.Database,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+	poolCfg.MaxConns = int32(positive(cfg.MaxConns, 10))
+	poolCfg.MinConns = int32(min(max(cfg.MinConns, 0), int(poolCfg.MaxConns)))
+	poolCfg.MaxConnLifetime = time.Duration(positive(cfg.MaxConnLifetimeMinutes, 60)) * time.Minute
+	poolCfg.MaxConnIdleTime = time.Duration(positive(cfg.MaxConnIdleMinutes, 10)) * time.Minute
+	poolCfg.ConnConfig.Tracer = &queryTracer{
+		log:  log.Action("slow_query"),
+		slow: time.Duration(positive(cfg.SlowQueryMillis, 200)) * time.Millisecond,
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database pool: %w", err)
+	}
+
+	s := &Store{
+		pool:         pool,
+		log:          log,
+		queryTimeout: time.Duration(positive(cfg.QueryTimeoutMillis, 5000)) * time.Millisecond,
+		txTimeout:    time.Duration(positive(cfg.TxTimeoutMillis, 10000)) * time.Millisecond,
+	}
+	if err := s.waitReady(ctx, positive(cfg.MaxRetries, 1)); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	statsCtx, stop := context.WithCancel(ctx)
+	s.stopStats = stop
+	if cfg.StatsIntervalSeconds > 0 {
+		go s.reportStats(statsCtx, time.Duration(cfg.StatsIntervalSeconds)*time.Second)
+	}
+	return s, nil
+}
+
+func (s *Store) waitReady(ctx context.Context, attempts int) error {
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if lastErr = s.pool.Ping(ctx); lastErr == nil {
+			s.log.Info("Successfully connected to the database", "max_conns", s.pool.Config().MaxConns)
+			return nil
+		}
+		s.log.Error(fmt.Sprintf("DB connection attempt %d failed", i+1), lastErr)
+
+		// 1s, 2s, 3s, ...
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second * time.Duration(i+1)):
+		}
+	}
+	return fmt.Errorf("failed to connect to the database after %d attempts: %w", attempts, lastErr)
+}
+
+// Close stops the statistics and closes every connection of the pool
+func (s *Store) Close() {
+	s.stopStats()
+	s.pool.Close()
+}
+
+// Ping checks that a connection can be acquired and answers
+func (s *Store) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	return s.pool.Ping(ctx)
+}
+
+// Exec runs sql on a pooled connection within the query timeout
+func (s *Store) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	return s.pool.Exec(ctx, sql, args...)
+}
+
+// Query runs sql within the query timeout; the rows hold their connection until closed
+func (s *Store) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	r, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &rows{Rows: r, cancel: cancel}, nil
+}
+
+// QueryRow runs sql within the query timeout, counted until Scan returns
+func (s *Store) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	return &row{Row: s.pool.QueryRow(ctx, sql, args...), cancel: cancel}
+}
+
+// Begin starts a transaction that must finish within the transaction timeout
+func (s *Store) Begin(ctx context.Context) (pgx.Tx, error) {
+	return s.BeginTx(ctx, pgx.TxOptions{})
+}
+
+// BeginTx is Begin with options. Every statement of the transaction, Commit
+// included, fails once the transaction timeout has passed; Rollback does not.
+func (s *Store) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	deadline := time.Now().Add(s.txTimeout)
+	bctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	t, err := s.pool.BeginTx(bctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &tx{Tx: t, deadline: deadline}, nil
+}
+
+// WithTx runs fn in a transaction, committed if fn returns nil and rolled back otherwise
+func (s *Store) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	t, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer t.Rollback(context.WithoutCancel(ctx)) // no-op once committed
+
+	if err := fn(t); err != nil {
+		return err
+	}
+	return t.Commit(ctx)
+}
+
+func positive(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,45 @@
+package pgstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Stats is a snapshot of the pool
+func (s *Store) Stats() *pgxpool.Stat {
+	return s.pool.Stat()
+}
+
+// reportStats logs the pool every interval, and warns when requests had to
+// wait for a connection since the previous report
+func (s *Store) reportStats(ctx context.Context, interval time.Duration) {
+	log := s.log.Action("db_pool_stats")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var waitedBefore int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		st := s.pool.Stat()
+		log.Info("database pool",
+			"total", st.TotalConns(),
+			"acquired", st.AcquiredConns(),
+			"idle", st.IdleConns(),
+			"max", st.MaxConns(),
+			"acquires", st.AcquireCount(),
+			"acquire_wait_ms", st.AcquireDuration().Milliseconds(),
+			"canceled_acquires", st.CanceledAcquireCount(),
+		)
+		if waited := st.EmptyAcquireCount() - waitedBefore; waited > 0 {
+			log.Warn("database pool exhausted, requests waited for a connection", "waits", waited, "max", st.MaxConns())
+		}
+		waitedBefore = st.EmptyAcquireCount()
+	}
+}
@@ -0,0 +1,76 @@
+package pgstore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"ride-hail/internal/mylogger"
+	"ride-hail/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxLoggedSQL keeps long statements from flooding the logs
+const maxLoggedSQL = 300
+
+// queryTracer gives every statement a client span and logs the slow ones,
+// inside transactions too
+type queryTracer struct {
+	log  mylogger.Logger
+	slow time.Duration
+}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	sql string
+	at  time.Time
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = tracing.Start(ctx, "db "+operation(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.query.text", compact(data.SQL)),
+		),
+	)
+	return context.WithValue(ctx, queryStartKey{}, queryStart{sql: data.SQL, at: time.Now()})
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	tracing.End(trace.SpanFromContext(ctx), data.Err)
+
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	if took := time.Since(start.at); took >= t.slow {
+		t.log.WithContext(ctx).Warn("slow query",
+			"sql", compact(start.sql),
+			"duration_ms", took.Milliseconds(),
+			"rows", data.CommandTag.RowsAffected(),
+		)
+	}
+}
+
+// operation is the leading keyword of sql, e.g. SELECT
+func operation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// compact puts sql on one line and cuts it to maxLoggedSQL
+func compact(sql string) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if len(sql) > maxLoggedSQL {
+		sql = sql[:maxLoggedSQL] + "..."
+	}
+	return sql
+}
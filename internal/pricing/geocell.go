@@ -0,0 +1,69 @@
+package pricing
+
+import "strings"
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Cell is a geohash cell; neighbouring points share a prefix
+type Cell struct {
+	Hash                 string
+	MinLat, MaxLat       float64
+	MinLng, MaxLng       float64
+	CenterLat, CenterLng float64
+}
+
+// CellOf returns the geohash cell of the given precision (1..12) containing the point
+func CellOf(lat, lng float64, precision int) Cell {
+	if precision < 1 {
+		precision = 1
+	}
+	if precision > 12 {
+		precision = 12
+	}
+
+	var (
+		sb       strings.Builder
+		latRange = [2]float64{-90, 90}
+		lngRange = [2]float64{-180, 180}
+		even     = true
+		bit, ch  = 0, 0
+	)
+	for sb.Len() < precision {
+		// bits alternate between longitude and latitude, longitude first
+		if even {
+			mid := (lngRange[0] + lngRange[1]) / 2
+			if lng >= mid {
+				ch = ch<<1 | 1
+				lngRange[0] = mid
+			} else {
+				ch <<= 1
+				lngRange[1] = mid
+			}
+		} else {
+			mid := (latRange[0] + latRange[1]) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				latRange[0] = mid
+			} else {
+				ch <<= 1
+				latRange[1] = mid
+			}
+		}
+		even = !even
+
+		if bit++; bit == 5 {
+			sb.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return Cell{
+		Hash:      sb.String(),
+		MinLat:    latRange[0],
+		MaxLat:    latRange[1],
+		MinLng:    lngRange[0],
+		MaxLng:    lngRange[1],
+		CenterLat: (latRange[0] + latRange[1]) / 2,
+		CenterLng: (lngRange[0] + lngRange[1]) / 2,
+	}
+}
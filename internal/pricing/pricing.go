@@ -0,0 +1,114 @@
+// Package pricing turns a trip into a fare. Tariffs come from config, so the
+// estimate shown to the passenger and the final fare charged at completion
+// are computed by the same rules.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"ride-hail/internal/config"
+)
+
+var (
+	ErrUnknownVehicleType = errors.New("no tariff for vehicle type")
+	ErrInvalidTrip        = errors.New("trip distance, duration and surge must not be negative")
+)
+
+// Trip is what a fare is charged for. A zero DurationMinutes is estimated
+// from the distance and the average speed, a zero SurgeMultiplier means no surge.
+type Trip struct {
+	DistanceKm      float64
+	DurationMinutes float64
+	SurgeMultiplier float64
+}
+
+// Breakdown is an itemized fare; Total is what the passenger pays
+type Breakdown struct {
+	VehicleType     string  `json:"vehicle_type"`
+	Currency        string  `json:"currency"`
+	DistanceKm      float64 `json:"distance_km"`
+	DurationMinutes float64 `json:"duration_minutes"`
+	BaseFare        float64 `json:"base_fare"`
+	DistanceFare    float64 `json:"distance_fare"`
+	TimeFare        float64 `json:"time_fare"`
+	// SurgeFare is what the surge multiplier adds to base, distance and time
+	SurgeMultiplier float64 `json:"surge_multiplier"`
+	SurgeFare       float64 `json:"surge_fare"`
+	BookingFee      float64 `json:"booking_fee"`
+	// MinimumFareAdjustment tops the fare up to the tariff minimum
+	MinimumFareAdjustment float64 `json:"minimum_fare_adjustment"`
+	Total                 float64 `json:"total"`
+}
+
+// TariffEngine prices trips with the per-vehicle tariffs from config
+type TariffEngine struct {
+	currency    string
+	avgSpeedKmh float64
+	tariffs     map[string]config.Tariffconfig
+}
+
+func NewTariffEngine(cfg *config.Pricingconfig) *TariffEngine {
+	e := &TariffEngine{
+		currency:    cfg.Currency,
+		avgSpeedKmh: cfg.AvgSpeedKmh,
+		tariffs:     make(map[string]config.Tariffconfig, len(cfg.Tariffs)),
+	}
+	if e.avgSpeedKmh <= 0 {
+		e.avgSpeedKmh = 30
+	}
+	for vehicleType, tariff := range cfg.Tariffs {
+		if tariff != nil {
+			e.tariffs[strings.ToUpper(vehicleType)] = *tariff
+		}
+	}
+	return e
+}
+
+func (e *TariffEngine) Quote(vehicleType string, trip Trip) (Breakdown, error) {
+	vehicleType = strings.ToUpper(vehicleType)
+	tariff, ok := e.tariffs[vehicleType]
+	if !ok {
+		return Breakdown{}, fmt.Errorf("%w: %q", ErrUnknownVehicleType, vehicleType)
+	}
+	if trip.DistanceKm < 0 || trip.DurationMinutes < 0 || trip.SurgeMultiplier < 0 {
+		return Breakdown{}, ErrInvalidTrip
+	}
+	if trip.DurationMinutes == 0 {
+		trip.DurationMinutes = e.EstimateDuration(trip.DistanceKm)
+	}
+	if trip.SurgeMultiplier < 1 {
+		trip.SurgeMultiplier = 1
+	}
+
+	b := Breakdown{
+		VehicleType:     vehicleType,
+		Currency:        e.currency,
+		DistanceKm:      round(trip.DistanceKm),
+		DurationMinutes: round(trip.DurationMinutes),
+		BaseFare:        round(tariff.BaseFare),
+		DistanceFare:    round(trip.DistanceKm * tariff.PerKm),
+		TimeFare:        round(trip.DurationMinutes * tariff.PerMinute),
+		SurgeMultiplier: trip.SurgeMultiplier,
+		BookingFee:      round(tariff.BookingFee),
+	}
+	b.SurgeFare = round((b.BaseFare + b.DistanceFare + b.TimeFare) * (trip.SurgeMultiplier - 1))
+	subtotal := b.BaseFare + b.DistanceFare + b.TimeFare + b.SurgeFare + b.BookingFee
+	if subtotal < tariff.MinimumFare {
+		b.MinimumFareAdjustment = round(tariff.MinimumFare - subtotal)
+	}
+	b.Total = round(subtotal + b.MinimumFareAdjustment)
+	return b, nil
+}
+
+// EstimateDuration is the driving time in minutes at the average speed
+func (e *TariffEngine) EstimateDuration(distanceKm float64) float64 {
+	return distanceKm / e.avgSpeedKmh * 60
+}
+
+// round keeps money and distances to two decimals, as stored in DECIMAL(10,2)
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,131 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+
+	"ride-hail/internal/config"
+)
+
+func testEngine() *TariffEngine {
+	return NewTariffEngine(&config.Pricingconfig{
+		Currency:    "KZT",
+		AvgSpeedKmh: 30,
+		Tariffs: map[string]*config.Tariffconfig{
+			"economy": {BaseFare: 500, PerKm: 100, PerMinute: 50, MinimumFare: 800, BookingFee: 100},
+			"premium": {BaseFare: 800, PerKm: 120, PerMinute: 60, MinimumFare: 1500},
+		},
+	})
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name        string
+		vehicleType string
+		trip        Trip
+		want        Breakdown
+	}{
+		{
+			name:        "plain trip",
+			vehicleType: "ECONOMY",
+			trip:        Trip{DistanceKm: 10, DurationMinutes: 20},
+			want: Breakdown{
+				VehicleType: "ECONOMY", Currency: "KZT", DistanceKm: 10, DurationMinutes: 20,
+				BaseFare: 500, DistanceFare: 1000, TimeFare: 1000, SurgeMultiplier: 1,
+				BookingFee: 100, Total: 2600,
+			},
+		},
+		{
+			name:        "lower-case vehicle type and estimated duration",
+			vehicleType: "economy",
+			trip:        Trip{DistanceKm: 15},
+			want: Breakdown{
+				VehicleType: "ECONOMY", Currency: "KZT", DistanceKm: 15, DurationMinutes: 30,
+				BaseFare: 500, DistanceFare: 1500, TimeFare: 1500, SurgeMultiplier: 1,
+				BookingFee: 100, Total: 3600,
+			},
+		},
+		{
+			name:        "surge applies to base, distance and time but not the booking fee",
+			vehicleType: "ECONOMY",
+			trip:        Trip{DistanceKm: 10, DurationMinutes: 20, SurgeMultiplier: 1.5},
+			want: Breakdown{
+				VehicleType: "ECONOMY", Currency: "KZT", DistanceKm: 10, DurationMinutes: 20,
+				BaseFare: 500, DistanceFare: 1000, TimeFare: 1000, SurgeMultiplier: 1.5,
+				SurgeFare: 1250, BookingFee: 100, Total: 3850,
+			},
+		},
+		{
+			name:        "surge below one is no surge",
+			vehicleType: "ECONOMY",
+			trip:        Trip{DistanceKm: 10, DurationMinutes: 20, SurgeMultiplier: 0.5},
+			want: Breakdown{
+				VehicleType: "ECONOMY", Currency: "KZT", DistanceKm: 10, DurationMinutes: 20,
+				BaseFare: 500, DistanceFare: 1000, TimeFare: 1000, SurgeMultiplier: 1,
+				BookingFee: 100, Total: 2600,
+			},
+		},
+		{
+			name:        "short trip is topped up to the minimum fare",
+			vehicleType: "PREMIUM",
+			trip:        Trip{DistanceKm: 1, DurationMinutes: 3},
+			want: Breakdown{
+				VehicleType: "PREMIUM", Currency: "KZT", DistanceKm: 1, DurationMinutes: 3,
+				BaseFare: 800, DistanceFare: 120, TimeFare: 180, SurgeMultiplier: 1,
+				MinimumFareAdjustment: 400, Total: 1500,
+			},
+		},
+		{
+			name:        "amounts are rounded to two decimals",
+			vehicleType: "ECONOMY",
+			trip:        Trip{DistanceKm: 1.234, DurationMinutes: 2.345},
+			want: Breakdown{
+				VehicleType: "ECONOMY", Currency: "KZT", DistanceKm: 1.23, DurationMinutes: 2.35,
+				BaseFare: 500, DistanceFare: 123.4, TimeFare: 117.25, SurgeMultiplier: 1,
+				BookingFee: 100, MinimumFareAdjustment: 0, Total: 840.65,
+			},
+		},
+	}
+
+	e := testEngine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.Quote(tt.vehicleType, tt.trip)
+			if err != nil {
+				t.Fatalf("Quote() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Quote() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	e := testEngine()
+	tests := []struct {
+		name        string
+		vehicleType string
+		trip        Trip
+		want        error
+	}{
+		{"unknown vehicle type", "XL", Trip{DistanceKm: 1}, ErrUnknownVehicleType},
+		{"negative distance", "ECONOMY", Trip{DistanceKm: -1}, ErrInvalidTrip},
+		{"negative duration", "ECONOMY", Trip{DistanceKm: 1, DurationMinutes: -1}, ErrInvalidTrip},
+		{"negative surge", "ECONOMY", Trip{DistanceKm: 1, SurgeMultiplier: -1}, ErrInvalidTrip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := e.Quote(tt.vehicleType, tt.trip); !errors.Is(err, tt.want) {
+				t.Errorf("Quote() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateDurationDefaultSpeed(t *testing.T) {
+	e := NewTariffEngine(&config.Pricingconfig{})
+	if got := e.EstimateDuration(15); got != 30 {
+		t.Errorf("EstimateDuration(15) = %v, want 30 at the default 30 km/h", got)
+	}
+}
@@ -0,0 +1,153 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"ride-hail/internal/config"
+)
+
+// Surge is the demand snapshot of one cell and the multiplier it produced
+type Surge struct {
+	Cell   string `json:"cell"`
+	Demand int    `json:"demand"`
+	Supply int    `json:"supply"`
+	// Raw is the multiplier of this snapshot alone, Multiplier the smoothed and capped one
+	Raw        float64 `json:"raw_multiplier"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// NoSurge is used when a cell cannot be evaluated
+var NoSurge = Surge{Raw: 1, Multiplier: 1}
+
+// SmoothedSurge is the smoothed multiplier of a cell and when it was computed
+type SmoothedSurge struct {
+	Value float64
+	At    time.Time
+}
+
+// SurgeStore keeps the smoothed multiplier of every cell. Smooth passes the
+// stored value to next (ok is false for a cell not seen yet) and stores what
+// next returns; both happen atomically, so every instance sharing the store
+// extends one series per cell.
+type SurgeStore interface {
+	Smooth(ctx context.Context, cell string, next func(prev SmoothedSurge, ok bool) SmoothedSurge) (SmoothedSurge, error)
+}
+
+// SurgeCalculator turns live demand/supply counts per cell into a fare
+// multiplier. Each cell keeps an exponentially smoothed value so a single
+// burst of requests does not make prices jump.
+type SurgeCalculator struct {
+	precision     int
+	sensitivity   float64
+	maxMultiplier float64
+	minDemand     int
+	smoothing     time.Duration
+
+	store SurgeStore
+	now   func() time.Time
+}
+
+// NewSurgeCalculator smooths through store; a nil store keeps the values in
+// this process only
+func NewSurgeCalculator(cfg *config.Surgeconfig, store SurgeStore) *SurgeCalculator {
+	c := &SurgeCalculator{
+		precision:     cfg.GeohashPrecision,
+		sensitivity:   cfg.Sensitivity,
+		maxMultiplier: cfg.MaxMultiplier,
+		minDemand:     cfg.MinDemand,
+		smoothing:     time.Duration(cfg.SmoothingSeconds) * time.Second,
+		store:         store,
+		now:           time.Now,
+	}
+	if c.precision <= 0 {
+		c.precision = 6
+	}
+	if c.maxMultiplier < 1 {
+		c.maxMultiplier = 1
+	}
+	if c.store == nil {
+		c.store = NewMemorySurgeStore(10 * c.smoothing)
+	}
+	return c
+}
+
+// Cell is the surge cell containing the point
+func (c *SurgeCalculator) Cell(lat, lng float64) Cell {
+	return CellOf(lat, lng, c.precision)
+}
+
+// Update feeds a new demand/supply snapshot of a cell and returns the current surge
+func (c *SurgeCalculator) Update(ctx context.Context, cell string, demand, supply int) (Surge, error) {
+	raw := c.raw(demand, supply)
+
+	value := raw
+	if c.smoothing > 0 {
+		s, err := c.store.Smooth(ctx, cell, func(prev SmoothedSurge, ok bool) SmoothedSurge {
+			now := c.now()
+			if !ok {
+				// a cell seen for the first time starts from no surge, one period ago
+				prev = SmoothedSurge{Value: 1, At: now.Add(-c.smoothing)}
+			}
+			// the older the previous value, the less it weighs
+			alpha := 1 - math.Exp(-math.Max(now.Sub(prev.At).Seconds(), 0)/c.smoothing.Seconds())
+			return SmoothedSurge{Value: prev.Value + alpha*(raw-prev.Value), At: now}
+		})
+		if err != nil {
+			return Surge{}, fmt.Errorf("smooth surge of cell %s: %w", cell, err)
+		}
+		value = s.Value
+	}
+
+	return Surge{
+		Cell:       cell,
+		Demand:     demand,
+		Supply:     supply,
+		Raw:        raw,
+		Multiplier: math.Round(value*10) / 10,
+	}, nil
+}
+
+func (c *SurgeCalculator) raw(demand, supply int) float64 {
+	if demand < c.minDemand || demand <= supply {
+		return 1
+	}
+	ratio := float64(demand) / math.Max(float64(supply), 1)
+	return math.Min(1+c.sensitivity*(ratio-1), c.maxMultiplier)
+}
+
+// MemorySurgeStore keeps the smoothed values in this process; instances do
+// not share them and a restart forgets them
+type MemorySurgeStore struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	cells map[string]SmoothedSurge
+}
+
+// NewMemorySurgeStore drops cells not updated for ttl (an hour if ttl is not
+// positive); their smoothed value would have decayed to the next snapshot anyway
+func NewMemorySurgeStore(ttl time.Duration) *MemorySurgeStore {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &MemorySurgeStore{ttl: ttl, cells: make(map[string]SmoothedSurge)}
+}
+
+func (m *MemorySurgeStore) Smooth(ctx context.Context, cell string, next func(prev SmoothedSurge, ok bool) SmoothedSurge) (SmoothedSurge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev, ok := m.cells[cell]
+	s := next(prev, ok)
+	m.cells[cell] = s
+
+	for cell, old := range m.cells {
+		if s.At.Sub(old.At) > m.ttl {
+			delete(m.cells, cell)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,157 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"ride-hail/internal/config"
+)
+
+func testSurge(smoothingSeconds int) (*SurgeCalculator, *time.Time) {
+	return testSurgeWithStore(smoothingSeconds, nil)
+}
+
+func testSurgeWithStore(smoothingSeconds int, store SurgeStore) (*SurgeCalculator, *time.Time) {
+	c := NewSurgeCalculator(&config.Surgeconfig{
+		GeohashPrecision: 6,
+		Sensitivity:      0.5,
+		MaxMultiplier:    2.5,
+		MinDemand:        3,
+		SmoothingSeconds: smoothingSeconds,
+	}, store)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+// update is Update for a calculator whose store cannot fail
+func update(t *testing.T, c *SurgeCalculator, cell string, demand, supply int) Surge {
+	t.Helper()
+	s, err := c.Update(context.Background(), cell, demand, supply)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	return s
+}
+
+func TestSurgeRaw(t *testing.T) {
+	tests := []struct {
+		name           string
+		demand, supply int
+		want           float64
+	}{
+		{"demand below minimum", 2, 0, 1},
+		{"supply covers demand", 10, 10, 1},
+		{"twice the demand", 20, 10, 1.5},
+		{"no supply counts as one driver", 5, 0, 3},
+		{"capped at the maximum", 100, 1, 2.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := testSurge(0)
+			got := update(t, c, "cell", tt.demand, tt.supply)
+			if want := math.Min(tt.want, 2.5); got.Raw != want || got.Multiplier != want {
+				t.Errorf("Update(%d, %d) = raw %v, multiplier %v, want %v", tt.demand, tt.supply, got.Raw, got.Multiplier, want)
+			}
+		})
+	}
+}
+
+func TestSurgeSmoothing(t *testing.T) {
+	c, now := testSurge(60)
+
+	// a new cell starts from no surge one smoothing period ago: 1 + (1-1/e)*(2.5-1)
+	first := update(t, c, "cell", 100, 1)
+	if first.Raw != 2.5 || first.Multiplier != 1.9 {
+		t.Fatalf("first Update() = raw %v, multiplier %v, want raw 2.5, multiplier 1.9", first.Raw, first.Multiplier)
+	}
+
+	// a second snapshot at the same instant does not move the smoothed value
+	if got := update(t, c, "cell", 100, 1); got.Multiplier != 1.9 {
+		t.Errorf("immediate Update() multiplier = %v, want 1.9", got.Multiplier)
+	}
+
+	// other cells are smoothed independently
+	if got := update(t, c, "other", 0, 0); got.Multiplier != 1 {
+		t.Errorf("other cell multiplier = %v, want 1", got.Multiplier)
+	}
+
+	// long after, the previous value no longer weighs and the cap still holds
+	*now = now.Add(10 * time.Minute)
+	if got := update(t, c, "cell", 1000, 1); got.Multiplier != 2.5 {
+		t.Errorf("late Update() multiplier = %v, want 2.5", got.Multiplier)
+	}
+
+	// and demand going away brings the price back down gradually
+	*now = now.Add(30 * time.Second)
+	got := update(t, c, "cell", 0, 10)
+	if got.Raw != 1 || got.Multiplier <= 1 || got.Multiplier >= 2.5 {
+		t.Errorf("Update() after demand drop = raw %v, multiplier %v, want raw 1 and a multiplier between 1 and 2.5", got.Raw, got.Multiplier)
+	}
+}
+
+func TestSurgeSharedStore(t *testing.T) {
+	store := NewMemorySurgeStore(0)
+	a, nowA := testSurgeWithStore(60, store)
+	b, nowB := testSurgeWithStore(60, store)
+
+	first := update(t, a, "cell", 100, 1)
+	*nowB = *nowA
+	// the second instance continues the series of the first: at the same
+	// instant a quiet snapshot does not move it, while a series of its own
+	// would start from no surge
+	if got := update(t, b, "cell", 0, 10); got.Multiplier != first.Multiplier {
+		t.Errorf("second instance multiplier = %v, want %v", got.Multiplier, first.Multiplier)
+	}
+}
+
+func TestSurgeStoreError(t *testing.T) {
+	c, _ := testSurgeWithStore(60, failingStore{})
+	if _, err := c.Update(context.Background(), "cell", 100, 1); err == nil {
+		t.Error("Update() error = nil, want the store error")
+	}
+
+	// without smoothing the store is not needed
+	c, _ = testSurgeWithStore(0, failingStore{})
+	if got := update(t, c, "cell", 100, 1); got.Multiplier != 2.5 {
+		t.Errorf("unsmoothed multiplier = %v, want 2.5", got.Multiplier)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Smooth(context.Context, string, func(SmoothedSurge, bool) SmoothedSurge) (SmoothedSurge, error) {
+	return SmoothedSurge{}, errors.New("store unavailable")
+}
+
+func TestMemorySurgeStoreEvictsStaleCells(t *testing.T) {
+	store := NewMemorySurgeStore(10 * time.Minute)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ts time.Time) func(SmoothedSurge, bool) SmoothedSurge {
+		return func(SmoothedSurge, bool) SmoothedSurge { return SmoothedSurge{Value: 1, At: ts} }
+	}
+
+	ctx := context.Background()
+	store.Smooth(ctx, "stale", at(start))
+	store.Smooth(ctx, "fresh", at(start.Add(11*time.Minute)))
+
+	if _, ok := store.cells["stale"]; ok {
+		t.Error("stale cell was not evicted")
+	}
+	if _, ok := store.cells["fresh"]; !ok {
+		t.Error("fresh cell was evicted")
+	}
+}
+
+func TestNewSurgeCalculatorDefaults(t *testing.T) {
+	c := NewSurgeCalculator(&config.Surgeconfig{MaxMultiplier: 0.5}, nil)
+	if c.precision != 6 {
+		t.Errorf("precision = %d, want 6", c.precision)
+	}
+	if c.maxMultiplier != 1 {
+		t.Errorf("maxMultiplier = %v, want 1", c.maxMultiplier)
+	}
+}
@@ -9,16 +9,16 @@ import (
 	"time"
 
 	"ride-hail/internal/config"
+	"ride-hail/internal/confirm"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/outbox"
 	"ride-hail/internal/ride-service/core/ports"
-
-	messagebrokerdto "ride-hail/internal/ride-service/core/domain/message_broker_dto"
+	"ride-hail/internal/tracing"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 const (
-	exchange       = "ride_topic"
 	reconnInterval = 10
 )
 
@@ -28,6 +28,7 @@ type RabbitMQ struct {
 	mylog        mylogger.Logger
 	conn         *amqp.Connection
 	ch           *amqp.Channel
+	pub          *confirm.Channel
 	reconnecting bool
 	mu           *sync.Mutex
 }
@@ -47,47 +48,89 @@ func New(ctx context.Context, rabbitmqCfg config.RabbitMqconfig, mylog mylogger.
 	return r, nil
 }
 
-func (r *RabbitMQ) PushMessageToRequest(ctx context.Context, message messagebrokerdto.Ride) error {
-	mylog := r.mylog.Action("pushMessage")
+// PublishConfirmed waits for the broker to confirm the message, so the
+// outbox relay marks it sent only once RabbitMQ has taken responsibility.
+// Errors are the typed ones of the confirm package; the relay retries them.
+// The trace of ctx goes in the headers, its id is the correlation id.
+func (r *RabbitMQ) PublishConfirmed(ctx context.Context, m outbox.Message) error {
+	mylog := r.mylog.Action("publishConfirmed")
 
 	if r.conn.IsClosed() {
 		mylog.Error("connection between rabbitmq is closed", fmt.Errorf("closed conn"))
 		go r.reconnect(r.ctx)
-		return errors.New("connection is closed")
+		return confirm.ErrClosed
 	}
 
-	routingKey := fmt.Sprintf("ride.request.%s", message.RideType)
-	body, err := json.Marshal(message)
-	if err != nil {
-		return err
-	}
-	return r.ch.PublishWithContext(ctx, exchange, routingKey, false, false, amqp.Publishing{
-		ContentType:  "application/json",
-		DeliveryMode: amqp.Persistent,
-		Priority:     uint8(message.Priority),
-		Body:         body,
+	err := r.pub.Publish(ctx, m.Exchange, m.RoutingKey, amqp.Publishing{
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		Priority:      m.Priority,
+		MessageId:     m.ID,
+		CorrelationId: tracing.CorrelationID(ctx),
+		Headers:       tracing.Inject(ctx, nil),
+		Timestamp:     m.CreatedAt,
+		Body:          m.Payload,
 	})
+	if errors.Is(err, confirm.ErrClosed) {
+		go r.reconnect(r.ctx)
+	}
+	return err
 }
 
-func (r *RabbitMQ) PushMessageToStatus(ctx context.Context, msg messagebrokerdto.RideStatus) error {
-	mylog := r.mylog.Action("pushMessage")
-
+// PublishJSON publishes msg once, not through the outbox: passenger events are
+// only worth delivering right now. A message for an instance that is gone
+// comes back as confirm.ErrUnroutable.
+func (r *RabbitMQ) PublishJSON(ctx context.Context, exchange, routingKey string, msg any) (err error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
 	if r.conn.IsClosed() {
-		mylog.Error("connection between rabbitmq is closed", fmt.Errorf("closed conn"))
 		go r.reconnect(r.ctx)
-		return errors.New("connection is closed")
+		return confirm.ErrClosed
 	}
 
-	routingKey := fmt.Sprintf("ride.status.%s", msg.Status)
-	body, err := json.Marshal(msg)
+	ctx, span := tracing.StartProducer(ctx, exchange, routingKey)
+	defer func() { tracing.End(span, err) }()
+
+	err = r.pub.Publish(ctx, exchange, routingKey, amqp.Publishing{
+		ContentType:   "application/json",
+		CorrelationId: tracing.CorrelationID(ctx),
+		Headers:       tracing.Inject(ctx, nil),
+		Body:          body,
+	})
+	if errors.Is(err, confirm.ErrClosed) {
+		go r.reconnect(r.ctx)
+	}
+	return err
+}
+
+// ConsumeInstance consumes the queue of this instance on the direct exchange.
+// The queue lives as long as the connection: once the instance is gone, events
+// for its passengers are unroutable instead of piling up.
+func (r *RabbitMQ) ConsumeInstance(ctx context.Context, exchange, instanceId string) (<-chan amqp.Delivery, error) {
+	if !r.IsAlive() {
+		return nil, confirm.ErrClosed
+	}
+	if err := r.ch.ExchangeDeclare(exchange, "direct", true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("exchange declare: %w", err)
+	}
+	q, err := r.ch.QueueDeclare(
+		exchange+"."+instanceId,
+		false, // durable
+		true,  // auto-delete
+		true,  // exclusive
+		false, // no-wait
+		nil,
+	)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("queue declare: %w", err)
 	}
-	return r.ch.PublishWithContext(ctx, exchange, routingKey, false, false, amqp.Publishing{
-		ContentType:  "application/json",
-		DeliveryMode: amqp.Persistent,
-		Body:         body,
-	})
+	if err := r.ch.QueueBind(q.Name, instanceId, exchange, false, nil); err != nil {
+		return nil, fmt.Errorf("queue bind: %w", err)
+	}
+	// auto-ack: a lost event is superseded by the next one
+	return r.ch.ConsumeWithContext(ctx, q.Name, "", true, true, false, false, nil)
 }
 
 func (r *RabbitMQ) ConsumeMessageFromDrivers(ctx context.Context, queue, driverName string) (<-chan amqp.Delivery, error) {
@@ -143,11 +186,13 @@ func (r *RabbitMQ) connect() error {
 		return err
 	}
 
-	if err := ch.Confirm(false); err != nil {
+	pub, err := confirm.NewChannel(ch, time.Duration(r.cfg.ConfirmTimeoutMillis)*time.Millisecond)
+	if err != nil {
 		return err
 	}
 	r.conn = conn
 	r.ch = ch
+	r.pub = pub
 	return nil
 }
 
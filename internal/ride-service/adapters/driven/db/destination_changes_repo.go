@@ -0,0 +1,318 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/ride-service/core/domain/dto"
+	"ride-hail/internal/ride-service/core/domain/model"
+	"ride-hail/internal/ride-service/core/myerrors"
+	"ride-hail/internal/ridestate"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (rr *RidesRepo) GetRideRoute(ctx context.Context, passengerId, rideId string) (model.Rides, error) {
+	q := `
+	SELECT
+		r.ride_id,
+		r.passenger_id,
+		COALESCE(r.driver_id::text, ''),
+		r.vehicle_type,
+		r.status,
+		COALESCE(r.estimated_fare, 0),
+		r.fare_breakdown,
+		pc.latitude,
+		pc.longitude,
+		pc.address,
+		dc.latitude,
+		dc.longitude,
+		dc.address,
+		COALESCE(dc.distance_km, 0)
+	FROM
+		rides r
+	JOIN coordinates pc ON pc.coord_id = r.pickup_coord_id
+	JOIN coordinates dc ON dc.coord_id = r.destination_coord_id
+	WHERE
+		r.ride_id = $1 AND r.passenger_id = $2`
+
+	var (
+		ride          model.Rides
+		fareBreakdown []byte
+	)
+	err := rr.db.store.QueryRow(ctx, q, rideId, passengerId).Scan(
+		&ride.ID,
+		&ride.PassengerId,
+		&ride.DriverId,
+		&ride.VehicleType,
+		&ride.Status,
+		&ride.EstimatedFare,
+		&fareBreakdown,
+		&ride.PickupCoordinate.Latitude,
+		&ride.PickupCoordinate.Longitude,
+		&ride.PickupCoordinate.Address,
+		&ride.DestinationCoordinate.Latitude,
+		&ride.DestinationCoordinate.Longitude,
+		&ride.DestinationCoordinate.Address,
+		&ride.DestinationCoordinate.DistanceKm,
+	)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := rr.db.IsAlive(); err2 != nil {
+			return model.Rides{}, err2
+		}
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidUUID(err) {
+			return model.Rides{}, myerrors.ErrRideNotFound
+		}
+		return model.Rides{}, fmt.Errorf("failed to get ride: %w", err)
+	}
+	if len(fareBreakdown) > 0 {
+		if err := json.Unmarshal(fareBreakdown, &ride.FareBreakdown); err != nil {
+			return model.Rides{}, fmt.Errorf("failed to unmarshal fare breakdown: %w", err)
+		}
+	}
+
+	stops, err := rr.listRideStops(ctx, ride.ID)
+	if err != nil {
+		return model.Rides{}, err
+	}
+	for _, stop := range stops {
+		ride.Stops = append(ride.Stops, model.RideStop{
+			StopIndex: stop.StopIndex,
+			Latitude:  stop.Latitude,
+			Longitude: stop.Longitude,
+			Address:   stop.Address,
+			ArrivedAt: stop.ArrivedAt,
+		})
+	}
+
+	return ride, nil
+}
+
+func (rr *RidesRepo) CreateDestinationChange(ctx context.Context, c model.DestinationChange, messages func(model.DestinationChange) ([]outbox.Message, error)) (string, error) {
+	tx, err := rr.db.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		// Check if the database is alive
+		if err2 := rr.db.IsAlive(); err2 != nil {
+			return "", err2
+		}
+		return "", err
+	}
+	defer tx.Rollback(ctx) // Safe rollback if not committed
+
+	// only the latest proposal can be accepted
+	q1 := `
+	UPDATE ride_destination_changes
+	SET status = 'SUPERSEDED', resolved_at = NOW()
+	WHERE ride_id = $1 AND status = 'PENDING'`
+	if _, err := tx.Exec(ctx, q1, c.RideId); err != nil {
+		return "", fmt.Errorf("failed to supersede destination changes: %w", err)
+	}
+
+	breakdown, err := json.Marshal(c.FareBreakdown)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal fare breakdown: %w", err)
+	}
+
+	q2 := `
+	INSERT INTO ride_destination_changes (
+		ride_id,
+		latitude,
+		longitude,
+		address,
+		distance_km,
+		old_fare,
+		new_fare,
+		fare_breakdown,
+		expires_at
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING change_id`
+
+	changeId := ""
+	err = tx.QueryRow(ctx, q2,
+		c.RideId,
+		c.Destination.Latitude,
+		c.Destination.Longitude,
+		c.Destination.Address,
+		c.DistanceKm,
+		c.OldFare,
+		c.NewFare,
+		breakdown,
+		c.ExpiresAt,
+	).Scan(&changeId)
+	if err != nil {
+		return "", fmt.Errorf("failed to create destination change: %w", err)
+	}
+
+	c.ChangeId = changeId
+	msgs, err := messages(c)
+	if err != nil {
+		return "", err
+	}
+	if err := outbox.Insert(ctx, tx, outboxService, msgs...); err != nil {
+		return "", err
+	}
+
+	return changeId, tx.Commit(ctx)
+}
+
+func (rr *RidesRepo) ResolveDestinationChange(ctx context.Context, changeId, driverId string, accepted bool, messages func(model.DestinationChange) ([]outbox.Message, error)) (model.DestinationChange, error) {
+	tx, err := rr.db.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		// Check if the database is alive
+		if err2 := rr.db.IsAlive(); err2 != nil {
+			return model.DestinationChange{}, err2
+		}
+		return model.DestinationChange{}, err
+	}
+	defer tx.Rollback(ctx) // Safe rollback if not committed
+
+	q1 := `
+	SELECT
+		c.change_id,
+		c.ride_id,
+		c.latitude,
+		c.longitude,
+		c.address,
+		c.distance_km,
+		c.old_fare,
+		c.new_fare,
+		c.fare_breakdown,
+		c.status,
+		c.expires_at,
+		r.passenger_id,
+		COALESCE(r.driver_id::text, ''),
+		r.status,
+		r.destination_coord_id,
+		dc.latitude,
+		dc.longitude,
+		dc.address,
+		COALESCE(dc.distance_km, 0)
+	FROM
+		ride_destination_changes c
+	JOIN rides r ON r.ride_id = c.ride_id
+	JOIN coordinates dc ON dc.coord_id = r.destination_coord_id
+	WHERE
+		c.change_id = $1
+	FOR UPDATE OF c, r`
+
+	var (
+		c          model.DestinationChange
+		breakdown  []byte
+		rideStatus string
+		destId     string
+		old        dto.LocationDto
+		oldKm      float64
+	)
+	err = tx.QueryRow(ctx, q1, changeId).Scan(
+		&c.ChangeId,
+		&c.RideId,
+		&c.Destination.Latitude,
+		&c.Destination.Longitude,
+		&c.Destination.Address,
+		&c.DistanceKm,
+		&c.OldFare,
+		&c.NewFare,
+		&breakdown,
+		&c.Status,
+		&c.ExpiresAt,
+		&c.PassengerId,
+		&c.DriverId,
+		&rideStatus,
+		&destId,
+		&old.Latitude,
+		&old.Longitude,
+		&old.Address,
+		&oldKm,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidUUID(err) {
+			return model.DestinationChange{}, myerrors.ErrDestinationChangeNotFound
+		}
+		return model.DestinationChange{}, fmt.Errorf("failed to lock destination change: %w", err)
+	}
+	if c.DriverId != driverId {
+		return model.DestinationChange{}, myerrors.ErrDestinationChangeNotFound
+	}
+	if err := json.Unmarshal(breakdown, &c.FareBreakdown); err != nil {
+		return model.DestinationChange{}, fmt.Errorf("failed to unmarshal fare breakdown: %w", err)
+	}
+
+	// answered already, e.g. a redelivered message
+	if c.Status != model.DestinationChangePending {
+		return c, nil
+	}
+
+	switch {
+	case !accepted:
+		c.Status = model.DestinationChangeRejected
+	case time.Now().After(c.ExpiresAt), !ridestate.Status(rideStatus).HasDriver():
+		c.Status = model.DestinationChangeExpired
+	default:
+		c.Status = model.DestinationChangeAccepted
+	}
+
+	q2 := `UPDATE ride_destination_changes SET status = $2, resolved_at = NOW() WHERE change_id = $1`
+	if _, err := tx.Exec(ctx, q2, c.ChangeId, c.Status); err != nil {
+		return model.DestinationChange{}, fmt.Errorf("failed to resolve destination change: %w", err)
+	}
+
+	msgs, err := messages(c)
+	if err != nil {
+		return model.DestinationChange{}, err
+	}
+	if err := outbox.Insert(ctx, tx, outboxService, msgs...); err != nil {
+		return model.DestinationChange{}, err
+	}
+
+	if c.Status != model.DestinationChangeAccepted {
+		return c, tx.Commit(ctx)
+	}
+
+	q3 := `
+	UPDATE coordinates
+	SET latitude = $2, longitude = $3, address = $4, distance_km = $5, fare_amount = $6, updated_at = NOW()
+	WHERE coord_id = $1`
+	if _, err := tx.Exec(ctx, q3, destId, c.Destination.Latitude, c.Destination.Longitude, c.Destination.Address, c.DistanceKm, c.NewFare); err != nil {
+		return model.DestinationChange{}, fmt.Errorf("failed to move destination: %w", err)
+	}
+
+	q4 := `
+	UPDATE rides
+	SET estimated_fare = $2, final_fare = $2, fare_breakdown = $3, updated_at = NOW()
+	WHERE ride_id = $1`
+	if _, err := tx.Exec(ctx, q4, c.RideId, c.NewFare, breakdown); err != nil {
+		return model.DestinationChange{}, fmt.Errorf("failed to update fare: %w", err)
+	}
+
+	event := ridestate.EventData{
+		Actor:      ridestate.ActorDriver,
+		ActorId:    driverId,
+		FromStatus: ridestate.Status(rideStatus),
+		ToStatus:   ridestate.Status(rideStatus),
+		Payload: map[string]interface{}{
+			"reason":          "destination_change",
+			"change_id":       c.ChangeId,
+			"proposed_by":     c.PassengerId,
+			"old_destination": old,
+			"new_destination": dto.LocationDto{
+				Latitude:  c.Destination.Latitude,
+				Longitude: c.Destination.Longitude,
+				Address:   c.Destination.Address,
+			},
+			"distance_before": oldKm,
+			"distance_after":  c.DistanceKm,
+			"fare_before":     c.OldFare,
+			"fare_after":      c.NewFare,
+			"fare_breakdown":  c.FareBreakdown,
+		},
+	}
+	if err := ridestate.InsertEvent(ctx, tx, c.RideId, ridestate.EventFareAdjusted, event); err != nil {
+		return model.DestinationChange{}, err
+	}
+
+	return c, tx.Commit(ctx)
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/internal/inbox"
+	"ride-hail/internal/ride-service/core/ports"
+)
+
+type InboxRepo struct {
+	db *DB
+}
+
+func NewInboxRepo(db *DB) ports.IInboxRepo {
+	return &InboxRepo{
+		db: db,
+	}
+}
+
+func (ir *InboxRepo) Purge(ctx context.Context, olderThan time.Duration, limit int) (int64, error) {
+	n, err := inbox.Purge(ctx, ir.db.store, olderThan, limit)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := ir.db.IsAlive(); err2 != nil {
+			return 0, err2
+		}
+		return 0, err
+	}
+	return n, nil
+}
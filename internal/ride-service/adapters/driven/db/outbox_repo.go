@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/ride-service/core/domain/model"
+	"ride-hail/internal/ride-service/core/ports"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// outboxService marks the outbox rows written by the ride service
+const outboxService = "ride-service"
+
+type OutboxRepo struct {
+	db *DB
+}
+
+func NewOutboxRepo(db *DB) ports.IOutboxRepo {
+	return &OutboxRepo{
+		db: db,
+	}
+}
+
+func (or *OutboxRepo) Claim(ctx context.Context, limit int, lease time.Duration) ([]outbox.Message, error) {
+	var msgs []outbox.Message
+	err := or.db.store.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		msgs, err = outbox.Claim(ctx, tx, outboxService, limit, lease)
+		return err
+	})
+	if err != nil {
+		// Check if the database is alive
+		if err2 := or.db.IsAlive(); err2 != nil {
+			return nil, err2
+		}
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (or *OutboxRepo) MarkSent(ctx context.Context, id string) error {
+	if err := outbox.MarkSent(ctx, or.db.store, id); err != nil {
+		if err2 := or.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return err
+	}
+	return nil
+}
+
+func (or *OutboxRepo) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	if err := outbox.MarkFailed(ctx, or.db.store, id, cause, retryAt); err != nil {
+		if err2 := or.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return err
+	}
+	return nil
+}
+
+// insertRideMessages stores the messages announcing the ride change in the
+// change's transaction; a nil builder announces nothing
+func insertRideMessages(ctx context.Context, tx outbox.DBTX, ride model.Rides, build ports.RideMessages) error {
+	if build == nil {
+		return nil
+	}
+	msgs, err := build(ride)
+	if err != nil {
+		return err
+	}
+	return outbox.Insert(ctx, tx, outboxService, msgs...)
+}
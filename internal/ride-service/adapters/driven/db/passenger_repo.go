@@ -19,7 +19,7 @@ func NewPassengerRepo(db *DB) ports.IPassengerRepo {
 func (pr *PassengerRepo) Exist(ctx context.Context, passengerId string) (string, error) {
 	q := `SELECT role FROM users WHERE user_id = $1`
 
-	conn := pr.db.conn
+	conn := pr.db.store
 
 	role := ""
 	row := conn.QueryRow(ctx, q, passengerId)
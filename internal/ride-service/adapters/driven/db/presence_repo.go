@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/ride-service/core/ports"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PresenceRepo keeps the instance holding each passenger socket. A record
+// without a heartbeat for longer than staleAfter belongs to a dead instance.
+type PresenceRepo struct {
+	db         *DB
+	staleAfter time.Duration
+}
+
+func NewPresenceRepo(db *DB, staleAfter time.Duration) ports.IPresenceRepo {
+	return &PresenceRepo{
+		db:         db,
+		staleAfter: staleAfter,
+	}
+}
+
+func (pr *PresenceRepo) Register(ctx context.Context, passengerId, instanceId string) error {
+	q := `
+		INSERT INTO passenger_connections (passenger_id, instance_id)
+		VALUES ($1, $2)
+		ON CONFLICT (passenger_id) DO UPDATE
+			SET instance_id = EXCLUDED.instance_id, connected_at = NOW(), last_seen_at = NOW()
+	`
+	if _, err := pr.db.store.Exec(ctx, q, passengerId, instanceId); err != nil {
+		// Check if the database is alive
+		if err2 := pr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to register passenger connection: %w", err)
+	}
+	return nil
+}
+
+// Unregister leaves the record alone when the passenger has already reconnected to another instance
+func (pr *PresenceRepo) Unregister(ctx context.Context, passengerId, instanceId string) error {
+	q := `DELETE FROM passenger_connections WHERE passenger_id = $1 AND instance_id = $2`
+	if _, err := pr.db.store.Exec(ctx, q, passengerId, instanceId); err != nil {
+		if err2 := pr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to unregister passenger connection: %w", err)
+	}
+	return nil
+}
+
+func (pr *PresenceRepo) Heartbeat(ctx context.Context, instanceId string) error {
+	q := `UPDATE passenger_connections SET last_seen_at = NOW() WHERE instance_id = $1`
+	if _, err := pr.db.store.Exec(ctx, q, instanceId); err != nil {
+		if err2 := pr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to refresh passenger connections: %w", err)
+	}
+	return nil
+}
+
+func (pr *PresenceRepo) UnregisterInstance(ctx context.Context, instanceId string) error {
+	q := `DELETE FROM passenger_connections WHERE instance_id = $1`
+	if _, err := pr.db.store.Exec(ctx, q, instanceId); err != nil {
+		if err2 := pr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to unregister instance connections: %w", err)
+	}
+	return nil
+}
+
+func (pr *PresenceRepo) Locate(ctx context.Context, passengerId string) (string, bool, error) {
+	q := `
+		SELECT instance_id
+		FROM passenger_connections
+		WHERE passenger_id = $1 AND last_seen_at > NOW() - make_interval(secs => $2)
+	`
+	instanceId := ""
+	err := pr.db.store.QueryRow(ctx, q, passengerId, pr.staleAfter.Seconds()).Scan(&instanceId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		if err2 := pr.db.IsAlive(); err2 != nil {
+			return "", false, err2
+		}
+		return "", false, fmt.Errorf("failed to locate passenger connection: %w", err)
+	}
+	return instanceId, true, nil
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/ride-service/core/ports"
+)
+
+type RevocationRepo struct {
+	db *DB
+}
+
+func NewRevocationRepo(db *DB) ports.IRevocationRepo {
+	return &RevocationRepo{
+		db: db,
+	}
+}
+
+// IsRevoked reports whether the token was logged out or its subject was cut off after it was issued
+func (rr *RevocationRepo) IsRevoked(ctx context.Context, jti, subjectId string, issuedAt time.Time) (bool, error) {
+	q := `
+		SELECT
+			EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+			OR EXISTS (SELECT 1 FROM revoked_subjects WHERE subject_id = $2 AND revoked_before > $3)
+	`
+
+	revoked := false
+	if err := rr.db.store.QueryRow(ctx, q, jti, subjectId, issuedAt).Scan(&revoked); err != nil {
+		// Check if the database is alive
+		if err2 := rr.db.IsAlive(); err2 != nil {
+			return false, err2
+		}
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,314 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ride-hail/internal/ride-service/core/domain/dto"
+	"ride-hail/internal/ride-service/core/myerrors"
+
+	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (rr *RidesRepo) GetPassengerRide(ctx context.Context, passengerId, rideId string) (dto.RideDetailsDto, error) {
+	q := `
+	SELECT
+		r.ride_id,
+		r.ride_number,
+		r.status,
+		r.vehicle_type,
+		pc.latitude,
+		pc.longitude,
+		COALESCE(pc.address, ''),
+		dc.latitude,
+		dc.longitude,
+		COALESCE(dc.address, ''),
+		COALESCE(r.estimated_fare, 0),
+		r.final_fare,
+		r.fare_breakdown,
+		r.driver_id,
+		d.username,
+		d.rating,
+		d.vehicle_attrs,
+		r.requested_at,
+		r.matched_at,
+		r.arrived_at,
+		r.started_at,
+		r.completed_at,
+		r.cancelled_at,
+		r.cancellation_reason
+	FROM
+		rides r
+	LEFT JOIN coordinates pc ON pc.coord_id = r.pickup_coord_id
+	LEFT JOIN coordinates dc ON dc.coord_id = r.destination_coord_id
+	LEFT JOIN drivers d ON d.driver_id = r.driver_id
+	WHERE
+		r.ride_id = $1 AND r.passenger_id = $2`
+
+	var (
+		ride                 dto.RideDetailsDto
+		pickupLat, pickupLng *float64
+		destLat, destLng     *float64
+		driverId, driverName *string
+		driverRating         *float64
+		vehicleAttrs         []byte
+		fareBreakdown        []byte
+	)
+
+	row := rr.db.store.QueryRow(ctx, q, rideId, passengerId)
+	err := row.Scan(
+		&ride.RideId,
+		&ride.RideNumber,
+		&ride.Status,
+		&ride.VehicleType,
+		&pickupLat,
+		&pickupLng,
+		&ride.Pickup.Address,
+		&destLat,
+		&destLng,
+		&ride.Destination.Address,
+		&ride.EstimatedFare,
+		&ride.FinalFare,
+		&fareBreakdown,
+		&driverId,
+		&driverName,
+		&driverRating,
+		&vehicleAttrs,
+		&ride.RequestedAt,
+		&ride.MatchedAt,
+		&ride.ArrivedAt,
+		&ride.StartedAt,
+		&ride.CompletedAt,
+		&ride.CancelledAt,
+		&ride.CancellationReason,
+	)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := rr.db.IsAlive(); err2 != nil {
+			return dto.RideDetailsDto{}, err2
+		}
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidUUID(err) {
+			return dto.RideDetailsDto{}, myerrors.ErrRideNotFound
+		}
+		return dto.RideDetailsDto{}, fmt.Errorf("failed to get ride: %w", err)
+	}
+
+	ride.Pickup.Latitude, ride.Pickup.Longitude = deref(pickupLat), deref(pickupLng)
+	ride.Destination.Latitude, ride.Destination.Longitude = deref(destLat), deref(destLng)
+	if len(fareBreakdown) > 0 {
+		ride.FareBreakdown = json.RawMessage(fareBreakdown)
+	}
+
+	if driverId != nil {
+		driver := &websocketdto.DriverInfo{
+			DriverID: *driverId,
+			Rating:   deref(driverRating),
+		}
+		if driverName != nil {
+			driver.Name = *driverName
+		}
+		if len(vehicleAttrs) > 0 {
+			if err := json.Unmarshal(vehicleAttrs, &driver.Vehicle); err != nil {
+				return dto.RideDetailsDto{}, fmt.Errorf("failed to unmarshal vehicle details: %w", err)
+			}
+		}
+		ride.Driver = driver
+	}
+
+	ride.Stops, err = rr.listRideStops(ctx, ride.RideId)
+	if err != nil {
+		return dto.RideDetailsDto{}, err
+	}
+
+	return ride, nil
+}
+
+func (rr *RidesRepo) listRideStops(ctx context.Context, rideId string) ([]dto.RideStopDto, error) {
+	q := `
+	SELECT
+		stop_index,
+		latitude,
+		longitude,
+		address,
+		arrived_at
+	FROM
+		ride_stops
+	WHERE
+		ride_id = $1
+	ORDER BY stop_index`
+
+	rows, err := rr.db.store.Query(ctx, q, rideId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ride stops: %w", err)
+	}
+	defer rows.Close()
+
+	var stops []dto.RideStopDto
+	for rows.Next() {
+		var stop dto.RideStopDto
+		if err := rows.Scan(&stop.StopIndex, &stop.Latitude, &stop.Longitude, &stop.Address, &stop.ArrivedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ride stop: %w", err)
+		}
+		stops = append(stops, stop)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get ride stops: %w", err)
+	}
+	return stops, nil
+}
+
+// ListPassengerRides returns rides newest first using keyset pagination on (created_at, ride_id)
+func (rr *RidesRepo) ListPassengerRides(ctx context.Context, passengerId string, filter dto.RideHistoryFilter, after *dto.RideHistoryCursor) ([]dto.RideSummaryDto, error) {
+	var (
+		where = []string{"r.passenger_id = $1"}
+		args  = []interface{}{passengerId}
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(filter.Statuses) > 0 {
+		where = append(where, "r.status::text = ANY("+arg(filter.Statuses)+")")
+	}
+	if filter.From != nil {
+		where = append(where, "r.created_at >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		where = append(where, "r.created_at < "+arg(*filter.To))
+	}
+	if after != nil {
+		where = append(where, fmt.Sprintf("(r.created_at, r.ride_id) < (%s, %s)", arg(after.CreatedAt), arg(after.RideId)))
+	}
+
+	q := `
+	SELECT
+		r.ride_id,
+		r.ride_number,
+		r.status,
+		r.vehicle_type,
+		COALESCE(pc.address, ''),
+		COALESCE(dc.address, ''),
+		COALESCE(r.estimated_fare, 0),
+		r.final_fare,
+		r.created_at
+	FROM
+		rides r
+	LEFT JOIN coordinates pc ON pc.coord_id = r.pickup_coord_id
+	LEFT JOIN coordinates dc ON dc.coord_id = r.destination_coord_id
+	WHERE
+		` + strings.Join(where, " AND ") + `
+	ORDER BY r.created_at DESC, r.ride_id DESC
+	LIMIT ` + arg(filter.Limit)
+
+	rows, err := rr.db.store.Query(ctx, q, args...)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := rr.db.IsAlive(); err2 != nil {
+			return nil, err2
+		}
+		if isInvalidUUID(err) {
+			return nil, myerrors.ErrInvalidCursor
+		}
+		return nil, fmt.Errorf("failed to list rides: %w", err)
+	}
+	defer rows.Close()
+
+	rides := []dto.RideSummaryDto{}
+	for rows.Next() {
+		var ride dto.RideSummaryDto
+		if err := rows.Scan(
+			&ride.RideId,
+			&ride.RideNumber,
+			&ride.Status,
+			&ride.VehicleType,
+			&ride.PickupAddress,
+			&ride.DestinationAddress,
+			&ride.EstimatedFare,
+			&ride.FinalFare,
+			&ride.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan ride: %w", err)
+		}
+		rides = append(rides, ride)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list rides: %w", err)
+	}
+
+	return rides, nil
+}
+
+func (rr *RidesRepo) GetPassengerRideEvents(ctx context.Context, passengerId, rideId string) ([]dto.RideEventDto, error) {
+	q1 := `SELECT EXISTS (SELECT 1 FROM rides WHERE ride_id = $1 AND passenger_id = $2)`
+
+	q2 := `
+	SELECT
+		ride_event_id,
+		COALESCE(event_type::text, ''),
+		event_data,
+		created_at
+	FROM
+		ride_events
+	WHERE
+		ride_id = $1
+	ORDER BY created_at, ride_event_id`
+
+	owned := false
+	if err := rr.db.store.QueryRow(ctx, q1, rideId, passengerId).Scan(&owned); err != nil {
+		// Check if the database is alive
+		if err2 := rr.db.IsAlive(); err2 != nil {
+			return nil, err2
+		}
+		if isInvalidUUID(err) {
+			return nil, myerrors.ErrRideNotFound
+		}
+		return nil, fmt.Errorf("failed to check ride owner: %w", err)
+	}
+	if !owned {
+		return nil, myerrors.ErrRideNotFound
+	}
+
+	rows, err := rr.db.store.Query(ctx, q2, rideId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ride events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []dto.RideEventDto{}
+	for rows.Next() {
+		var (
+			event dto.RideEventDto
+			data  []byte
+		)
+		if err := rows.Scan(&event.EventId, &event.EventType, &data, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ride event: %w", err)
+		}
+		event.EventData = json.RawMessage(data)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get ride events: %w", err)
+	}
+
+	return events, nil
+}
+
+// isInvalidUUID reports whether postgres rejected a malformed uuid parameter
+func isInvalidUUID(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "22P02" // invalid_text_representation
+}
+
+func deref(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
@@ -4,19 +4,30 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 
-	"ride-hail/internal/ride-service/core/domain/dto"
+	"ride-hail/internal/inbox"
+	"ride-hail/internal/pricing"
 	"ride-hail/internal/ride-service/core/domain/model"
+	"ride-hail/internal/ride-service/core/myerrors"
 	"ride-hail/internal/ride-service/core/ports"
+	"ride-hail/internal/ridestate"
 
-	messagebrokerdto "ride-hail/internal/ride-service/core/domain/message_broker_dto"
+	"ride-hail/internal/contracts"
 
 	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
 
 	"github.com/jackc/pgx/v5"
 )
 
+// consumers recorded in processed_messages
+const (
+	consumerDriverResponse = "ride-service.driver_response"
+	consumerDriverStatus   = "ride-service.driver_status"
+	consumerUnmatched      = "ride-service.unmatched_request"
+)
+
 type RidesRepo struct {
 	db *DB
 }
@@ -27,21 +38,34 @@ func NewRidesRepo(db *DB) ports.IRidesRepo {
 	}
 }
 
-func (rr *RidesRepo) GetDistance(ctx context.Context, req dto.RidesRequestDto) (float64, error) {
-	q := `SELECT ST_Distance(ST_MakePoint($1, $2)::geography, ST_MakePoint($3, $4)::geography) / 1000 as distance_km`
-
-	db := rr.db.conn
-	row := db.QueryRow(ctx, q, req.PickUpLongitude, req.PickUpLatitude, req.DestinationLongitude, req.DestinationLatitude)
-	distance := 0.0
-	err := row.Scan(&distance)
+func (rr *RidesRepo) CountCellDemand(ctx context.Context, cell pricing.Cell) (int, int, error) {
+	q := `
+	SELECT
+		(SELECT COUNT(*)
+		 FROM rides r
+		 JOIN coordinates c ON c.coord_id = r.pickup_coord_id
+		 WHERE r.status = 'REQUESTED'
+		   AND c.latitude >= $1 AND c.latitude < $2
+		   AND c.longitude >= $3 AND c.longitude < $4),
+		(SELECT COUNT(*)
+		 FROM drivers d
+		 JOIN coordinates c ON c.entity_id = d.driver_id
+		   AND c.entity_type = 'DRIVER'
+		   AND c.is_current = true
+		 WHERE d.status = 'AVAILABLE'
+		   AND c.latitude >= $1 AND c.latitude < $2
+		   AND c.longitude >= $3 AND c.longitude < $4)`
+
+	var demand, supply int
+	err := rr.db.store.QueryRow(ctx, q, cell.MinLat, cell.MaxLat, cell.MinLng, cell.MaxLng).Scan(&demand, &supply)
 	if err != nil {
 		// Check if the database is alive
 		if err2 := rr.db.IsAlive(); err2 != nil {
-			return 0, err2
+			return 0, 0, err2
 		}
-		return 0.0, err
+		return 0, 0, err
 	}
-	return distance, nil
+	return demand, supply, nil
 }
 
 func (rr *RidesRepo) GetNumberRides(ctx context.Context) (int64, error) {
@@ -53,7 +77,7 @@ func (rr *RidesRepo) GetNumberRides(ctx context.Context) (int64, error) {
 	WHERE
 		created_at::date = current_date
 	`
-	db := rr.db.conn
+	db := rr.db.store
 	row := db.QueryRow(ctx, q)
 	var count int64 = 0
 	err := row.Scan(&count)
@@ -69,7 +93,7 @@ func (rr *RidesRepo) GetNumberRides(ctx context.Context) (int64, error) {
 
 func (rr *RidesRepo) CheckDuplicate(ctx context.Context, passengerId string) (int, error) {
 	q := `SELECT COUNT(8) FROM rides WHERE passenger_id = $1 AND status IN ('REQUESTED', 'MATCHED', 'EN_ROUTE', 'ARRIVED');`
-	db := rr.db.conn
+	db := rr.db.store
 
 	row := db.QueryRow(ctx, q, passengerId)
 	var count int = 0
@@ -85,8 +109,8 @@ func (rr *RidesRepo) CheckDuplicate(ctx context.Context, passengerId string) (in
 	return count, nil
 }
 
-func (rr *RidesRepo) CreateRide(ctx context.Context, m model.Rides) (string, error) {
-	conn := rr.db.conn
+func (rr *RidesRepo) CreateRide(ctx context.Context, m model.Rides, messages ports.RideMessages) (string, error) {
+	conn := rr.db.store
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		// Check if the database is alive
@@ -157,20 +181,31 @@ func (rr *RidesRepo) CreateRide(ctx context.Context, m model.Rides) (string, err
 	q3 := `INSERT INTO rides(
 		ride_number,
 		passenger_id,
+		vehicle_type,
 		status,
 		priority, 
 		estimated_fare,
 		final_fare, 
+		fare_breakdown,
+		price_locked,
 		pickup_coord_id, 
-		destination_coord_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING ride_id`
+		destination_coord_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING ride_id`
+
+	breakdown, err := json.Marshal(m.FareBreakdown)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal fare breakdown: %w", err)
+	}
 
 	row = tx.QueryRow(ctx, q3,
 		m.RideNumber,
 		m.PassengerId,
+		m.VehicleType,
 		m.Status,
 		m.Priority,
 		m.EstimatedFare,
 		m.FinalFare,
+		breakdown,
+		m.PriceLocked,
 		PickupCoordinateId,
 		DestinationCoordinateId,
 	)
@@ -180,11 +215,74 @@ func (rr *RidesRepo) CreateRide(ctx context.Context, m model.Rides) (string, err
 		return "", err
 	}
 
+	q4 := `INSERT INTO ride_stops (ride_id, stop_index, latitude, longitude, address) VALUES ($1, $2, $3, $4, $5)`
+	for _, stop := range m.Stops {
+		if _, err := tx.Exec(ctx, q4, RideId, stop.StopIndex, stop.Latitude, stop.Longitude, stop.Address); err != nil {
+			return "", fmt.Errorf("failed to insert ride stop: %w", err)
+		}
+	}
+
+	// a booking is dispatched if and only if its ride exists, so a crash
+	// between the two cannot dispatch it twice
+	if m.BookingId != "" {
+		q5 := `
+		UPDATE scheduled_rides
+		SET status = 'DISPATCHED', ride_id = $2, dispatched_at = NOW(), updated_at = NOW()
+		WHERE booking_id = $1 AND status = 'DISPATCHING'`
+		tag, err := tx.Exec(ctx, q5, m.BookingId, RideId)
+		if err != nil {
+			return "", fmt.Errorf("failed to mark booking dispatched: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return "", myerrors.ErrBookingNotClaimed
+		}
+	}
+
+	event := ridestate.EventData{
+		Actor:    ridestate.ActorPassenger,
+		ActorId:  m.PassengerId,
+		ToStatus: ridestate.Requested,
+		Payload: map[string]interface{}{
+			"ride_number":    m.RideNumber,
+			"vehicle_type":   m.VehicleType,
+			"estimated_fare": m.EstimatedFare,
+			"fare_breakdown": m.FareBreakdown,
+		},
+	}
+	if len(m.Stops) > 0 {
+		event.Payload["stops"] = m.Stops
+	}
+	if err := ridestate.InsertEvent(ctx, tx, RideId, ridestate.EventRideRequested, event); err != nil {
+		return "", err
+	}
+
+	if m.Surge.Multiplier > 1 {
+		adjusted := ridestate.EventData{
+			Actor:    ridestate.ActorSystem,
+			ToStatus: ridestate.Requested,
+			Payload: map[string]interface{}{
+				"reason":           "surge",
+				"surge":            m.Surge,
+				"fare_before":      m.FareBreakdown.Total - m.FareBreakdown.SurgeFare,
+				"fare_after":       m.FareBreakdown.Total,
+				"surge_multiplier": m.FareBreakdown.SurgeMultiplier,
+			},
+		}
+		if err := ridestate.InsertEvent(ctx, tx, RideId, ridestate.EventFareAdjusted, adjusted); err != nil {
+			return "", err
+		}
+	}
+
+	m.ID = RideId
+	if err := insertRideMessages(ctx, tx, m, messages); err != nil {
+		return "", err
+	}
+
 	return RideId, tx.Commit(ctx)
 }
 
-func (rr *RidesRepo) ChangeStatusMatch(ctx context.Context, rideID, driverID string) (string, string, error) {
-	conn := rr.db.conn
+func (rr *RidesRepo) ChangeStatusMatch(ctx context.Context, rideID, driverID, messageID string, messages ports.RideMessages) (string, string, error) {
+	conn := rr.db.store
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		// Check if the database is alive
@@ -196,12 +294,18 @@ func (rr *RidesRepo) ChangeStatusMatch(ctx context.Context, rideID, driverID str
 	}
 	defer tx.Rollback(ctx) // Safe rollback if not committed
 
-	q := `UPDATE 
-			rides 
-		SET 
-			driver_id = $1,
-			status = 'MATCHED'
-		WHERE ride_id = $2`
+	if err := inbox.Claim(ctx, tx, consumerDriverResponse, messageID); err != nil {
+		return "", "", err
+	}
+
+	payload := map[string]interface{}{
+		"driver_id": driverID,
+	}
+	if _, err := ridestate.Apply(ctx, tx, rideID, ridestate.Matched, ridestate.ActorDriver, driverID, payload); err != nil {
+		return "", "", err
+	}
+
+	q := `UPDATE rides SET driver_id = $1 WHERE ride_id = $2`
 	_, err = tx.Exec(ctx, q, driverID, rideID)
 	if err != nil {
 		return "", "", err
@@ -219,58 +323,64 @@ func (rr *RidesRepo) ChangeStatusMatch(ctx context.Context, rideID, driverID str
 	if err != nil {
 		return "", "", err
 	}
+
+	ride := model.Rides{ID: rideID, PassengerId: passengerId, DriverId: driverID, RideNumber: rideNumber, Status: string(ridestate.Matched)}
+	if err := insertRideMessages(ctx, tx, ride, messages); err != nil {
+		return "", "", err
+	}
 	return passengerId, rideNumber, tx.Commit(ctx)
 }
 
-func (rr *RidesRepo) FindDistanceAndPassengerId(ctx context.Context, longitude, latitude float64, rideId string) (float64, string, error) {
+func (rr *RidesRepo) GetPickupAndPassengerId(ctx context.Context, rideId string) (model.Coordinates, string, error) {
 	q := `SELECT
-			ST_Distance(ST_MakePoint(c.longitude, c.latitude)::geography, ST_MakePoint($1, $2)::geography),
+			c.latitude,
+			c.longitude,
 			r.passenger_id
-		FROM rides r 
-		JOIN coordinates c ON r.pickup_coord_id = c.coord_id 
-		WHERE r.ride_id = $3`
+		FROM rides r
+		JOIN coordinates c ON r.pickup_coord_id = c.coord_id
+		WHERE r.ride_id = $1`
 
-	conn := rr.db.conn
+	conn := rr.db.store
 
-	row := conn.QueryRow(ctx, q, longitude, latitude, rideId)
+	row := conn.QueryRow(ctx, q, rideId)
 	var (
-		distance    float64
+		pickup      model.Coordinates
 		passengerId string
 	)
-	if err := row.Scan(&distance, &passengerId); err != nil {
+	if err := row.Scan(&pickup.Latitude, &pickup.Longitude, &passengerId); err != nil {
 		// Check if the database is alive
 		if err2 := rr.db.IsAlive(); err2 != nil {
-			return 0, "", err2
+			return model.Coordinates{}, "", err2
 		}
-		return 0.0, "", err
+		return model.Coordinates{}, "", err
 	}
 
-	return distance, passengerId, nil
+	return pickup, passengerId, nil
 }
 
-func (rr *RidesRepo) CancelRide(ctx context.Context, rideId, reason string) (model.Rides, error) {
+func (rr *RidesRepo) CancelRide(ctx context.Context, rideId, reason string, messages ports.RideMessages) (model.Rides, error) {
 	q1 := `
     SELECT  
+        passenger_id,
         driver_id, 
         status,
-		final_fare
+		COALESCE(final_fare, 0)
     FROM 
         rides
     WHERE 
-        ride_id = $1`
+        ride_id = $1
+    FOR UPDATE`
 
 	q2 := `
     UPDATE rides
     SET 
-        status = 'CANCELLED', 
-        cancelled_at = NOW(),
         cancellation_reason = $2
-    WHERE ride_id = $1 AND status != 'COMPLETED'`
+    WHERE ride_id = $1`
 
 	// Use sql.NullString or pointers to handle NULL values
 
 	// Start transaction first to maintain consistency
-	tx, err := rr.db.conn.BeginTx(ctx, pgx.TxOptions{})
+	tx, err := rr.db.store.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return model.Rides{}, err
 	}
@@ -282,23 +392,35 @@ func (rr *RidesRepo) CancelRide(ctx context.Context, rideId, reason string) (mod
 	row := tx.QueryRow(ctx, q1, rideId)
 
 	var driverId *string
-	if err := row.Scan(&driverId, &ride.Status, &ride.FinalFare); err != nil {
+	if err := row.Scan(&ride.PassengerId, &driverId, &ride.Status, &ride.FinalFare); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidUUID(err) {
+			return model.Rides{}, myerrors.ErrRideNotFound
+		}
 		return model.Rides{}, fmt.Errorf("failed to fetch ride details: %w", err)
 	}
 
 	if driverId != nil {
 		ride.DriverId = *driverId
 	}
-	// Validate business rules
-	if ride.Status == "CANCELLED" {
-		return model.Rides{}, fmt.Errorf("ride already cancelled")
+
+	payload := map[string]interface{}{
+		"reason":    reason,
+		"driver_id": ride.DriverId,
+	}
+	if _, err := ridestate.Apply(ctx, tx, rideId, ridestate.Cancelled, ridestate.ActorPassenger, ride.PassengerId, payload); err != nil {
+		return model.Rides{}, err
 	}
 
-	// Perform the update
 	if _, err := tx.Exec(ctx, q2, rideId, reason); err != nil {
 		return model.Rides{}, fmt.Errorf("failed to cancel ride: %w", err)
 	}
 
+	// the builder sees the status the ride was cancelled from
+	ride.ID = rideId
+	if err := insertRideMessages(ctx, tx, ride, messages); err != nil {
+		return model.Rides{}, err
+	}
+
 	// Commit transaction
 	if err := tx.Commit(ctx); err != nil {
 		return model.Rides{}, fmt.Errorf("failed to commit: %w", err)
@@ -307,9 +429,50 @@ func (rr *RidesRepo) CancelRide(ctx context.Context, rideId, reason string) (mod
 	return ride, nil
 }
 
+// CancelUnmatchedRide cancels a ride whose request was parked because no
+// driver took it. A ride that was matched or cancelled in the meantime fails
+// the transition and is left as it is.
+func (rr *RidesRepo) CancelUnmatchedRide(ctx context.Context, rideId, reason, messageId string) (model.Rides, error) {
+	tx, err := rr.db.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		if err2 := rr.db.IsAlive(); err2 != nil {
+			return model.Rides{}, err2
+		}
+		return model.Rides{}, err
+	}
+	defer tx.Rollback(ctx) // Safe rollback if not committed
+
+	if err := inbox.Claim(ctx, tx, consumerUnmatched, messageId); err != nil {
+		return model.Rides{}, err
+	}
+
+	payload := map[string]interface{}{
+		"reason": reason,
+	}
+	if _, err := ridestate.Apply(ctx, tx, rideId, ridestate.Cancelled, ridestate.ActorSystem, "", payload); err != nil {
+		return model.Rides{}, err
+	}
+
+	q := `
+	UPDATE rides
+	SET cancellation_reason = $2
+	WHERE ride_id = $1
+	RETURNING passenger_id, ride_number`
+
+	ride := model.Rides{ID: rideId, Status: string(ridestate.Cancelled)}
+	if err := tx.QueryRow(ctx, q, rideId, reason).Scan(&ride.PassengerId, &ride.RideNumber); err != nil {
+		return model.Rides{}, fmt.Errorf("failed to cancel ride: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return model.Rides{}, fmt.Errorf("failed to commit: %w", err)
+	}
+	return ride, nil
+}
+
 // ChangeStatus will return passenger id, ride number and driver information
-func (rr *RidesRepo) ChangeStatus(ctx context.Context, msg messagebrokerdto.DriverStatusUpdate) (string, string, float64, websocketdto.DriverInfo, error) {
-	conn := rr.db.conn
+func (rr *RidesRepo) ChangeStatus(ctx context.Context, msg contracts.DriverStatusChanged, messageID string, messages ports.RideMessages) (string, string, float64, websocketdto.DriverInfo, error) {
+	conn := rr.db.store
 
 	// Start transaction first to maintain consistency
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
@@ -321,6 +484,10 @@ func (rr *RidesRepo) ChangeStatus(ctx context.Context, msg messagebrokerdto.Driv
 	}
 	defer tx.Rollback(ctx) // Safe rollback if not committed
 
+	if err := inbox.Claim(ctx, tx, consumerDriverStatus, messageID); err != nil {
+		return "", "", 0, websocketdto.DriverInfo{}, err
+	}
+
 	q1 := `
     SELECT  
         r.passenger_id, 
@@ -335,12 +502,6 @@ func (rr *RidesRepo) ChangeStatus(ctx context.Context, msg messagebrokerdto.Driv
 	ON d.driver_id = r.driver_id 
     WHERE 
         ride_id = $1`
-
-	q2 := `
-	UPDATE rides
-    SET 
-        status = $2
-    WHERE ride_id = $1`
 	var (
 		driverInfo  websocketdto.DriverInfo
 		jsonData    []byte
@@ -348,10 +509,10 @@ func (rr *RidesRepo) ChangeStatus(ctx context.Context, msg messagebrokerdto.Driv
 		rideNumber  sql.NullString
 		finalFare   sql.NullFloat64
 	)
-	driverInfo.DriverID = msg.DriverId
+	driverInfo.DriverID = msg.DriverID
 
 	// first get the passenger id
-	row := tx.QueryRow(ctx, q1, msg.RideId)
+	row := tx.QueryRow(ctx, q1, msg.RideID)
 	if err := row.Scan(
 		&passengerId,
 		&rideNumber,
@@ -380,8 +541,17 @@ func (rr *RidesRepo) ChangeStatus(ctx context.Context, msg messagebrokerdto.Driv
 		return "", "", 0, websocketdto.DriverInfo{}, fmt.Errorf("ride number not found")
 	}
 
-	// Perform the update
-	if _, err := tx.Exec(ctx, q2, msg.RideId, msg.Status); err != nil {
+	to, err := ridestate.Parse(msg.Status)
+	if err != nil {
+		return "", "", 0, websocketdto.DriverInfo{}, err
+	}
+
+	payload := map[string]interface{}{
+		"message_id": messageID,
+	}
+	// driver-location may have already moved the ride in its own tx, and
+	// location updates repeat ARRIVED, so staying in place is not an error
+	if _, err := ridestate.Apply(ctx, tx, msg.RideID, to, ridestate.ActorDriver, msg.DriverID, payload); err != nil && !errors.Is(err, ridestate.ErrSameStatus) {
 		// Check if the database is alive
 		if err2 := rr.db.IsAlive(); err2 != nil {
 			return "", "", 0, websocketdto.DriverInfo{}, err2
@@ -389,11 +559,23 @@ func (rr *RidesRepo) ChangeStatus(ctx context.Context, msg messagebrokerdto.Driv
 		return "", "", 0, websocketdto.DriverInfo{}, fmt.Errorf("failed to update status: %w", err)
 	}
 
+	ride := model.Rides{
+		ID:          msg.RideID,
+		PassengerId: passengerId.String,
+		DriverId:    msg.DriverID,
+		RideNumber:  rideNumber.String,
+		Status:      string(to),
+		FinalFare:   finalFare.Float64,
+	}
+	if err := insertRideMessages(ctx, tx, ride, messages); err != nil {
+		return "", "", 0, websocketdto.DriverInfo{}, err
+	}
+
 	return passengerId.String, rideNumber.String, finalFare.Float64, driverInfo, tx.Commit(ctx)
 }
 
-func (rr *RidesRepo) CancelEveryPossibleRides(ctx context.Context) error {
-	conn := rr.db.conn
+func (rr *RidesRepo) CancelEveryPossibleRides(ctx context.Context, messages ports.RideMessages) error {
+	conn := rr.db.store
 
 	// Start transaction first to maintain consistency
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
@@ -405,37 +587,67 @@ func (rr *RidesRepo) CancelEveryPossibleRides(ctx context.Context) error {
 	}
 	defer tx.Rollback(ctx) // Safe rollback if not committed
 
-	q := `UPDATE rides SET status = 'CANCELLED' WHERE status IN ('REQUESTED', 'MATCHED', 'EN_ROUTE', 'ARRIVED', 'IN_PROGRESS')`
+	// The audit rows are written by the same statement so every cancelled ride gets one
+	q := `
+	WITH prev AS (
+		SELECT ride_id, status
+		FROM rides
+		WHERE status::text = ANY($3)
+		FOR UPDATE
+	), cancelled AS (
+		UPDATE rides r
+		SET status = 'CANCELLED', cancelled_at = NOW(), cancellation_reason = $1
+		FROM prev
+		WHERE r.ride_id = prev.ride_id
+		RETURNING r.ride_id, prev.status AS from_status, r.driver_id, r.final_fare
+	), events AS (
+		INSERT INTO ride_events (ride_id, event_type, event_data)
+		SELECT
+			ride_id,
+			'RIDE_CANCELLED',
+			jsonb_build_object(
+				'actor', $2::text,
+				'from_status', from_status,
+				'to_status', 'CANCELLED',
+				'payload', jsonb_build_object('reason', $1::text)
+			)
+		FROM cancelled
+	)
+	SELECT ride_id, from_status, COALESCE(driver_id::text, ''), COALESCE(final_fare, 0) FROM cancelled`
 
-	_, err = tx.Exec(ctx, q)
-	if err != nil {
-		return err
+	// only statuses the state machine lets the system cancel from
+	var cancellable []string
+	for _, st := range ridestate.Active() {
+		if _, err := ridestate.Check(st, ridestate.Cancelled, ridestate.ActorSystem); err == nil {
+			cancellable = append(cancellable, string(st))
+		}
 	}
 
-	return tx.Commit(ctx)
-}
-
-func (pr *RidesRepo) GetCancelPossibleRides(ctx context.Context) ([]model.Rides, error) {
-	q := `SELECT ride_id, status, final_fare FROM rides WHERE status IN ('REQUESTED', 'MATCHED', 'IN_PROGRESS', 'EN_ROUTE', 'ARRIVED');`
-	cn := pr.db.conn
-
-	rows, err := cn.Query(ctx, q)
+	rows, err := tx.Query(ctx, q, "service shutdown", ridestate.ActorSystem, cancellable)
 	if err != nil {
-		// Check if the database is alive
-		if err2 := pr.db.IsAlive(); err2 != nil {
-			return nil, err2
-		}
-		return []model.Rides{}, err
+		return err
 	}
 
+	// the builder sees the status each ride was cancelled from
 	var rides []model.Rides
 	for rows.Next() {
 		var ride model.Rides
-		if err := rows.Scan(&ride.ID, &ride.Status, &ride.FinalFare); err != nil {
-			continue
+		if err := rows.Scan(&ride.ID, &ride.Status, &ride.DriverId, &ride.FinalFare); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan cancelled ride: %w", err)
 		}
 		rides = append(rides, ride)
 	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, ride := range rides {
+		if err := insertRideMessages(ctx, tx, ride, messages); err != nil {
+			return err
+		}
+	}
 
-	return rides, nil
+	return tx.Commit(ctx)
 }
@@ -0,0 +1,278 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/ride-service/core/domain/model"
+	"ride-hail/internal/ride-service/core/myerrors"
+	"ride-hail/internal/ride-service/core/ports"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type ScheduledRidesRepo struct {
+	db *DB
+}
+
+func NewScheduledRidesRepo(db *DB) ports.IScheduledRidesRepo {
+	return &ScheduledRidesRepo{
+		db: db,
+	}
+}
+
+const scheduledRideColumns = `
+	booking_id,
+	created_at,
+	passenger_id,
+	vehicle_type,
+	pickup_latitude,
+	pickup_longitude,
+	pickup_address,
+	destination_latitude,
+	destination_longitude,
+	destination_address,
+	stops,
+	scheduled_at,
+	COALESCE(estimated_fare, 0),
+	status,
+	ride_id,
+	failure_reason`
+
+func scanScheduledRide(row pgx.Row) (model.ScheduledRide, error) {
+	var (
+		b     model.ScheduledRide
+		stops []byte
+	)
+	err := row.Scan(
+		&b.BookingId,
+		&b.CreatedAt,
+		&b.PassengerId,
+		&b.VehicleType,
+		&b.Pickup.Latitude,
+		&b.Pickup.Longitude,
+		&b.Pickup.Address,
+		&b.Destination.Latitude,
+		&b.Destination.Longitude,
+		&b.Destination.Address,
+		&stops,
+		&b.ScheduledAt,
+		&b.EstimatedFare,
+		&b.Status,
+		&b.RideId,
+		&b.FailureReason,
+	)
+	if err != nil {
+		return b, err
+	}
+	if err := json.Unmarshal(stops, &b.Stops); err != nil {
+		return b, fmt.Errorf("failed to unmarshal booking stops: %w", err)
+	}
+	return b, nil
+}
+
+func (sr *ScheduledRidesRepo) CreateBooking(ctx context.Context, b model.ScheduledRide) (string, error) {
+	q := `
+	INSERT INTO scheduled_rides (
+		passenger_id,
+		vehicle_type,
+		pickup_latitude,
+		pickup_longitude,
+		pickup_address,
+		destination_latitude,
+		destination_longitude,
+		destination_address,
+		stops,
+		scheduled_at,
+		estimated_fare
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING booking_id`
+
+	stops, err := json.Marshal(b.Stops)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal booking stops: %w", err)
+	}
+
+	bookingId := ""
+	err = sr.db.store.QueryRow(ctx, q,
+		b.PassengerId,
+		b.VehicleType,
+		b.Pickup.Latitude,
+		b.Pickup.Longitude,
+		b.Pickup.Address,
+		b.Destination.Latitude,
+		b.Destination.Longitude,
+		b.Destination.Address,
+		stops,
+		b.ScheduledAt,
+		b.EstimatedFare,
+	).Scan(&bookingId)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := sr.db.IsAlive(); err2 != nil {
+			return "", err2
+		}
+		return "", fmt.Errorf("failed to create booking: %w", err)
+	}
+	return bookingId, nil
+}
+
+func (sr *ScheduledRidesRepo) ListUpcomingBookings(ctx context.Context, passengerId string) ([]model.ScheduledRide, error) {
+	q := `SELECT ` + scheduledRideColumns + `
+	FROM scheduled_rides
+	WHERE passenger_id = $1 AND status IN ('SCHEDULED', 'DISPATCHING')
+	ORDER BY scheduled_at, booking_id`
+
+	rows, err := sr.db.store.Query(ctx, q, passengerId)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := sr.db.IsAlive(); err2 != nil {
+			return nil, err2
+		}
+		return nil, fmt.Errorf("failed to list bookings: %w", err)
+	}
+	defer rows.Close()
+
+	bookings := []model.ScheduledRide{}
+	for rows.Next() {
+		b, err := scanScheduledRide(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan booking: %w", err)
+		}
+		bookings = append(bookings, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list bookings: %w", err)
+	}
+	return bookings, nil
+}
+
+func (sr *ScheduledRidesRepo) CancelBooking(ctx context.Context, passengerId, bookingId string) error {
+	q := `
+	UPDATE scheduled_rides
+	SET status = 'CANCELLED', cancelled_at = NOW(), updated_at = NOW()
+	WHERE booking_id = $1 AND passenger_id = $2 AND status = 'SCHEDULED'`
+
+	tag, err := sr.db.store.Exec(ctx, q, bookingId, passengerId)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := sr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		if isInvalidUUID(err) {
+			return myerrors.ErrBookingNotFound
+		}
+		return fmt.Errorf("failed to cancel booking: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+
+	// tell "not yours / does not exist" apart from "too late"
+	var status string
+	err = sr.db.store.QueryRow(ctx, `SELECT status FROM scheduled_rides WHERE booking_id = $1 AND passenger_id = $2`, bookingId, passengerId).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return myerrors.ErrBookingNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to cancel booking: %w", err)
+	}
+	return fmt.Errorf("%w: status is %s", myerrors.ErrBookingNotCancellable, status)
+}
+
+func (sr *ScheduledRidesRepo) ClaimDueBookings(ctx context.Context, dueAfter, dueBefore time.Time, limit int) ([]model.ScheduledRide, error) {
+	q := `
+	UPDATE scheduled_rides
+	SET status = 'DISPATCHING', updated_at = NOW()
+	WHERE booking_id IN (
+		SELECT booking_id
+		FROM scheduled_rides
+		WHERE status = 'SCHEDULED' AND scheduled_at >= $1 AND scheduled_at <= $2
+		ORDER BY scheduled_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + scheduledRideColumns
+
+	bookings, err := sr.queryBookings(ctx, q, dueAfter, dueBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim bookings: %w", err)
+	}
+	return bookings, nil
+}
+
+// ExpireMissedBookings fails what a dispatcher that was down did not send in time
+func (sr *ScheduledRidesRepo) ExpireMissedBookings(ctx context.Context, scheduledBefore time.Time, reason string) ([]model.ScheduledRide, error) {
+	q := `
+	UPDATE scheduled_rides
+	SET status = 'FAILED', failure_reason = $2, updated_at = NOW()
+	WHERE status = 'SCHEDULED' AND scheduled_at < $1
+	RETURNING ` + scheduledRideColumns
+
+	bookings, err := sr.queryBookings(ctx, q, scheduledBefore, reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire bookings: %w", err)
+	}
+	return bookings, nil
+}
+
+// queryBookings runs q, which returns scheduledRideColumns
+func (sr *ScheduledRidesRepo) queryBookings(ctx context.Context, q string, args ...any) ([]model.ScheduledRide, error) {
+	rows, err := sr.db.store.Query(ctx, q, args...)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := sr.db.IsAlive(); err2 != nil {
+			return nil, err2
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := []model.ScheduledRide{}
+	for rows.Next() {
+		b, err := scanScheduledRide(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan booking: %w", err)
+		}
+		bookings = append(bookings, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+func (sr *ScheduledRidesRepo) MarkBookingFailed(ctx context.Context, bookingId, reason string) error {
+	q := `
+	UPDATE scheduled_rides
+	SET status = 'FAILED', failure_reason = $2, updated_at = NOW()
+	WHERE booking_id = $1`
+
+	if _, err := sr.db.store.Exec(ctx, q, bookingId, reason); err != nil {
+		// Check if the database is alive
+		if err2 := sr.db.IsAlive(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("failed to mark booking failed: %w", err)
+	}
+	return nil
+}
+
+func (sr *ScheduledRidesRepo) ReleaseStaleClaims(ctx context.Context, olderThan time.Duration) (int64, error) {
+	q := `
+	UPDATE scheduled_rides
+	SET status = 'SCHEDULED', updated_at = NOW()
+	WHERE status = 'DISPATCHING' AND updated_at < NOW() - $1::interval`
+
+	tag, err := sr.db.store.Exec(ctx, q, olderThan)
+	if err != nil {
+		// Check if the database is alive
+		if err2 := sr.db.IsAlive(); err2 != nil {
+			return 0, err2
+		}
+		return 0, fmt.Errorf("failed to release bookings: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
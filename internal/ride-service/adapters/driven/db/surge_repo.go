@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"ride-hail/internal/pricing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SurgeRepo keeps the smoothed surge of every cell in surge_cells
+type SurgeRepo struct {
+	db *DB
+}
+
+func NewSurgeRepo(db *DB) pricing.SurgeStore {
+	return &SurgeRepo{
+		db: db,
+	}
+}
+
+func (sr *SurgeRepo) Smooth(ctx context.Context, cell string, next func(prev pricing.SmoothedSurge, ok bool) pricing.SmoothedSurge) (pricing.SmoothedSurge, error) {
+	var s pricing.SmoothedSurge
+	err := sr.db.store.WithTx(ctx, func(tx pgx.Tx) error {
+		// the row may not exist yet, so the cell is locked rather than the row
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('surge:' || $1))`, cell); err != nil {
+			return err
+		}
+
+		var prev pricing.SmoothedSurge
+		ok := true
+		err := tx.QueryRow(ctx, `SELECT multiplier, updated_at FROM surge_cells WHERE cell = $1`, cell).Scan(&prev.Value, &prev.At)
+		if errors.Is(err, pgx.ErrNoRows) {
+			ok = false
+		} else if err != nil {
+			return err
+		}
+
+		s = next(prev, ok)
+		q := `
+		INSERT INTO surge_cells (cell, multiplier, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (cell) DO UPDATE SET multiplier = EXCLUDED.multiplier, updated_at = EXCLUDED.updated_at`
+		_, err = tx.Exec(ctx, q, cell, s.Value, s.At)
+		return err
+	})
+	if err != nil {
+		// Check if the database is alive
+		if err2 := sr.db.IsAlive(); err2 != nil {
+			return pricing.SmoothedSurge{}, err2
+		}
+		return pricing.SmoothedSurge{}, err
+	}
+	return s, nil
+}
@@ -3,13 +3,18 @@ package notification
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 
+	"ride-hail/internal/inbox"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/ride-service/core/myerrors"
 	"ride-hail/internal/ride-service/core/ports"
+	"ride-hail/internal/ridestate"
+	"ride-hail/internal/tracing"
 
-	messagebrokerdto "ride-hail/internal/ride-service/core/domain/message_broker_dto"
+	"ride-hail/internal/contracts"
 	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
 
 	"github.com/rabbitmq/amqp091-go"
@@ -17,9 +22,15 @@ import (
 
 const (
 	// routing key
-	driverResponse  = "driver_responses"
-	driverStatus    = "driver_status"
-	locationUpdates = "location_updates"
+	driverResponse    = "driver_responses"
+	driverStatus      = "driver_status"
+	locationUpdates   = "location_updates"
+	driverDestination = "driver_destination"
+	// ride requests parked after every matching attempt
+	unmatchedRequests = "ride_requests.unmatched"
+
+	parkReasonHeader = "x-park-reason"
+	noDriversFound   = "no drivers found"
 
 	// websocket type
 	rideStatusUpdate     = "ride_status_update"
@@ -72,18 +83,31 @@ func (n *Notification) Run() error {
 		return err
 	}
 
-	n.wg.Add(3)
-	go n.work(n.ctx, chDriverResponse, n.DriverResponse)
-	go n.work(n.ctx, chDriverStatus, n.DriverStatusUpdate)
-	go n.work(n.ctx, chLocation, n.LocationUpdate)
+	chDestination, err := n.consumer.ConsumeMessageFromDrivers(n.ctx, driverDestination, "")
+	if err != nil {
+		return err
+	}
+
+	chUnmatched, err := n.consumer.ConsumeMessageFromDrivers(n.ctx, unmatchedRequests, "")
+	if err != nil {
+		return err
+	}
+
+	n.wg.Add(5)
+	go n.work(n.ctx, driverResponse, chDriverResponse, n.DriverResponse)
+	go n.work(n.ctx, driverStatus, chDriverStatus, n.DriverStatusUpdate)
+	go n.work(n.ctx, locationUpdates, chLocation, n.LocationUpdate)
+	go n.work(n.ctx, driverDestination, chDestination, n.DestinationChangeResponse)
+	go n.work(n.ctx, unmatchedRequests, chUnmatched, n.UnmatchedRide)
 
 	return nil
 }
 
 func (n *Notification) work(
 	ctx context.Context,
+	queue string,
 	ch <-chan amqp091.Delivery,
-	Do func(msg amqp091.Delivery) error,
+	Do func(ctx context.Context, msg amqp091.Delivery) error,
 ) {
 	log := n.log.Action("work")
 	defer func() {
@@ -97,27 +121,31 @@ func (n *Notification) work(
 				return
 			}
 
-			err := Do(msg)
-			if err != nil {
-				continue
-			}
+			// every message is handled in the trace of its publisher
+			msgCtx, span := tracing.StartConsumer(ctx, queue, msg)
+			err := Do(msgCtx, msg)
+			tracing.End(span, err)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (n *Notification) DriverResponse(msg amqp091.Delivery) error {
-	log := n.log.Action("DriverReponse")
-	m := messagebrokerdto.RideAcceptance{}
-	err := json.Unmarshal(msg.Body, &m)
+func (n *Notification) DriverResponse(ctx context.Context, msg amqp091.Delivery) error {
+	log := n.log.Action("DriverReponse").WithContext(ctx)
+	m := contracts.DriverResponse{}
+	env, err := contracts.Decode(msg.Body, &m)
 	if err != nil {
-		log.Error("cannot unmarshal", err)
+		log.Error("cannot decode", err)
 		msg.Nack(false, false)
 		return err
 	}
 	fmt.Printf("Driver Response Message: %+v\n", string(msg.Body))
-	passengerId, rideNumber, err := n.rideService.SetStatusMatch(m.RideID, m.DriverID)
+	passengerId, rideNumber, err := n.rideService.SetStatusMatch(ctx, m.RideID, m.DriverID, msg.MessageId)
+	if errors.Is(err, inbox.ErrAlreadyProcessed) {
+		log.Info("driver response already applied", "message-id", msg.MessageId, "ride-id", m.RideID)
+		return msg.Ack(false)
+	}
 	if err != nil {
 		log.Error("cannot set status to match", err)
 		msg.Nack(false, false)
@@ -134,7 +162,7 @@ func (n *Notification) DriverResponse(msg amqp091.Delivery) error {
 			Rating:   m.DriverInfo.Rating,
 			Vehicle:  websocketdto.Vehicle(m.DriverInfo.Vehicle),
 		},
-		CorrelationID: msg.CorrelationId,
+		CorrelationID: env.CorrelationID,
 	}
 
 	payload, err := json.Marshal(m1)
@@ -149,22 +177,22 @@ func (n *Notification) DriverResponse(msg amqp091.Delivery) error {
 		Data: payload,
 	}
 
-	n.dispatcher.WriteToUser(passengerId, eventMsg)
+	n.dispatcher.WriteToUser(ctx, passengerId, eventMsg)
 
 	return msg.Ack(false)
 }
 
-func (n *Notification) LocationUpdate(msg amqp091.Delivery) error {
-	log := n.log.Action("LocationUpdate")
-	m2 := messagebrokerdto.LocationUpdate{}
+func (n *Notification) LocationUpdate(ctx context.Context, msg amqp091.Delivery) error {
+	log := n.log.Action("LocationUpdate").WithContext(ctx)
+	m2 := contracts.LocationUpdated{}
 	log.Info("nigga what did i get?", "body", string(msg.Body))
-	err := json.Unmarshal(msg.Body, &m2)
+	_, err := contracts.Decode(msg.Body, &m2)
 	if err != nil {
-		log.Error("cannot unmarshal", err)
+		log.Error("cannot decode", err)
 		msg.Nack(false, false)
 		return err
 	}
-	passengerId, estimatedTime, distance, err := n.rideService.EstimateDistance(m2.RideID, m2.Location.Lng, m2.Location.Lat, m2.SpeedKmh)
+	passengerId, estimatedTime, distance, err := n.rideService.EstimateDistance(ctx, m2.RideID, m2.Location.Lng, m2.Location.Lat)
 	if err != nil {
 		log.Error("cannot estimate distance", err)
 		msg.Nack(false, false)
@@ -191,32 +219,98 @@ func (n *Notification) LocationUpdate(msg amqp091.Delivery) error {
 		Data: payload,
 	}
 	log.Debug("get locationUpdate")
-	n.dispatcher.WriteToUser(passengerId, m)
+	n.dispatcher.WriteToUser(ctx, passengerId, m)
 
 	msg.Ack(false)
 	return nil
 }
 
-func (n *Notification) DriverStatusUpdate(msg amqp091.Delivery) error {
-	log := n.log.Action("DriverStatusUpdate")
-	driverStatusUpdateMessage := messagebrokerdto.DriverStatusUpdate{}
+func (n *Notification) DriverStatusUpdate(ctx context.Context, msg amqp091.Delivery) error {
+	log := n.log.Action("DriverStatusUpdate").WithContext(ctx)
+	driverStatusUpdateMessage := contracts.DriverStatusChanged{}
 
-	err := json.Unmarshal(msg.Body, &driverStatusUpdateMessage)
+	_, err := contracts.Decode(msg.Body, &driverStatusUpdateMessage)
 	if err != nil {
-		log.Error("cannot unmarshal", err)
+		log.Error("cannot decode", err)
 		msg.Nack(false, false)
 		return err
 	}
 
-	passengerId, data, err := n.rideService.UpdateRideStatus(driverStatusUpdateMessage)
+	passengerId, data, err := n.rideService.UpdateRideStatus(ctx, driverStatusUpdateMessage, msg.MessageId)
+	if errors.Is(err, inbox.ErrAlreadyProcessed) {
+		log.Info("driver status already applied", "message-id", msg.MessageId, "ride-id", driverStatusUpdateMessage.RideID)
+		return msg.Ack(false)
+	}
 	if err != nil {
 		log.Error("cannot update ride status", err)
 		msg.Nack(false, false)
 		return err
 	}
 
-	n.dispatcher.WriteToUser(passengerId, data)
+	n.dispatcher.WriteToUser(ctx, passengerId, data)
 
 	msg.Ack(false)
 	return nil
 }
+
+func (n *Notification) DestinationChangeResponse(ctx context.Context, msg amqp091.Delivery) error {
+	log := n.log.Action("DestinationChangeResponse").WithContext(ctx)
+	m := contracts.DestinationChangeAnswered{}
+
+	_, err := contracts.Decode(msg.Body, &m)
+	if err != nil {
+		log.Error("cannot decode", err)
+		msg.Nack(false, false)
+		return err
+	}
+
+	passengerId, data, err := n.rideService.ResolveDestinationChange(ctx, m)
+	if err != nil {
+		log.Error("cannot resolve destination change", err)
+		msg.Nack(false, false)
+		return err
+	}
+
+	n.dispatcher.WriteToUser(ctx, passengerId, data)
+
+	msg.Ack(false)
+	return nil
+}
+
+// UnmatchedRide cancels the ride of a parked request. A ride that got a driver
+// or was cancelled while the request waited is left alone.
+func (n *Notification) UnmatchedRide(ctx context.Context, msg amqp091.Delivery) error {
+	log := n.log.Action("UnmatchedRide").WithContext(ctx)
+	m := contracts.RideRequested{}
+
+	_, err := contracts.Decode(msg.Body, &m)
+	if err != nil {
+		log.Error("cannot decode", err)
+		msg.Nack(false, false)
+		return err
+	}
+
+	reason, _ := msg.Headers[parkReasonHeader].(string)
+	if reason == "" {
+		reason = noDriversFound
+	}
+
+	passengerId, data, err := n.rideService.CancelUnmatchedRide(ctx, m.RideID, msg.MessageId, reason)
+	if errors.Is(err, inbox.ErrAlreadyProcessed) {
+		log.Info("unmatched ride already cancelled", "message-id", msg.MessageId, "ride-id", m.RideID)
+		return msg.Ack(false)
+	}
+	if errors.Is(err, ridestate.ErrIllegalTransition) || errors.Is(err, ridestate.ErrSameStatus) || errors.Is(err, myerrors.ErrRideNotFound) {
+		log.Info("ride is no longer waiting for a driver", "ride-id", m.RideID, "error", err.Error())
+		return msg.Ack(false)
+	}
+	if err != nil {
+		log.Error("cannot cancel unmatched ride", err, "ride-id", m.RideID)
+		msg.Nack(false, false)
+		return err
+	}
+
+	n.dispatcher.WriteToUser(ctx, passengerId, data)
+
+	return msg.Ack(false)
+}
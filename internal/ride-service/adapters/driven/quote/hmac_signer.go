@@ -0,0 +1,72 @@
+package quote
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ride-hail/internal/ride-service/core/domain/model"
+	"ride-hail/internal/ride-service/core/myerrors"
+)
+
+// version prefix, bumped if the payload format ever changes
+const prefix = "q1"
+
+// HMACSigner makes stateless quote ids: "q1.<payload>.<mac>", both parts
+// base64url. Anyone can read a quote, nobody can change it.
+type HMACSigner struct {
+	key []byte
+}
+
+// NewHMACSigner needs the secret shared by every ride-service replica: a
+// quote issued by one replica, or before a restart, is verified by another
+func NewHMACSigner(secret string) (*HMACSigner, error) {
+	if secret == "" {
+		return nil, errors.New("QUOTE_SIGNING_SECRET is not set")
+	}
+	return &HMACSigner{key: []byte(secret)}, nil
+}
+
+func (s *HMACSigner) Sign(q model.Quote) (string, error) {
+	payload, err := json.Marshal(q)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal quote: %w", err)
+	}
+
+	body := prefix + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(s.mac(body)), nil
+}
+
+func (s *HMACSigner) Verify(quoteId string) (model.Quote, error) {
+	i := strings.LastIndexByte(quoteId, '.')
+	if i < 0 || !strings.HasPrefix(quoteId, prefix+".") {
+		return model.Quote{}, myerrors.ErrInvalidQuote
+	}
+	body, sig := quoteId[:i], quoteId[i+1:]
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, s.mac(body)) {
+		return model.Quote{}, myerrors.ErrInvalidQuote
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(body, prefix+"."))
+	if err != nil {
+		return model.Quote{}, myerrors.ErrInvalidQuote
+	}
+
+	var q model.Quote
+	if err := json.Unmarshal(payload, &q); err != nil {
+		return model.Quote{}, myerrors.ErrInvalidQuote
+	}
+	return q, nil
+}
+
+func (s *HMACSigner) mac(body string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(body))
+	return h.Sum(nil)
+}
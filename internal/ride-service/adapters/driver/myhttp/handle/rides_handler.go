@@ -2,22 +2,32 @@ package handle
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"ride-hail/internal/mylogger"
 	"ride-hail/internal/ride-service/core/domain/dto"
+	"ride-hail/internal/ride-service/core/myerrors"
 	"ride-hail/internal/ride-service/core/ports"
+	"ride-hail/internal/ridestate"
 )
 
 type RidesHandler struct {
-	ridesService ports.IRidesService
-	log          mylogger.Logger
+	ridesService    ports.IRidesService
+	scheduleService ports.IScheduleService
+	log             mylogger.Logger
 }
 
-func NewRidesHandler(rs ports.IRidesService, log mylogger.Logger) *RidesHandler {
+func NewRidesHandler(rs ports.IRidesService, ss ports.IScheduleService, log mylogger.Logger) *RidesHandler {
 	return &RidesHandler{
-		ridesService: rs,
-		log:          log,
+		ridesService:    rs,
+		scheduleService: ss,
+		log:             log,
 	}
 }
 
@@ -30,9 +40,20 @@ func (rh *RidesHandler) CreateRide() http.HandlerFunc {
 			return
 		}
 
-		res, err := rh.ridesService.CreateRide(req)
+		// a ride booked for later is stored, not dispatched
+		if req.ScheduledAt != nil {
+			res, err := rh.scheduleService.ScheduleRide(req)
+			if err != nil {
+				JsonError(w, scheduleErrorCode(err), err)
+				return
+			}
+			jsonResponse(w, http.StatusCreated, res)
+			return
+		}
+
+		res, err := rh.ridesService.CreateRide(r.Context(), req)
 		if err != nil {
-			JsonError(w, http.StatusInternalServerError, err)
+			JsonError(w, quoteErrorCode(err), err)
 			return
 		}
 
@@ -40,6 +61,60 @@ func (rh *RidesHandler) CreateRide() http.HandlerFunc {
 	}
 }
 
+func (rh *RidesHandler) ListScheduledRides() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		passengerId := r.Header.Get("X-UserId")
+
+		res, err := rh.scheduleService.ListScheduledRides(passengerId)
+		if err != nil {
+			JsonError(w, scheduleErrorCode(err), err)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"bookings": res,
+		})
+	}
+}
+
+func (rh *RidesHandler) CancelScheduledRide() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		passengerId := r.Header.Get("X-UserId")
+		bookingId := r.PathValue("booking_id")
+
+		if err := rh.scheduleService.CancelScheduledRide(passengerId, bookingId); err != nil {
+			JsonError(w, scheduleErrorCode(err), err)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"booking_id": bookingId,
+			"status":     "CANCELLED",
+			"message":    "Scheduled ride cancelled successfully",
+		})
+	}
+}
+
+func (rh *RidesHandler) QuoteRide() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		passengerId := r.Header.Get("X-UserId")
+		req := dto.RideQuoteRequestDto{}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			JsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		res, err := rh.ridesService.QuoteRide(passengerId, req)
+		if err != nil {
+			JsonError(w, quoteErrorCode(err), err)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, res)
+	}
+}
+
 func (rh *RidesHandler) CancelRide() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		rideId := r.PathValue("ride_id")
@@ -51,12 +126,200 @@ func (rh *RidesHandler) CancelRide() http.HandlerFunc {
 			return
 		}
 
-		res, err := rh.ridesService.CancelRide(req, rideId)
+		res, err := rh.ridesService.CancelRide(r.Context(), req, rideId)
 		if err != nil {
-			JsonError(w, http.StatusInternalServerError, err)
+			JsonError(w, transitionErrorCode(err), err)
 			return
 		}
 
 		jsonResponse(w, http.StatusCreated, res)
 	}
 }
+
+func (rh *RidesHandler) ChangeDestination() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		passengerId := r.Header.Get("X-UserId")
+		rideId := r.PathValue("ride_id")
+
+		req := dto.DestinationChangeRequestDto{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			JsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		res, err := rh.ridesService.ProposeDestinationChange(r.Context(), passengerId, rideId, req)
+		if err != nil {
+			JsonError(w, destinationErrorCode(err), err)
+			return
+		}
+
+		// the change is applied once the driver accepts it
+		jsonResponse(w, http.StatusAccepted, res)
+	}
+}
+
+func (rh *RidesHandler) GetRide() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		passengerId := r.Header.Get("X-UserId")
+		rideId := r.PathValue("ride_id")
+
+		res, err := rh.ridesService.GetRide(passengerId, rideId)
+		if err != nil {
+			JsonError(w, readErrorCode(err), err)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, res)
+	}
+}
+
+func (rh *RidesHandler) ListRides() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		passengerId := r.Header.Get("X-UserId")
+
+		filter, err := parseHistoryFilter(r.URL.Query())
+		if err != nil {
+			JsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		res, err := rh.ridesService.ListRides(passengerId, filter)
+		if err != nil {
+			JsonError(w, readErrorCode(err), err)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, res)
+	}
+}
+
+func (rh *RidesHandler) GetRideEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		passengerId := r.Header.Get("X-UserId")
+		rideId := r.PathValue("ride_id")
+
+		res, err := rh.ridesService.GetRideEvents(passengerId, rideId)
+		if err != nil {
+			JsonError(w, readErrorCode(err), err)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"ride_id": rideId,
+			"events":  res,
+		})
+	}
+}
+
+func transitionErrorCode(err error) int {
+	switch {
+	case errors.Is(err, myerrors.ErrRideNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ridestate.ErrIllegalTransition), errors.Is(err, ridestate.ErrSameStatus):
+		return http.StatusConflict
+	case errors.Is(err, ridestate.ErrActorNotAllowed):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func quoteErrorCode(err error) int {
+	switch {
+	case errors.Is(err, myerrors.ErrInvalidQuote), errors.Is(err, myerrors.ErrQuoteMismatch):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func scheduleErrorCode(err error) int {
+	switch {
+	case errors.Is(err, myerrors.ErrBookingNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, myerrors.ErrBookingNotCancellable):
+		return http.StatusConflict
+	case errors.Is(err, myerrors.ErrInvalidSchedule):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func destinationErrorCode(err error) int {
+	switch {
+	case errors.Is(err, myerrors.ErrRideNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, myerrors.ErrDestinationChangeNotAllowed):
+		return http.StatusConflict
+	case errors.Is(err, myerrors.ErrInvalidDestination):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func readErrorCode(err error) int {
+	switch {
+	case errors.Is(err, myerrors.ErrRideNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, myerrors.ErrInvalidCursor), errors.Is(err, myerrors.ErrInvalidFilter):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// parseHistoryFilter reads ?status=A,B&from=&to=&limit=&cursor=.
+// from/to accept RFC3339 or a plain date; a plain "to" date includes that whole day.
+func parseHistoryFilter(q url.Values) (dto.RideHistoryFilter, error) {
+	filter := dto.RideHistoryFilter{Cursor: q.Get("cursor")}
+
+	for _, v := range q["status"] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				filter.Statuses = append(filter.Statuses, s)
+			}
+		}
+	}
+
+	if v := q.Get("from"); v != "" {
+		t, _, err := parseTimeParam(v)
+		if err != nil {
+			return dto.RideHistoryFilter{}, fmt.Errorf("%w: from: %v", myerrors.ErrInvalidFilter, err)
+		}
+		filter.From = &t
+	}
+
+	if v := q.Get("to"); v != "" {
+		t, dateOnly, err := parseTimeParam(v)
+		if err != nil {
+			return dto.RideHistoryFilter{}, fmt.Errorf("%w: to: %v", myerrors.ErrInvalidFilter, err)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = &t
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return dto.RideHistoryFilter{}, fmt.Errorf("%w: limit must be a positive integer", myerrors.ErrInvalidFilter)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+func parseTimeParam(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("expected RFC3339 or YYYY-MM-DD, got %q", v)
+	}
+	return t, true, nil
+}
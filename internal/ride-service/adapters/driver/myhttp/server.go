@@ -10,16 +10,23 @@ import (
 	"time"
 
 	"ride-hail/internal/config"
+	"ride-hail/internal/inbox"
+	"ride-hail/internal/jwks"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/pricing"
 	"ride-hail/internal/ride-service/adapters/driven/bm"
 	"ride-hail/internal/ride-service/adapters/driven/db"
 	"ride-hail/internal/ride-service/adapters/driven/notification"
+	"ride-hail/internal/ride-service/adapters/driven/quote"
 	"ride-hail/internal/ride-service/adapters/driver/myhttp/handle"
 	"ride-hail/internal/ride-service/adapters/driver/myhttp/middleware"
 	"ride-hail/internal/ride-service/adapters/driver/myhttp/ws"
 	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
 	"ride-hail/internal/ride-service/core/ports"
 	"ride-hail/internal/ride-service/core/services"
+	"ride-hail/internal/routing"
+	"ride-hail/internal/tracing"
 )
 
 var ErrServerClosed = errors.New("Server closed")
@@ -31,6 +38,8 @@ type Server struct {
 	appCtx           context.Context
 	dispatcherCtx    context.Context
 	dispathcerCancel context.CancelFunc
+	relayCtx         context.Context
+	relayCancel      context.CancelFunc
 
 	mu  sync.Mutex
 	wg  sync.WaitGroup
@@ -42,20 +51,26 @@ type Server struct {
 
 	notify     *notification.Notification
 	dispatcher *ws.Dispatcher
+	relay      *outbox.Relay
+	purger     *inbox.Purger
 
 	db               *db.DB
 	mb               ports.IRidesBroker
 	rideService      ports.IRidesService
 	passengerService ports.IPassengerService
+	scheduleService  ports.IScheduleService
 }
 
 func NewServer(ctx, appCtx context.Context, mylog mylogger.Logger, cfg *config.Config) *Server {
 	disCtx, cancel := context.WithCancel(appCtx)
+	relayCtx, relayCancel := context.WithCancel(appCtx)
 	s := &Server{
 		ctx:              ctx,
 		appCtx:           appCtx,
 		dispatcherCtx:    disCtx,
 		dispathcerCancel: cancel,
+		relayCtx:         relayCtx,
+		relayCancel:      relayCancel,
 
 		cfg:   cfg,
 		mylog: mylog,
@@ -87,7 +102,9 @@ func (s *Server) Run() error {
 	mylog.Info("Successful message broker connection")
 
 	// Configure routes and handlers
-	s.Configure()
+	if err := s.Configure(); err != nil {
+		return fmt.Errorf("failed to configure server: %w", err)
+	}
 
 	cert, err := tls.LoadX509KeyPair(s.cfg.App.CertPath, s.cfg.App.CertKeyPath)
 	if err != nil {
@@ -102,7 +119,7 @@ func (s *Server) Run() error {
 	s.mu.Lock()
 	s.srv = &http.Server{
 		Addr:      fmt.Sprintf(":%v", s.cfg.Srv.RideServicePort),
-		Handler:   s.mux,
+		Handler:   tracing.Middleware(s.mux),
 		TLSConfig: tlsConfig,
 	}
 	s.mu.Unlock()
@@ -111,6 +128,25 @@ func (s *Server) Run() error {
 	if err != nil {
 		return err
 	}
+	s.scheduleService.Run()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.relay.Run(s.relayCtx)
+	}()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.purger.Run(s.ctx)
+	}()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.dispatcher.Run(s.dispatcherCtx)
+	}()
 
 	mylog.Info("server is running")
 	return s.startHTTPServer()
@@ -150,6 +186,8 @@ func (s *Server) Stop(ctx context.Context) error {
 		}
 	}
 	log.Info("cancelled everything...")
+	// the relay outlives the cancellation so drivers hear about it
+	s.relayCancel()
 	s.wg.Wait()
 
 	if s.srv != nil {
@@ -194,35 +232,81 @@ func (s *Server) startHTTPServer() error {
 }
 
 // Configure sets up the HTTP handlers for various APIs including Market Data, Data Mode control, and Health checks.
-func (s *Server) Configure() {
+func (s *Server) Configure() error {
+	// Public keys of auth-service
+	keys, err := jwks.NewCache(s.cfg.Jwt, s.cfg.App.CertPath, s.mylog)
+	if err != nil {
+		return fmt.Errorf("failed to create key set cache: %w", err)
+	}
+
 	// Repositories
 	rideRepo := db.NewRidesRepo(s.db)
 	passengerRepo := db.NewPassengerRepo(s.db)
+	revocationRepo := db.NewRevocationRepo(s.db)
+	scheduledRepo := db.NewScheduledRidesRepo(s.db)
+	outboxRepo := db.NewOutboxRepo(s.db)
+
+	quoteSigner, err := quote.NewHMACSigner(s.cfg.Quote.SigningSecret)
+	if err != nil {
+		return fmt.Errorf("failed to create quote signer: %w", err)
+	}
+	tariffs := pricing.NewTariffEngine(s.cfg.Pricing)
+	router, err := routing.New(s.cfg.Routing)
+	if err != nil {
+		return fmt.Errorf("failed to create router: %w", err)
+	}
 
 	// services
-	rideService := services.NewRidesService(s.appCtx, s.mylog, rideRepo, s.mb, nil)
+	rideService := services.NewRidesService(s.appCtx, s.mylog, rideRepo, s.mb, nil,
+		tariffs,
+		router,
+		pricing.NewSurgeCalculator(s.cfg.Surge, db.NewSurgeRepo(s.db)),
+		quoteSigner,
+		time.Duration(s.cfg.Quote.TTLSeconds)*time.Second,
+	)
 	passengerService := services.NewPassengerService(s.appCtx, s.mylog, passengerRepo, nil)
 	s.rideService = rideService
 	s.passengerService = passengerService
 
-	// handlers
-	rideHandler := handle.NewRidesHandler(rideService, s.mylog)
-
-	authMiddleware := middleware.NewAuthMiddleware(s.cfg.App.PublicJwtSecret)
+	authMiddleware := middleware.NewAuthMiddleware(keys.Keyfunc(), revocationRepo)
 
-	eventHandle := ws.NewEventHandler(s.cfg.App.PublicJwtSecret)
-	dispatcher := ws.NewDispathcer(s.dispatcherCtx, s.mylog, passengerService, eventHandle, &s.wg)
+	eventHandle := ws.NewEventHandler(keys.Keyfunc(), revocationRepo, rideService)
+	// passengers connected to other replicas are reached through the registry
+	heartbeat := time.Duration(max(s.cfg.WS.HeartbeatSeconds, 1)) * time.Second
+	presenceRepo := db.NewPresenceRepo(s.db, 3*heartbeat)
+	dispatcher := ws.NewDispathcer(s.dispatcherCtx, s.mylog, passengerService, eventHandle, &s.wg,
+		s.cfg.WS.InstanceID, presenceRepo, s.mb, heartbeat)
 	dispatcher.InitHandler()
 	s.dispatcher = dispatcher
 
+	// scheduled rides are dispatched with the passenger socket at hand
+	scheduleService := services.NewScheduleService(s.ctx, s.mylog, &s.wg, scheduledRepo, rideRepo, rideService, tariffs, router, dispatcher, s.cfg.Schedule)
+	s.scheduleService = scheduleService
+
+	// broker messages written with the rides are published from the outbox
+	s.relay = outbox.NewRelay(outboxRepo, s.mb, s.cfg.Outbox, s.mylog)
+	s.purger = inbox.NewPurger(db.NewInboxRepo(s.db), s.cfg.Inbox, s.mylog)
+
+	// handlers
+	rideHandler := handle.NewRidesHandler(rideService, scheduleService, s.mylog)
+
 	// consumers
 	notify := notification.New(s.ctx, &s.wg, s.mylog, dispatcher, s.mb, passengerService, rideService)
 	s.notify = notify
 
 	// Register routes
 	s.mux.Handle("POST /rides", authMiddleware.Wrap(rideHandler.CreateRide()))
+	s.mux.Handle("POST /rides/quote", authMiddleware.Wrap(rideHandler.QuoteRide()))
+	s.mux.Handle("GET /rides/scheduled", authMiddleware.Wrap(rideHandler.ListScheduledRides()))
+	s.mux.Handle("DELETE /rides/scheduled/{booking_id}", authMiddleware.Wrap(rideHandler.CancelScheduledRide()))
 	s.mux.Handle("POST /rides/{ride_id}/cancel", authMiddleware.Wrap(rideHandler.CancelRide()))
+	s.mux.Handle("POST /rides/{ride_id}/destination", authMiddleware.Wrap(rideHandler.ChangeDestination()))
+	s.mux.Handle("GET /rides", authMiddleware.Wrap(rideHandler.ListRides()))
+	s.mux.Handle("GET /rides/{ride_id}", authMiddleware.Wrap(rideHandler.GetRide()))
+	s.mux.Handle("GET /rides/{ride_id}/events", authMiddleware.Wrap(rideHandler.GetRideEvents()))
 
 	// websocket routes
 	s.mux.Handle("/ws/passengers/{passenger_id}", dispatcher.WsHandler())
+
+	return nil
 }
@@ -32,6 +32,8 @@ type Client struct {
 	passengerId string
 	wg          *sync.WaitGroup
 	cancelAuth  context.CancelFunc
+	// set by the auth event; only the read loop touches it
+	authenticated bool
 }
 
 func NewClient(ctx context.Context, log mylogger.Logger, conn *websocket.Conn, dis *Dispatcher, passengerId string, cancelAuth context.CancelFunc, wg *sync.WaitGroup) *Client {
@@ -73,7 +75,7 @@ func (c *Client) ReadMessage() {
 			continue
 		}
 
-		if err := c.dispatcher.EventHandle(c, req); err != nil {
+		if err := c.dispatcher.EventHandle(c.ctx, c, req); err != nil {
 			log.Error("cannot handle event", err)
 		}
 
@@ -10,14 +10,27 @@ import (
 
 	"ride-hail/internal/mylogger"
 	"ride-hail/internal/ride-service/core/ports"
+	"ride-hail/internal/tracing"
 
 	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
 
 	"github.com/gorilla/websocket"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var ErrEventNotSupported = errors.New("this event type is not supported")
 
+// passengerWSExchange carries events to the instance holding the passenger
+// socket; the routing key is the instance id
+const passengerWSExchange = "passenger_ws"
+
+// routedEvent is an event for a passenger connected to another instance
+type routedEvent struct {
+	PassengerId string             `json:"passenger_id"`
+	Event       websocketdto.Event `json:"event"`
+}
+
 // ================================================================================================== //
 // websocketUpgrader is used to upgrade incomming HTTP requests into a persitent websocket connection //
 // ================================================================================================== //
@@ -41,9 +54,17 @@ type Dispatcher struct {
 	sync.RWMutex
 	wg  *sync.WaitGroup
 	log mylogger.Logger
+
+	// presence tells which instance holds a passenger who is not connected here
+	instanceId string
+	presence   ports.IPresenceRepo
+	broker     ports.IRidesBroker
+	heartbeat  time.Duration
 }
 
-func NewDispathcer(ctx context.Context, log mylogger.Logger, passengerRepo ports.IPassengerService, eventHader *EventHandler, wg *sync.WaitGroup) *Dispatcher {
+func NewDispathcer(ctx context.Context, log mylogger.Logger, passengerRepo ports.IPassengerService, eventHader *EventHandler, wg *sync.WaitGroup,
+	instanceId string, presence ports.IPresenceRepo, broker ports.IRidesBroker, heartbeat time.Duration,
+) *Dispatcher {
 	return &Dispatcher{
 		ctx:              ctx,
 		clients:          make(ClientList),
@@ -52,11 +73,16 @@ func NewDispathcer(ctx context.Context, log mylogger.Logger, passengerRepo ports
 		log:              log,
 		eventHandler:     eventHader,
 		wg:               wg,
+		instanceId:       instanceId,
+		presence:         presence,
+		broker:           broker,
+		heartbeat:        heartbeat,
 	}
 }
 
 func (d *Dispatcher) InitHandler() {
 	d.hander["auth"] = d.eventHandler.AuthHandler
+	d.hander["change_destination"] = d.eventHandler.ChangeDestinationHandler
 }
 
 func (d *Dispatcher) WsHandler() http.HandlerFunc {
@@ -107,28 +133,67 @@ func (d *Dispatcher) AddClient(client *Client) {
 	log.Info("passenger successfully added", "passengerId", client.passengerId)
 }
 
+// RemoveClient forgets the client unless the passenger has reconnected since
 func (d *Dispatcher) RemoveClient(client *Client) {
 	log := d.log.Action("RemoveClient")
 	d.Lock()
-	defer d.Unlock()
-
-	if _, ok := d.clients[client.passengerId]; ok {
-		client.conn.Close()
+	client.conn.Close()
+	current, ok := d.clients[client.passengerId]
+	removed := ok && current == client
+	if removed {
 		// close(d.clients[client.passengerId].egress)
 		delete(d.clients, client.passengerId)
-		log.Info("passenger successfully deleted", "passengerId", client.passengerId)
-	} else {
+	}
+	d.Unlock()
+
+	if !removed {
 		log.Warn("passenger doesnt exist in map", "passengerId", client.passengerId)
+		return
+	}
+	if client.authenticated {
+		d.unregisterPresence(client.passengerId)
+	}
+	log.Info("passenger successfully deleted", "passengerId", client.passengerId)
+}
+
+// WriteToUser sends the event to the passenger socket, here or on the
+// instance the presence registry points to. A passenger connected nowhere
+// misses the event, as before.
+func (d *Dispatcher) WriteToUser(ctx context.Context, passengerId string, event websocketdto.Event) {
+	if d.writeLocal(passengerId, event) {
+		return
+	}
+	log := d.log.Action("WriteToUser").WithContext(ctx).With("passengerId", passengerId)
+
+	instanceId, ok, err := d.presence.Locate(ctx, passengerId)
+	if err != nil {
+		log.Error("cannot locate passenger", err)
+		return
+	}
+	if !ok || instanceId == d.instanceId {
+		log.Debug("passenger is not connected", "type", event.Type)
+		return
+	}
+
+	err = d.broker.PublishJSON(ctx, passengerWSExchange, instanceId, routedEvent{
+		PassengerId: passengerId,
+		Event:       event,
+	})
+	if err != nil {
+		log.Error("cannot route event to instance", err, "instance_id", instanceId, "type", event.Type)
 	}
 }
 
-func (d *Dispatcher) WriteToUser(passengerId string, event websocketdto.Event) {
+// writeLocal reports whether the passenger is connected to this instance
+func (d *Dispatcher) writeLocal(passengerId string, event websocketdto.Event) bool {
 	d.Lock()
 	defer d.Unlock()
 
-	if client, ok := d.clients[passengerId]; ok {
+	client, ok := d.clients[passengerId]
+	if ok {
 		client.egress <- event
 	}
+	return ok
 }
 
 func (d *Dispatcher) BroadCast(event websocketdto.Event) {
@@ -155,7 +220,7 @@ func (d *Dispatcher) StartTimerAuth(client *Client, cancel context.CancelFunc, c
 			Data: data,
 		}
 
-		d.WriteToUser(client.passengerId, event)
+		d.writeLocal(client.passengerId, event)
 		cancel()
 	case <-ctxAuth.Done():
 		msg := msg{
@@ -166,19 +231,101 @@ func (d *Dispatcher) StartTimerAuth(client *Client, cancel context.CancelFunc, c
 			Type: "auth",
 			Data: data,
 		}
-		d.WriteToUser(client.passengerId, event)
+		d.writeLocal(client.passengerId, event)
 		return
 	}
 }
 
-func (d *Dispatcher) EventHandle(client *Client, event websocketdto.Event) error {
-	if handler, ok := d.hander[event.Type]; ok {
-		// Execute the handler and return any err
-		if err := handler(client, event); err != nil {
-			return err
-		}
-		return nil
-	} else {
+// EventHandle runs the handler of the event; every event starts a trace
+func (d *Dispatcher) EventHandle(ctx context.Context, client *Client, event websocketdto.Event) (err error) {
+	handler, ok := d.hander[event.Type]
+	if !ok {
 		return ErrEventNotSupported
 	}
+
+	ctx, span := tracing.Start(ctx, "ws "+event.Type, trace.WithSpanKind(trace.SpanKindServer))
+	defer func() { tracing.End(span, err) }()
+
+	return handler(ctx, client, event)
+}
+
+// Run consumes the events other instances route to this one and keeps the
+// presence of its passengers alive until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	log := d.log.Action("ws_instance").With("instance_id", d.instanceId)
+
+	// records of an earlier run with the same id belong to no open socket
+	d.unregisterInstance(ctx)
+	defer d.unregisterInstance(ctx)
+
+	deliveries, err := d.broker.ConsumeInstance(ctx, passengerWSExchange, d.instanceId)
+	if err != nil {
+		log.Error("cannot consume the instance queue, retrying on heartbeat", err)
+	}
+	ticker := time.NewTicker(d.heartbeat)
+	defer ticker.Stop()
+
+	log.Info("instance is routing passenger events")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.presence.Heartbeat(ctx, d.instanceId); err != nil {
+				log.Error("cannot refresh passenger connections", err)
+			}
+			// the queue goes away with the broker connection
+			if deliveries == nil {
+				if deliveries, err = d.broker.ConsumeInstance(ctx, passengerWSExchange, d.instanceId); err != nil {
+					log.Error("cannot consume the instance queue", err)
+				}
+			}
+		case msg, ok := <-deliveries:
+			if !ok {
+				log.Warn("instance queue closed, resubscribing on heartbeat")
+				deliveries = nil
+				continue
+			}
+			d.handleRouted(ctx, msg)
+		}
+	}
+}
+
+func (d *Dispatcher) handleRouted(ctx context.Context, msg amqp.Delivery) {
+	ctx, span := tracing.StartConsumer(ctx, passengerWSExchange+"."+d.instanceId, msg)
+	defer span.End()
+	log := d.log.Action("handleRouted").WithContext(ctx)
+
+	var routed routedEvent
+	if err := json.Unmarshal(msg.Body, &routed); err != nil {
+		log.Error("cannot decode routed event", err)
+		return
+	}
+	if !d.writeLocal(routed.PassengerId, routed.Event) {
+		log.Debug("passenger is no longer connected here", "passengerId", routed.PassengerId)
+	}
+}
+
+// registerPresence points other instances here once the passenger is authenticated
+func (d *Dispatcher) registerPresence(ctx context.Context, passengerId string) {
+	if err := d.presence.Register(ctx, passengerId, d.instanceId); err != nil {
+		// the socket still gets the events of this instance
+		d.log.Action("registerPresence").WithContext(ctx).Error("cannot register passenger connection", err, "passengerId", passengerId)
+	}
+}
+
+func (d *Dispatcher) unregisterPresence(passengerId string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(d.ctx), 3*time.Second)
+	defer cancel()
+	if err := d.presence.Unregister(ctx, passengerId, d.instanceId); err != nil {
+		d.log.Action("unregisterPresence").Error("cannot unregister passenger connection", err, "passengerId", passengerId)
+	}
+}
+
+func (d *Dispatcher) unregisterInstance(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+	if err := d.presence.UnregisterInstance(ctx, d.instanceId); err != nil {
+		d.log.Action("ws_instance").Error("cannot unregister instance connections", err, "instance_id", d.instanceId)
+	}
 }
@@ -1,38 +1,44 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
+	"ride-hail/internal/ride-service/core/domain/dto"
+	"ride-hail/internal/ride-service/core/domain/model"
 	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
+	"ride-hail/internal/ride-service/core/ports"
 
 	"github.com/golang-jwt/jwt"
 )
 
-type EventHandle func(c *Client, e websocketdto.Event) error
+type EventHandle func(ctx context.Context, c *Client, e websocketdto.Event) error
 
 type EventHandler struct {
-	accessToken string
+	keyfunc      jwt.Keyfunc
+	revocations  ports.IRevocationRepo
+	ridesService ports.IRidesService
 }
 
-func NewEventHandler(accessToken string) *EventHandler {
+func NewEventHandler(keyfunc jwt.Keyfunc, revocations ports.IRevocationRepo, ridesService ports.IRidesService) *EventHandler {
 	return &EventHandler{
-		accessToken: accessToken,
+		keyfunc:      keyfunc,
+		revocations:  revocations,
+		ridesService: ridesService,
 	}
 }
 
-func (eh *EventHandler) AuthHandler(client *Client, e websocketdto.Event) error {
+func (eh *EventHandler) AuthHandler(ctx context.Context, client *Client, e websocketdto.Event) error {
 	var token websocketdto.AuthMessage
 	err := json.Unmarshal(e.Data, &token)
 	if err != nil {
 		return err
 	}
 	tokenString := strings.TrimPrefix(token.Token, "Bearer ")
-	tokenJWT, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		return []byte(eh.accessToken), nil
-	})
+	tokenJWT, err := jwt.Parse(tokenString, eh.keyfunc)
 	if err != nil {
 		return err
 	}
@@ -60,9 +66,59 @@ func (eh *EventHandler) AuthHandler(client *Client, e websocketdto.Event) error
 	}
 
 	if time.Now().Unix() > int64(exp) {
-		return fmt.Errorf("nigga time is up")
+		return fmt.Errorf("token expired")
+	}
+
+	jti, _ := claims["jti"].(string)
+	iat, _ := claims["iat"].(float64)
+	if jti == "" || iat == 0 {
+		return fmt.Errorf("no jti or iat")
+	}
+
+	revoked, err := eh.revocations.IsRevoked(ctx, jti, userId, time.UnixMilli(int64(iat*1000)))
+	if err != nil {
+		return fmt.Errorf("cannot check revocation: %w", err)
+	}
+	if revoked {
+		return fmt.Errorf("token revoked")
 	}
+	client.authenticated = true
+	client.dispatcher.registerPresence(ctx, client.passengerId)
 	client.cancelAuth()
 
 	return nil
 }
+
+// ChangeDestinationHandler proposes a new destination, the same as POST /rides/{ride_id}/destination
+func (eh *EventHandler) ChangeDestinationHandler(ctx context.Context, client *Client, e websocketdto.Event) error {
+	if !client.authenticated {
+		return fmt.Errorf("not authenticated")
+	}
+
+	var req dto.DestinationChangeRequestDto
+	if err := json.Unmarshal(e.Data, &req); err != nil {
+		return err
+	}
+	if req.RideId == nil || *req.RideId == "" {
+		return fmt.Errorf("ride_id is required")
+	}
+
+	res, err := eh.ridesService.ProposeDestinationChange(ctx, client.passengerId, *req.RideId, req)
+	if err != nil {
+		res = dto.DestinationChangeDto{
+			RideId:  *req.RideId,
+			Status:  model.DestinationChangeRejected,
+			Message: err.Error(),
+		}
+	}
+
+	data, mErr := json.Marshal(res)
+	if mErr != nil {
+		return mErr
+	}
+	client.dispatcher.WriteToUser(ctx, client.passengerId, websocketdto.Event{
+		Type: "destination_change_update",
+		Data: data,
+	})
+	return err
+}
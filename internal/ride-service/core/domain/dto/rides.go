@@ -1,5 +1,11 @@
 package dto
 
+import (
+	"time"
+
+	"ride-hail/internal/pricing"
+)
+
 // API Transfer data
 
 type RidesRequestDto struct {
@@ -11,15 +17,59 @@ type RidesRequestDto struct {
 	DestinationLongitude *float64 `json:"destination_longitude"`
 	DestinationAddress   *string  `json:"destination_address"`
 	RideType             *string  `json:"ride_type"`
+	// QuoteId locks the price of an earlier POST /rides/quote while it is valid
+	QuoteId *string `json:"quote_id,omitempty"`
+	// ScheduledAt books the ride for later instead of dispatching it now
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	// Stops are visited in order between pickup and destination
+	Stops []StopDto `json:"stops,omitempty"`
+	// BookingId is set by the scheduler, never by clients; the booking is
+	// marked dispatched in the transaction that creates the ride
+	BookingId *string `json:"-"`
+}
+
+type StopDto struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Address   *string  `json:"address"`
 }
 
 type RidesResponseDto struct {
-	RideId                   string  `json:"ride_id"`
-	RideNumber               string  `json:"ride_number"`
-	Status                   string  `json:"status"`
-	EstimatedFare            float64 `json:"estimated_fare"`
-	EstimatedDurationMinutes float64 `json:"estimated_duration_minutes"`
-	EstimatedDistanceKm      float64 `json:"estimated_distance_km"`
+	RideId                   string            `json:"ride_id"`
+	RideNumber               string            `json:"ride_number"`
+	Status                   string            `json:"status"`
+	EstimatedFare            float64           `json:"estimated_fare"`
+	EstimatedDurationMinutes float64           `json:"estimated_duration_minutes"`
+	EstimatedDistanceKm      float64           `json:"estimated_distance_km"`
+	SurgeMultiplier          float64           `json:"surge_multiplier"`
+	FareBreakdown            pricing.Breakdown `json:"fare_breakdown"`
+	// PriceLocked is true when the fare comes from a still valid quote
+	PriceLocked bool `json:"price_locked"`
+}
+
+type RideQuoteRequestDto struct {
+	PickUpLatitude       *float64  `json:"pickup_latitude"`
+	PickUpLongitude      *float64  `json:"pickup_longitude"`
+	PickUpAddress        *string   `json:"pickup_address"`
+	DestinationLatitude  *float64  `json:"destination_latitude"`
+	DestinationLongitude *float64  `json:"destination_longitude"`
+	DestinationAddress   *string   `json:"destination_address"`
+	Stops                []StopDto `json:"stops,omitempty"`
+}
+
+type RideQuoteDto struct {
+	QuoteId                  string            `json:"quote_id"`
+	RideType                 string            `json:"ride_type"`
+	EstimatedFare            float64           `json:"estimated_fare"`
+	EstimatedDurationMinutes float64           `json:"estimated_duration_minutes"`
+	EstimatedDistanceKm      float64           `json:"estimated_distance_km"`
+	SurgeMultiplier          float64           `json:"surge_multiplier"`
+	FareBreakdown            pricing.Breakdown `json:"fare_breakdown"`
+}
+
+type RideQuoteResponseDto struct {
+	Quotes    []RideQuoteDto `json:"quotes"`
+	ExpiresAt time.Time      `json:"expires_at"`
 }
 
 type RideStatusUpdate struct {
@@ -27,3 +77,47 @@ type RideStatusUpdate struct {
 	RideNumber string
 	Status     string
 }
+
+type ScheduledRideDto struct {
+	BookingId     string        `json:"booking_id"`
+	Status        string        `json:"status"`
+	RideType      string        `json:"ride_type"`
+	Pickup        LocationDto   `json:"pickup"`
+	Destination   LocationDto   `json:"destination"`
+	Stops         []LocationDto `json:"stops,omitempty"`
+	ScheduledAt   time.Time     `json:"scheduled_at"`
+	DispatchAt    time.Time     `json:"dispatch_at"`
+	EstimatedFare float64       `json:"estimated_fare"`
+	RideId        *string       `json:"ride_id,omitempty"`
+	FailureReason *string       `json:"failure_reason,omitempty"`
+}
+
+// To Passenger - a booked ride went to matching (or could not)
+type ScheduledRideDispatchDto struct {
+	BookingId string `json:"booking_id"`
+	RideId    string `json:"ride_id,omitempty"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+}
+
+type DestinationChangeRequestDto struct {
+	// RideId is only read from websocket events, REST takes it from the path
+	RideId               *string  `json:"ride_id,omitempty"`
+	DestinationLatitude  *float64 `json:"destination_latitude"`
+	DestinationLongitude *float64 `json:"destination_longitude"`
+	DestinationAddress   *string  `json:"destination_address"`
+}
+
+// To Passenger - a destination change and its outcome
+type DestinationChangeDto struct {
+	ChangeId            string             `json:"change_id"`
+	RideId              string             `json:"ride_id"`
+	Status              string             `json:"status"`
+	Destination         LocationDto        `json:"destination"`
+	EstimatedDistanceKm float64            `json:"estimated_distance_km"`
+	OldFare             float64            `json:"old_fare"`
+	NewFare             float64            `json:"new_fare"`
+	FareBreakdown       *pricing.Breakdown `json:"fare_breakdown,omitempty"`
+	ExpiresAt           time.Time          `json:"expires_at"`
+	Message             string             `json:"message,omitempty"`
+}
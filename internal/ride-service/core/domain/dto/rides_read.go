@@ -0,0 +1,80 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
+)
+
+type LocationDto struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Address   string  `json:"address"`
+}
+
+type RideStopDto struct {
+	StopIndex int `json:"stop_index"`
+	LocationDto
+	ArrivedAt *time.Time `json:"arrived_at,omitempty"`
+}
+
+type RideDetailsDto struct {
+	RideId             string                   `json:"ride_id"`
+	RideNumber         string                   `json:"ride_number"`
+	Status             string                   `json:"status"`
+	VehicleType        string                   `json:"vehicle_type"`
+	Pickup             LocationDto              `json:"pickup"`
+	Destination        LocationDto              `json:"destination"`
+	Stops              []RideStopDto            `json:"stops,omitempty"`
+	EstimatedFare      float64                  `json:"estimated_fare"`
+	FinalFare          *float64                 `json:"final_fare,omitempty"`
+	FareBreakdown      json.RawMessage          `json:"fare_breakdown,omitempty"`
+	Driver             *websocketdto.DriverInfo `json:"driver,omitempty"`
+	RequestedAt        *time.Time               `json:"requested_at,omitempty"`
+	MatchedAt          *time.Time               `json:"matched_at,omitempty"`
+	ArrivedAt          *time.Time               `json:"arrived_at,omitempty"`
+	StartedAt          *time.Time               `json:"started_at,omitempty"`
+	CompletedAt        *time.Time               `json:"completed_at,omitempty"`
+	CancelledAt        *time.Time               `json:"cancelled_at,omitempty"`
+	CancellationReason *string                  `json:"cancellation_reason,omitempty"`
+}
+
+type RideSummaryDto struct {
+	RideId             string    `json:"ride_id"`
+	RideNumber         string    `json:"ride_number"`
+	Status             string    `json:"status"`
+	VehicleType        string    `json:"vehicle_type"`
+	PickupAddress      string    `json:"pickup_address"`
+	DestinationAddress string    `json:"destination_address"`
+	EstimatedFare      float64   `json:"estimated_fare"`
+	FinalFare          *float64  `json:"final_fare,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// RideHistoryFilter is the parsed query of GET /rides
+type RideHistoryFilter struct {
+	Statuses []string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Cursor   string
+}
+
+// RideHistoryCursor points at the last ride of the previous page
+type RideHistoryCursor struct {
+	CreatedAt time.Time
+	RideId    string
+}
+
+type RideHistoryDto struct {
+	Rides      []RideSummaryDto `json:"rides"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type RideEventDto struct {
+	EventId   string          `json:"event_id"`
+	EventType string          `json:"event_type"`
+	EventData json.RawMessage `json:"event_data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
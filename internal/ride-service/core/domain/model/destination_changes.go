@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"ride-hail/internal/pricing"
+)
+
+// ride_destination_changes.status values
+const (
+	DestinationChangePending    = "PENDING"
+	DestinationChangeAccepted   = "ACCEPTED"
+	DestinationChangeRejected   = "REJECTED"
+	DestinationChangeExpired    = "EXPIRED"
+	DestinationChangeSuperseded = "SUPERSEDED"
+)
+
+// DestinationChange is a new destination proposed by the passenger during a
+// ride; it takes effect only once the driver accepts it
+type DestinationChange struct {
+	ChangeId      string // uuid
+	CreatedAt     time.Time
+	RideId        string
+	PassengerId   string
+	DriverId      string
+	Destination   Coordinates
+	DistanceKm    float64
+	OldFare       float64
+	NewFare       float64
+	FareBreakdown pricing.Breakdown
+	Status        string
+	ExpiresAt     time.Time
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"ride-hail/internal/pricing"
+)
+
+// Quote is a price locked for one passenger, trip and vehicle type until ExpiresAt
+type Quote struct {
+	PassengerId string            `json:"pid"`
+	RideType    string            `json:"rt"`
+	PickupLat   float64           `json:"plat"`
+	PickupLng   float64           `json:"plng"`
+	DestLat     float64           `json:"dlat"`
+	DestLng     float64           `json:"dlng"`
+	Stops       []QuotePoint      `json:"st,omitempty"`
+	Fare        pricing.Breakdown `json:"fare"`
+	Surge       pricing.Surge     `json:"surge"`
+	ExpiresAt   time.Time         `json:"exp"`
+}
+
+type QuotePoint struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
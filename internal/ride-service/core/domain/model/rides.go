@@ -1,28 +1,48 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"ride-hail/internal/pricing"
+)
 
 type Rides struct {
-	ID                    string // uuid
-	CreatedAt             time.Time
-	UpdateAt              time.Time
-	RideNumber            string
-	PassengerId           string // uuid
-	DriverId              string // uuid
-	VehicleType           string
-	Status                string
-	Priority              int
-	RequestedAt           time.Time
-	MatchedAt             time.Time
-	ArrivedAt             time.Time
-	StartedAt             time.Time
-	CompletedAt           time.Time
-	CancelledAt           time.Time
-	CancellationReason    string
-	EstimatedFare         float64
-	FinalFare             float64
+	ID                 string // uuid
+	CreatedAt          time.Time
+	UpdateAt           time.Time
+	RideNumber         string
+	PassengerId        string // uuid
+	DriverId           string // uuid
+	VehicleType        string
+	Status             string
+	Priority           int
+	RequestedAt        time.Time
+	MatchedAt          time.Time
+	ArrivedAt          time.Time
+	StartedAt          time.Time
+	CompletedAt        time.Time
+	CancelledAt        time.Time
+	CancellationReason string
+	EstimatedFare      float64
+	FinalFare          float64
+	FareBreakdown      pricing.Breakdown
+	Surge              pricing.Surge
+	// PriceLocked means EstimatedFare comes from a quote and is what the passenger pays
+	PriceLocked           bool
 	PickupCoordinate      Coordinates
 	DestinationCoordinate Coordinates
+	Stops                 []RideStop
+	// BookingId is the scheduled ride this ride was dispatched from, if any
+	BookingId string
+}
+
+// RideStop is an intermediate stop; StopIndex starts at 1
+type RideStop struct {
+	StopIndex int        `json:"stop_index"`
+	Latitude  float64    `json:"latitude"`
+	Longitude float64    `json:"longitude"`
+	Address   string     `json:"address"`
+	ArrivedAt *time.Time `json:"arrived_at,omitempty"`
 }
 
 type Coordinates struct {
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// scheduled_rides.status values
+const (
+	BookingScheduled   = "SCHEDULED"
+	BookingDispatching = "DISPATCHING"
+	BookingDispatched  = "DISPATCHED"
+	BookingFailed      = "FAILED"
+	BookingCancelled   = "CANCELLED"
+)
+
+// ScheduledRide is a booking waiting to be turned into a ride
+type ScheduledRide struct {
+	BookingId     string // uuid
+	CreatedAt     time.Time
+	PassengerId   string // uuid
+	VehicleType   string
+	Pickup        Coordinates
+	Destination   Coordinates
+	Stops         []RideStop
+	ScheduledAt   time.Time
+	EstimatedFare float64
+	Status        string
+	RideId        *string
+	FailureReason *string
+}
@@ -22,4 +22,6 @@ type RideStatusUpdateDto struct {
 	Status        string     `json:"status"`
 	DriverInfo    DriverInfo `json:"driver_info"`
 	CorrelationID string     `json:"correlation_id"`
+	// Message explains a status the passenger did not cause, e.g. a cancellation by the system
+	Message string `json:"message,omitempty"`
 }
@@ -1,8 +1,29 @@
 package myerrors
 
-import "errors"
+import (
+	"errors"
+
+	"ride-hail/internal/ridestate"
+)
 
 var (
+	// ErrRideNotFound is the error of ridestate.Apply, so status changes report it too
+	ErrRideNotFound  = ridestate.ErrRideNotFound
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidFilter = errors.New("invalid filter")
+
+	ErrInvalidQuote  = errors.New("invalid quote id")
+	ErrQuoteMismatch = errors.New("quote does not match the ride request")
+
+	ErrInvalidSchedule       = errors.New("invalid scheduled time")
+	ErrBookingNotFound       = errors.New("scheduled ride not found")
+	ErrBookingNotCancellable = errors.New("scheduled ride has already been dispatched")
+	ErrBookingNotClaimed     = errors.New("scheduled ride is no longer being dispatched")
+
+	ErrInvalidDestination          = errors.New("invalid destination")
+	ErrDestinationChangeNotAllowed = errors.New("destination can only be changed while a driver is assigned and the ride is not finished")
+	ErrDestinationChangeNotFound   = errors.New("destination change not found")
+
 	ErrDBConnClosed    = errors.New("failed to connect to db")
 	ErrDBConnClosedMsg = errors.New("internal error, please try again later")
 )
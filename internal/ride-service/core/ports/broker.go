@@ -3,7 +3,7 @@ package ports
 import (
 	"context"
 
-	messagebrokerdto "ride-hail/internal/ride-service/core/domain/message_broker_dto"
+	"ride-hail/internal/outbox"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -11,12 +11,21 @@ import (
 const (
 	DriverResponse = "driver.response.*"
 	DriverStatus   = "driver.status.*"
+	// driver answers to destination changes
+	DriverDestination = "driver.destination.*"
 )
 
 type IRidesBroker interface {
 	Close() error
-	PushMessageToRequest(ctx context.Context, message messagebrokerdto.Ride) error
-	PushMessageToStatus(ctx context.Context, msg messagebrokerdto.RideStatus) error
+	// PublishConfirmed returns once the broker has confirmed the message; only the outbox relay publishes
+	PublishConfirmed(ctx context.Context, m outbox.Message) error
 
 	ConsumeMessageFromDrivers(ctx context.Context, queue, driverName string) (<-chan amqp.Delivery, error)
+
+	// PublishJSON publishes msg right away, not through the outbox; for messages
+	// that are worthless once the moment has passed, like passenger events
+	PublishJSON(ctx context.Context, exchange, routingKey string, msg any) error
+	// ConsumeInstance declares the direct exchange and the queue of this instance,
+	// "<exchange>.<instanceId>", deleted with the connection. Deliveries are auto-acked.
+	ConsumeInstance(ctx context.Context, exchange, instanceId string) (<-chan amqp.Delivery, error)
 }
@@ -0,0 +1,29 @@
+package ports
+
+import (
+	"context"
+
+	"ride-hail/internal/pricing"
+	"ride-hail/internal/ride-service/core/domain/model"
+)
+
+type IPricingEngine interface {
+	// Quote returns the itemized fare of a trip in the given vehicle type
+	Quote(vehicleType string, trip pricing.Trip) (pricing.Breakdown, error)
+}
+
+type ISurgeCalculator interface {
+	// Cell is the surge cell a pickup point falls into
+	Cell(lat, lng float64) pricing.Cell
+	// Update feeds the latest demand/supply counts of a cell and returns its
+	// surge, smoothed over the snapshots of every instance
+	Update(ctx context.Context, cell string, demand, supply int) (pricing.Surge, error)
+}
+
+type IQuoteSigner interface {
+	// Sign turns a quote into an opaque quote id
+	Sign(q model.Quote) (string, error)
+	// Verify returns the quote behind an id, or myerrors.ErrInvalidQuote if it
+	// was not issued by us. Expiry is left to the caller.
+	Verify(quoteId string) (model.Quote, error)
+}
@@ -2,26 +2,98 @@ package ports
 
 import (
 	"context"
+	"time"
 
+	"ride-hail/internal/contracts"
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/pricing"
 	"ride-hail/internal/ride-service/core/domain/dto"
-	messagebrokerdto "ride-hail/internal/ride-service/core/domain/message_broker_dto"
 	"ride-hail/internal/ride-service/core/domain/model"
 	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
 )
 
+// RideMessages builds the broker messages announcing a ride change. The repo
+// calls it inside the transaction of the change and stores the messages in
+// the outbox, so they are published only if the change commits.
+type RideMessages func(ride model.Rides) ([]outbox.Message, error)
+
 type IRidesRepo interface {
-	CreateRide(context.Context, model.Rides) (string, error)
-	CancelRide(context.Context, string, string) (model.Rides, error)
-	ChangeStatus(context.Context, messagebrokerdto.DriverStatusUpdate) (string, string, float64, websocketdto.DriverInfo, error)
-	GetDistance(context.Context, dto.RidesRequestDto) (float64, error)
+	CreateRide(context.Context, model.Rides, RideMessages) (string, error)
+	CancelRide(context.Context, string, string, RideMessages) (model.Rides, error)
+	// CancelUnmatchedRide cancels a ride no driver took; the message id is recorded like in ChangeStatus
+	CancelUnmatchedRide(ctx context.Context, rideId, reason, messageId string) (model.Rides, error)
+	// ChangeStatus and ChangeStatusMatch apply a broker message; the message id
+	// is recorded in the same transaction and a repeated one fails with inbox.ErrAlreadyProcessed
+	ChangeStatus(ctx context.Context, msg contracts.DriverStatusChanged, messageId string, messages RideMessages) (string, string, float64, websocketdto.DriverInfo, error)
 	GetNumberRides(context.Context) (int64, error)
-	ChangeStatusMatch(context.Context, string, string) (string, string, error)
-	FindDistanceAndPassengerId(ctx context.Context, longitude, latitude float64, rideId string) (distance float64, passengerId string, err error)
+	ChangeStatusMatch(ctx context.Context, rideId, driverId, messageId string, messages RideMessages) (string, string, error)
+	GetPickupAndPassengerId(ctx context.Context, rideId string) (pickup model.Coordinates, passengerId string, err error)
 	CheckDuplicate(ctx context.Context, passengerId string) (count int, err error)
-	CancelEveryPossibleRides(ctx context.Context) error
-	GetCancelPossibleRides(ctx context.Context) ([]model.Rides, error)
+	// CancelEveryPossibleRides cancels every ride the system may cancel; messages are built per cancelled ride
+	CancelEveryPossibleRides(ctx context.Context, messages RideMessages) error
+	// waiting rides and available drivers inside the cell
+	CountCellDemand(ctx context.Context, cell pricing.Cell) (demand, supply int, err error)
+
+	// read API, every query is scoped to the passenger
+	GetPassengerRide(ctx context.Context, passengerId, rideId string) (dto.RideDetailsDto, error)
+	ListPassengerRides(ctx context.Context, passengerId string, filter dto.RideHistoryFilter, after *dto.RideHistoryCursor) ([]dto.RideSummaryDto, error)
+	GetPassengerRideEvents(ctx context.Context, passengerId, rideId string) ([]dto.RideEventDto, error)
+
+	// GetRideRoute loads the passenger's ride with its pickup, stops, destination and fare
+	GetRideRoute(ctx context.Context, passengerId, rideId string) (model.Rides, error)
+	// CreateDestinationChange stores a PENDING change and supersedes older pending ones of the ride
+	CreateDestinationChange(ctx context.Context, change model.DestinationChange, messages func(model.DestinationChange) ([]outbox.Message, error)) (string, error)
+	// ResolveDestinationChange records the driver's answer; an accepted change that has not
+	// expired moves the destination and the fare. The returned change holds the final status;
+	// messages are written to the outbox in the same transaction, once per change.
+	ResolveDestinationChange(ctx context.Context, changeId, driverId string, accepted bool, messages func(model.DestinationChange) ([]outbox.Message, error)) (model.DestinationChange, error)
+}
+
+type IScheduledRidesRepo interface {
+	CreateBooking(ctx context.Context, booking model.ScheduledRide) (string, error)
+	// bookings that are still SCHEDULED or DISPATCHING, soonest first
+	ListUpcomingBookings(ctx context.Context, passengerId string) ([]model.ScheduledRide, error)
+	CancelBooking(ctx context.Context, passengerId, bookingId string) error
+	// ClaimDueBookings moves up to limit bookings due between dueAfter and
+	// dueBefore to DISPATCHING; concurrent callers never get the same booking
+	ClaimDueBookings(ctx context.Context, dueAfter, dueBefore time.Time, limit int) ([]model.ScheduledRide, error)
+	// ExpireMissedBookings fails the bookings still SCHEDULED for a pickup before the given time
+	ExpireMissedBookings(ctx context.Context, scheduledBefore time.Time, reason string) ([]model.ScheduledRide, error)
+	MarkBookingFailed(ctx context.Context, bookingId, reason string) error
+	// ReleaseStaleClaims puts bookings stuck in DISPATCHING, e.g. after a crash, back to SCHEDULED
+	ReleaseStaleClaims(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// IInboxRepo keeps processed_messages from growing forever
+type IInboxRepo interface {
+	Purge(ctx context.Context, olderThan time.Duration, limit int) (int64, error)
+}
+
+// IOutboxRepo is the ride service's side of the outbox table
+type IOutboxRepo interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]outbox.Message, error)
+	MarkSent(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error
 }
 
 type IPassengerRepo interface {
 	Exist(ctx context.Context, passengerId string) (string, error)
 }
+
+// IPresenceRepo records which instance holds the socket of each passenger
+type IPresenceRepo interface {
+	// Register replaces the previous record of the passenger
+	Register(ctx context.Context, passengerId, instanceId string) error
+	// Unregister removes the record only while it still belongs to the instance
+	Unregister(ctx context.Context, passengerId, instanceId string) error
+	// Heartbeat keeps the records of the instance alive
+	Heartbeat(ctx context.Context, instanceId string) error
+	// UnregisterInstance removes every record of the instance
+	UnregisterInstance(ctx context.Context, instanceId string) error
+	// Locate returns the instance of the passenger; ok is false without a live record
+	Locate(ctx context.Context, passengerId string) (instanceId string, ok bool, err error)
+}
+
+type IRevocationRepo interface {
+	IsRevoked(ctx context.Context, jti, subjectId string, issuedAt time.Time) (bool, error)
+}
@@ -0,0 +1,12 @@
+package ports
+
+import (
+	"context"
+
+	"ride-hail/internal/routing"
+)
+
+type IRouter interface {
+	// Route estimates the drive through the points in the given order
+	Route(ctx context.Context, points []routing.Point) (routing.Route, error)
+}
@@ -1,21 +1,50 @@
 package ports
 
 import (
+	"context"
+
+	"ride-hail/internal/contracts"
 	"ride-hail/internal/ride-service/core/domain/dto"
-	messagebrokerdto "ride-hail/internal/ride-service/core/domain/message_broker_dto"
 	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
 )
 
+// The context of a method carries the trace of the request or message that
+// caused it; the service keeps its own context for deadlines.
 type IRidesService interface {
-	CreateRide(dto.RidesRequestDto) (dto.RidesResponseDto, error)
-	CancelRide(dto.RidesCancelRequestDto, string) (dto.RideCancelResponseDto, error)
+	CreateRide(context.Context, dto.RidesRequestDto) (dto.RidesResponseDto, error)
+	// input: passengerId
+	QuoteRide(string, dto.RideQuoteRequestDto) (dto.RideQuoteResponseDto, error)
+	CancelRide(context.Context, dto.RidesCancelRequestDto, string) (dto.RideCancelResponseDto, error)
 
-	// input: rideId, driverId, output: passengerId, rideNumber, error
+	// input: rideId, driverId, messageId, output: passengerId, rideNumber, error
 	// set to status match, and also send to the exchange
-	SetStatusMatch(string, string) (passengerId string, rideNumber string, err error)
-	EstimateDistance(rideId string, longitude, latitude, speed float64) (passengerId, estimatedTime string, distance float64, err error)
+	SetStatusMatch(context.Context, string, string, string) (passengerId string, rideNumber string, err error)
+	EstimateDistance(ctx context.Context, rideId string, longitude, latitude float64) (passengerId, estimatedTime string, distance float64, err error)
 	CancelEveryPossibleRides() error
-	UpdateRideStatus(messagebrokerdto.DriverStatusUpdate) (string, websocketdto.Event, error)
+	// input: the driver's status message and its message id
+	UpdateRideStatus(context.Context, contracts.DriverStatusChanged, string) (string, websocketdto.Event, error)
+	// input: rideId, messageId, reason; output: passengerId and the event to send them
+	CancelUnmatchedRide(context.Context, string, string, string) (string, websocketdto.Event, error)
+
+	// input: passengerId, rideId
+	ProposeDestinationChange(context.Context, string, string, dto.DestinationChangeRequestDto) (dto.DestinationChangeDto, error)
+	// output: passengerId and the event to send them
+	ResolveDestinationChange(context.Context, contracts.DestinationChangeAnswered) (string, websocketdto.Event, error)
+
+	// input: passengerId, rideId
+	GetRide(string, string) (dto.RideDetailsDto, error)
+	ListRides(string, dto.RideHistoryFilter) (dto.RideHistoryDto, error)
+	GetRideEvents(string, string) ([]dto.RideEventDto, error)
+}
+
+type IScheduleService interface {
+	ScheduleRide(dto.RidesRequestDto) (dto.ScheduledRideDto, error)
+	// input: passengerId
+	ListScheduledRides(string) ([]dto.ScheduledRideDto, error)
+	// input: passengerId, bookingId
+	CancelScheduledRide(string, string) error
+	// Run dispatches due bookings until the context is done
+	Run()
 }
 
 type IPassengerService interface {
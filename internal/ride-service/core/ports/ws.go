@@ -1,7 +1,12 @@
 package ports
 
-import websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
+import (
+	"context"
 
+	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
+)
+
+// INotifyWebsocket reaches the passenger's socket on whichever instance holds it
 type INotifyWebsocket interface {
-	WriteToUser(passengerId string, msg websocketdto.Event)
+	WriteToUser(ctx context.Context, passengerId string, msg websocketdto.Event)
 }
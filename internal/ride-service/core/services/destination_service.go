@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/pricing"
+	"ride-hail/internal/ride-service/core/domain/dto"
+	"ride-hail/internal/ride-service/core/domain/model"
+	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
+	"ride-hail/internal/ride-service/core/myerrors"
+	"ride-hail/internal/ridestate"
+	"ride-hail/internal/tracing"
+
+	"ride-hail/internal/contracts"
+)
+
+// DESTINATION_CHANGE_TTL is how long the driver has to answer a new destination
+const DESTINATION_CHANGE_TTL = 60 * time.Second
+
+// ProposeDestinationChange prices the ride with a new destination and asks the
+// driver to confirm it. Nothing changes on the ride until the driver accepts.
+func (rs *RidesService) ProposeDestinationChange(ctx context.Context, passengerId, rideId string, req dto.DestinationChangeRequestDto) (dto.DestinationChangeDto, error) {
+	log := rs.mylog.Action("ProposeDestinationChange").WithContext(ctx).With("ride_id", rideId)
+
+	if err := validateLatLng(req.DestinationLatitude, req.DestinationLongitude); err != nil {
+		return dto.DestinationChangeDto{}, fmt.Errorf("%w: coords: %v", myerrors.ErrInvalidDestination, err)
+	}
+	if err := validateAddress(req.DestinationAddress); err != nil {
+		return dto.DestinationChangeDto{}, fmt.Errorf("%w: address: %v", myerrors.ErrInvalidDestination, err)
+	}
+
+	ctx, cancel := context.WithTimeout(rs.traced(ctx), time.Second*15)
+	defer cancel()
+
+	ride, err := rs.RidesRepo.GetRideRoute(ctx, passengerId, rideId)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return dto.DestinationChangeDto{}, myerrors.ErrDBConnClosedMsg
+		}
+		return dto.DestinationChangeDto{}, err
+	}
+	if !ridestate.Status(ride.Status).HasDriver() {
+		return dto.DestinationChangeDto{}, fmt.Errorf("%w: ride is %s", myerrors.ErrDestinationChangeNotAllowed, ride.Status)
+	}
+
+	// the whole route is priced again: pickup, every stop, then the new destination
+	route := dto.RidesRequestDto{
+		PickUpLatitude:       &ride.PickupCoordinate.Latitude,
+		PickUpLongitude:      &ride.PickupCoordinate.Longitude,
+		DestinationLatitude:  req.DestinationLatitude,
+		DestinationLongitude: req.DestinationLongitude,
+	}
+	for _, stop := range ride.Stops {
+		route.Stops = append(route.Stops, dto.StopDto{Latitude: &stop.Latitude, Longitude: &stop.Longitude})
+	}
+	estimate, err := rs.Router.Route(ctx, routePoints(route))
+	if err != nil {
+		log.Error("cannot estimate the new route", err)
+		return dto.DestinationChangeDto{}, err
+	}
+	distance := estimate.DistanceKm
+
+	// the surge locked in when the ride was requested still applies
+	fare, err := rs.Pricing.Quote(ride.VehicleType, pricing.Trip{DistanceKm: distance, DurationMinutes: estimate.DurationMinutes, SurgeMultiplier: ride.FareBreakdown.SurgeMultiplier})
+	if err != nil {
+		log.Error("cannot price the new route", err)
+		return dto.DestinationChangeDto{}, err
+	}
+
+	change := model.DestinationChange{
+		RideId:      ride.ID,
+		PassengerId: passengerId,
+		DriverId:    ride.DriverId,
+		Destination: model.Coordinates{
+			Latitude:  *req.DestinationLatitude,
+			Longitude: *req.DestinationLongitude,
+			Address:   *req.DestinationAddress,
+		},
+		DistanceKm:    distance,
+		OldFare:       ride.EstimatedFare,
+		NewFare:       fare.Total,
+		FareBreakdown: fare,
+		Status:        model.DestinationChangePending,
+		ExpiresAt:     time.Now().Add(DESTINATION_CHANGE_TTL).UTC(),
+	}
+
+	// the pending change simply expires if the driver never gets it
+	change.ChangeId, err = rs.RidesRepo.CreateDestinationChange(ctx, change, func(c model.DestinationChange) ([]outbox.Message, error) {
+		msg, err := destinationChangeMessage(contracts.DestinationChangeRequested{
+			ChangeID: c.ChangeId,
+			RideID:   c.RideId,
+			DriverID: c.DriverId,
+			NewDestination: contracts.Location{
+				Lat:     c.Destination.Latitude,
+				Lng:     c.Destination.Longitude,
+				Address: c.Destination.Address,
+			},
+			DistanceKm: c.DistanceKm,
+			OldFare:    c.OldFare,
+			NewFare:    c.NewFare,
+			ExpiresAt:  c.ExpiresAt.Format(time.RFC3339),
+		}, tracing.CorrelationID(ctx))
+		return []outbox.Message{msg}, err
+	})
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return dto.DestinationChangeDto{}, myerrors.ErrDBConnClosedMsg
+		}
+		log.Error("cannot store destination change", err)
+		return dto.DestinationChangeDto{}, err
+	}
+
+	log.Info("destination change proposed", "change_id", change.ChangeId, "old_fare", change.OldFare, "new_fare", change.NewFare, "distance", distance)
+	return destinationChangeDto(change), nil
+}
+
+// ResolveDestinationChange applies the driver's answer, tells the driver the
+// outcome and builds the passenger notification
+func (rs *RidesService) ResolveDestinationChange(ctx context.Context, msg contracts.DestinationChangeAnswered) (string, websocketdto.Event, error) {
+	log := rs.mylog.Action("ResolveDestinationChange").WithContext(ctx).With("change_id", msg.ChangeID, "ride_id", msg.RideID)
+
+	ctx, cancel := context.WithTimeout(rs.traced(ctx), time.Second*15)
+	defer cancel()
+
+	change, err := rs.RidesRepo.ResolveDestinationChange(ctx, msg.ChangeID, msg.DriverID, msg.Accepted, func(c model.DestinationChange) ([]outbox.Message, error) {
+		m, err := destinationResultMessage(contracts.DestinationChangeResolved{
+			ChangeID: c.ChangeId,
+			RideID:   c.RideId,
+			DriverID: c.DriverId,
+			Status:   c.Status,
+		}, tracing.CorrelationID(ctx))
+		return []outbox.Message{m}, err
+	})
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return "", websocketdto.Event{}, myerrors.ErrDBConnClosedMsg
+		}
+		log.Error("cannot resolve destination change", err)
+		return "", websocketdto.Event{}, err
+	}
+	log.Info("destination change resolved", "status", change.Status, "accepted", msg.Accepted)
+
+	data, err := json.Marshal(destinationChangeDto(change))
+	if err != nil {
+		return "", websocketdto.Event{}, err
+	}
+
+	return change.PassengerId, websocketdto.Event{
+		Type: "destination_change_update",
+		Data: data,
+	}, nil
+}
+
+func destinationChangeDto(c model.DestinationChange) dto.DestinationChangeDto {
+	res := dto.DestinationChangeDto{
+		ChangeId: c.ChangeId,
+		RideId:   c.RideId,
+		Status:   c.Status,
+		Destination: dto.LocationDto{
+			Latitude:  c.Destination.Latitude,
+			Longitude: c.Destination.Longitude,
+			Address:   c.Destination.Address,
+		},
+		EstimatedDistanceKm: c.DistanceKm,
+		OldFare:             c.OldFare,
+		NewFare:             c.NewFare,
+		FareBreakdown:       &c.FareBreakdown,
+		ExpiresAt:           c.ExpiresAt,
+	}
+
+	switch c.Status {
+	case model.DestinationChangePending:
+		res.Message = "Waiting for the driver to confirm the new destination"
+	case model.DestinationChangeAccepted:
+		res.Message = "The driver accepted the new destination"
+	case model.DestinationChangeRejected:
+		res.Message = "The driver declined the new destination"
+	case model.DestinationChangeExpired:
+		res.Message = "The new destination was not confirmed in time"
+	case model.DestinationChangeSuperseded:
+		res.Message = "A newer destination was proposed"
+	}
+	return res
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+
+	"ride-hail/internal/contracts"
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/ride-service/core/domain/model"
+)
+
+// RIDE_EXCHANGE is the topic exchange every ride service message goes to
+const RIDE_EXCHANGE = "ride_topic"
+
+// rideMessage wraps msg in a contracts envelope for the outbox
+func rideMessage(routingKey, correlationID string, msg contracts.Message) (outbox.Message, error) {
+	env, err := contracts.Wrap(contracts.ProducerRideService, correlationID, msg)
+	if err != nil {
+		return outbox.Message{}, err
+	}
+	return outbox.NewMessage(RIDE_EXCHANGE, routingKey, env)
+}
+
+func rideRequestMessage(msg contracts.RideRequested, correlationID string) (outbox.Message, error) {
+	m, err := rideMessage(fmt.Sprintf("ride.request.%s", msg.RideType), correlationID, msg)
+	if err != nil {
+		return outbox.Message{}, err
+	}
+	m.Priority = uint8(msg.Priority)
+	return m, nil
+}
+
+func rideStatusMessage(msg contracts.RideStatusChanged, correlationID string) (outbox.Message, error) {
+	return rideMessage(fmt.Sprintf("ride.status.%s", msg.Status), correlationID, msg)
+}
+
+func destinationChangeMessage(msg contracts.DestinationChangeRequested, correlationID string) (outbox.Message, error) {
+	return rideMessage(fmt.Sprintf("ride.destination.%s", msg.RideID), correlationID, msg)
+}
+
+// destinationResultMessage goes to the queue of destination change requests,
+// so the driver learns the outcome from the instance holding the socket
+func destinationResultMessage(msg contracts.DestinationChangeResolved, correlationID string) (outbox.Message, error) {
+	return rideMessage(fmt.Sprintf("ride.destination.%s", msg.RideID), correlationID, msg)
+}
+
+// cancelledStatus is the status message of a ride cancelled from ride.Status.
+// The driver keeps half the fare of a ride cancelled on the way.
+func cancelledStatus(ride model.Rides) contracts.RideStatusChanged {
+	msg := contracts.RideStatusChanged{
+		RideID:   ride.ID,
+		Status:   "CANCELLED",
+		DriverID: ride.DriverId,
+	}
+	if ride.Status == "IN_PROGRESS" {
+		msg.FinalFare = ride.FinalFare * 0.5
+	}
+	return msg
+}
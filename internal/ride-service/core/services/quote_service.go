@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"ride-hail/internal/pricing"
+	"ride-hail/internal/ride-service/core/domain/dto"
+	"ride-hail/internal/ride-service/core/domain/model"
+	"ride-hail/internal/ride-service/core/myerrors"
+)
+
+// a quote only applies to the trip it was issued for; ~1m of GPS noise is fine
+const quoteCoordTolerance = 1e-5
+
+// QuoteRide prices the trip in every vehicle type without creating a ride.
+// Each quote id locks its price for the quote TTL.
+func (rs *RidesService) QuoteRide(passengerId string, req dto.RideQuoteRequestDto) (dto.RideQuoteResponseDto, error) {
+	log := rs.mylog.Action("QuoteRide")
+
+	if err := validatePassengerId(&passengerId); err != nil {
+		return dto.RideQuoteResponseDto{}, fmt.Errorf("invalid passenger id: %v", err)
+	}
+	if err := validateLatLng(req.PickUpLatitude, req.PickUpLongitude); err != nil {
+		return dto.RideQuoteResponseDto{}, fmt.Errorf("invalid pickup coords: %v", err)
+	}
+	if err := validateLatLng(req.DestinationLatitude, req.DestinationLongitude); err != nil {
+		return dto.RideQuoteResponseDto{}, fmt.Errorf("invalid destination coords: %v", err)
+	}
+	if err := validateStops(req.Stops); err != nil {
+		return dto.RideQuoteResponseDto{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(rs.ctx, time.Second*15)
+	defer cancel()
+
+	route, err := rs.Router.Route(ctx, routePoints(dto.RidesRequestDto{
+		PickUpLatitude:       req.PickUpLatitude,
+		PickUpLongitude:      req.PickUpLongitude,
+		DestinationLatitude:  req.DestinationLatitude,
+		DestinationLongitude: req.DestinationLongitude,
+		Stops:                req.Stops,
+	}))
+	if err != nil {
+		log.Error("cannot estimate the route", err)
+		return dto.RideQuoteResponseDto{}, err
+	}
+	distance := route.DistanceKm
+
+	// asking for a price is not demand yet
+	surge := rs.currentSurge(ctx, *req.PickUpLatitude, *req.PickUpLongitude, 0)
+	expiresAt := time.Now().Add(rs.quoteTTL).UTC()
+	stops := quotePoints(req.Stops)
+
+	res := dto.RideQuoteResponseDto{ExpiresAt: expiresAt}
+	for _, rideType := range getAllowedRideTypes() {
+		fare, err := rs.Pricing.Quote(rideType, pricing.Trip{DistanceKm: distance, DurationMinutes: route.DurationMinutes, SurgeMultiplier: surge.Multiplier})
+		if err != nil {
+			log.Error("cannot price the ride", err, "type", rideType)
+			return dto.RideQuoteResponseDto{}, err
+		}
+
+		quoteId, err := rs.Quotes.Sign(model.Quote{
+			PassengerId: passengerId,
+			RideType:    rideType,
+			PickupLat:   *req.PickUpLatitude,
+			PickupLng:   *req.PickUpLongitude,
+			DestLat:     *req.DestinationLatitude,
+			DestLng:     *req.DestinationLongitude,
+			Stops:       stops,
+			Fare:        fare,
+			Surge:       surge,
+			ExpiresAt:   expiresAt,
+		})
+		if err != nil {
+			log.Error("cannot sign quote", err, "type", rideType)
+			return dto.RideQuoteResponseDto{}, err
+		}
+
+		res.Quotes = append(res.Quotes, dto.RideQuoteDto{
+			QuoteId:                  quoteId,
+			RideType:                 rideType,
+			EstimatedFare:            fare.Total,
+			EstimatedDurationMinutes: fare.DurationMinutes,
+			EstimatedDistanceKm:      distance,
+			SurgeMultiplier:          fare.SurgeMultiplier,
+			FareBreakdown:            fare,
+		})
+	}
+
+	log.Info("quoted ride", "passenger_id", passengerId, "distance", distance, "surge", surge.Multiplier)
+	return res, nil
+}
+
+// lockedFare returns the fare of the request's quote. locked is false when
+// there is no quote or it has expired, in which case the ride is priced live;
+// a forged quote or one issued for another trip is an error.
+func (rs *RidesService) lockedFare(req dto.RidesRequestDto, rideType string) (pricing.Breakdown, pricing.Surge, bool, error) {
+	if req.QuoteId == nil || *req.QuoteId == "" {
+		return pricing.Breakdown{}, pricing.Surge{}, false, nil
+	}
+
+	q, err := rs.Quotes.Verify(*req.QuoteId)
+	if err != nil {
+		return pricing.Breakdown{}, pricing.Surge{}, false, err
+	}
+
+	switch {
+	case q.PassengerId != *req.PassengerId,
+		q.RideType != rideType,
+		!sameCoord(q.PickupLat, *req.PickUpLatitude), !sameCoord(q.PickupLng, *req.PickUpLongitude),
+		!sameCoord(q.DestLat, *req.DestinationLatitude), !sameCoord(q.DestLng, *req.DestinationLongitude),
+		!sameStops(q.Stops, quotePoints(req.Stops)):
+		return pricing.Breakdown{}, pricing.Surge{}, false, myerrors.ErrQuoteMismatch
+	}
+
+	if time.Now().After(q.ExpiresAt) {
+		rs.mylog.Action("lockedFare").Info("quote expired, pricing live", "passenger_id", q.PassengerId, "expired_at", q.ExpiresAt)
+		return pricing.Breakdown{}, pricing.Surge{}, false, nil
+	}
+
+	return q.Fare, q.Surge, true, nil
+}
+
+func sameCoord(a, b float64) bool {
+	return math.Abs(a-b) <= quoteCoordTolerance
+}
+
+func sameStops(a, b []model.QuotePoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameCoord(a[i].Lat, b[i].Lat) || !sameCoord(a[i].Lng, b[i].Lng) {
+			return false
+		}
+	}
+	return true
+}
+
+func quotePoints(stops []dto.StopDto) []model.QuotePoint {
+	var res []model.QuotePoint
+	for _, stop := range stops {
+		res = append(res, model.QuotePoint{Lat: *stop.Latitude, Lng: *stop.Longitude})
+	}
+	return res
+}
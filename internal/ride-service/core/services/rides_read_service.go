@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ride-hail/internal/ride-service/core/domain/dto"
+	"ride-hail/internal/ride-service/core/myerrors"
+)
+
+const (
+	DEFAULT_HISTORY_LIMIT = 20
+	MAX_HISTORY_LIMIT     = 100
+)
+
+var AllowedRideStatuses = map[string]bool{
+	"REQUESTED":   true,
+	"MATCHED":     true,
+	"EN_ROUTE":    true,
+	"ARRIVED":     true,
+	"IN_PROGRESS": true,
+	"COMPLETED":   true,
+	"CANCELLED":   true,
+}
+
+func (rs *RidesService) GetRide(passengerId, rideId string) (dto.RideDetailsDto, error) {
+	log := rs.mylog.Action("GetRide")
+
+	ctx, cancel := context.WithTimeout(rs.ctx, time.Second*15)
+	defer cancel()
+
+	ride, err := rs.RidesRepo.GetPassengerRide(ctx, passengerId, rideId)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return dto.RideDetailsDto{}, myerrors.ErrDBConnClosedMsg
+		}
+		if !errors.Is(err, myerrors.ErrRideNotFound) {
+			log.Error("Failed to get ride", err, "ride_id", rideId)
+		}
+		return dto.RideDetailsDto{}, err
+	}
+
+	return ride, nil
+}
+
+func (rs *RidesService) ListRides(passengerId string, filter dto.RideHistoryFilter) (dto.RideHistoryDto, error) {
+	log := rs.mylog.Action("ListRides")
+
+	if err := normalizeHistoryFilter(&filter); err != nil {
+		return dto.RideHistoryDto{}, err
+	}
+
+	var after *dto.RideHistoryCursor
+	if filter.Cursor != "" {
+		cursor, err := decodeHistoryCursor(filter.Cursor)
+		if err != nil {
+			return dto.RideHistoryDto{}, err
+		}
+		after = &cursor
+	}
+
+	ctx, cancel := context.WithTimeout(rs.ctx, time.Second*15)
+	defer cancel()
+
+	// one extra row tells us whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+
+	rides, err := rs.RidesRepo.ListPassengerRides(ctx, passengerId, filter, after)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return dto.RideHistoryDto{}, myerrors.ErrDBConnClosedMsg
+		}
+		if !errors.Is(err, myerrors.ErrInvalidCursor) {
+			log.Error("Failed to list rides", err)
+		}
+		return dto.RideHistoryDto{}, err
+	}
+
+	res := dto.RideHistoryDto{Rides: rides}
+	if len(rides) > limit {
+		res.Rides = rides[:limit]
+		last := res.Rides[limit-1]
+		res.NextCursor = encodeHistoryCursor(dto.RideHistoryCursor{CreatedAt: last.CreatedAt, RideId: last.RideId})
+	}
+
+	return res, nil
+}
+
+func (rs *RidesService) GetRideEvents(passengerId, rideId string) ([]dto.RideEventDto, error) {
+	log := rs.mylog.Action("GetRideEvents")
+
+	ctx, cancel := context.WithTimeout(rs.ctx, time.Second*15)
+	defer cancel()
+
+	events, err := rs.RidesRepo.GetPassengerRideEvents(ctx, passengerId, rideId)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return nil, myerrors.ErrDBConnClosedMsg
+		}
+		if !errors.Is(err, myerrors.ErrRideNotFound) {
+			log.Error("Failed to get ride events", err, "ride_id", rideId)
+		}
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func normalizeHistoryFilter(filter *dto.RideHistoryFilter) error {
+	for i, s := range filter.Statuses {
+		sn := strings.ToUpper(strings.TrimSpace(s))
+		if !AllowedRideStatuses[sn] {
+			return fmt.Errorf("%w: unknown status %q", myerrors.ErrInvalidFilter, s)
+		}
+		filter.Statuses[i] = sn
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return fmt.Errorf("%w: from must be before to", myerrors.ErrInvalidFilter)
+	}
+
+	switch {
+	case filter.Limit < 0:
+		return fmt.Errorf("%w: limit must be positive", myerrors.ErrInvalidFilter)
+	case filter.Limit == 0:
+		filter.Limit = DEFAULT_HISTORY_LIMIT
+	case filter.Limit > MAX_HISTORY_LIMIT:
+		filter.Limit = MAX_HISTORY_LIMIT
+	}
+
+	return nil
+}
+
+// Cursor is opaque to clients: base64url("<created_at RFC3339Nano>|<ride_id>")
+func encodeHistoryCursor(c dto.RideHistoryCursor) string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.RideId
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeHistoryCursor(s string) (dto.RideHistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return dto.RideHistoryCursor{}, myerrors.ErrInvalidCursor
+	}
+
+	createdAt, rideId, ok := strings.Cut(string(raw), "|")
+	if !ok || rideId == "" {
+		return dto.RideHistoryCursor{}, myerrors.ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return dto.RideHistoryCursor{}, myerrors.ErrInvalidCursor
+	}
+
+	return dto.RideHistoryCursor{CreatedAt: t, RideId: rideId}, nil
+}
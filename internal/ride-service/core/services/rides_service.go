@@ -6,38 +6,28 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"math/rand"
 	"strings"
 	"time"
 
+	"ride-hail/internal/inbox"
 	"ride-hail/internal/mylogger"
+	"ride-hail/internal/outbox"
+	"ride-hail/internal/pricing"
 	"ride-hail/internal/ride-service/core/domain/dto"
 	"ride-hail/internal/ride-service/core/domain/model"
 	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
 	"ride-hail/internal/ride-service/core/myerrors"
 	"ride-hail/internal/ride-service/core/ports"
+	"ride-hail/internal/ridestate"
+	"ride-hail/internal/routing"
+	"ride-hail/internal/tracing"
 
-	messagebrokerdto "ride-hail/internal/ride-service/core/domain/message_broker_dto"
+	"ride-hail/internal/contracts"
 )
 
 const (
-	DEFUALT_RATE_PER_MIN = 40
-
-	ECONOMY = "ECONOMY"
-	PREMIUM = "PREMIUM"
-	XL      = "XL"
-
-	ECONOMY_BASE = 500 // base
-	PREMIUM_BASE = 800
-	XL_BASE      = 1000
-
-	ECONOMY_RATE_PER_KM = 100 // 100₸/km
-	PREMIUM_RATE_PER_KM = 120
-	XL_RATE_PER_KM      = 150
-
-	ECONOMY_RATE_PER_MIN = 50 // 50₸/min
-	PREMIUM_RATE_PER_MIN = 60
-	XL_RATE_PER_MIN      = 75
+	// MAX_RIDE_STOPS limits intermediate stops between pickup and destination
+	MAX_RIDE_STOPS = 5
 )
 
 type RidesService struct {
@@ -45,6 +35,11 @@ type RidesService struct {
 	RidesRepo      ports.IRidesRepo
 	RidesBroker    ports.IRidesBroker
 	RidesWebsocket ports.INotifyWebsocket
+	Pricing        ports.IPricingEngine
+	Router         ports.IRouter
+	Surge          ports.ISurgeCalculator
+	Quotes         ports.IQuoteSigner
+	quoteTTL       time.Duration
 	ctx            context.Context
 }
 
@@ -53,6 +48,11 @@ func NewRidesService(ctx context.Context,
 	RidesRepo ports.IRidesRepo,
 	RidesBroker ports.IRidesBroker,
 	RidesWebsocket ports.INotifyWebsocket,
+	Pricing ports.IPricingEngine,
+	Router ports.IRouter,
+	Surge ports.ISurgeCalculator,
+	Quotes ports.IQuoteSigner,
+	quoteTTL time.Duration,
 ) ports.IRidesService {
 	return &RidesService{
 		ctx:            ctx,
@@ -60,19 +60,30 @@ func NewRidesService(ctx context.Context,
 		RidesRepo:      RidesRepo,
 		RidesBroker:    RidesBroker,
 		RidesWebsocket: RidesWebsocket,
+		Pricing:        Pricing,
+		Router:         Router,
+		Surge:          Surge,
+		Quotes:         Quotes,
+		quoteTTL:       quoteTTL,
 	}
 }
 
+// traced is the service context carrying the span of ctx: a ride change is
+// part of the caller's trace but is not abandoned with the caller's request
+func (rs *RidesService) traced(ctx context.Context) context.Context {
+	return tracing.WithSpanFrom(rs.ctx, ctx)
+}
+
 // implement me
-func (rs *RidesService) CreateRide(req dto.RidesRequestDto) (dto.RidesResponseDto, error) {
+func (rs *RidesService) CreateRide(ctx context.Context, req dto.RidesRequestDto) (dto.RidesResponseDto, error) {
 	m := model.Rides{}
-	log := rs.mylog.Action("CreateRide")
+	log := rs.mylog.Action("CreateRide").WithContext(ctx)
 
 	if err := validateRideRequest(req); err != nil {
 		return dto.RidesResponseDto{}, err
 	}
 
-	ctx, cancel := context.WithTimeout(rs.ctx, time.Second*15)
+	ctx, cancel := context.WithTimeout(rs.traced(ctx), time.Second*15)
 	defer cancel()
 
 	count, err := rs.RidesRepo.CheckDuplicate(ctx, *req.PassengerId)
@@ -85,18 +96,15 @@ func (rs *RidesService) CreateRide(req dto.RidesRequestDto) (dto.RidesResponseDt
 		return dto.RidesResponseDto{}, fmt.Errorf("cannot create duplicated ride")
 	}
 
-	ctx, cancel = context.WithTimeout(rs.ctx, time.Second*15)
+	ctx, cancel = context.WithTimeout(rs.traced(ctx), time.Second*15)
 	defer cancel()
-	// estimate distance between pick up and destination points
-	distance, err := rs.RidesRepo.GetDistance(ctx, req)
+	// estimate the drive from pick up through every stop to destination
+	route, err := rs.Router.Route(ctx, routePoints(req))
 	if err != nil {
-		if errors.Is(err, myerrors.ErrDBConnClosed) {
-			log.Error("Failed to connect to connect to db", err)
-			return dto.RidesResponseDto{}, myerrors.ErrDBConnClosedMsg
-		}
-		log.Error("cannot get distance between two points", err)
+		log.Error("cannot estimate the route", err)
 		return dto.RidesResponseDto{}, err
 	}
+	distance := route.DistanceKm
 
 	// only for ride-number
 	numberOfRides, err := rs.RidesRepo.GetNumberRides(ctx)
@@ -111,23 +119,27 @@ func (rs *RidesService) CreateRide(req dto.RidesRequestDto) (dto.RidesResponseDt
 
 	RideNumber := fmt.Sprintf("RIDE_%d%d%d_%0*d", time.Now().Year(), time.Now().Month(), time.Now().Day(), 3, numberOfRides+1)
 
+	rideType := strings.ToUpper(*req.RideType)
+
+	fare, surge, locked, err := rs.lockedFare(req, rideType)
+	if err != nil {
+		log.Warn("rejected quote", "error", err.Error())
+		return dto.RidesResponseDto{}, err
+	}
+	if !locked {
+		surge = rs.currentSurge(ctx, *req.PickUpLatitude, *req.PickUpLongitude, 1)
+		fare, err = rs.Pricing.Quote(rideType, pricing.Trip{DistanceKm: distance, DurationMinutes: route.DurationMinutes, SurgeMultiplier: surge.Multiplier})
+		if err != nil {
+			log.Warn("cannot price the ride", "type", rideType, "error", err.Error())
+			return dto.RidesResponseDto{}, err
+		}
+	}
+
 	var (
-		EstimatedFare float64 = 0
+		EstimatedFare float64 = fare.Total
 		Priority      int     = 1
 	)
 
-	switch *req.RideType {
-	case ECONOMY:
-		EstimatedFare = ECONOMY_BASE + (distance * ECONOMY_RATE_PER_KM) + (DEFUALT_RATE_PER_MIN * ECONOMY_RATE_PER_MIN)
-	case PREMIUM:
-		EstimatedFare = PREMIUM_BASE + (distance * PREMIUM_RATE_PER_KM) + (DEFUALT_RATE_PER_MIN * PREMIUM_RATE_PER_MIN)
-	case XL:
-		EstimatedFare = XL_BASE + (distance * XL_RATE_PER_KM) + (DEFUALT_RATE_PER_MIN * XL_RATE_PER_MIN)
-	default:
-		log.Warn("unkown ride type", "type", req.RideType)
-		return dto.RidesResponseDto{}, fmt.Errorf("unkown ride type")
-	}
-
 	// PRIORITY estimate
 	if EstimatedFare >= 10000 {
 		Priority = 10
@@ -141,10 +153,18 @@ func (rs *RidesService) CreateRide(req dto.RidesRequestDto) (dto.RidesResponseDt
 	m = model.Rides{
 		RideNumber:    RideNumber,
 		PassengerId:   *req.PassengerId,
+		VehicleType:   rideType,
 		Status:        "REQUESTED",
 		EstimatedFare: EstimatedFare,
 		FinalFare:     EstimatedFare,
+		FareBreakdown: fare,
+		Surge:         surge,
+		PriceLocked:   locked,
 		Priority:      Priority,
+		Stops:         rideStops(req.Stops),
+	}
+	if req.BookingId != nil {
+		m.BookingId = *req.BookingId
 	}
 
 	m.PickupCoordinate = model.Coordinates{
@@ -155,7 +175,7 @@ func (rs *RidesService) CreateRide(req dto.RidesRequestDto) (dto.RidesResponseDt
 		Longitude:       *req.PickUpLongitude,
 		FareAmount:      m.EstimatedFare,
 		DistanceKm:      distance,
-		DurationMinutes: 0,
+		DurationMinutes: math.Round(fare.DurationMinutes), // coordinates.duration_minutes is INTEGER
 		IsCurrent:       true,
 	}
 	m.DestinationCoordinate = model.Coordinates{
@@ -166,52 +186,55 @@ func (rs *RidesService) CreateRide(req dto.RidesRequestDto) (dto.RidesResponseDt
 		Longitude:       *req.DestinationLongitude,
 		FareAmount:      m.EstimatedFare,
 		DistanceKm:      distance,
-		DurationMinutes: 0,
+		DurationMinutes: math.Round(fare.DurationMinutes), // coordinates.duration_minutes is INTEGER
 		IsCurrent:       true,
 	}
-	log.Info("creating a ride", "RideNumber", RideNumber, "passenger-id", req.PassengerId, "estimated-fare", EstimatedFare, "distance", distance)
-	ctx, cancel = context.WithTimeout(rs.ctx, time.Second*15)
+	log.Info("creating a ride", "RideNumber", RideNumber, "passenger-id", req.PassengerId, "estimated-fare", EstimatedFare, "distance", distance, "surge", surge.Multiplier, "stops", len(m.Stops))
+	ctx, cancel = context.WithTimeout(rs.traced(ctx), time.Second*15)
 	defer cancel()
-	ride_id, err := rs.RidesRepo.CreateRide(ctx, m)
-	if err != nil {
-		if errors.Is(err, myerrors.ErrDBConnClosed) {
-			log.Error("Failed to connect to connect to db", err)
-			return dto.RidesResponseDto{}, myerrors.ErrDBConnClosedMsg
-		}
-		return dto.RidesResponseDto{}, err
-	}
-
-	// publish message to rabbitmq
-	log.Info("Inserting ride to BM")
-
-	log.Debug("Debugging", "RideNumber", RideNumber)
-
-	rideMsg := messagebrokerdto.Ride{
-		RideID:         ride_id,
+	// the request is published by the outbox relay once the ride is stored
+	rideMsg := contracts.RideRequested{
 		RideNumber:     RideNumber,
-		RideType:       *req.RideType,
+		RideType:       rideType,
 		EstimatedFare:  EstimatedFare,
 		MaxDistanceKm:  distance,
 		TimeoutSeconds: 30,
 		Priority:       Priority,
-		CorrelationID:  generateCorrelationID(),
 	}
 
-	rideMsg.PickupLocation = messagebrokerdto.Location{
+	rideMsg.PickupLocation = contracts.Location{
 		Lat:     *req.PickUpLatitude,
 		Lng:     *req.PickUpLongitude,
 		Address: *req.PickUpAddress,
 	}
 
-	rideMsg.DestinationLocation = messagebrokerdto.Location{
+	rideMsg.DestinationLocation = contracts.Location{
 		Lat:     *req.DestinationLatitude,
 		Lng:     *req.DestinationLongitude,
 		Address: *req.DestinationAddress,
 	}
 
-	if err := rs.RidesBroker.PushMessageToRequest(rs.ctx, rideMsg); err != nil {
-		log.Error("Failed to publish message", err)
-		return dto.RidesResponseDto{}, fmt.Errorf("cannot send message to broker: %w", err)
+	for _, stop := range m.Stops {
+		rideMsg.Stops = append(rideMsg.Stops, contracts.Location{
+			Lat:     stop.Latitude,
+			Lng:     stop.Longitude,
+			Address: stop.Address,
+		})
+	}
+
+	log.Debug("Debugging", "RideNumber", RideNumber)
+
+	ride_id, err := rs.RidesRepo.CreateRide(ctx, m, func(ride model.Rides) ([]outbox.Message, error) {
+		rideMsg.RideID = ride.ID
+		msg, err := rideRequestMessage(rideMsg, tracing.CorrelationID(ctx))
+		return []outbox.Message{msg}, err
+	})
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return dto.RidesResponseDto{}, myerrors.ErrDBConnClosedMsg
+		}
+		return dto.RidesResponseDto{}, err
 	}
 
 	log.Info("successfully created a ride", "ride-id", ride_id)
@@ -221,16 +244,39 @@ func (rs *RidesService) CreateRide(req dto.RidesRequestDto) (dto.RidesResponseDt
 		Status:                   "REQUESTED",
 		EstimatedFare:            EstimatedFare,
 		EstimatedDistanceKm:      distance,
-		EstimatedDurationMinutes: distance * 1000 / DEFUALT_RATE_PER_MIN,
+		EstimatedDurationMinutes: fare.DurationMinutes,
+		SurgeMultiplier:          fare.SurgeMultiplier,
+		FareBreakdown:            fare,
+		PriceLocked:              locked,
 	}
 	return res, nil
 }
 
+// currentSurge evaluates the pickup cell; pending is demand not stored yet.
+// Surge never blocks a ride: if the counts cannot be read the ride is priced without it.
+func (rs *RidesService) currentSurge(ctx context.Context, lat, lng float64, pending int) pricing.Surge {
+	cell := rs.Surge.Cell(lat, lng)
+
+	demand, supply, err := rs.RidesRepo.CountCellDemand(ctx, cell)
+	if err != nil {
+		rs.mylog.Action("currentSurge").Warn("cannot count cell demand, pricing without surge", "cell", cell.Hash, "error", err.Error())
+		return pricing.NoSurge
+	}
+
+	surge, err := rs.Surge.Update(ctx, cell.Hash, demand+pending, supply)
+	if err != nil {
+		rs.mylog.Action("currentSurge").Warn("cannot smooth cell surge, pricing without surge", "cell", cell.Hash, "error", err.Error())
+		return pricing.NoSurge
+	}
+	return surge
+}
+
 var (
 	ErrEmptyField       = errors.New("field id empty")
 	ErrInvalidLatitute  = errors.New("invalid latititude [-90, 90]")
 	ErrInvalidLongitude = errors.New("invalid longitude  [-180, 180]")
 	ErrInvalidAdress    = errors.New("maximum 255 characters allowed")
+	ErrTooManyStops     = fmt.Errorf("maximum %d stops allowed", MAX_RIDE_STOPS)
 )
 
 func validateRideRequest(req dto.RidesRequestDto) error {
@@ -252,6 +298,10 @@ func validateRideRequest(req dto.RidesRequestDto) error {
 		return fmt.Errorf("invalid destination address: %v", err)
 	}
 
+	if err := validateStops(req.Stops); err != nil {
+		return err
+	}
+
 	if err := validateRideType(req.RideType); err != nil {
 		return fmt.Errorf("invalid ride type: %v", err)
 	}
@@ -293,6 +343,50 @@ func validateAddress(s *string) error {
 	return nil
 }
 
+// validateStops checks the intermediate stops; a stop address is optional
+func validateStops(stops []dto.StopDto) error {
+	if len(stops) > MAX_RIDE_STOPS {
+		return fmt.Errorf("invalid stops: %v", ErrTooManyStops)
+	}
+	for i, stop := range stops {
+		if err := validateLatLng(stop.Latitude, stop.Longitude); err != nil {
+			return fmt.Errorf("invalid stop %d coords: %v", i+1, err)
+		}
+		if stop.Address != nil {
+			if err := validateAddress(stop.Address); err != nil {
+				return fmt.Errorf("invalid stop %d address: %v", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// routePoints is pickup, every stop in order, then destination
+func routePoints(req dto.RidesRequestDto) []routing.Point {
+	points := []routing.Point{{Lat: *req.PickUpLatitude, Lng: *req.PickUpLongitude}}
+	for _, stop := range req.Stops {
+		points = append(points, routing.Point{Lat: *stop.Latitude, Lng: *stop.Longitude})
+	}
+	return append(points, routing.Point{Lat: *req.DestinationLatitude, Lng: *req.DestinationLongitude})
+}
+
+// rideStops numbers validated stops in the order they were requested
+func rideStops(stops []dto.StopDto) []model.RideStop {
+	res := make([]model.RideStop, 0, len(stops))
+	for i, stop := range stops {
+		s := model.RideStop{
+			StopIndex: i + 1,
+			Latitude:  *stop.Latitude,
+			Longitude: *stop.Longitude,
+		}
+		if stop.Address != nil {
+			s.Address = *stop.Address
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
 func getAllowedRideTypes() []string {
 	return []string{"ECONOMY", "PREMIUM", "XL"}
 }
@@ -314,15 +408,28 @@ func validateRideType(s *string) error {
 	return nil
 }
 
-func (rs *RidesService) CancelRide(req dto.RidesCancelRequestDto, rideId string) (dto.RideCancelResponseDto, error) {
-	log := rs.mylog.Action("CreateRide")
+func (rs *RidesService) CancelRide(ctx context.Context, req dto.RidesCancelRequestDto, rideId string) (dto.RideCancelResponseDto, error) {
+	log := rs.mylog.Action("CreateRide").WithContext(ctx)
 
-	ctx, cancel := context.WithTimeout(rs.ctx, time.Second*15)
+	ctx, cancel := context.WithTimeout(rs.traced(ctx), time.Second*15)
 	defer cancel()
 
 	log.Info("params", "rideId", rideId, "reason", req.Reason)
 
-	ride, err := rs.RidesRepo.CancelRide(ctx, rideId, req.Reason)
+	cancelledAt := time.Now().Format(time.RFC3339)
+
+	// only an assigned driver has to hear about the cancellation
+	ride, err := rs.RidesRepo.CancelRide(ctx, rideId, req.Reason, func(ride model.Rides) ([]outbox.Message, error) {
+		if ride.DriverId == "" {
+			return nil, nil
+		}
+		status := cancelledStatus(ride)
+		if ride.Status != "IN_PROGRESS" {
+			status.FinalFare = 100
+		}
+		msg, err := rideStatusMessage(status, tracing.CorrelationID(ctx))
+		return []outbox.Message{msg}, err
+	})
 	if err != nil {
 		if errors.Is(err, myerrors.ErrDBConnClosed) {
 			log.Error("Failed to connect to connect to db", err)
@@ -331,9 +438,7 @@ func (rs *RidesService) CancelRide(req dto.RidesCancelRequestDto, rideId string)
 		log.Error("Failed to cancel ride", err)
 		return dto.RideCancelResponseDto{}, err
 	}
-	log.Info("Ride cancelled successfully")
-
-	cancelledAt := time.Now().Format(time.RFC3339)
+	log.Info("Ride cancelled successfully", "driver-id", ride.DriverId)
 
 	res := dto.RideCancelResponseDto{
 		RideId:      rideId,
@@ -342,36 +447,22 @@ func (rs *RidesService) CancelRide(req dto.RidesCancelRequestDto, rideId string)
 		Message:     "Ride cancelled successfully",
 	}
 
-	if ride.DriverId != "" {
-		m2 := messagebrokerdto.RideStatus{
-			RideId:     rideId,
-			Status:     "CANCELLED",
-			Timestamp:  cancelledAt,
-			DriverID:   ride.DriverId,
-			Final_fare: 100,
-		}
-		if ride.Status == "IN_PROGRESS" {
-			m2.Final_fare = ride.FinalFare * 0.5
-		}
-
-		ctx, cancel = context.WithTimeout(rs.ctx, time.Second*15)
-		defer cancel()
-
-		err = rs.RidesBroker.PushMessageToStatus(ctx, m2)
-		if err != nil {
-			return dto.RideCancelResponseDto{}, err
-		}
-	}
-
 	return res, nil
 }
 
-func (rs *RidesService) SetStatusMatch(rideId, driverId string) (string, string, error) {
-	ctx, cancel := context.WithTimeout(rs.ctx, time.Second*15)
+func (rs *RidesService) SetStatusMatch(ctx context.Context, rideId, driverId, messageId string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(rs.traced(ctx), time.Second*15)
 	defer cancel()
-	log := rs.mylog.Action("SetStatusMatch")
+	log := rs.mylog.Action("SetStatusMatch").WithContext(ctx)
 	log.Info("sex", "rideId", rideId, "driverId", driverId)
-	passengerId, rideNumber, err := rs.RidesRepo.ChangeStatusMatch(ctx, rideId, driverId)
+	passengerId, rideNumber, err := rs.RidesRepo.ChangeStatusMatch(ctx, rideId, driverId, messageId, func(ride model.Rides) ([]outbox.Message, error) {
+		msg, err := rideStatusMessage(contracts.RideStatusChanged{
+			RideID:   ride.ID,
+			Status:   ride.Status,
+			DriverID: ride.DriverId,
+		}, tracing.CorrelationID(ctx))
+		return []outbox.Message{msg}, err
+	})
 	if err != nil {
 		// TODO: add handle error
 		if errors.Is(err, myerrors.ErrDBConnClosed) {
@@ -380,45 +471,38 @@ func (rs *RidesService) SetStatusMatch(rideId, driverId string) (string, string,
 		}
 		return "", "", err
 	}
-	m2 := messagebrokerdto.RideStatus{
-		RideId:    rideId,
-		Status:    "MATCHED",
-		Timestamp: time.Now().Format(time.RFC3339),
-		DriverID:  driverId,
-	}
-	ctx, cancel = context.WithTimeout(rs.ctx, time.Second*15)
-	defer cancel()
-
-	err = rs.RidesBroker.PushMessageToStatus(ctx, m2)
-	if err != nil {
-		return "", "", err
-	}
 
 	return passengerId, rideNumber, nil
 }
 
-func (rs *RidesService) EstimateDistance(rideId string, longitude, latitude, speed float64) (string, string, float64, error) {
-	log := rs.mylog.Action("FindPassenger")
+func (rs *RidesService) EstimateDistance(ctx context.Context, rideId string, longitude, latitude float64) (string, string, float64, error) {
+	log := rs.mylog.Action("EstimateDistance").WithContext(ctx)
 
-	ctx, cancel := context.WithTimeout(rs.ctx, time.Second*5)
+	ctx, cancel := context.WithTimeout(rs.traced(ctx), time.Second*5)
 	defer cancel()
 
-	distance, passengerId, err := rs.RidesRepo.FindDistanceAndPassengerId(ctx, longitude, latitude, rideId)
+	pickup, passengerId, err := rs.RidesRepo.GetPickupAndPassengerId(ctx, rideId)
 	if err != nil {
 		if errors.Is(err, myerrors.ErrDBConnClosed) {
 			log.Error("Failed to connect to connect to db", err)
 			return "", "", 0, myerrors.ErrDBConnClosedMsg
 		}
-		log.Error("cannot get user or something", err)
+		log.Error("cannot get pickup of the ride", err)
 		return "", "", 0.0, err
 	}
-	if IsCloseToZero(speed) {
-		speed = DEFUALT_RATE_PER_MIN
+
+	route, err := rs.Router.Route(ctx, []routing.Point{
+		{Lat: latitude, Lng: longitude},
+		{Lat: pickup.Latitude, Lng: pickup.Longitude},
+	})
+	if err != nil {
+		log.Error("cannot estimate the route to pickup", err)
+		return "", "", 0.0, err
 	}
 
-	t := time.Now().Add(time.Duration(distance / speed)).Format(time.RFC3339)
+	eta := time.Now().Add(time.Duration(route.DurationMinutes * float64(time.Minute))).Format(time.RFC3339)
 
-	return passengerId, t, distance, nil
+	return passengerId, eta, route.DistanceKm, nil
 }
 
 func (rs *RidesService) CancelEveryPossibleRides() error {
@@ -426,35 +510,11 @@ func (rs *RidesService) CancelEveryPossibleRides() error {
 	ctx, cancel := context.WithTimeout(rs.ctx, time.Second*5)
 	defer cancel()
 
-	rides, err := rs.RidesRepo.GetCancelPossibleRides(ctx)
-	if err != nil {
-		if errors.Is(err, myerrors.ErrDBConnClosed) {
-			log.Error("Failed to connect to connect to db", err)
-			return myerrors.ErrDBConnClosedMsg
-		}
-
-		log.Error("cannot get possible rides", err)
-		return err
-	}
-	for _, ride := range rides {
-		msg := messagebrokerdto.RideStatus{
-			RideId:    ride.ID,
-			Status:    "CANCELLED",
-			Timestamp: time.Now().Format(time.RFC3339),
-		}
-		log.Info("sending cancel info", "ride-id", msg.RideId, "status", ride.Status, "final_fare", ride.FinalFare)
-
-		if ride.Status == "IN_PROGRESS" {
-			msg.Final_fare = ride.FinalFare * 0.5
-			log.Info("sending status with in progress", "ride-id", msg.RideId)
-		}
-		if err := rs.RidesBroker.PushMessageToStatus(ctx, msg); err != nil {
-			log.Error("cannot push cancel message ride", err, "ride-id", msg.RideId)
-			continue
-		}
-	}
-
-	err = rs.RidesRepo.CancelEveryPossibleRides(ctx)
+	err := rs.RidesRepo.CancelEveryPossibleRides(ctx, func(ride model.Rides) ([]outbox.Message, error) {
+		log.Info("sending cancel info", "ride-id", ride.ID, "status", ride.Status, "final_fare", ride.FinalFare)
+		msg, err := rideStatusMessage(cancelledStatus(ride), "")
+		return []outbox.Message{msg}, err
+	})
 	if err != nil {
 		if errors.Is(err, myerrors.ErrDBConnClosed) {
 			log.Error("Failed to connect to connect to db", err)
@@ -469,26 +529,6 @@ func (rs *RidesService) CancelEveryPossibleRides() error {
 }
 
 // Generate a new UUID as a correlation ID
-func generateCorrelationID() string {
-	// Define the character set (lowercase, uppercase, and digits)
-	charSet := []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
-
-	// Seed the random number generator for true randomness
-	rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	// Generate a random length for the string, ensuring at least 3 characters
-	n := rand.Intn(3) + 3
-
-	// Pre-allocate the slice for the correlation ID
-	b := make([]rune, n)
-
-	// Create the random part of the ID
-	for i := range b {
-		b[i] = charSet[rand.Intn(len(charSet))]
-	}
-	return "req_" + string(b)
-}
-
 const Epsilon = 1e-9
 
 func IsCloseToZero(f float64) bool {
@@ -511,52 +551,47 @@ func IsCloseToZero(f float64) bool {
 // 	CorrelationID string     `json:"correlation_id"`
 // }
 
-func (ps *RidesService) UpdateRideStatus(msg messagebrokerdto.DriverStatusUpdate) (string, websocketdto.Event, error) {
-	log := ps.mylog.Action("UpdateRideStatus")
+func (ps *RidesService) UpdateRideStatus(ctx context.Context, msg contracts.DriverStatusChanged, messageId string) (string, websocketdto.Event, error) {
+	log := ps.mylog.Action("UpdateRideStatus").WithContext(ctx)
 
-	ctx, cancel := context.WithTimeout(ps.ctx, time.Second*15)
+	ctx, cancel := context.WithTimeout(ps.traced(ctx), time.Second*15)
 	defer cancel()
-	switch msg.Status {
-	case "AVAILABLE":
-		msg.Status = "COMPLETED"
-	case "BUSY":
-		msg.Status = "IN_PROGRESS"
-	case "COMPLETED":
-		msg.Status = "COMPLETED"
-	case "ARRVIED":
-		msg.Status = "ARRVIED"
-
-	default:
-		log.Warn("msg status different", "status", msg.Status)
+	rideStatus, ok := ridestate.FromDriverStatus(msg.Status)
+	if !ok {
+		log.Warn("driver status does not change the ride", "status", msg.Status, "ride_id", msg.RideID)
+		return "", websocketdto.Event{}, fmt.Errorf("%w: driver status %s", ridestate.ErrUnknownStatus, msg.Status)
 	}
+	msg.Status = string(rideStatus)
 	log.Info("get update ride status", "status", msg.Status)
-	passengerId, rideNumber, finalFare, driverInfo, err := ps.RidesRepo.ChangeStatus(ctx, msg)
+	// a completed ride tells the driver side its final fare
+	passengerId, rideNumber, _, driverInfo, err := ps.RidesRepo.ChangeStatus(ctx, msg, messageId, func(ride model.Rides) ([]outbox.Message, error) {
+		if ride.Status != string(ridestate.Completed) {
+			return nil, nil
+		}
+		log.Info("sending completed", "status", ride.Status)
+		m, err := rideStatusMessage(contracts.RideStatusChanged{
+			RideID:    ride.ID,
+			Status:    ride.Status,
+			FinalFare: ride.FinalFare,
+		}, tracing.CorrelationID(ctx))
+		return []outbox.Message{m}, err
+	})
 	if err != nil {
 		if errors.Is(err, myerrors.ErrDBConnClosed) {
 			log.Error("Failed to connect to connect to db", err)
 			return "", websocketdto.Event{}, myerrors.ErrDBConnClosedMsg
 		}
+		if errors.Is(err, inbox.ErrAlreadyProcessed) {
+			return "", websocketdto.Event{}, err
+		}
 
-		log.Error("Failed to cancel ride", err)
+		log.Error("Failed to update ride status", err)
 		return "", websocketdto.Event{}, err
 	}
-	if msg.Status == "AVAILABLE" || msg.Status == "COMPLETED" {
-		log.Info("sending completed", "status", msg.Status)
-
-		msg := messagebrokerdto.RideStatus{
-			RideId:     msg.RideId,
-			Status:     "COMPLETED",
-			Timestamp:  time.Now().Format(time.RFC3339),
-			Final_fare: finalFare,
-		}
-		if err := ps.RidesBroker.PushMessageToStatus(ctx, msg); err != nil {
-			log.Error("cannot push cancel message ride", err, "ride-id", msg.RideId)
-		}
-	}
 	data := websocketdto.RideStatusUpdateDto{
-		RideID:        msg.RideId,
+		RideID:        msg.RideID,
 		Status:        msg.Status,
-		CorrelationID: generateCorrelationID(),
+		CorrelationID: tracing.CorrelationID(ctx),
 		DriverInfo:    driverInfo,
 		RideNumber:    rideNumber,
 	}
@@ -574,3 +609,35 @@ func (ps *RidesService) UpdateRideStatus(msg messagebrokerdto.DriverStatusUpdate
 
 	return passengerId, res, nil
 }
+
+// CancelUnmatchedRide cancels a ride whose request was parked after every
+// matching attempt and returns the event telling the passenger why
+func (rs *RidesService) CancelUnmatchedRide(ctx context.Context, rideId, messageId, reason string) (string, websocketdto.Event, error) {
+	log := rs.mylog.Action("CancelUnmatchedRide").WithContext(ctx)
+
+	ctx, cancel := context.WithTimeout(rs.traced(ctx), time.Second*15)
+	defer cancel()
+
+	ride, err := rs.RidesRepo.CancelUnmatchedRide(ctx, rideId, reason, messageId)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return "", websocketdto.Event{}, myerrors.ErrDBConnClosedMsg
+		}
+		return "", websocketdto.Event{}, err
+	}
+	log.Info("unmatched ride cancelled", "ride_id", rideId, "reason", reason)
+
+	jsonData, err := json.Marshal(websocketdto.RideStatusUpdateDto{
+		RideID:        ride.ID,
+		RideNumber:    ride.RideNumber,
+		Status:        ride.Status,
+		CorrelationID: tracing.CorrelationID(ctx),
+		Message:       reason,
+	})
+	if err != nil {
+		return "", websocketdto.Event{}, err
+	}
+
+	return ride.PassengerId, websocketdto.Event{Type: "ride_status_update", Data: jsonData}, nil
+}
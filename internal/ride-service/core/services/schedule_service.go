@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ride-hail/internal/config"
+	"ride-hail/internal/mylogger"
+	"ride-hail/internal/pricing"
+	"ride-hail/internal/ride-service/core/domain/dto"
+	"ride-hail/internal/ride-service/core/domain/model"
+	websocketdto "ride-hail/internal/ride-service/core/domain/websocket_dto"
+	"ride-hail/internal/ride-service/core/myerrors"
+	"ride-hail/internal/ride-service/core/ports"
+	"ride-hail/internal/tracing"
+)
+
+const (
+	// bookings claimed per poll
+	SCHEDULE_BATCH_SIZE = 20
+	// a claim older than this was left behind by a crashed dispatcher
+	SCHEDULE_STALE_CLAIM = 5 * time.Minute
+)
+
+// ScheduleService keeps advance bookings in scheduled_rides and turns them
+// into regular rides a lead time before pickup. Bookings live in the
+// database, so nothing is lost when the service restarts.
+type ScheduleService struct {
+	mylog          mylogger.Logger
+	ScheduledRepo  ports.IScheduledRidesRepo
+	RidesRepo      ports.IRidesRepo
+	RidesService   ports.IRidesService
+	Pricing        ports.IPricingEngine
+	Router         ports.IRouter
+	RidesWebsocket ports.INotifyWebsocket
+	ctx            context.Context
+	wg             *sync.WaitGroup
+
+	lead     time.Duration
+	poll     time.Duration
+	maxAhead time.Duration
+	grace    time.Duration
+}
+
+func NewScheduleService(ctx context.Context,
+	log mylogger.Logger,
+	wg *sync.WaitGroup,
+	ScheduledRepo ports.IScheduledRidesRepo,
+	RidesRepo ports.IRidesRepo,
+	RidesService ports.IRidesService,
+	Pricing ports.IPricingEngine,
+	Router ports.IRouter,
+	RidesWebsocket ports.INotifyWebsocket,
+	cfg *config.Scheduleconfig,
+) ports.IScheduleService {
+	ss := &ScheduleService{
+		ctx:            ctx,
+		mylog:          log,
+		wg:             wg,
+		ScheduledRepo:  ScheduledRepo,
+		RidesRepo:      RidesRepo,
+		RidesService:   RidesService,
+		Pricing:        Pricing,
+		Router:         Router,
+		RidesWebsocket: RidesWebsocket,
+		lead:           time.Duration(cfg.LeadMinutes) * time.Minute,
+		poll:           time.Duration(cfg.PollSeconds) * time.Second,
+		maxAhead:       time.Duration(cfg.MaxDaysAhead) * 24 * time.Hour,
+		grace:          time.Duration(cfg.GraceMinutes) * time.Minute,
+	}
+	if ss.poll <= 0 {
+		ss.poll = 15 * time.Second
+	}
+	return ss
+}
+
+func (ss *ScheduleService) ScheduleRide(req dto.RidesRequestDto) (dto.ScheduledRideDto, error) {
+	log := ss.mylog.Action("ScheduleRide")
+
+	if err := validateRideRequest(req); err != nil {
+		return dto.ScheduledRideDto{}, err
+	}
+	if req.ScheduledAt == nil {
+		return dto.ScheduledRideDto{}, fmt.Errorf("%w: scheduled_at is required", myerrors.ErrInvalidSchedule)
+	}
+
+	scheduledAt := req.ScheduledAt.UTC()
+	now := time.Now()
+	if scheduledAt.Before(now.Add(ss.lead)) {
+		return dto.ScheduledRideDto{}, fmt.Errorf("%w: must be at least %v from now, request an immediate ride instead", myerrors.ErrInvalidSchedule, ss.lead)
+	}
+	if ss.maxAhead > 0 && scheduledAt.After(now.Add(ss.maxAhead)) {
+		return dto.ScheduledRideDto{}, fmt.Errorf("%w: must be within %v from now", myerrors.ErrInvalidSchedule, ss.maxAhead)
+	}
+
+	booking := model.ScheduledRide{
+		PassengerId: *req.PassengerId,
+		VehicleType: strings.ToUpper(*req.RideType),
+		Pickup: model.Coordinates{
+			Latitude:  *req.PickUpLatitude,
+			Longitude: *req.PickUpLongitude,
+			Address:   *req.PickUpAddress,
+		},
+		Destination: model.Coordinates{
+			Latitude:  *req.DestinationLatitude,
+			Longitude: *req.DestinationLongitude,
+			Address:   *req.DestinationAddress,
+		},
+		Stops:       rideStops(req.Stops),
+		ScheduledAt: scheduledAt,
+		Status:      model.BookingScheduled,
+	}
+
+	ctx, cancel := context.WithTimeout(ss.ctx, time.Second*15)
+	defer cancel()
+
+	// The estimate ignores surge: demand at pickup time is unknown yet,
+	// the ride is priced live when it is dispatched.
+	route, err := ss.Router.Route(ctx, routePoints(req))
+	if err != nil {
+		log.Error("cannot estimate the route", err)
+		return dto.ScheduledRideDto{}, err
+	}
+	fare, err := ss.Pricing.Quote(booking.VehicleType, pricing.Trip{DistanceKm: route.DistanceKm, DurationMinutes: route.DurationMinutes})
+	if err != nil {
+		return dto.ScheduledRideDto{}, err
+	}
+	booking.EstimatedFare = fare.Total
+
+	booking.BookingId, err = ss.ScheduledRepo.CreateBooking(ctx, booking)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return dto.ScheduledRideDto{}, myerrors.ErrDBConnClosedMsg
+		}
+		log.Error("cannot create booking", err)
+		return dto.ScheduledRideDto{}, err
+	}
+
+	log.Info("ride scheduled", "booking_id", booking.BookingId, "passenger_id", booking.PassengerId, "scheduled_at", scheduledAt)
+	return ss.toDto(booking), nil
+}
+
+func (ss *ScheduleService) ListScheduledRides(passengerId string) ([]dto.ScheduledRideDto, error) {
+	log := ss.mylog.Action("ListScheduledRides")
+
+	ctx, cancel := context.WithTimeout(ss.ctx, time.Second*15)
+	defer cancel()
+
+	bookings, err := ss.ScheduledRepo.ListUpcomingBookings(ctx, passengerId)
+	if err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return nil, myerrors.ErrDBConnClosedMsg
+		}
+		log.Error("cannot list bookings", err)
+		return nil, err
+	}
+
+	res := make([]dto.ScheduledRideDto, 0, len(bookings))
+	for _, b := range bookings {
+		res = append(res, ss.toDto(b))
+	}
+	return res, nil
+}
+
+func (ss *ScheduleService) CancelScheduledRide(passengerId, bookingId string) error {
+	log := ss.mylog.Action("CancelScheduledRide")
+
+	ctx, cancel := context.WithTimeout(ss.ctx, time.Second*15)
+	defer cancel()
+
+	if err := ss.ScheduledRepo.CancelBooking(ctx, passengerId, bookingId); err != nil {
+		if errors.Is(err, myerrors.ErrDBConnClosed) {
+			log.Error("Failed to connect to connect to db", err)
+			return myerrors.ErrDBConnClosedMsg
+		}
+		if !errors.Is(err, myerrors.ErrBookingNotFound) && !errors.Is(err, myerrors.ErrBookingNotCancellable) {
+			log.Error("cannot cancel booking", err, "booking_id", bookingId)
+		}
+		return err
+	}
+
+	log.Info("booking cancelled", "booking_id", bookingId)
+	return nil
+}
+
+// Run polls for due bookings in the background until the service context is done
+func (ss *ScheduleService) Run() {
+	ss.wg.Add(1)
+	go func() {
+		defer ss.wg.Done()
+		log := ss.mylog.Action("scheduler")
+		log.Info("scheduler started", "lead", ss.lead.String(), "poll", ss.poll.String())
+
+		ticker := time.NewTicker(ss.poll)
+		defer ticker.Stop()
+
+		for {
+			ss.tick()
+
+			select {
+			case <-ss.ctx.Done():
+				log.Info("scheduler stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (ss *ScheduleService) tick() {
+	log := ss.mylog.Action("scheduler")
+
+	ctx, cancel := context.WithTimeout(ss.ctx, time.Second*15)
+	defer cancel()
+
+	if n, err := ss.ScheduledRepo.ReleaseStaleClaims(ctx, SCHEDULE_STALE_CLAIM); err != nil {
+		log.Error("cannot release stale claims", err)
+	} else if n > 0 {
+		log.Warn("released stale booking claims", "count", n)
+	}
+
+	// after downtime, a pickup long gone is no ride to send a driver to
+	now := time.Now()
+	missed, err := ss.ScheduledRepo.ExpireMissedBookings(ctx, now.Add(-ss.grace), "pickup time passed before the ride could be dispatched")
+	if err != nil {
+		log.Error("cannot expire missed bookings", err)
+	}
+	for _, b := range missed {
+		log.Warn("booking missed its pickup time", "booking_id", b.BookingId, "scheduled_at", b.ScheduledAt)
+		ss.notify(ctx, b.PassengerId, dto.ScheduledRideDispatchDto{
+			BookingId: b.BookingId,
+			Status:    model.BookingFailed,
+			Message:   "Your scheduled ride could not be dispatched in time",
+		})
+	}
+
+	bookings, err := ss.ScheduledRepo.ClaimDueBookings(ctx, now.Add(-ss.grace), now.Add(ss.lead), SCHEDULE_BATCH_SIZE)
+	if err != nil {
+		log.Error("cannot claim due bookings", err)
+		return
+	}
+
+	for _, b := range bookings {
+		ss.dispatch(b)
+	}
+}
+
+// dispatch creates the ride through the regular CreateRide path, which
+// prices it, marks the booking dispatched in the same transaction and
+// publishes it to ride.request.*; the ride's trace starts here
+func (ss *ScheduleService) dispatch(b model.ScheduledRide) {
+	ctx, span := tracing.Start(ss.ctx, "dispatch scheduled ride")
+	defer span.End()
+	log := ss.mylog.Action("dispatch").WithContext(ctx).With("booking_id", b.BookingId, "passenger_id", b.PassengerId)
+
+	req := dto.RidesRequestDto{
+		PassengerId:          &b.PassengerId,
+		PickUpLatitude:       &b.Pickup.Latitude,
+		PickUpLongitude:      &b.Pickup.Longitude,
+		PickUpAddress:        &b.Pickup.Address,
+		DestinationLatitude:  &b.Destination.Latitude,
+		DestinationLongitude: &b.Destination.Longitude,
+		DestinationAddress:   &b.Destination.Address,
+		RideType:             &b.VehicleType,
+		BookingId:            &b.BookingId,
+	}
+	for _, stop := range b.Stops {
+		req.Stops = append(req.Stops, dto.StopDto{
+			Latitude:  &stop.Latitude,
+			Longitude: &stop.Longitude,
+			Address:   &stop.Address,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*15)
+	defer cancel()
+
+	ride, err := ss.RidesService.CreateRide(ctx, req)
+	if errors.Is(err, myerrors.ErrBookingNotClaimed) {
+		// another dispatcher took over a claim it thought was stale
+		log.Warn("booking was dispatched elsewhere")
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		log.Error("cannot dispatch booking", err)
+		if err := ss.ScheduledRepo.MarkBookingFailed(ctx, b.BookingId, err.Error()); err != nil {
+			log.Error("cannot mark booking failed", err)
+		}
+		ss.notify(ctx, b.PassengerId, dto.ScheduledRideDispatchDto{
+			BookingId: b.BookingId,
+			Status:    model.BookingFailed,
+			Message:   "Your scheduled ride could not be dispatched: " + err.Error(),
+		})
+		return
+	}
+
+	log.Info("booking dispatched", "ride_id", ride.RideId, "scheduled_at", b.ScheduledAt)
+	ss.notify(ctx, b.PassengerId, dto.ScheduledRideDispatchDto{
+		BookingId: b.BookingId,
+		RideId:    ride.RideId,
+		Status:    model.BookingDispatched,
+		Message:   "Looking for a driver for your scheduled ride",
+	})
+}
+
+func (ss *ScheduleService) notify(ctx context.Context, passengerId string, data dto.ScheduledRideDispatchDto) {
+	if ss.RidesWebsocket == nil {
+		return
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		ss.mylog.Action("notify").Error("Error marshalling JSON", err)
+		return
+	}
+	ss.RidesWebsocket.WriteToUser(ctx, passengerId, websocketdto.Event{
+		Type: "scheduled_ride_update",
+		Data: raw,
+	})
+}
+
+func (ss *ScheduleService) toDto(b model.ScheduledRide) dto.ScheduledRideDto {
+	return dto.ScheduledRideDto{
+		BookingId: b.BookingId,
+		Status:    b.Status,
+		RideType:  b.VehicleType,
+		Pickup: dto.LocationDto{
+			Latitude:  b.Pickup.Latitude,
+			Longitude: b.Pickup.Longitude,
+			Address:   b.Pickup.Address,
+		},
+		Destination: dto.LocationDto{
+			Latitude:  b.Destination.Latitude,
+			Longitude: b.Destination.Longitude,
+			Address:   b.Destination.Address,
+		},
+		Stops:         stopLocations(b.Stops),
+		ScheduledAt:   b.ScheduledAt,
+		DispatchAt:    b.ScheduledAt.Add(-ss.lead),
+		EstimatedFare: b.EstimatedFare,
+		RideId:        b.RideId,
+		FailureReason: b.FailureReason,
+	}
+}
+
+func stopLocations(stops []model.RideStop) []dto.LocationDto {
+	var res []dto.LocationDto
+	for _, stop := range stops {
+		res = append(res, dto.LocationDto{
+			Latitude:  stop.Latitude,
+			Longitude: stop.Longitude,
+			Address:   stop.Address,
+		})
+	}
+	return res
+}
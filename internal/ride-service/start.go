@@ -10,6 +10,7 @@ import (
 	"ride-hail/internal/config"
 	"ride-hail/internal/mylogger"
 	"ride-hail/internal/ride-service/adapters/driver/myhttp"
+	"ride-hail/internal/tracing"
 )
 
 type RideService struct{}
@@ -18,6 +19,17 @@ func Execute(ctx context.Context, mylog mylogger.Logger, cfg *config.Config) err
 	newCtx, close := signal.NotifyContext(ctx, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
 	defer close()
 
+	shutdownTracing, err := tracing.Init("ride-service", cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	// after the server, so the spans of the last requests are flushed
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			mylog.Error("Failed to flush traces", err)
+		}
+	}()
+
 	server := myhttp.NewServer(newCtx, ctx, mylog, cfg)
 
 	// Run server in goroutine
@@ -0,0 +1,60 @@
+package ridestate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrRideNotFound is returned by Apply for a ride that does not exist
+var ErrRideNotFound = errors.New("ride not found")
+
+// Apply locks the ride, checks the move against the state machine,
+// sets the status with its timestamp column and records the event, all in tx.
+// It returns the status the ride was in before the move.
+func Apply(ctx context.Context, tx pgx.Tx, rideId string, to Status, actor Actor, actorId string, payload map[string]interface{}) (Status, error) {
+	q1 := `SELECT status FROM rides WHERE ride_id = $1 FOR UPDATE`
+
+	var raw string
+	if err := tx.QueryRow(ctx, q1, rideId).Scan(&raw); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrRideNotFound
+		}
+		return "", fmt.Errorf("failed to lock ride: %w", err)
+	}
+
+	from, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := Check(from, to, actor)
+	if err != nil {
+		return from, err
+	}
+
+	q2 := `UPDATE rides SET status = $2, updated_at = NOW()`
+	if t.Timestamp != "" {
+		q2 += `, ` + t.Timestamp + ` = NOW()`
+	}
+	q2 += ` WHERE ride_id = $1`
+
+	if _, err := tx.Exec(ctx, q2, rideId, to); err != nil {
+		return from, fmt.Errorf("failed to update ride status: %w", err)
+	}
+
+	event := EventData{
+		Actor:      actor,
+		ActorId:    actorId,
+		FromStatus: from,
+		ToStatus:   to,
+		Payload:    payload,
+	}
+	if err := InsertEvent(ctx, tx, rideId, t.Event, event); err != nil {
+		return from, err
+	}
+
+	return from, nil
+}
@@ -0,0 +1,47 @@
+package ridestate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ride_event_type values
+const (
+	EventRideRequested   = "RIDE_REQUESTED"
+	EventDriverMatched   = "DRIVER_MATCHED"
+	EventDriverArrived   = "DRIVER_ARRIVED"
+	EventRideStarted     = "RIDE_STARTED"
+	EventRideCompleted   = "RIDE_COMPLETED"
+	EventRideCancelled   = "RIDE_CANCELLED"
+	EventStatusChanged   = "STATUS_CHANGED"
+	EventLocationUpdated = "LOCATION_UPDATED"
+	EventFareAdjusted    = "FARE_ADJUSTED"
+)
+
+// EventData is what goes into ride_events.event_data, whichever service
+// writes the event
+type EventData struct {
+	Actor      Actor                  `json:"actor"`
+	ActorId    string                 `json:"actor_id,omitempty"`
+	FromStatus Status                 `json:"from_status,omitempty"`
+	ToStatus   Status                 `json:"to_status"`
+	Payload    map[string]interface{} `json:"payload,omitempty"`
+}
+
+// InsertEvent appends to the audit trail inside the caller's transaction,
+// so an event exists if and only if the state change was committed
+func InsertEvent(ctx context.Context, tx pgx.Tx, rideId, eventType string, data EventData) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ride event: %w", err)
+	}
+
+	q := `INSERT INTO ride_events (ride_id, event_type, event_data) VALUES ($1, $2, $3)`
+	if _, err := tx.Exec(ctx, q, rideId, eventType, raw); err != nil {
+		return fmt.Errorf("failed to insert ride event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Package ridestate is the single source of truth for how a ride moves
+// between ride_status values, who may move it, which timestamp column
+// records the move and what goes into ride_events. Repositories of every
+// service check status changes against it and write events through it.
+package ridestate
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Status string
+
+// ride_status values
+const (
+	Requested  Status = "REQUESTED"
+	Matched    Status = "MATCHED"
+	EnRoute    Status = "EN_ROUTE"
+	Arrived    Status = "ARRIVED"
+	InProgress Status = "IN_PROGRESS"
+	Completed  Status = "COMPLETED"
+	Cancelled  Status = "CANCELLED"
+)
+
+type Actor string
+
+const (
+	ActorPassenger Actor = "PASSENGER"
+	ActorDriver    Actor = "DRIVER"
+	ActorSystem    Actor = "SYSTEM"
+)
+
+var (
+	ErrUnknownStatus     = errors.New("unknown ride status")
+	ErrSameStatus        = errors.New("ride is already in this status")
+	ErrIllegalTransition = errors.New("illegal ride status transition")
+	ErrActorNotAllowed   = errors.New("actor is not allowed to perform this transition")
+)
+
+// TransitionError describes a rejected transition; it unwraps to one of the
+// sentinel errors above
+type TransitionError struct {
+	From  Status
+	To    Status
+	Actor Actor
+	Err   error
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s: %s -> %s by %s", e.Err, e.From, e.To, e.Actor)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
+
+// Transition is one allowed edge of the state machine
+type Transition struct {
+	From   Status
+	To     Status
+	Actors []Actor
+	// Timestamp is the rides column set to NOW(), empty if none
+	Timestamp string
+	// Event is the ride_event_type recorded for the transition
+	Event string
+}
+
+type edge struct {
+	from, to Status
+}
+
+var transitions = map[edge]Transition{}
+
+func allow(from, to Status, timestamp, event string, actors ...Actor) {
+	transitions[edge{from, to}] = Transition{
+		From:      from,
+		To:        to,
+		Actors:    actors,
+		Timestamp: timestamp,
+		Event:     event,
+	}
+}
+
+func init() {
+	allow(Requested, Matched, "matched_at", EventDriverMatched, ActorDriver, ActorSystem)
+	allow(Matched, EnRoute, "", EventStatusChanged, ActorDriver)
+	allow(Matched, Arrived, "arrived_at", EventDriverArrived, ActorDriver)
+	allow(EnRoute, Arrived, "arrived_at", EventDriverArrived, ActorDriver)
+	allow(Arrived, InProgress, "started_at", EventRideStarted, ActorDriver)
+	allow(InProgress, Completed, "completed_at", EventRideCompleted, ActorDriver, ActorSystem)
+
+	allow(Requested, Cancelled, "cancelled_at", EventRideCancelled, ActorPassenger, ActorSystem)
+	for _, from := range []Status{Matched, EnRoute, Arrived} {
+		allow(from, Cancelled, "cancelled_at", EventRideCancelled, ActorPassenger, ActorDriver, ActorSystem)
+	}
+	allow(InProgress, Cancelled, "cancelled_at", EventRideCancelled, ActorPassenger, ActorSystem)
+}
+
+// Parse validates a raw ride_status value
+func Parse(s string) (Status, error) {
+	switch st := Status(s); st {
+	case Requested, Matched, EnRoute, Arrived, InProgress, Completed, Cancelled:
+		return st, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownStatus, s)
+	}
+}
+
+// Check returns the transition from -> to if actor may perform it
+func Check(from, to Status, actor Actor) (Transition, error) {
+	if from == to {
+		return Transition{}, &TransitionError{From: from, To: to, Actor: actor, Err: ErrSameStatus}
+	}
+
+	t, ok := transitions[edge{from, to}]
+	if !ok {
+		return Transition{}, &TransitionError{From: from, To: to, Actor: actor, Err: ErrIllegalTransition}
+	}
+
+	for _, a := range t.Actors {
+		if a == actor {
+			return t, nil
+		}
+	}
+	return Transition{}, &TransitionError{From: from, To: to, Actor: actor, Err: ErrActorNotAllowed}
+}
+
+// Terminal reports whether no transition leaves s
+func (s Status) Terminal() bool {
+	return s == Completed || s == Cancelled
+}
+
+// HasDriver reports whether a driver is assigned and the ride has not ended
+func (s Status) HasDriver() bool {
+	return s != Requested && !s.Terminal()
+}
+
+// Active lists the statuses of rides that are not finished yet
+func Active() []Status {
+	return []Status{Requested, Matched, EnRoute, Arrived, InProgress}
+}
+
+// FromDriverStatus maps a driver.status.* message to the ride status it
+// implies. ok is false for driver statuses that say nothing about the ride;
+// AVAILABLE is one of them, a driver becomes available for many reasons.
+func FromDriverStatus(driverStatus string) (Status, bool) {
+	switch driverStatus {
+	case "EN_ROUTE":
+		return EnRoute, true
+	case "ARRIVED":
+		return Arrived, true
+	case "BUSY":
+		return InProgress, true
+	case "COMPLETED":
+		return Completed, true
+	default:
+		return "", false
+	}
+}
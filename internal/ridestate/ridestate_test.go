@@ -0,0 +1,139 @@
+package ridestate
+
+import (
+	"errors"
+	"testing"
+)
+
+var (
+	allStatuses = []Status{Requested, Matched, EnRoute, Arrived, InProgress, Completed, Cancelled}
+	allActors   = []Actor{ActorPassenger, ActorDriver, ActorSystem}
+)
+
+// expected is written out independently of init() so a change to the
+// state machine has to be made in both places
+var expected = map[[2]Status]struct {
+	actors    []Actor
+	timestamp string
+	event     string
+}{
+	{Requested, Matched}:    {[]Actor{ActorDriver, ActorSystem}, "matched_at", EventDriverMatched},
+	{Matched, EnRoute}:      {[]Actor{ActorDriver}, "", EventStatusChanged},
+	{Matched, Arrived}:      {[]Actor{ActorDriver}, "arrived_at", EventDriverArrived},
+	{EnRoute, Arrived}:      {[]Actor{ActorDriver}, "arrived_at", EventDriverArrived},
+	{Arrived, InProgress}:   {[]Actor{ActorDriver}, "started_at", EventRideStarted},
+	{InProgress, Completed}: {[]Actor{ActorDriver, ActorSystem}, "completed_at", EventRideCompleted},
+	{Requested, Cancelled}:  {[]Actor{ActorPassenger, ActorSystem}, "cancelled_at", EventRideCancelled},
+	{Matched, Cancelled}:    {[]Actor{ActorPassenger, ActorDriver, ActorSystem}, "cancelled_at", EventRideCancelled},
+	{EnRoute, Cancelled}:    {[]Actor{ActorPassenger, ActorDriver, ActorSystem}, "cancelled_at", EventRideCancelled},
+	{Arrived, Cancelled}:    {[]Actor{ActorPassenger, ActorDriver, ActorSystem}, "cancelled_at", EventRideCancelled},
+	{InProgress, Cancelled}: {[]Actor{ActorPassenger, ActorSystem}, "cancelled_at", EventRideCancelled},
+}
+
+func contains(actors []Actor, actor Actor) bool {
+	for _, a := range actors {
+		if a == actor {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheck(t *testing.T) {
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			for _, actor := range allActors {
+				name := string(from) + "->" + string(to) + "/" + string(actor)
+				t.Run(name, func(t *testing.T) {
+					got, err := Check(from, to, actor)
+					want, edgeAllowed := expected[[2]Status{from, to}]
+
+					var wantErr error
+					switch {
+					case from == to:
+						wantErr = ErrSameStatus
+					case !edgeAllowed:
+						wantErr = ErrIllegalTransition
+					case !contains(want.actors, actor):
+						wantErr = ErrActorNotAllowed
+					}
+
+					if wantErr != nil {
+						if !errors.Is(err, wantErr) {
+							t.Fatalf("Check() error = %v, want %v", err, wantErr)
+						}
+						var te *TransitionError
+						if !errors.As(err, &te) || te.From != from || te.To != to || te.Actor != actor {
+							t.Fatalf("Check() error = %#v, want TransitionError for %s", err, name)
+						}
+						return
+					}
+
+					if err != nil {
+						t.Fatalf("Check() unexpected error: %v", err)
+					}
+					if got.From != from || got.To != to {
+						t.Errorf("Check() = %s -> %s, want %s -> %s", got.From, got.To, from, to)
+					}
+					if got.Timestamp != want.timestamp {
+						t.Errorf("Timestamp = %q, want %q", got.Timestamp, want.timestamp)
+					}
+					if got.Event != want.event {
+						t.Errorf("Event = %q, want %q", got.Event, want.event)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestTerminalStatusesHaveNoExits(t *testing.T) {
+	for _, from := range allStatuses {
+		if !from.Terminal() {
+			continue
+		}
+		for _, to := range allStatuses {
+			for _, actor := range allActors {
+				if _, err := Check(from, to, actor); err == nil {
+					t.Errorf("Check(%s, %s, %s) allowed a transition out of a terminal status", from, to, actor)
+				}
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, st := range allStatuses {
+		got, err := Parse(string(st))
+		if err != nil || got != st {
+			t.Errorf("Parse(%q) = %q, %v", st, got, err)
+		}
+	}
+	for _, raw := range []string{"", "requested", "DONE"} {
+		if _, err := Parse(raw); !errors.Is(err, ErrUnknownStatus) {
+			t.Errorf("Parse(%q) error = %v, want ErrUnknownStatus", raw, err)
+		}
+	}
+}
+
+func TestFromDriverStatus(t *testing.T) {
+	tests := []struct {
+		driverStatus string
+		want         Status
+		ok           bool
+	}{
+		{"EN_ROUTE", EnRoute, true},
+		{"ARRIVED", Arrived, true},
+		{"BUSY", InProgress, true},
+		{"COMPLETED", Completed, true},
+		{"AVAILABLE", "", false},
+		{"OFFLINE", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := FromDriverStatus(tt.driverStatus)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FromDriverStatus(%q) = %q, %v, want %q, %v", tt.driverStatus, got, ok, tt.want, tt.ok)
+		}
+	}
+}
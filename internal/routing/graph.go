@@ -0,0 +1,285 @@
+package routing
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"ride-hail/internal/config"
+)
+
+// snapping looks for the nearest node in the point's grid cell and its neighbours
+const gridCellDeg = 0.01
+
+// Graph is a directed road graph. It is read once at startup and never
+// modified, so any number of routes can be searched on it concurrently.
+type Graph struct {
+	nodes    []Point
+	edges    [][]edge
+	grid     map[gridKey][]int32
+	maxSpeed float64
+}
+
+type edge struct {
+	to         int32
+	distanceKm float64
+	minutes    float64
+}
+
+type gridKey struct {
+	lat, lng int
+}
+
+// LoadGraph reads a road graph extracted from OpenStreetMap. The file is
+// plain text, one record per line:
+//
+//	# comment
+//	v <osm_node_id> <lat> <lng>
+//	e <from_node_id> <to_node_id> <length_m> <speed_kmh> [oneway]
+//
+// Vertices must come before the edges that use them. Edges are two-way
+// unless marked oneway.
+func LoadGraph(path string) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open road graph: %w", err)
+	}
+	defer f.Close()
+
+	g := &Graph{grid: make(map[gridKey][]int32)}
+	ids := make(map[int64]int32)
+
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch {
+		case fields[0] == "v" && len(fields) == 4:
+			id, err1 := strconv.ParseInt(fields[1], 10, 64)
+			lat, err2 := strconv.ParseFloat(fields[2], 64)
+			lng, err3 := strconv.ParseFloat(fields[3], 64)
+			if err := firstErr(err1, err2, err3); err != nil {
+				return nil, fmt.Errorf("road graph line %d: %w", line, err)
+			}
+			if _, dup := ids[id]; dup {
+				return nil, fmt.Errorf("road graph line %d: duplicate vertex %d", line, id)
+			}
+			ids[id] = g.addNode(Point{Lat: lat, Lng: lng})
+
+		case fields[0] == "e" && (len(fields) == 5 || len(fields) == 6 && fields[5] == "oneway"):
+			from, err1 := strconv.ParseInt(fields[1], 10, 64)
+			to, err2 := strconv.ParseInt(fields[2], 10, 64)
+			meters, err3 := strconv.ParseFloat(fields[3], 64)
+			speed, err4 := strconv.ParseFloat(fields[4], 64)
+			if err := firstErr(err1, err2, err3, err4); err != nil {
+				return nil, fmt.Errorf("road graph line %d: %w", line, err)
+			}
+			u, ok1 := ids[from]
+			v, ok2 := ids[to]
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("road graph line %d: edge uses an unknown vertex", line)
+			}
+			if meters < 0 || speed <= 0 {
+				return nil, fmt.Errorf("road graph line %d: length must not be negative and speed must be positive", line)
+			}
+			g.addEdge(u, v, meters/1000, speed)
+			if len(fields) == 5 {
+				g.addEdge(v, u, meters/1000, speed)
+			}
+
+		default:
+			return nil, fmt.Errorf("road graph line %d: malformed record %q", line, fields[0])
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read road graph: %w", err)
+	}
+	if len(g.nodes) == 0 {
+		return nil, fmt.Errorf("road graph %s has no vertices", path)
+	}
+	return g, nil
+}
+
+func (g *Graph) addNode(p Point) int32 {
+	id := int32(len(g.nodes))
+	g.nodes = append(g.nodes, p)
+	g.edges = append(g.edges, nil)
+	key := cellKey(p)
+	g.grid[key] = append(g.grid[key], id)
+	return id
+}
+
+func (g *Graph) addEdge(from, to int32, distanceKm, speedKmh float64) {
+	g.edges[from] = append(g.edges[from], edge{to: to, distanceKm: distanceKm, minutes: distanceKm / speedKmh * 60})
+	g.maxSpeed = max(g.maxSpeed, speedKmh)
+}
+
+// nearest returns the vertex closest to p within the 3x3 cells around it
+func (g *Graph) nearest(p Point) (int32, float64, bool) {
+	center := cellKey(p)
+	best, bestKm := int32(-1), math.Inf(1)
+	for dLat := -1; dLat <= 1; dLat++ {
+		for dLng := -1; dLng <= 1; dLng++ {
+			for _, id := range g.grid[gridKey{lat: center.lat + dLat, lng: center.lng + dLng}] {
+				if d := HaversineKm(p, g.nodes[id]); d < bestKm {
+					best, bestKm = id, d
+				}
+			}
+		}
+	}
+	return best, bestKm, best >= 0
+}
+
+// shortest runs A* on travel time. The heuristic is the straight line at
+// the fastest speed in the graph, which never overestimates.
+func (g *Graph) shortest(ctx context.Context, from, to int32) (Route, error) {
+	if from == to {
+		return Route{}, nil
+	}
+
+	type label struct {
+		minutes    float64
+		distanceKm float64
+		closed     bool
+	}
+	labels := map[int32]*label{from: {}}
+	h := func(id int32) float64 {
+		return HaversineKm(g.nodes[id], g.nodes[to]) / g.maxSpeed * 60
+	}
+
+	open := &queue{{node: from, priority: h(from)}}
+	for steps := 0; open.Len() > 0; steps++ {
+		// long searches are cut off with the request
+		if steps%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return Route{}, err
+			}
+		}
+
+		cur := heap.Pop(open).(item)
+		l := labels[cur.node]
+		if l.closed {
+			continue
+		}
+		if cur.node == to {
+			return Route{DistanceKm: l.distanceKm, DurationMinutes: l.minutes}, nil
+		}
+		l.closed = true
+
+		for _, e := range g.edges[cur.node] {
+			minutes := l.minutes + e.minutes
+			next, seen := labels[e.to]
+			if seen && (next.closed || next.minutes <= minutes) {
+				continue
+			}
+			if !seen {
+				next = &label{}
+				labels[e.to] = next
+			}
+			next.minutes = minutes
+			next.distanceKm = l.distanceKm + e.distanceKm
+			heap.Push(open, item{node: e.to, priority: minutes + h(e.to)})
+		}
+	}
+	return Route{}, ErrNoRoute
+}
+
+// GraphRouter routes over the road graph. Points are snapped to the nearest
+// vertex; legs that cannot be snapped or are not connected are estimated by
+// the speed profile instead, so a sparse graph degrades rather than fails.
+type GraphRouter struct {
+	graph   *Graph
+	profile *SpeedProfile
+	maxSnap float64
+}
+
+func NewGraphRouter(graph *Graph, profile *SpeedProfile, cfg *config.Routingconfig) *GraphRouter {
+	r := &GraphRouter{
+		graph:   graph,
+		profile: profile,
+		maxSnap: float64(cfg.MaxSnapMeters) / 1000,
+	}
+	if r.maxSnap <= 0 {
+		r.maxSnap = 0.5
+	}
+	return r
+}
+
+func (r *GraphRouter) Route(ctx context.Context, points []Point) (Route, error) {
+	if err := validate(points); err != nil {
+		return Route{}, err
+	}
+
+	var total Route
+	for i := 1; i < len(points); i++ {
+		leg, err := r.leg(ctx, points[i-1], points[i])
+		if err != nil {
+			return Route{}, err
+		}
+		total.DistanceKm += leg.DistanceKm
+		total.DurationMinutes += leg.DurationMinutes
+	}
+	return total, nil
+}
+
+func (r *GraphRouter) leg(ctx context.Context, a, b Point) (Route, error) {
+	from, snapA, okA := r.graph.nearest(a)
+	to, snapB, okB := r.graph.nearest(b)
+	if !okA || !okB || snapA > r.maxSnap || snapB > r.maxSnap {
+		return r.profile.Route(ctx, []Point{a, b})
+	}
+
+	route, err := r.graph.shortest(ctx, from, to)
+	if err == ErrNoRoute {
+		return r.profile.Route(ctx, []Point{a, b})
+	}
+	if err != nil {
+		return Route{}, err
+	}
+
+	// graph speeds are free flow; the stretch between a point and its vertex is driven at profile speed
+	snapKm := snapA + snapB
+	return Route{
+		DistanceKm:      route.DistanceKm + snapKm,
+		DurationMinutes: route.DurationMinutes*r.profile.Congestion() + snapKm/r.profile.SpeedKmh()*60,
+	}, nil
+}
+
+type item struct {
+	node     int32
+	priority float64
+}
+
+type queue []item
+
+func (q queue) Len() int           { return len(q) }
+func (q queue) Less(i, j int) bool { return q[i].priority < q[j].priority }
+func (q queue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x any)        { *q = append(*q, x.(item)) }
+func (q *queue) Pop() any {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+func cellKey(p Point) gridKey {
+	return gridKey{lat: int(math.Floor(p.Lat / gridCellDeg)), lng: int(math.Floor(p.Lng / gridCellDeg))}
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
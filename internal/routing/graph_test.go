@@ -0,0 +1,211 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ride-hail/internal/config"
+)
+
+// testGraph: the direct A-C road is slow, the detour through B is fast;
+// E reaches A by a one-way street and D is not connected at all
+const testGraph = `# test graph
+v 1 43.2000 76.9000
+v 2 43.2030 76.9050
+v 3 43.2000 76.9100
+
+v 4 43.2000 76.8950
+v 5 43.2050 76.9000
+e 1 3 1000 10
+e 1 2 600 60
+e 2 3 600 60
+e 5 1 700 60 oneway
+`
+
+var (
+	pointA = Point{Lat: 43.2000, Lng: 76.9000}
+	pointC = Point{Lat: 43.2000, Lng: 76.9100}
+	pointD = Point{Lat: 43.2000, Lng: 76.8950}
+)
+
+func writeGraph(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "graph.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadTestGraph(t *testing.T) *Graph {
+	t.Helper()
+	g, err := LoadGraph(writeGraph(t, testGraph))
+	if err != nil {
+		t.Fatalf("LoadGraph() error = %v", err)
+	}
+	return g
+}
+
+// testProfile runs at noon: 30 km/h against 60 km/h at night, congestion 2
+func testProfile() *SpeedProfile {
+	p := NewSpeedProfile(&config.Routingconfig{DetourFactor: 1.3, AvgSpeedKmh: 30, PeakSpeedKmh: 20, NightSpeedKmh: 60})
+	p.now = func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) }
+	return p
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestLoadGraphErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty file", "# nothing\n\n", "has no vertices"},
+		{"bad latitude", "v 1 north 76.9\n", "line 1"},
+		{"bad vertex id", "v x 43.2 76.9\n", "line 1"},
+		{"duplicate vertex", "v 1 43.2 76.9\nv 1 43.3 76.9\n", "line 2: duplicate vertex 1"},
+		{"unknown vertex", "v 1 43.2 76.9\ne 1 2 100 50\n", "line 2: edge uses an unknown vertex"},
+		{"bad length", "v 1 43.2 76.9\nv 2 43.3 76.9\ne 1 2 long 50\n", "line 3"},
+		{"negative length", "v 1 43.2 76.9\nv 2 43.3 76.9\ne 1 2 -1 50\n", "line 3: length must not be negative"},
+		{"zero speed", "v 1 43.2 76.9\nv 2 43.3 76.9\ne 1 2 100 0\n", "line 3: length must not be negative and speed must be positive"},
+		{"unknown edge flag", "v 1 43.2 76.9\nv 2 43.3 76.9\ne 1 2 100 50 twoway\n", `line 3: malformed record "e"`},
+		{"missing field", "v 1 43.2\n", `line 1: malformed record "v"`},
+		{"unknown record", "v 1 43.2 76.9\nx 1\n", `line 2: malformed record "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadGraph(writeGraph(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadGraph() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadGraph(filepath.Join(t.TempDir(), "missing.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadGraph() of a missing file error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestShortest(t *testing.T) {
+	g := loadTestGraph(t)
+	ctx := context.Background()
+	// vertices are numbered in file order
+	const a, b, c, d, e = 0, 1, 2, 3, 4
+
+	tests := []struct {
+		name     string
+		from, to int32
+		want     Route
+		wantErr  error
+	}{
+		{"fast detour beats the short slow road", a, c, Route{DistanceKm: 1.2, DurationMinutes: 1.2}, nil},
+		{"edges are two-way by default", c, a, Route{DistanceKm: 1.2, DurationMinutes: 1.2}, nil},
+		{"single edge", a, b, Route{DistanceKm: 0.6, DurationMinutes: 0.6}, nil},
+		{"same vertex", b, b, Route{}, nil},
+		{"one-way street forward", e, c, Route{DistanceKm: 1.9, DurationMinutes: 1.9}, nil},
+		{"one-way street backward", a, e, Route{}, ErrNoRoute},
+		{"disconnected vertex", a, d, Route{}, ErrNoRoute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.shortest(ctx, tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("shortest() error = %v, want %v", err, tt.wantErr)
+			}
+			if !approx(got.DistanceKm, tt.want.DistanceKm) || !approx(got.DurationMinutes, tt.want.DurationMinutes) {
+				t.Errorf("shortest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphRouterRoute(t *testing.T) {
+	g := loadTestGraph(t)
+	profile := testProfile()
+	r := NewGraphRouter(g, profile, &config.Routingconfig{MaxSnapMeters: 500})
+	ctx := context.Background()
+
+	got, err := r.Route(ctx, []Point{pointA, pointC})
+	if err != nil {
+		t.Fatalf("Route() error = %v", err)
+	}
+	// free-flow graph time is slowed down by the noon congestion
+	if !approx(got.DistanceKm, 1.2) || !approx(got.DurationMinutes, 2.4) {
+		t.Errorf("Route() = %+v, want 1.2 km in 2.4 min", got)
+	}
+
+	// the stretch from a point to its vertex is added at profile speed
+	offRoad := Point{Lat: 43.2010, Lng: 76.9000}
+	snapKm := HaversineKm(offRoad, pointA)
+	got, err = r.Route(ctx, []Point{offRoad, pointC})
+	if err != nil {
+		t.Fatalf("Route() error = %v", err)
+	}
+	if !approx(got.DistanceKm, 1.2+snapKm) || !approx(got.DurationMinutes, 2.4+snapKm/30*60) {
+		t.Errorf("Route() from off road = %+v, want %v km", got, 1.2+snapKm)
+	}
+
+	if _, err := r.Route(ctx, []Point{pointA}); !errors.Is(err, ErrTooFewPoints) {
+		t.Errorf("Route() of one point error = %v, want ErrTooFewPoints", err)
+	}
+	if _, err := r.Route(ctx, []Point{pointA, {Lat: 91}}); !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("Route() of an invalid point error = %v, want ErrInvalidPoint", err)
+	}
+}
+
+func TestGraphRouterFallsBackToProfile(t *testing.T) {
+	g := loadTestGraph(t)
+	profile := testProfile()
+	r := NewGraphRouter(g, profile, &config.Routingconfig{MaxSnapMeters: 500})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		points []Point
+	}{
+		{"no vertex nearby", []Point{pointA, {Lat: 43.3, Lng: 77.0}}},
+		{"nearest vertex beyond the snap distance", []Point{pointA, {Lat: 43.2080, Lng: 76.9150}}},
+		{"vertices not connected", []Point{pointD, pointC}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Route(ctx, tt.points)
+			if err != nil {
+				t.Fatalf("Route() error = %v", err)
+			}
+			want, _ := profile.Route(ctx, tt.points)
+			if !approx(got.DistanceKm, want.DistanceKm) || !approx(got.DurationMinutes, want.DurationMinutes) {
+				t.Errorf("Route() = %+v, want the profile estimate %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestSpeedProfile(t *testing.T) {
+	p := testProfile()
+	for hour, want := range map[int]float64{3: 60, 8: 20, 12: 30, 18: 20, 23: 60} {
+		p.now = func() time.Time { return time.Date(2024, 1, 1, hour, 0, 0, 0, time.UTC) }
+		if got := p.SpeedKmh(); got != want {
+			t.Errorf("SpeedKmh() at %d:00 = %v, want %v", hour, got, want)
+		}
+	}
+
+	p = testProfile()
+	got, err := p.Route(context.Background(), []Point{pointA, pointC})
+	if err != nil {
+		t.Fatalf("Route() error = %v", err)
+	}
+	wantKm := HaversineKm(pointA, pointC) * 1.3
+	if !approx(got.DistanceKm, wantKm) || !approx(got.DurationMinutes, wantKm/30*60) {
+		t.Errorf("Route() = %+v, want %v km at 30 km/h", got, wantKm)
+	}
+}
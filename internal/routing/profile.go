@@ -0,0 +1,78 @@
+package routing
+
+import (
+	"context"
+	"time"
+
+	"ride-hail/internal/config"
+)
+
+// SpeedProfile estimates a route from straight-line legs stretched by a
+// detour factor, driven at the speed of the current hour: slower in the
+// morning and evening peaks, faster at night.
+type SpeedProfile struct {
+	detourFactor float64
+	speeds       [24]float64
+	now          func() time.Time
+}
+
+func NewSpeedProfile(cfg *config.Routingconfig) *SpeedProfile {
+	p := &SpeedProfile{
+		detourFactor: cfg.DetourFactor,
+		now:          time.Now,
+	}
+	if p.detourFactor < 1 {
+		p.detourFactor = 1
+	}
+
+	avg := positive(cfg.AvgSpeedKmh, 30)
+	peak := positive(cfg.PeakSpeedKmh, avg)
+	night := positive(cfg.NightSpeedKmh, avg)
+	for hour := range p.speeds {
+		switch {
+		case hour >= 7 && hour < 10, hour >= 17 && hour < 20:
+			p.speeds[hour] = peak
+		case hour >= 22, hour < 6:
+			p.speeds[hour] = night
+		default:
+			p.speeds[hour] = avg
+		}
+	}
+	return p
+}
+
+func (p *SpeedProfile) Route(ctx context.Context, points []Point) (Route, error) {
+	if err := validate(points); err != nil {
+		return Route{}, err
+	}
+
+	distance := 0.0
+	for i := 1; i < len(points); i++ {
+		distance += HaversineKm(points[i-1], points[i]) * p.detourFactor
+	}
+	return Route{
+		DistanceKm:      distance,
+		DurationMinutes: distance / p.SpeedKmh() * 60,
+	}, nil
+}
+
+// SpeedKmh is the average driving speed right now
+func (p *SpeedProfile) SpeedKmh() float64 {
+	return p.speeds[p.now().Hour()]
+}
+
+// Congestion is how much slower than free flow traffic is right now; 1 at night
+func (p *SpeedProfile) Congestion() float64 {
+	fastest := 0.0
+	for _, s := range p.speeds {
+		fastest = max(fastest, s)
+	}
+	return fastest / p.SpeedKmh()
+}
+
+func positive(v, def float64) float64 {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,74 @@
+// Package routing estimates how far and how long a drive is. Matching,
+// pricing and passenger notifications all ask the same router, so the ETA a
+// driver is offered and the duration a passenger is charged for agree.
+package routing
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"ride-hail/internal/config"
+)
+
+const earthRadiusKm = 6371.0088
+
+var (
+	ErrTooFewPoints = errors.New("a route needs at least two points")
+	ErrInvalidPoint = errors.New("latitude must be within [-90, 90] and longitude within [-180, 180]")
+	// ErrNoRoute means the road graph does not connect the points
+	ErrNoRoute = errors.New("no route between points")
+)
+
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// Route is the estimate of driving through all points in order
+type Route struct {
+	DistanceKm      float64
+	DurationMinutes float64
+}
+
+// Router is implemented by SpeedProfile and GraphRouter
+type Router interface {
+	Route(ctx context.Context, points []Point) (Route, error)
+}
+
+// New returns the road graph router when a graph file is configured and the
+// speed profile alone otherwise
+func New(cfg *config.Routingconfig) (Router, error) {
+	profile := NewSpeedProfile(cfg)
+	if cfg.GraphPath == "" {
+		return profile, nil
+	}
+
+	graph, err := LoadGraph(cfg.GraphPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewGraphRouter(graph, profile, cfg), nil
+}
+
+// HaversineKm is the great-circle distance between two points
+func HaversineKm(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func validate(points []Point) error {
+	if len(points) < 2 {
+		return ErrTooFewPoints
+	}
+	for _, p := range points {
+		if p.Lat < -90 || p.Lat > 90 || p.Lng < -180 || p.Lng > 180 {
+			return ErrInvalidPoint
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Package tracing carries one trace from the HTTP or WebSocket request that
+// starts a ride through every broker message it causes, in every service.
+// The W3C trace context travels in HTTP headers, AMQP headers and the outbox;
+// finished spans are written as OpenTelemetry JSON lines to a file per service,
+// and the trace id is the correlation id of the messages and the logs.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ride-hail/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "ride-hail"
+
+// Init installs the tracer provider of the service and returns its shutdown,
+// which flushes the spans still buffered
+func Init(service string, cfg *config.Tracingconfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create traces directory: %w", err)
+	}
+	file, err := os.OpenFile(filepath.Join(cfg.Dir, service+".traces.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open traces file: %w", err)
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("cannot create span exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))),
+		// a trace that came sampled from another service stays sampled
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		file.Close()
+		return err
+	}, nil
+}
+
+// Start begins a span in ctx, a new trace if ctx has none
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, opts...)
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// CorrelationID is the trace id of ctx, empty outside a trace
+func CorrelationID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// WithSpanFrom returns base carrying the span of from. Services keep their own
+// context for deadlines and shutdown and take only the trace from the caller.
+func WithSpanFrom(base, from context.Context) context.Context {
+	return trace.ContextWithSpan(base, trace.SpanFromContext(from))
+}
+
+// InjectMap returns the trace context of ctx as plain headers, e.g. to store it
+func InjectMap(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// ExtractMap returns ctx continuing the trace stored by InjectMap
+func ExtractMap(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
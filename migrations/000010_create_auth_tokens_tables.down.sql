@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS trg_users_banned ON users;
+DROP FUNCTION IF EXISTS revoke_banned_user_tokens ();
+DROP TABLE IF EXISTS revoked_subjects;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,50 @@
+-- Rotating refresh tokens, only the sha256 of the token is stored
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  refresh_token_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW (),
+  family_id UUID NOT NULL,
+  subject_id UUID NOT NULL,
+  role TEXT NOT NULL,
+  token_hash VARCHAR(64) UNIQUE NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  revoked_at TIMESTAMPTZ,
+  replaced_by UUID REFERENCES refresh_tokens (refresh_token_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_subject ON refresh_tokens (subject_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family_id);
+
+-- Revoked access tokens, rows can be dropped once expires_at has passed
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+  jti TEXT PRIMARY KEY,
+  subject_id UUID NOT NULL,
+  revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW (),
+  expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Every access token of the subject issued before revoked_before is rejected
+CREATE TABLE IF NOT EXISTS revoked_subjects (
+  subject_id UUID PRIMARY KEY,
+  revoked_before TIMESTAMPTZ NOT NULL DEFAULT NOW (),
+  reason TEXT
+);
+
+-- Banning a user cuts off all of their sessions immediately
+CREATE OR REPLACE FUNCTION revoke_banned_user_tokens () RETURNS TRIGGER AS $$
+BEGIN
+  IF NEW.status = 'BANNED' AND OLD.status IS DISTINCT FROM 'BANNED' THEN
+    INSERT INTO revoked_subjects (subject_id, revoked_before, reason)
+    VALUES (NEW.user_id, NOW (), 'banned')
+    ON CONFLICT (subject_id) DO UPDATE
+      SET revoked_before = EXCLUDED.revoked_before, reason = EXCLUDED.reason;
+
+    UPDATE refresh_tokens SET revoked_at = NOW ()
+    WHERE subject_id = NEW.user_id AND revoked_at IS NULL;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_users_banned
+AFTER UPDATE OF status ON users
+FOR EACH ROW EXECUTE FUNCTION revoke_banned_user_tokens ();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ride_events_ride;
+
+DROP INDEX IF EXISTS idx_rides_passenger_history;
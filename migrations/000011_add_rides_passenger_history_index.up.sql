@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_rides_passenger_history ON rides (passenger_id, created_at DESC, ride_id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_ride_events_ride ON ride_events (ride_id, created_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_location_history_driver_recent;
+
+ALTER TABLE drivers
+  DROP COLUMN IF EXISTS offers_accepted,
+  DROP COLUMN IF EXISTS offers_received;
@@ -0,0 +1,5 @@
+ALTER TABLE drivers
+  ADD COLUMN IF NOT EXISTS offers_received INTEGER NOT NULL DEFAULT 0 CHECK (offers_received >= 0),
+  ADD COLUMN IF NOT EXISTS offers_accepted INTEGER NOT NULL DEFAULT 0 CHECK (offers_accepted >= 0);
+
+CREATE INDEX IF NOT EXISTS idx_location_history_driver_recent ON location_history (driver_id, recorded_at DESC);
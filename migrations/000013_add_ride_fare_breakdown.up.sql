@@ -0,0 +1 @@
+ALTER TABLE rides ADD COLUMN IF NOT EXISTS fare_breakdown JSONB;
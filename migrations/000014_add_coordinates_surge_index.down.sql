@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_coordinates_current_position;
@@ -0,0 +1,2 @@
+-- surge counts waiting rides and available drivers inside a geohash cell box
+CREATE INDEX IF NOT EXISTS idx_coordinates_current_position ON coordinates (entity_type, latitude, longitude) WHERE is_current = true;
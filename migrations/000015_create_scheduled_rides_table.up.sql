@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS scheduled_rides (
+  booking_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  passenger_id UUID REFERENCES users (user_id) NOT NULL,
+  vehicle_type vehicle_type NOT NULL DEFAULT 'ECONOMY',
+  pickup_latitude DECIMAL(10, 8) NOT NULL CHECK (pickup_latitude BETWEEN -90 AND 90),
+  pickup_longitude DECIMAL(11, 8) NOT NULL CHECK (pickup_longitude BETWEEN -180 AND 180),
+  pickup_address TEXT NOT NULL,
+  destination_latitude DECIMAL(10, 8) NOT NULL CHECK (destination_latitude BETWEEN -90 AND 90),
+  destination_longitude DECIMAL(11, 8) NOT NULL CHECK (destination_longitude BETWEEN -180 AND 180),
+  destination_address TEXT NOT NULL,
+  scheduled_at TIMESTAMPTZ NOT NULL,
+  estimated_fare DECIMAL(10, 2),
+  -- SCHEDULED -> DISPATCHING -> DISPATCHED | FAILED, or CANCELLED by the passenger
+  status TEXT NOT NULL DEFAULT 'SCHEDULED' CHECK (status IN ('SCHEDULED', 'DISPATCHING', 'DISPATCHED', 'FAILED', 'CANCELLED')),
+  ride_id UUID REFERENCES rides (ride_id),
+  failure_reason TEXT,
+  dispatched_at TIMESTAMPTZ,
+  cancelled_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_rides_due ON scheduled_rides (scheduled_at) WHERE status = 'SCHEDULED';
+CREATE INDEX IF NOT EXISTS idx_scheduled_rides_passenger ON scheduled_rides (passenger_id, scheduled_at);
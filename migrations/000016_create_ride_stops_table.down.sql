@@ -0,0 +1,2 @@
+ALTER TABLE scheduled_rides DROP COLUMN IF EXISTS stops;
+DROP TABLE IF EXISTS ride_stops;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS ride_stops (
+  stop_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  ride_id UUID REFERENCES rides (ride_id) NOT NULL,
+  -- 1-based position of the stop between pickup and destination
+  stop_index INTEGER NOT NULL CHECK (stop_index > 0),
+  latitude DECIMAL(10, 8) NOT NULL CHECK (latitude BETWEEN -90 AND 90),
+  longitude DECIMAL(11, 8) NOT NULL CHECK (longitude BETWEEN -180 AND 180),
+  address TEXT NOT NULL,
+  -- set by the driver when the stop is reached
+  arrived_at TIMESTAMPTZ,
+  UNIQUE (ride_id, stop_index)
+);
+
+-- stops of a booking, copied to ride_stops when it is dispatched
+ALTER TABLE scheduled_rides ADD COLUMN IF NOT EXISTS stops JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS ride_destination_changes;
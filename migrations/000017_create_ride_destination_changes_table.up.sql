@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS ride_destination_changes (
+  change_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  ride_id UUID REFERENCES rides (ride_id) NOT NULL,
+  latitude DECIMAL(10, 8) NOT NULL CHECK (latitude BETWEEN -90 AND 90),
+  longitude DECIMAL(11, 8) NOT NULL CHECK (longitude BETWEEN -180 AND 180),
+  address TEXT NOT NULL,
+  distance_km DECIMAL(8, 2) NOT NULL,
+  old_fare DECIMAL(10, 2) NOT NULL,
+  new_fare DECIMAL(10, 2) NOT NULL,
+  fare_breakdown JSONB NOT NULL,
+  -- PENDING until the driver answers; a newer proposal SUPERSEDES it
+  status TEXT NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'ACCEPTED', 'REJECTED', 'EXPIRED', 'SUPERSEDED')),
+  expires_at TIMESTAMPTZ NOT NULL,
+  resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_ride_destination_changes_pending ON ride_destination_changes (ride_id) WHERE status = 'PENDING';
@@ -0,0 +1,18 @@
+-- Broker messages written in the same transaction as the change they announce.
+-- A relay in the producing service publishes them and sets sent_at.
+CREATE TABLE IF NOT EXISTS outbox (
+  message_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  service TEXT NOT NULL,
+  exchange TEXT NOT NULL,
+  routing_key TEXT NOT NULL,
+  priority SMALLINT NOT NULL DEFAULT 0 CHECK (priority BETWEEN 0 AND 255),
+  payload JSONB NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  -- a claimed row is hidden until its lease runs out; failed rows back off the same way
+  available_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_unsent ON outbox (service, available_at) WHERE sent_at IS NULL;
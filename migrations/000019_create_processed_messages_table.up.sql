@@ -0,0 +1,8 @@
+-- Broker messages a consumer has already acted on. The row is inserted in the
+-- transaction of the side effect, so a redelivered message is skipped.
+CREATE TABLE IF NOT EXISTS processed_messages (
+  consumer TEXT NOT NULL,
+  message_id TEXT NOT NULL,
+  processed_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  PRIMARY KEY (consumer, message_id)
+);
@@ -0,0 +1,23 @@
+-- Messages RabbitMQ dead-lettered to dlx, stored by admin-service for
+-- inspection and replay. A replayed message that dies again updates its row.
+CREATE TABLE IF NOT EXISTS dead_letters (
+  dead_letter_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+  message_id TEXT,
+  -- where the message was when it died, from its latest x-death entry
+  queue TEXT NOT NULL,
+  exchange TEXT NOT NULL,
+  routing_key TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  death_count BIGINT NOT NULL DEFAULT 1,
+  headers JSONB NOT NULL DEFAULT '{}',
+  content_type TEXT,
+  body BYTEA NOT NULL,
+  dead_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  received_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  replayed_at TIMESTAMPTZ,
+  replay_count INTEGER NOT NULL DEFAULT 0
+);
+
+-- messages without an id never conflict, NULLs are distinct
+CREATE UNIQUE INDEX IF NOT EXISTS idx_dead_letters_message ON dead_letters (queue, message_id);
+CREATE INDEX IF NOT EXISTS idx_dead_letters_dead_at ON dead_letters (dead_at DESC);
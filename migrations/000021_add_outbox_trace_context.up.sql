@@ -0,0 +1,3 @@
+-- W3C trace context of the transaction that wrote the message; the relay
+-- publishes the message in the same trace
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS trace_context JSONB;
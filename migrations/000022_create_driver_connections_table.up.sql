@@ -0,0 +1,11 @@
+-- Which driver-location-service instance holds the WebSocket of each driver.
+-- Instances refresh last_seen_at of their rows; rows of an instance that
+-- stopped refreshing are ignored and replaced on the next connection.
+CREATE TABLE IF NOT EXISTS driver_connections (
+  driver_id UUID PRIMARY KEY REFERENCES drivers (driver_id) ON DELETE CASCADE,
+  instance_id TEXT NOT NULL,
+  connected_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now ()
+);
+
+CREATE INDEX IF NOT EXISTS idx_driver_connections_instance ON driver_connections (instance_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS passenger_connections;
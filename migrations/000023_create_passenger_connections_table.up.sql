@@ -0,0 +1,11 @@
+-- Which ride-service instance holds the WebSocket of each passenger.
+-- Instances refresh last_seen_at of their rows; rows of an instance that
+-- stopped refreshing are ignored and replaced on the next connection.
+CREATE TABLE IF NOT EXISTS passenger_connections (
+  passenger_id UUID PRIMARY KEY REFERENCES users (user_id) ON DELETE CASCADE,
+  instance_id TEXT NOT NULL,
+  connected_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+  last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now ()
+);
+
+CREATE INDEX IF NOT EXISTS idx_passenger_connections_instance ON passenger_connections (instance_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_outbox_unsent;
+ALTER TABLE outbox DROP COLUMN IF EXISTS seq;
+CREATE INDEX IF NOT EXISTS idx_outbox_unsent ON outbox (service, available_at) WHERE sent_at IS NULL;
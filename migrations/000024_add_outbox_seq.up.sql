@@ -0,0 +1,6 @@
+-- Write order of outbox messages. created_at is the transaction start, so
+-- the messages of one transaction share it; seq tells them apart.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+
+DROP INDEX IF EXISTS idx_outbox_unsent;
+CREATE INDEX IF NOT EXISTS idx_outbox_unsent ON outbox (service, seq) WHERE sent_at IS NULL;
@@ -0,0 +1,2 @@
+-- Extra access token claims given at login; refreshed access tokens carry them too
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS claims JSONB;
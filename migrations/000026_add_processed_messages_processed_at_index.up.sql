@@ -0,0 +1,2 @@
+-- processed_messages is purged by age; see inbox.Purger
+CREATE INDEX IF NOT EXISTS idx_processed_messages_processed_at ON processed_messages (processed_at);
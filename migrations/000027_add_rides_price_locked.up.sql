@@ -0,0 +1,2 @@
+-- A ride priced from a valid quote keeps its estimated_fare at completion
+ALTER TABLE rides ADD COLUMN IF NOT EXISTS price_locked BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,7 @@
+-- Smoothed surge multiplier of each geohash cell, shared by every ride-service
+-- instance so the price does not depend on which one serves the request
+CREATE TABLE IF NOT EXISTS surge_cells (
+  cell TEXT PRIMARY KEY,
+  multiplier DOUBLE PRECISION NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL
+);